@@ -0,0 +1,60 @@
+import { deleteJson, getJson, postJson, putJson } from './http';
+
+export interface Category {
+  id?: string;
+  parentId?: string;
+  name: string;
+  slug?: string;
+  sortOrder?: number;
+  path?: string;
+  depth?: number;
+  productCount?: number;
+  createdAt?: string;
+  updatedAt?: string;
+}
+
+export interface CategoryRollup {
+  categoryId: string;
+  parentId: string;
+  name: string;
+  path: string;
+  depth: number;
+  skuCount: number;
+  stockUnits: number;
+  stockCost: number;
+  stockRetail: number;
+  unitsSold: number;
+  revenue: number;
+  profit: number;
+}
+
+// listCategories returns the tree flattened in display order; indent by depth.
+export async function listCategories(): Promise<Category[]> {
+  const result = await getJson<Category[] | null>('/categories');
+  return result ?? [];
+}
+
+export async function saveCategory(category: Category): Promise<Category> {
+  const payload = { id: category.id, parentId: category.parentId ?? '', name: category.name, slug: category.slug ?? '', sortOrder: category.sortOrder ?? 0 };
+  if (!category.id) {
+    return postJson<Category>('/categories', payload);
+  }
+  return putJson<Category>(`/categories/${category.id}`, payload);
+}
+
+export async function deleteCategory(id: string): Promise<void> {
+  await deleteJson(`/categories/${id}`);
+}
+
+export async function fetchCategoryReport(start?: string, end?: string): Promise<CategoryRollup[]> {
+  const params = new URLSearchParams();
+  if (start) {
+    params.set('start', start);
+  }
+  if (end) {
+    params.set('end', end);
+  }
+  const query = params.toString();
+  const result = await getJson<CategoryRollup[] | null>(`/reports/categories${query ? `?${query}` : ''}`);
+  return result ?? [];
+}
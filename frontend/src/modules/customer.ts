@@ -110,3 +110,34 @@ export async function saveCustomer(customer: Customer): Promise<Customer> {
 export async function deleteCustomer(customerID: string): Promise<void> {
   await deleteJson(`/customers/${customerID}`);
 }
+
+export type DuplicateReason = 'phone' | 'email' | 'name_address';
+
+export interface CustomerDuplicate extends Customer {
+  orders: number;
+}
+
+export interface CustomerDuplicateGroup {
+  survivorId: string;
+  reasons: DuplicateReason[];
+  customers: CustomerDuplicate[];
+}
+
+export interface MergeCustomersResult {
+  customer: Customer;
+  mergedIds: string[];
+  ordersMoved: number;
+}
+
+export async function listCustomerDuplicates(): Promise<CustomerDuplicateGroup[]> {
+  const groups = await getJson<CustomerDuplicateGroup[]>('/customers/duplicates');
+  return groups.map((group) => ({
+    ...group,
+    customers: group.customers.map((customer) => ({ ...adaptCustomer(customer), orders: customer.orders ?? 0 }))
+  }));
+}
+
+export async function mergeCustomers(survivorId: string, duplicateIds: string[]): Promise<MergeCustomersResult> {
+  const result = await postJson<MergeCustomersResult>('/customers/merge', { survivorId, duplicateIds });
+  return { ...result, customer: adaptCustomer(result.customer) };
+}
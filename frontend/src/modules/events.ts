@@ -0,0 +1,83 @@
+import { API_BASE } from './http';
+
+export type DomainEventType =
+  | 'order.created'
+  | 'order.deleted'
+  | 'stock.adjusted'
+  | 'stock.low'
+  | 'opname.performed'
+  | 'stock.drift'
+  | 'product.created'
+  | 'product.updated'
+  | 'product.archived'
+  | 'product.unarchived'
+  | 'product.deleted'
+  | 'settings.updated';
+
+export interface DomainEvent<T = unknown> {
+  id: string;
+  type: DomainEventType;
+  occurredAt: string;
+  data: T;
+}
+
+export interface StockLowData {
+  productId: string;
+  name: string;
+  sku: string;
+  stock: number;
+  lowStockThreshold: number;
+}
+
+const eventTypes: DomainEventType[] = [
+  'order.created',
+  'order.deleted',
+  'stock.adjusted',
+  'stock.low',
+  'opname.performed',
+  'stock.drift',
+  'product.created',
+  'product.updated',
+  'product.archived',
+  'product.unarchived',
+  'product.deleted',
+  'settings.updated'
+];
+
+// subscribeEvents listens to /api/events and calls handler for every change another
+// tab or device makes. types narrows the stream ("stock." matches every stock event).
+// The browser reconnects on its own and the server replays what was missed; call the
+// returned function to stop listening.
+export function subscribeEvents(handler: (event: DomainEvent) => void, types: string[] = []): () => void {
+  if (typeof EventSource === 'undefined') {
+    return () => undefined;
+  }
+  const query = types.length ? `?types=${encodeURIComponent(types.join(','))}` : '';
+  const source = new EventSource(`${API_BASE}/events${query}`);
+  const listener = (message: MessageEvent<string>) => {
+    try {
+      handler(JSON.parse(message.data) as DomainEvent);
+    } catch (error) {
+      console.error(error);
+    }
+  };
+  for (const type of eventTypes) {
+    source.addEventListener(type, listener as EventListener);
+  }
+  return () => source.close();
+}
+
+// notifyLowStock raises a browser notification for a stock.low event, asking for
+// permission the first time.
+export async function notifyLowStock(data: StockLowData): Promise<void> {
+  if (typeof Notification === 'undefined' || Notification.permission === 'denied') {
+    return;
+  }
+  if (Notification.permission === 'default' && (await Notification.requestPermission()) !== 'granted') {
+    return;
+  }
+  new Notification('Stok menipis', {
+    body: `${data.name}${data.sku ? ` (${data.sku})` : ''} tersisa ${data.stock} (batas ${data.lowStockThreshold})`,
+    tag: `stock-low-${data.productId}`
+  });
+}
@@ -4,10 +4,21 @@ interface RequestOptions extends RequestInit {
   json?: unknown;
 }
 
+let currentActor = '';
+
+// setActor names the operator sent with every request so the server can attribute
+// price and stock changes to them.
+export function setActor(name: string): void {
+  currentActor = name.trim();
+}
+
 async function request<T>(path: string, options: RequestOptions = {}): Promise<T> {
   const url = `${API_BASE}${path}`;
   const headers = new Headers(options.headers ?? {});
   headers.set('Accept', 'application/json');
+  if (currentActor) {
+    headers.set('X-SmartSeller-User', currentActor);
+  }
 
   let body: BodyInit | undefined = options.body ?? null;
   if (options.json !== undefined) {
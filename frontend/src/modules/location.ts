@@ -0,0 +1,66 @@
+import { deleteJson, getJson, postJson, putJson } from './http';
+
+export type LocationKind = 'home' | 'warehouse' | 'consignment';
+
+export interface Location {
+  id?: string;
+  code: string;
+  name: string;
+  kind: LocationKind;
+  isDefault?: boolean;
+  notes?: string;
+  stockUnits?: number;
+  createdAt?: string;
+  updatedAt?: string;
+}
+
+export interface StockTransferItem {
+  productId: string;
+  productName?: string;
+  sku?: string;
+  quantity: number;
+}
+
+export interface StockTransfer {
+  id: string;
+  code: string;
+  fromLocationId: string;
+  toLocationId: string;
+  note: string;
+  actor: string;
+  items: StockTransferItem[];
+  createdAt: string;
+}
+
+export interface TransferStockPayload {
+  // location ids or codes; the default location when omitted
+  fromLocationId?: string;
+  toLocationId?: string;
+  note?: string;
+  items: { productId: string; quantity: number }[];
+}
+
+export async function listLocations(): Promise<Location[]> {
+  const result = await getJson<Location[] | null>('/locations');
+  return result ?? [];
+}
+
+export async function saveLocation(location: Location): Promise<Location> {
+  if (!location.id) {
+    return postJson<Location>('/locations', location);
+  }
+  return putJson<Location>(`/locations/${location.id}`, location);
+}
+
+export async function deleteLocation(id: string): Promise<void> {
+  await deleteJson(`/locations/${id}`);
+}
+
+export async function listStockTransfers(limit = 50): Promise<StockTransfer[]> {
+  const result = await getJson<StockTransfer[] | null>(`/stock-transfers?limit=${limit}`);
+  return result ?? [];
+}
+
+export async function transferStock(payload: TransferStockPayload): Promise<StockTransfer> {
+  return postJson<StockTransfer>('/stock-transfers', payload);
+}
@@ -10,6 +10,8 @@ export interface OrderItemInput {
 }
 
 export interface CreateOrderPayload {
+  // location id or code to pick from; the default location when omitted
+  locationId?: string;
   buyerId: string;
   recipientId: string;
   items: OrderItemInput[];
@@ -22,6 +24,29 @@ export interface CreateOrderPayload {
   isBuyerPayingShipping: boolean;
 }
 
+export type ShipmentStatus = 'pending' | 'picked_up' | 'in_transit' | 'delivered' | 'returned';
+
+export interface ShipmentEvent {
+  id: string;
+  orderId: string;
+  status: ShipmentStatus;
+  description: string;
+  location: string;
+  occurredAt: string;
+  createdAt: string;
+}
+
+export interface ShipmentTracking {
+  orderId: string;
+  orderCode: string;
+  courier: string;
+  trackingCode: string;
+  status: ShipmentStatus;
+  statusUpdatedAt?: string | null;
+  checkedAt?: string | null;
+  events: ShipmentEvent[];
+}
+
 export interface OrderItem {
   id: string;
   orderId: string;
@@ -32,11 +57,29 @@ export interface OrderItem {
   discountItem: number;
   costPrice: number;
   profit: number;
+  components?: OrderItemComponent[];
+  lots?: OrderItemLot[];
+}
+
+export interface OrderItemLot {
+  productId: string;
+  batchId: string;
+  lotNumber: string;
+  expiresAt: string | null;
+  quantity: number;
+}
+
+export interface OrderItemComponent {
+  productId: string;
+  sku: string;
+  quantity: number;
+  costPrice: number;
 }
 
 export interface Order {
   id: string;
   code: string;
+  locationId?: string;
   buyerId: string;
   recipientId: string;
   shipment: {
@@ -45,6 +88,8 @@ export interface Order {
     trackingCode: string;
     shippingCost: number;
     shippingByBuyer: boolean;
+    status: ShipmentStatus;
+    statusUpdatedAt?: string | null;
   };
   items: OrderItem[];
   discountOrder: number;
@@ -60,6 +105,7 @@ type ApiOrderItem = OrderItem;
 type ApiOrder = {
   id: string;
   code: string;
+  locationId?: string;
   buyerId: string;
   recipientId: string;
   shipment: {
@@ -68,6 +114,8 @@ type ApiOrder = {
     trackingCode: string;
     shippingCost: number;
     shippingByBuyer: boolean;
+    status: ShipmentStatus;
+    statusUpdatedAt?: string | null;
   };
   items: ApiOrderItem[];
   discountOrder: number;
@@ -96,6 +144,7 @@ function adaptOrder(order: ApiOrder): Order {
   return {
     id: order.id,
     code: order.code,
+    locationId: order.locationId || undefined,
     buyerId: order.buyerId,
     recipientId: order.recipientId,
     shipment: {
@@ -103,7 +152,9 @@ function adaptOrder(order: ApiOrder): Order {
       serviceLevel: order.shipment.serviceLevel,
       trackingCode: order.shipment.trackingCode,
       shippingCost: order.shipment.shippingCost,
-      shippingByBuyer: order.shipment.shippingByBuyer
+      shippingByBuyer: order.shipment.shippingByBuyer,
+      status: order.shipment.status ?? 'pending',
+      statusUpdatedAt: order.shipment.statusUpdatedAt ?? null
     },
     items: order.items.map(adaptOrderItem),
     discountOrder: order.discountOrder,
@@ -189,6 +240,11 @@ export async function listOrders(params?: OrderListParams): Promise<OrderListRes
   };
 }
 
+export async function getOrder(orderId: string): Promise<Order> {
+  const order = await getJson<ApiOrder>(`/orders/${orderId}`);
+  return adaptOrder(order);
+}
+
 export async function createOrder(payload: CreateOrderPayload): Promise<Order> {
   const order = await postJson<ApiOrder>('/orders', payload);
   return adaptOrder(order);
@@ -203,6 +259,14 @@ export async function generateLabel(orderId: string): Promise<string> {
   return response.base64;
 }
 
+export async function getOrderTracking(orderId: string): Promise<ShipmentTracking> {
+  return getJson<ShipmentTracking>(`/orders/${orderId}/tracking`);
+}
+
+export async function refreshOrderTracking(orderId: string): Promise<ShipmentTracking> {
+  return postJson<ShipmentTracking>(`/orders/${orderId}/tracking/refresh`);
+}
+
 function pdfBlobFromBase64(base64: string): Blob {
   const payload = (base64 || '').replace(/\s+/g, '');
   if (!payload) {
@@ -1,13 +1,15 @@
-import { deleteJson, getJson, postJson, putJson } from './http';
+import { API_BASE, deleteJson, getJson, postJson, putJson } from './http';
 
 export interface Product {
   id?: string;
   name: string;
   sku: string;
+  barcode?: string;
   costPrice: number;
   salePrice: number;
   stock: number;
   category?: string;
+  categoryId?: string;
   lowStockThreshold?: number;
   description?: string;
   imagePath?: string;
@@ -22,11 +24,60 @@ export interface Product {
   thumbHeight?: number;
   thumbSizeBytes?: number;
   imageData?: string;
+  isBundle?: boolean;
+  components?: BundleComponent[];
+  parentId?: string;
+  optionAxes?: string[];
+  options?: VariantOption[];
+  variants?: Product[];
+  locations?: LocationStock[];
+  images?: ProductImage[];
   createdAt?: string;
   updatedAt?: string;
   deletedAt?: string | null;
 }
 
+export interface VariantOption {
+  axis: string;
+  value: string;
+}
+
+export interface ProductImage {
+  id: string;
+  productId: string;
+  path: string;
+  thumbPath: string;
+  url?: string;
+  thumbUrl?: string;
+  hash?: string;
+  width: number;
+  height: number;
+  sizeBytes: number;
+  thumbWidth: number;
+  thumbHeight: number;
+  thumbSizeBytes: number;
+  altText: string;
+  sortOrder: number;
+  isPrimary: boolean;
+  createdAt?: string;
+}
+
+export interface LocationStock {
+  locationId: string;
+  locationCode: string;
+  locationName: string;
+  quantity: number;
+}
+
+export interface BundleComponent {
+  productId: string;
+  name?: string;
+  sku?: string;
+  quantity: number;
+  stock?: number;
+  costPrice?: number;
+}
+
 type ApiProduct = Product & {
   deletedAt?: string | null;
   createdAt?: string;
@@ -38,10 +89,12 @@ function adaptProduct(product: ApiProduct): Product {
     id: product.id,
     name: product.name,
     sku: product.sku,
+    barcode: product.barcode ?? '',
     costPrice: product.costPrice,
     salePrice: product.salePrice,
     stock: product.stock,
     category: product.category,
+    categoryId: product.categoryId || undefined,
     lowStockThreshold: product.lowStockThreshold,
     description: product.description,
     imagePath: product.imagePath,
@@ -56,6 +109,14 @@ function adaptProduct(product: ApiProduct): Product {
     thumbHeight: product.thumbHeight,
     thumbSizeBytes: product.thumbSizeBytes,
     imageData: product.imageData,
+    isBundle: product.isBundle ?? false,
+    components: product.components ?? [],
+    parentId: product.parentId || undefined,
+    optionAxes: product.optionAxes ?? [],
+    options: product.options ?? [],
+    variants: (product.variants ?? []).map(adaptProduct),
+    locations: product.locations ?? [],
+    images: product.images ?? [],
     deletedAt: product.deletedAt ?? null,
     createdAt: product.createdAt,
     updatedAt: product.updatedAt
@@ -66,8 +127,15 @@ export interface ProductListParams {
   page?: number;
   pageSize?: number;
   query?: string;
+  // category id or slug (subcategories included), or 'none' for uncategorised products
+  category?: string;
+  archived?: ArchiveFilter;
 }
 
+// exclude (default) lists active products, include adds archived ones, only lists
+// what can be restored.
+export type ArchiveFilter = 'exclude' | 'include' | 'only';
+
 export interface ProductListResponse {
   items: Product[];
   total: number;
@@ -76,6 +144,21 @@ export interface ProductListResponse {
   outOfStockCount: number;
   warningStockCount: number;
   lowStockHighlights: Product[];
+  expiringLotCount: number;
+  expiryWarnings: ExpiringLot[];
+}
+
+export interface ExpiringLot {
+  batchId: string;
+  productId: string;
+  name: string;
+  sku: string;
+  lotNumber: string;
+  expiresAt: string;
+  remaining: number;
+  value: number;
+  daysLeft: number;
+  expired: boolean;
 }
 
 type ApiProductListResponse = {
@@ -86,6 +169,8 @@ type ApiProductListResponse = {
   outOfStockCount: number;
   warningStockCount: number;
   lowStockHighlights: ApiProduct[];
+  expiringLotCount?: number;
+  expiryWarnings?: ExpiringLot[];
 };
 
 function buildQuery(params?: ProductListParams): string {
@@ -99,6 +184,12 @@ function buildQuery(params?: ProductListParams): string {
   if (params?.query && params.query.trim().length > 0) {
     searchParams.set('q', params.query.trim());
   }
+  if (params?.category) {
+    searchParams.set('category', params.category);
+  }
+  if (params?.archived && params.archived !== 'exclude') {
+    searchParams.set('archived', params.archived);
+  }
   const query = searchParams.toString();
   return query ? `?${query}` : '';
 }
@@ -112,7 +203,9 @@ export async function listProducts(params?: ProductListParams): Promise<ProductL
     pageSize: response.pageSize,
     outOfStockCount: response.outOfStockCount,
     warningStockCount: response.warningStockCount,
-    lowStockHighlights: response.lowStockHighlights.map(adaptProduct)
+    lowStockHighlights: response.lowStockHighlights.map(adaptProduct),
+    expiringLotCount: response.expiringLotCount ?? 0,
+    expiryWarnings: response.expiryWarnings ?? []
   };
 }
 
@@ -121,6 +214,7 @@ export async function saveProduct(product: Product): Promise<Product> {
   delete payload.imageUrl;
   delete payload.thumbUrl;
   delete payload.imageMime;
+  delete payload.images;
   if (!payload.id) {
     delete payload.id;
     const created = await postJson<ApiProduct>('/products', payload);
@@ -131,14 +225,202 @@ export async function saveProduct(product: Product): Promise<Product> {
   return adaptProduct(updated);
 }
 
-export async function adjustStock(productID: string, delta: number, reason: string): Promise<void> {
-  await postJson(`/products/${productID}/adjust-stock`, { delta, reason });
+export async function listProductImages(productId: string): Promise<ProductImage[]> {
+  return getJson<ProductImage[]>(`/products/${productId}/images`);
+}
+
+export async function addProductImage(productId: string, imageData: string, altText = '', primary = false): Promise<ProductImage[]> {
+  return postJson<ProductImage[]>(`/products/${productId}/images`, { imageData, altText, primary });
+}
+
+export async function updateProductImage(productId: string, imageId: string, altText: string, primary = false): Promise<ProductImage[]> {
+  return putJson<ProductImage[]>(`/products/${productId}/images/${imageId}`, { altText, primary });
+}
+
+export async function reorderProductImages(productId: string, imageIds: string[]): Promise<ProductImage[]> {
+  return putJson<ProductImage[]>(`/products/${productId}/images/order`, { imageIds });
+}
+
+export async function deleteProductImage(productId: string, imageId: string): Promise<void> {
+  await deleteJson(`/products/${productId}/images/${imageId}`);
+}
+
+export type AdjustmentDirection = 'in' | 'out' | 'both';
+
+export interface AdjustmentReason {
+  code: string;
+  label: string;
+  direction: AdjustmentDirection;
+  requiresNote: boolean;
+  active: boolean;
+  isSystem?: boolean;
+  sortOrder: number;
+}
+
+export interface AdjustStockInput {
+  delta: number;
+  // defaults to the 'other' reason when left out
+  reason?: string;
+  note?: string;
+  locationId?: string;
+  user?: string;
+}
+
+// reason is an adjustment reason code; locationId takes a location id or code, omit it
+// to adjust the default location.
+export async function adjustStock(productID: string, input: AdjustStockInput): Promise<void> {
+  await postJson(`/products/${productID}/adjust-stock`, input);
+}
+
+export async function listAdjustmentReasons(activeOnly = false): Promise<AdjustmentReason[]> {
+  return await getJson<AdjustmentReason[]>(`/adjustment-reasons${activeOnly ? '?active=true' : ''}`);
+}
+
+export async function createAdjustmentReason(reason: AdjustmentReason): Promise<AdjustmentReason> {
+  return await postJson<AdjustmentReason>('/adjustment-reasons', reason);
+}
+
+// Fields left out of an update keep their stored value.
+export async function updateAdjustmentReason(
+  reason: Partial<AdjustmentReason> & { code: string }
+): Promise<AdjustmentReason> {
+  return await putJson<AdjustmentReason>(`/adjustment-reasons/${encodeURIComponent(reason.code)}`, reason);
+}
+
+export async function deleteAdjustmentReason(code: string): Promise<void> {
+  await deleteJson(`/adjustment-reasons/${encodeURIComponent(code)}`);
+}
+
+export async function lookupProduct(code: string): Promise<Product> {
+  const product = await getJson<ApiProduct>(`/products/lookup?code=${encodeURIComponent(code)}`);
+  return adaptProduct(product);
 }
 
 export async function archiveProduct(productID: string): Promise<void> {
   await postJson(`/products/${productID}/archive`);
 }
 
+export async function unarchiveProduct(productID: string): Promise<Product> {
+  const restored = await postJson<ApiProduct>(`/products/${productID}/unarchive`);
+  return adaptProduct(restored);
+}
+
 export async function deleteProduct(productID: string): Promise<void> {
   await deleteJson(`/products/${productID}`);
 }
+
+export type BarcodeKind = 'code128' | 'ean13';
+
+export function productBarcodeUrl(productID: string, kind: BarcodeKind = 'code128'): string {
+  return `${API_BASE}/products/${productID}/barcode.png?type=${kind}`;
+}
+
+export interface BarcodeSheetItem {
+  productId: string;
+  copies: number;
+}
+
+export async function generateBarcodeSheet(items: BarcodeSheetItem[], kind: BarcodeKind = 'code128', showPrice = false): Promise<string> {
+  const response = await postJson<{ base64: string }>('/products/barcode-sheet', { kind, showPrice, items });
+  return response.base64;
+}
+
+export type ProductSheetFormat = 'csv' | 'xlsx';
+
+export async function fetchProductsExport(format: ProductSheetFormat = 'xlsx', images = false, archived: ArchiveFilter = 'include'): Promise<Blob> {
+  const params = new URLSearchParams({ format, archived });
+  if (images) {
+    params.set('images', '1');
+  }
+  const response = await fetch(`${API_BASE}/products/export?${params.toString()}`);
+  if (!response.ok) {
+    const text = await response.text();
+    throw new Error(text || 'Gagal mengekspor produk.');
+  }
+  return await response.blob();
+}
+
+export interface ProductImportRow {
+  row: number;
+  sku: string;
+  name: string;
+  action: 'create' | 'update' | 'skip';
+  image: boolean;
+  errors?: string[];
+  warnings?: string[];
+}
+
+export interface ProductImportResult {
+  dryRun: boolean;
+  applied: boolean;
+  created: number;
+  updated: number;
+  skipped: number;
+  failed: number;
+  images: number;
+  rows: ProductImportRow[];
+}
+
+// importProducts uploads a CSV, XLSX or ZIP (sheet + images named by SKU) encoded as base64.
+// Run with dryRun first to show the validation preview.
+export async function importProducts(fileName: string, data: string, dryRun: boolean): Promise<ProductImportResult> {
+  return postJson<ProductImportResult>('/products/import', { fileName, data, dryRun });
+}
+
+export interface PriceChange {
+  id: string;
+  productId: string;
+  costPrice: number;
+  salePrice: number;
+  previousCostPrice: number;
+  previousSalePrice: number;
+  margin: number;
+  marginPercent: number;
+  actor: string;
+  source: string;
+  changedAt: string;
+}
+
+export async function fetchPriceHistory(productId: string, limit?: number): Promise<PriceChange[]> {
+  const query = limit ? `?limit=${limit}` : '';
+  return await getJson<PriceChange[]>(`/products/${encodeURIComponent(productId)}/price-history${query}`);
+}
+
+export interface StockBatch {
+  id: string;
+  productId: string;
+  reference: string;
+  note: string;
+  unitCost: number;
+  quantity: number;
+  remaining: number;
+  lotNumber: string;
+  expiresAt: string | null;
+  receivedAt: string;
+  createdAt: string;
+}
+
+export interface ReceiveStockPayload {
+  quantity: number;
+  unitCost: number;
+  reference?: string;
+  note?: string;
+  receivedAt?: string;
+  lotNumber?: string;
+  expiresAt?: string;
+}
+
+export async function fetchStockBatches(productId: string, includeDepleted = false): Promise<StockBatch[]> {
+  const query = includeDepleted ? '?all=1' : '';
+  return await getJson<StockBatch[]>(`/products/${encodeURIComponent(productId)}/batches${query}`);
+}
+
+export async function receiveStock(productId: string, payload: ReceiveStockPayload): Promise<Product> {
+  const product = await postJson<ApiProduct>(`/products/${encodeURIComponent(productId)}/batches`, payload);
+  return adaptProduct(product);
+}
+
+export async function fetchExpiringLots(days?: number): Promise<ExpiringLot[]> {
+  const query = days ? `?days=${days}` : '';
+  return await getJson<ExpiringLot[]>(`/reports/expiry${query}`);
+}
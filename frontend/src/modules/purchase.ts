@@ -0,0 +1,142 @@
+import { deleteJson, getJson, postJson, putJson } from './http';
+
+export interface Supplier {
+  id?: string;
+  name: string;
+  contact?: string;
+  phone?: string;
+  email?: string;
+  address?: string;
+  paymentTermDays?: number;
+  notes?: string;
+  createdAt?: string;
+  updatedAt?: string;
+}
+
+export type PurchaseOrderStatus = 'draft' | 'ordered' | 'partially_received' | 'received';
+
+export interface PurchaseOrderLine {
+  id: string;
+  purchaseOrderId: string;
+  productId: string;
+  productName: string;
+  sku: string;
+  quantity: number;
+  unitCost: number;
+  receivedQuantity: number;
+  receivedValue: number;
+}
+
+export interface PurchasePayment {
+  id: string;
+  purchaseOrderId: string;
+  amount: number;
+  note: string;
+  paidAt: string;
+}
+
+export interface PurchaseOrder {
+  id: string;
+  code: string;
+  supplierId: string;
+  supplierName: string;
+  status: PurchaseOrderStatus;
+  notes: string;
+  expectedAt?: string | null;
+  orderedAt?: string | null;
+  lastReceivedAt?: string | null;
+  total: number;
+  receivedValue: number;
+  paidAmount: number;
+  outstanding: number;
+  lines: PurchaseOrderLine[];
+  payments?: PurchasePayment[];
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface PurchaseOrderPayload {
+  supplierId: string;
+  notes?: string;
+  expectedAt?: string;
+  lines: { productId: string; quantity: number; unitCost: number }[];
+}
+
+export interface ReceivePurchasePayload {
+  receivedAt?: string;
+  note?: string;
+  lines: { lineId: string; quantity: number; unitCost?: number; lotNumber?: string; expiresAt?: string }[];
+}
+
+export interface SupplierPayable {
+  supplierId: string;
+  supplierName: string;
+  paymentTermDays: number;
+  openOrders: number;
+  receivedValue: number;
+  paidAmount: number;
+  outstanding: number;
+  overdue: number;
+  nextDueAt?: string | null;
+}
+
+export async function listSuppliers(): Promise<Supplier[]> {
+  const result = await getJson<Supplier[] | null>('/suppliers');
+  return result ?? [];
+}
+
+export async function saveSupplier(supplier: Supplier): Promise<Supplier> {
+  if (!supplier.id) {
+    return postJson<Supplier>('/suppliers', supplier);
+  }
+  return putJson<Supplier>(`/suppliers/${supplier.id}`, supplier);
+}
+
+export async function deleteSupplier(id: string): Promise<void> {
+  await deleteJson(`/suppliers/${id}`);
+}
+
+export async function listPurchaseOrders(filters: { status?: PurchaseOrderStatus; supplierId?: string } = {}): Promise<PurchaseOrder[]> {
+  const params = new URLSearchParams();
+  if (filters.status) {
+    params.set('status', filters.status);
+  }
+  if (filters.supplierId) {
+    params.set('supplierId', filters.supplierId);
+  }
+  const query = params.toString();
+  const result = await getJson<PurchaseOrder[] | null>(`/purchase-orders${query ? `?${query}` : ''}`);
+  return result ?? [];
+}
+
+export async function getPurchaseOrder(id: string): Promise<PurchaseOrder> {
+  return getJson<PurchaseOrder>(`/purchase-orders/${id}`);
+}
+
+export async function savePurchaseOrder(payload: PurchaseOrderPayload, id?: string): Promise<PurchaseOrder> {
+  if (!id) {
+    return postJson<PurchaseOrder>('/purchase-orders', payload);
+  }
+  return putJson<PurchaseOrder>(`/purchase-orders/${id}`, payload);
+}
+
+export async function deletePurchaseOrder(id: string): Promise<void> {
+  await deleteJson(`/purchase-orders/${id}`);
+}
+
+export async function placePurchaseOrder(id: string): Promise<PurchaseOrder> {
+  return postJson<PurchaseOrder>(`/purchase-orders/${id}/order`);
+}
+
+export async function receivePurchaseOrder(id: string, payload: ReceivePurchasePayload): Promise<PurchaseOrder> {
+  return postJson<PurchaseOrder>(`/purchase-orders/${id}/receive`, payload);
+}
+
+export async function addPurchasePayment(id: string, payload: { amount: number; note?: string; paidAt?: string }): Promise<PurchaseOrder> {
+  return postJson<PurchaseOrder>(`/purchase-orders/${id}/payments`, payload);
+}
+
+export async function fetchPayables(): Promise<SupplierPayable[]> {
+  const result = await getJson<SupplierPayable[] | null>('/payables');
+  return result ?? [];
+}
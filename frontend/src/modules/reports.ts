@@ -1,4 +1,4 @@
-import { API_BASE } from './http';
+import { API_BASE, getJson, postJson } from './http';
 
 export interface OrderExportFilters {
   search?: string;
@@ -35,3 +35,289 @@ export async function fetchOrdersCsv(filters: OrderExportFilters = {}): Promise<
   }
   return await response.blob();
 }
+
+export interface ProductMargin {
+  productId: string;
+  sku: string;
+  name: string;
+  startSalePrice: number;
+  startCostPrice: number;
+  startMarginPercent: number;
+  endSalePrice: number;
+  endCostPrice: number;
+  endMarginPercent: number;
+  priceChanges: number;
+  unitsSold: number;
+  revenue: number;
+  cost: number;
+  profit: number;
+  realisedMarginPercent: number;
+}
+
+export async function fetchMarginReport(filters: { start?: string; end?: string } = {}): Promise<ProductMargin[]> {
+  const params = new URLSearchParams();
+  if (filters.start) {
+    params.set('start', filters.start);
+  }
+  if (filters.end) {
+    params.set('end', filters.end);
+  }
+  const query = params.toString();
+  return await getJson<ProductMargin[]>(`/reports/margins${query ? `?${query}` : ''}`);
+}
+
+export interface ProductValuation {
+  productId: string;
+  sku: string;
+  name: string;
+  stock: number;
+  unitCost: number;
+  value: number;
+  listCostValue: number;
+}
+
+export interface InventoryValuation {
+  method: 'fifo' | 'average';
+  totalUnits: number;
+  totalValue: number;
+  listCostValue: number;
+  items: ProductValuation[];
+}
+
+export async function fetchInventoryValuation(): Promise<InventoryValuation> {
+  return await getJson<InventoryValuation>('/reports/inventory-valuation');
+}
+
+export interface SalesWindow {
+  window: number;
+  days: number;
+  unitsSold: number;
+  perDay: number;
+}
+
+export interface RestockSuggestion {
+  productId: string;
+  sku: string;
+  name: string;
+  category: string;
+  stock: number;
+  onOrder: number;
+  lowStockThreshold: number;
+  sales: SalesWindow[];
+  dailyVelocity: number;
+  daysOfCover: number | null;
+  safetyStock: number;
+  reorderPoint: number;
+  reorderQuantity: number;
+  needsReorder: boolean;
+}
+
+export interface RestockReport {
+  generatedAt: string;
+  windows: number[];
+  leadTimeDays: number;
+  safetyDays: number;
+  coverDays: number;
+  items: RestockSuggestion[];
+}
+
+export interface RestockReportFilters {
+  windows?: number[];
+  leadTimeDays?: number;
+  safetyDays?: number;
+  coverDays?: number;
+  reorderOnly?: boolean;
+}
+
+export interface ThresholdChange {
+  productId: string;
+  name: string;
+  previous: number;
+  threshold: number;
+}
+
+export async function fetchRestockReport(filters: RestockReportFilters = {}): Promise<RestockReport> {
+  const params = new URLSearchParams();
+  if (filters.windows?.length) {
+    params.set('windows', filters.windows.join(','));
+  }
+  if (filters.leadTimeDays) {
+    params.set('leadTimeDays', String(filters.leadTimeDays));
+  }
+  if (filters.safetyDays !== undefined && filters.safetyDays !== null) {
+    params.set('safetyDays', String(filters.safetyDays));
+  }
+  if (filters.coverDays) {
+    params.set('coverDays', String(filters.coverDays));
+  }
+  if (filters.reorderOnly) {
+    params.set('reorderOnly', 'true');
+  }
+  const query = params.toString();
+  const report = await getJson<RestockReport>(`/reports/restock${query ? `?${query}` : ''}`);
+  return { ...report, items: report.items ?? [] };
+}
+
+export async function applyRestockThresholds(productIds: string[] = []): Promise<ThresholdChange[]> {
+  return await postJson<ThresholdChange[]>('/reports/restock/thresholds', { productIds });
+}
+
+export interface OpnameVarianceLine {
+  productId: string;
+  sku: string;
+  name: string;
+  previousStock: number;
+  counted: number;
+  difference: number;
+  unitCost: number;
+  salePrice: number;
+  costValue: number;
+  saleValue: number;
+}
+
+export interface OpnameVarianceReport {
+  opnameId: string;
+  brand: string;
+  locationId: string;
+  locationName: string;
+  note: string;
+  performedBy: string;
+  performedAt: string;
+  sessionCode?: string;
+  counters: string[];
+  reviewedBy?: string;
+  approvedBy?: string;
+  lines: OpnameVarianceLine[];
+  lossUnits: number;
+  gainUnits: number;
+  shrinkageCost: number;
+  shrinkageSale: number;
+  netCost: number;
+  netSale: number;
+}
+
+export async function fetchOpnameVarianceReport(opnameId: string): Promise<OpnameVarianceReport> {
+  return await getJson<OpnameVarianceReport>(`/stock-opnames/${opnameId}/report?format=json`);
+}
+
+export async function downloadOpnameVarianceReport(opnameId: string, format: 'pdf' | 'csv' = 'pdf'): Promise<Blob> {
+  const response = await fetch(`${API_BASE}/stock-opnames/${opnameId}/report?format=${format}`);
+  if (!response.ok) {
+    const text = await response.text();
+    throw new Error(text || 'Gagal mengunduh laporan opname.');
+  }
+  return await response.blob();
+}
+
+export interface ShrinkagePoint {
+  opnameId: string;
+  locationId: string;
+  performedBy: string;
+  performedAt: string;
+  items: number;
+  lossUnits: number;
+  gainUnits: number;
+  shrinkageCost: number;
+  shrinkageSale: number;
+  netCost: number;
+  netSale: number;
+}
+
+export interface ShrinkageMonth {
+  month: string;
+  opnames: number;
+  lossUnits: number;
+  shrinkageCost: number;
+  shrinkageSale: number;
+  netCost: number;
+  netSale: number;
+}
+
+export interface ShrinkageTrend {
+  start?: string;
+  end?: string;
+  points: ShrinkagePoint[];
+  months: ShrinkageMonth[];
+  shrinkageCost: number;
+  shrinkageSale: number;
+  netCost: number;
+  netSale: number;
+}
+
+export async function fetchShrinkageTrend(filters: { start?: string; end?: string } = {}): Promise<ShrinkageTrend> {
+  const params = new URLSearchParams();
+  if (filters.start) {
+    params.set('start', filters.start);
+  }
+  if (filters.end) {
+    params.set('end', filters.end);
+  }
+  const query = params.toString();
+  return await getJson<ShrinkageTrend>(`/reports/shrinkage${query ? `?${query}` : ''}`);
+}
+
+export type AdjustmentPeriod = 'day' | 'week' | 'month';
+
+export interface AdjustmentReasonTotal {
+  code: string;
+  label: string;
+  direction: string;
+  count: number;
+  unitsIn: number;
+  unitsOut: number;
+  value: number;
+}
+
+export interface AdjustmentPeriodTotals {
+  period: string;
+  reasons: AdjustmentReasonTotal[];
+  lossValue: number;
+  netValue: number;
+}
+
+export interface AdjustmentReport {
+  start?: string;
+  end?: string;
+  period: AdjustmentPeriod;
+  periods: AdjustmentPeriodTotals[];
+  reasons: AdjustmentReasonTotal[];
+  lossValue: number;
+  netValue: number;
+}
+
+export interface AdjustmentReportFilters {
+  start?: string;
+  end?: string;
+  period?: AdjustmentPeriod;
+}
+
+function adjustmentReportQuery(filters: AdjustmentReportFilters, format?: 'csv'): string {
+  const params = new URLSearchParams();
+  if (filters.start) {
+    params.set('start', filters.start);
+  }
+  if (filters.end) {
+    params.set('end', filters.end);
+  }
+  if (filters.period) {
+    params.set('period', filters.period);
+  }
+  if (format) {
+    params.set('format', format);
+  }
+  const query = params.toString();
+  return `/reports/adjustments${query ? `?${query}` : ''}`;
+}
+
+export async function fetchAdjustmentReport(filters: AdjustmentReportFilters = {}): Promise<AdjustmentReport> {
+  return await getJson<AdjustmentReport>(adjustmentReportQuery(filters));
+}
+
+export async function downloadAdjustmentReport(filters: AdjustmentReportFilters = {}): Promise<Blob> {
+  const response = await fetch(`${API_BASE}${adjustmentReportQuery(filters, 'csv')}`);
+  if (!response.ok) {
+    const text = await response.text();
+    throw new Error(text || 'Gagal mengunduh laporan penyesuaian stok.');
+  }
+  return await response.blob();
+}
@@ -10,6 +10,11 @@ export interface AppSettings {
   logoSizeBytes?: number;
   logoMime?: string;
   logoData?: string;
+  costingMethod?: 'fifo' | 'average';
+  restockLeadTimeDays?: number;
+  restockSafetyDays?: number;
+  restockCoverDays?: number;
+  restockAutoThreshold?: boolean;
 }
 
 export interface BackupOptions {
@@ -93,7 +98,12 @@ function adaptSettings(settings: ApiSettings): AppSettings {
     logoHeight: settings.logoHeight,
     logoSizeBytes: settings.logoSizeBytes,
     logoMime: settings.logoMime,
-    logoData: settings.logoData
+    logoData: settings.logoData,
+    costingMethod: settings.costingMethod,
+    restockLeadTimeDays: settings.restockLeadTimeDays,
+    restockSafetyDays: settings.restockSafetyDays,
+    restockCoverDays: settings.restockCoverDays,
+    restockAutoThreshold: settings.restockAutoThreshold
   };
 }
 
@@ -214,3 +224,81 @@ export async function restoreBackup(payload: string, options: RestoreOptions): P
     executionDriver: response.executionDriver || 'driver'
   };
 }
+
+export interface WebhookSubscription {
+  id?: string;
+  name: string;
+  url: string;
+  // secret is only sent on save and returned once, when the webhook is created.
+  secret?: string;
+  secretSet?: boolean;
+  events: string[];
+  active: boolean;
+  createdAt?: string;
+  updatedAt?: string;
+}
+
+export type WebhookDeliveryStatus = 'pending' | 'delivered' | 'failed';
+
+export interface WebhookDelivery {
+  id: string;
+  subscriptionId: string;
+  eventId: string;
+  eventType: string;
+  payload: string;
+  status: WebhookDeliveryStatus;
+  attempts: number;
+  nextAttemptAt?: string;
+  lastStatusCode: number;
+  lastError?: string;
+  createdAt?: string;
+  deliveredAt?: string;
+}
+
+function adaptWebhook(webhook: WebhookSubscription): WebhookSubscription {
+  return {
+    ...webhook,
+    events: webhook.events ?? [],
+    createdAt: normaliseDate(webhook.createdAt),
+    updatedAt: normaliseDate(webhook.updatedAt)
+  };
+}
+
+export async function listWebhookEventTypes(): Promise<string[]> {
+  return getJson<string[]>('/webhooks/events');
+}
+
+export async function listWebhooks(): Promise<WebhookSubscription[]> {
+  const items = await getJson<WebhookSubscription[]>('/webhooks');
+  return items.map(adaptWebhook);
+}
+
+export async function saveWebhook(payload: WebhookSubscription): Promise<WebhookSubscription> {
+  const data: Record<string, unknown> = { ...payload };
+  delete data.createdAt;
+  delete data.updatedAt;
+  if (!data.id) {
+    delete data.id;
+    return adaptWebhook(await postJson<WebhookSubscription>('/webhooks', data));
+  }
+  const id = String(data.id);
+  return adaptWebhook(await putJson<WebhookSubscription>(`/webhooks/${id}`, data));
+}
+
+export async function deleteWebhook(id: string): Promise<void> {
+  await deleteJson(`/webhooks/${id}`);
+}
+
+export async function listWebhookDeliveries(id: string, limit = 50): Promise<WebhookDelivery[]> {
+  const items = await getJson<WebhookDelivery[]>(`/webhooks/${id}/deliveries?limit=${limit}`);
+  return items.map((item) => ({
+    ...item,
+    nextAttemptAt: normaliseDate(item.nextAttemptAt),
+    createdAt: normaliseDate(item.createdAt),
+    deliveredAt: normaliseDate(item.deliveredAt)
+  }));
+}
+
+export async function retryWebhookDelivery(deliveryId: string): Promise<void> {
+  await postJson<void>(`/webhooks/deliveries/${deliveryId}/retry`, {});
+}
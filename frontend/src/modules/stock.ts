@@ -1,4 +1,4 @@
-import { getJson, postJson } from './http';
+import { deleteJson, getJson, postJson, putJson } from './http';
 
 export interface StockOpnameItem {
   id: string;
@@ -9,10 +9,13 @@ export interface StockOpnameItem {
   counted: number;
   previousStock: number;
   difference: number;
+  unitCost: number;
+  salePrice: number;
 }
 
 export interface StockOpname {
   id: string;
+  locationId?: string;
   note: string;
   performedBy: string;
   performedAt: string;
@@ -26,6 +29,8 @@ export interface PerformStockOpnameItemInput {
 }
 
 export interface PerformStockOpnamePayload {
+  // location id or code counted; the default location when omitted
+  locationId?: string;
   note: string;
   user: string;
   items: PerformStockOpnameItemInput[];
@@ -35,6 +40,7 @@ type ApiStockOpnameItem = StockOpnameItem;
 
 type ApiStockOpname = {
   id: string;
+  locationId?: string;
   note: string;
   performedBy: string;
   performedAt: string;
@@ -51,7 +57,9 @@ function adaptItem(item: ApiStockOpnameItem): StockOpnameItem {
     productSku: item.productSku,
     counted: item.counted,
     previousStock: item.previousStock,
-    difference: item.difference
+    difference: item.difference,
+    unitCost: item.unitCost ?? 0,
+    salePrice: item.salePrice ?? 0
   };
 }
 
@@ -60,6 +68,7 @@ function adaptOpname(opname: ApiStockOpname): StockOpname {
   const created = new Date(opname.createdAt as unknown as string);
   return {
     id: opname.id,
+    locationId: opname.locationId || undefined,
     note: opname.note,
     performedBy: opname.performedBy,
     performedAt: Number.isNaN(performed.getTime()) ? (opname.performedAt as unknown as string) : performed.toISOString(),
@@ -68,21 +77,348 @@ function adaptOpname(opname: ApiStockOpname): StockOpname {
   };
 }
 
-export async function listStockOpnames(limit = 10): Promise<StockOpname[]> {
+export interface StockOpnameSummary {
+  id: string;
+  locationId: string;
+  locationName: string;
+  note: string;
+  performedBy: string;
+  performedAt: string;
+  createdAt: string;
+  itemCount: number;
+  increaseUnits: number;
+  decreaseUnits: number;
+}
+
+export interface StockOpnameListParams {
+  dateStart?: string;
+  dateEnd?: string;
+  performedBy?: string;
+  productId?: string;
+  locationId?: string;
+  page?: number;
+  pageSize?: number;
+}
+
+export interface StockOpnameListResponse {
+  items: StockOpnameSummary[];
+  total: number;
+  page: number;
+  pageSize: number;
+}
+
+export async function listStockOpnames(params: StockOpnameListParams = {}): Promise<StockOpnameListResponse> {
   const searchParams = new URLSearchParams();
-  if (limit) {
-    searchParams.set('limit', String(limit));
+  for (const key of ['dateStart', 'dateEnd', 'performedBy', 'productId', 'locationId'] as const) {
+    const value = params[key];
+    if (value) {
+      searchParams.set(key, value);
+    }
   }
-  const query = searchParams.toString();
-  const path = query ? `/stock-opnames?${query}` : '/stock-opnames';
-  const results = await getJson<ApiStockOpname[] | null | undefined>(path);
-  if (!results) {
-    return [];
+  if (params.page) {
+    searchParams.set('page', String(params.page));
+  }
+  if (params.pageSize) {
+    searchParams.set('pageSize', String(params.pageSize));
   }
-  return results.map(adaptOpname);
+  const query = searchParams.toString();
+  const response = await getJson<StockOpnameListResponse>(query ? `/stock-opnames?${query}` : '/stock-opnames');
+  return { ...response, items: response.items ?? [] };
+}
+
+export interface ProductOpnameEntry {
+  stockOpnameId: string;
+  locationId: string;
+  locationName: string;
+  note: string;
+  performedBy: string;
+  performedAt: string;
+  counted: number;
+  previousStock: number;
+  difference: number;
+  unitCost: number;
+  salePrice: number;
+}
+
+export interface ProductOpnameListResponse {
+  items: ProductOpnameEntry[];
+  total: number;
+  page: number;
+  pageSize: number;
+}
+
+export async function listProductOpnames(productId: string, page = 1, pageSize = 20): Promise<ProductOpnameListResponse> {
+  const searchParams = new URLSearchParams({ page: String(page), pageSize: String(pageSize) });
+  const response = await getJson<ProductOpnameListResponse>(`/products/${encodeURIComponent(productId)}/opnames?${searchParams.toString()}`);
+  return { ...response, items: response.items ?? [] };
+}
+
+export async function getStockOpname(id: string): Promise<StockOpname> {
+  const response = await getJson<ApiStockOpname>(`/stock-opnames/${id}`);
+  return adaptOpname(response);
 }
 
 export async function performStockOpname(payload: PerformStockOpnamePayload): Promise<StockOpname> {
   const response = await postJson<ApiStockOpname>('/stock-opnames', payload);
   return adaptOpname(response);
 }
+
+export interface StockMutationSource {
+  type: 'order' | 'stock_opname' | 'purchase_order' | 'transfer';
+  id?: string;
+  code?: string;
+  // API path of the originating document, absent when it no longer exists
+  link?: string;
+}
+
+export interface StockMutation {
+  id: string;
+  productId: string;
+  productName: string;
+  sku: string;
+  locationId: string;
+  locationName: string;
+  delta: number;
+  reason: string;
+  // product stock right after this mutation
+  balance: number;
+  source?: StockMutationSource;
+  createdAt: string;
+}
+
+export interface StockMutationListParams {
+  productId?: string;
+  // reason prefixes such as 'order:', 'stock_opname:' or 'manual'; 'manual' also
+  // lists adjustments booked under a reason code
+  reasons?: string[];
+  dateStart?: string;
+  dateEnd?: string;
+  page?: number;
+  pageSize?: number;
+}
+
+export interface StockMutationListResponse {
+  items: StockMutation[];
+  total: number;
+  page: number;
+  pageSize: number;
+}
+
+export async function listStockMutations(params: StockMutationListParams = {}): Promise<StockMutationListResponse> {
+  const searchParams = new URLSearchParams();
+  if (params.reasons?.length) {
+    searchParams.set('reason', params.reasons.join(','));
+  }
+  if (params.dateStart) {
+    searchParams.set('dateStart', params.dateStart);
+  }
+  if (params.dateEnd) {
+    searchParams.set('dateEnd', params.dateEnd);
+  }
+  if (params.page) {
+    searchParams.set('page', String(params.page));
+  }
+  if (params.pageSize) {
+    searchParams.set('pageSize', String(params.pageSize));
+  }
+  const query = searchParams.toString();
+  const base = params.productId ? `/products/${encodeURIComponent(params.productId)}/mutations` : '/stock-mutations';
+  const response = await getJson<StockMutationListResponse>(query ? `${base}?${query}` : base);
+  return { ...response, items: response.items ?? [] };
+}
+
+export interface OrderStockDrift {
+  orderId?: string;
+  orderCode: string;
+  locationId?: string;
+  deleted: boolean;
+  missing: number;
+}
+
+export interface StockDiscrepancy {
+  productId: string;
+  name: string;
+  sku: string;
+  stock: number;
+  ledgerBalance: number;
+  ledgerDrift: number;
+  orderedUnits: number;
+  ledgerUnits: number;
+  orderDrift: number;
+  orders: OrderStockDrift[];
+}
+
+export interface StockReconciliation {
+  checkedAt: string;
+  productsChecked: number;
+  discrepancies: StockDiscrepancy[];
+}
+
+export interface ReconcileConfirmation {
+  productId: string;
+  ledgerDrift: number;
+  orderDrift: number;
+}
+
+export interface ReconciliationResult {
+  corrected: string[];
+  skipped: { productId: string; reason: string }[];
+  report: StockReconciliation;
+}
+
+export async function checkStockReconciliation(): Promise<StockReconciliation> {
+  const report = await getJson<StockReconciliation>('/stock-reconciliation');
+  return { ...report, discrepancies: report.discrepancies ?? [] };
+}
+
+export async function latestStockReconciliation(): Promise<StockReconciliation | null> {
+  return getJson<StockReconciliation | null>('/stock-reconciliation/latest');
+}
+
+export async function applyStockReconciliation(items: ReconcileConfirmation[]): Promise<ReconciliationResult> {
+  return postJson<ReconciliationResult>('/stock-reconciliation/apply', { items });
+}
+
+export interface OpnameScanItem {
+  productId: string;
+  productName: string;
+  productSku: string;
+  barcode: string;
+  counted: number;
+  lastScannedAt: string;
+}
+
+export interface OpnameScanSession {
+  id: string;
+  locationId: string;
+  user: string;
+  note: string;
+  items: OpnameScanItem[];
+  lastScan?: OpnameScanItem;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export async function startScanSession(user: string, note = '', locationId?: string): Promise<OpnameScanSession> {
+  return postJson<OpnameScanSession>('/stock-opnames/scan-sessions', { user, note, locationId });
+}
+
+export async function getScanSession(sessionId: string): Promise<OpnameScanSession> {
+  return getJson<OpnameScanSession>(`/stock-opnames/scan-sessions/${sessionId}`);
+}
+
+export async function recordScan(sessionId: string, code: string, quantity = 1): Promise<OpnameScanSession> {
+  return postJson<OpnameScanSession>(`/stock-opnames/scan-sessions/${sessionId}/scans`, { code, quantity });
+}
+
+export async function setScanCount(sessionId: string, productId: string, counted: number): Promise<OpnameScanSession> {
+  return putJson<OpnameScanSession>(`/stock-opnames/scan-sessions/${sessionId}/items/${productId}`, { counted });
+}
+
+export async function removeScanItem(sessionId: string, productId: string): Promise<OpnameScanSession> {
+  await deleteJson(`/stock-opnames/scan-sessions/${sessionId}/items/${productId}`);
+  return getScanSession(sessionId);
+}
+
+export async function discardScanSession(sessionId: string): Promise<void> {
+  await deleteJson(`/stock-opnames/scan-sessions/${sessionId}`);
+}
+
+export async function submitScanSession(sessionId: string): Promise<StockOpname> {
+  const response = await postJson<ApiStockOpname>(`/stock-opnames/scan-sessions/${sessionId}/submit`);
+  return adaptOpname(response);
+}
+
+export type OpnameSessionStatus = 'open' | 'counting' | 'review' | 'applied' | 'cancelled';
+
+export interface OpnameCount {
+  counter: string;
+  quantity: number;
+  countedAt: string;
+}
+
+export interface OpnameSessionItem {
+  productId: string;
+  productName: string;
+  productSku: string;
+  counted: number | null;
+  systemStock: number | null;
+  difference: number | null;
+  counts: OpnameCount[];
+}
+
+export interface OpnameSession {
+  id: string;
+  code: string;
+  locationId: string;
+  note: string;
+  status: OpnameSessionStatus;
+  blindCount: boolean;
+  openedBy: string;
+  reviewedBy?: string;
+  approvedBy?: string;
+  cancelledBy?: string;
+  stockOpnameId?: string;
+  counters: string[];
+  items: OpnameSessionItem[];
+  openedAt: string;
+  reviewAt?: string;
+  closedAt?: string;
+  updatedAt: string;
+}
+
+export interface OpenOpnameSessionPayload {
+  user: string;
+  note?: string;
+  locationId?: string;
+  blindCount?: boolean;
+  productIds?: string[];
+}
+
+export interface OpnameCountEntry {
+  productId?: string;
+  code?: string;
+  quantity: number;
+}
+
+export async function listOpnameSessions(statuses: OpnameSessionStatus[] = [], limit = 20): Promise<OpnameSession[]> {
+  const params = new URLSearchParams({ limit: String(limit) });
+  if (statuses.length > 0) {
+    params.set('status', statuses.join(','));
+  }
+  return getJson<OpnameSession[]>(`/stock-opnames/sessions?${params.toString()}`);
+}
+
+export async function openOpnameSession(payload: OpenOpnameSessionPayload): Promise<OpnameSession> {
+  return postJson<OpnameSession>('/stock-opnames/sessions', payload);
+}
+
+export async function getOpnameSession(sessionId: string): Promise<OpnameSession> {
+  return getJson<OpnameSession>(`/stock-opnames/sessions/${sessionId}`);
+}
+
+export async function recordOpnameCounts(sessionId: string, counter: string, items: OpnameCountEntry[]): Promise<OpnameSession> {
+  return postJson<OpnameSession>(`/stock-opnames/sessions/${sessionId}/counts`, { counter, items });
+}
+
+export async function removeOpnameCount(sessionId: string, productId: string, counter: string): Promise<OpnameSession> {
+  const params = new URLSearchParams({ counter });
+  await deleteJson(`/stock-opnames/sessions/${sessionId}/counts/${productId}?${params.toString()}`);
+  return getOpnameSession(sessionId);
+}
+
+export async function submitOpnameSessionForReview(sessionId: string, user: string, zeroUncounted = false): Promise<OpnameSession> {
+  return postJson<OpnameSession>(`/stock-opnames/sessions/${sessionId}/review`, { user, zeroUncounted });
+}
+
+export async function reopenOpnameSession(sessionId: string): Promise<OpnameSession> {
+  return postJson<OpnameSession>(`/stock-opnames/sessions/${sessionId}/reopen`);
+}
+
+export async function approveOpnameSession(sessionId: string, user: string): Promise<OpnameSession> {
+  return postJson<OpnameSession>(`/stock-opnames/sessions/${sessionId}/approve`, { user });
+}
+
+export async function cancelOpnameSession(sessionId: string, user: string): Promise<OpnameSession> {
+  return postJson<OpnameSession>(`/stock-opnames/sessions/${sessionId}/cancel`, { user });
+}
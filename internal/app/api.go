@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"time"
 
 	"smartseller-lite-starter/internal/domain"
+	"smartseller-lite-starter/internal/events"
 	"smartseller-lite-starter/internal/service"
+	"smartseller-lite-starter/internal/sheet"
 )
 
 // API exposes the domain services to the HTTP transport.
@@ -33,12 +36,16 @@ func (a *API) ArchiveProduct(ctx context.Context, id string) error {
 	return a.core.ProductService.Archive(ctx, id)
 }
 
+func (a *API) UnarchiveProduct(ctx context.Context, id string) (*domain.Product, error) {
+	return a.core.ProductService.Unarchive(ctx, id)
+}
+
 func (a *API) DeleteProduct(ctx context.Context, id string) error {
 	return a.core.ProductService.Delete(ctx, id)
 }
 
-func (a *API) AdjustStock(ctx context.Context, productID string, delta int, reason string) error {
-	return a.core.ProductService.AdjustStock(ctx, productID, delta, reason)
+func (a *API) AdjustStock(ctx context.Context, productID string, input service.AdjustStockInput) error {
+	return a.core.AdjustmentService.Adjust(ctx, productID, input)
 }
 
 func (a *API) ListCustomers(ctx context.Context, opts service.CustomerListOptions) (service.CustomerListResult, error) {
@@ -56,6 +63,14 @@ func (a *API) DeleteCustomer(ctx context.Context, id string) error {
 	return a.core.CustomerService.Delete(ctx, id)
 }
 
+func (a *API) CustomerDuplicates(ctx context.Context) ([]service.CustomerDuplicateGroup, error) {
+	return a.core.CustomerService.Duplicates(ctx)
+}
+
+func (a *API) MergeCustomers(ctx context.Context, input service.MergeCustomersInput) (*service.MergeCustomersResult, error) {
+	return a.core.CustomerService.Merge(ctx, input)
+}
+
 func (a *API) ListOrders(ctx context.Context, opts service.OrderListOptions) (service.OrderListResult, error) {
 	return a.core.OrderService.ListPaged(ctx, opts)
 }
@@ -114,11 +129,11 @@ func (a *API) ExportOrdersCSV(ctx context.Context, filters service.OrderExportFi
 	return a.core.ReportService.ExportOrdersCSV(ctx, filters)
 }
 
-func (a *API) ListStockOpnames(ctx context.Context, limit int) ([]domain.StockOpname, error) {
+func (a *API) ListStockOpnames(ctx context.Context, opts service.StockOpnameListOptions) (service.StockOpnameListResult, error) {
 	if a.core.StockOpnameService == nil {
-		return []domain.StockOpname{}, nil
+		return service.StockOpnameListResult{Items: []domain.StockOpnameSummary{}}, nil
 	}
-	return a.core.StockOpnameService.List(ctx, limit)
+	return a.core.StockOpnameService.List(ctx, opts)
 }
 
 func (a *API) PerformStockOpname(ctx context.Context, payload service.PerformStockOpnameInput) (*domain.StockOpname, error) {
@@ -127,3 +142,338 @@ func (a *API) PerformStockOpname(ctx context.Context, payload service.PerformSto
 	}
 	return a.core.StockOpnameService.Perform(ctx, payload)
 }
+
+func (a *API) GetOrderTracking(ctx context.Context, orderID string) (*domain.ShipmentTracking, error) {
+	if a.core.TrackingService == nil {
+		return nil, fmt.Errorf("tracking service unavailable")
+	}
+	return a.core.TrackingService.Get(ctx, orderID)
+}
+
+func (a *API) RefreshOrderTracking(ctx context.Context, orderID string) (*domain.ShipmentTracking, error) {
+	if a.core.TrackingService == nil {
+		return nil, fmt.Errorf("tracking service unavailable")
+	}
+	return a.core.TrackingService.Refresh(ctx, orderID)
+}
+
+func (a *API) ListWebhookEventTypes() []string {
+	return a.core.WebhookService.EventTypes()
+}
+
+func (a *API) ListWebhooks(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	return a.core.WebhookService.List(ctx)
+}
+
+func (a *API) SaveWebhook(ctx context.Context, payload domain.WebhookSubscription) (*domain.WebhookSubscription, error) {
+	return a.core.WebhookService.Save(ctx, payload)
+}
+
+func (a *API) DeleteWebhook(ctx context.Context, id string) error {
+	return a.core.WebhookService.Delete(ctx, id)
+}
+
+func (a *API) ListWebhookDeliveries(ctx context.Context, subscriptionID string, limit int) ([]domain.WebhookDelivery, error) {
+	return a.core.WebhookService.Deliveries(ctx, subscriptionID, limit)
+}
+
+func (a *API) RedeliverWebhook(ctx context.Context, deliveryID string) error {
+	return a.core.WebhookService.Redeliver(ctx, deliveryID)
+}
+
+func (a *API) ProductBarcodeImage(ctx context.Context, productID, kind string) ([]byte, error) {
+	return a.core.ProductService.BarcodeImage(ctx, productID, kind)
+}
+
+func (a *API) GenerateBarcodeSheet(ctx context.Context, input service.BarcodeSheetInput) (string, error) {
+	pdfBytes, err := a.core.ProductService.GenerateBarcodeSheetPDF(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(pdfBytes), nil
+}
+
+func (a *API) LookupProduct(ctx context.Context, code string) (*domain.Product, error) {
+	return a.core.ProductService.Lookup(ctx, code)
+}
+
+func (a *API) StartOpnameScanSession(ctx context.Context, input service.StartScanSessionInput) (*domain.OpnameScanSession, error) {
+	return a.core.StockOpnameService.StartScanSession(ctx, input)
+}
+
+func (a *API) GetOpnameScanSession(ctx context.Context, id string) (*domain.OpnameScanSession, error) {
+	return a.core.StockOpnameService.GetScanSession(ctx, id)
+}
+
+func (a *API) RecordOpnameScan(ctx context.Context, id string, input service.ScanInput) (*domain.OpnameScanSession, error) {
+	return a.core.StockOpnameService.RecordScan(ctx, id, input)
+}
+
+func (a *API) SetOpnameScanCount(ctx context.Context, id, productID string, counted int) (*domain.OpnameScanSession, error) {
+	return a.core.StockOpnameService.SetScanCount(ctx, id, productID, counted)
+}
+
+func (a *API) RemoveOpnameScanItem(ctx context.Context, id, productID string) (*domain.OpnameScanSession, error) {
+	return a.core.StockOpnameService.RemoveScanItem(ctx, id, productID)
+}
+
+func (a *API) DiscardOpnameScanSession(ctx context.Context, id string) error {
+	return a.core.StockOpnameService.DiscardScanSession(ctx, id)
+}
+
+func (a *API) SubmitOpnameScanSession(ctx context.Context, id string) (*domain.StockOpname, error) {
+	return a.core.StockOpnameService.SubmitScanSession(ctx, id)
+}
+
+func (a *API) ExportProducts(ctx context.Context, format sheet.Format, withImages bool, archived service.ArchiveFilter) ([]byte, error) {
+	return a.core.ProductService.ExportProducts(ctx, format, withImages, archived)
+}
+
+func (a *API) ImportProducts(ctx context.Context, input service.ProductImportInput) (service.ProductImportResult, error) {
+	return a.core.ProductService.ImportProducts(ctx, input)
+}
+
+func (a *API) ListCategories(ctx context.Context) ([]domain.Category, error) {
+	return a.core.CategoryService.List(ctx)
+}
+
+func (a *API) SaveCategory(ctx context.Context, payload domain.Category) (*domain.Category, error) {
+	return a.core.CategoryService.Save(ctx, payload)
+}
+
+func (a *API) DeleteCategory(ctx context.Context, id string) error {
+	return a.core.CategoryService.Delete(ctx, id)
+}
+
+func (a *API) CategoryReport(ctx context.Context, filters service.CategoryReportFilters) ([]service.CategoryRollup, error) {
+	return a.core.ReportService.CategoryReport(ctx, filters)
+}
+
+func (a *API) ProductPriceHistory(ctx context.Context, id string, limit int) ([]domain.PriceChange, error) {
+	return a.core.ProductService.PriceHistory(ctx, id, limit)
+}
+
+func (a *API) MarginReport(ctx context.Context, filters service.MarginReportFilters) ([]service.ProductMargin, error) {
+	return a.core.ReportService.MarginReport(ctx, filters)
+}
+
+func (a *API) ReceiveStock(ctx context.Context, productID string, input service.ReceiveStockInput) (*domain.Product, error) {
+	return a.core.ProductService.ReceiveStock(ctx, productID, input)
+}
+
+func (a *API) ListStockBatches(ctx context.Context, productID string, includeDepleted bool) ([]domain.StockBatch, error) {
+	return a.core.ProductService.ListBatches(ctx, productID, includeDepleted)
+}
+
+func (a *API) InventoryValuation(ctx context.Context) (service.InventoryValuation, error) {
+	return a.core.ReportService.InventoryValuation(ctx)
+}
+
+func (a *API) ListSuppliers(ctx context.Context) ([]domain.Supplier, error) {
+	return a.core.SupplierService.List(ctx)
+}
+
+func (a *API) SaveSupplier(ctx context.Context, payload domain.Supplier) (*domain.Supplier, error) {
+	return a.core.SupplierService.Save(ctx, payload)
+}
+
+func (a *API) DeleteSupplier(ctx context.Context, id string) error {
+	return a.core.SupplierService.Delete(ctx, id)
+}
+
+func (a *API) ListPurchaseOrders(ctx context.Context, opts service.PurchaseOrderListOptions) ([]domain.PurchaseOrder, error) {
+	return a.core.PurchaseService.List(ctx, opts)
+}
+
+func (a *API) GetPurchaseOrder(ctx context.Context, id string) (*domain.PurchaseOrder, error) {
+	return a.core.PurchaseService.Get(ctx, id)
+}
+
+func (a *API) CreatePurchaseOrder(ctx context.Context, input service.PurchaseOrderInput) (*domain.PurchaseOrder, error) {
+	return a.core.PurchaseService.Create(ctx, input)
+}
+
+func (a *API) UpdatePurchaseOrder(ctx context.Context, id string, input service.PurchaseOrderInput) (*domain.PurchaseOrder, error) {
+	return a.core.PurchaseService.Update(ctx, id, input)
+}
+
+func (a *API) PlacePurchaseOrder(ctx context.Context, id string) (*domain.PurchaseOrder, error) {
+	return a.core.PurchaseService.Place(ctx, id)
+}
+
+func (a *API) ReceivePurchaseOrder(ctx context.Context, id string, input service.ReceivePurchaseInput) (*domain.PurchaseOrder, error) {
+	return a.core.PurchaseService.Receive(ctx, id, input)
+}
+
+func (a *API) AddPurchasePayment(ctx context.Context, id string, input service.PurchasePaymentInput) (*domain.PurchaseOrder, error) {
+	return a.core.PurchaseService.AddPayment(ctx, id, input)
+}
+
+func (a *API) DeletePurchaseOrder(ctx context.Context, id string) error {
+	return a.core.PurchaseService.Delete(ctx, id)
+}
+
+func (a *API) SupplierPayables(ctx context.Context) ([]service.SupplierPayable, error) {
+	return a.core.PurchaseService.Payables(ctx)
+}
+
+func (a *API) ListLocations(ctx context.Context) ([]domain.Location, error) {
+	return a.core.LocationService.List(ctx)
+}
+
+func (a *API) SaveLocation(ctx context.Context, payload domain.Location) (*domain.Location, error) {
+	return a.core.LocationService.Save(ctx, payload)
+}
+
+func (a *API) DeleteLocation(ctx context.Context, id string) error {
+	return a.core.LocationService.Delete(ctx, id)
+}
+
+func (a *API) TransferStock(ctx context.Context, input service.TransferStockInput) (*domain.StockTransfer, error) {
+	return a.core.LocationService.Transfer(ctx, input)
+}
+
+func (a *API) ListStockTransfers(ctx context.Context, limit int) ([]domain.StockTransfer, error) {
+	return a.core.LocationService.ListTransfers(ctx, limit)
+}
+
+func (a *API) GetOrder(ctx context.Context, id string) (*domain.Order, error) {
+	return a.core.OrderService.Get(ctx, id)
+}
+
+func (a *API) GetStockOpname(ctx context.Context, id string) (*domain.StockOpname, error) {
+	return a.core.StockOpnameService.Get(ctx, id)
+}
+
+func (a *API) ListStockMutations(ctx context.Context, opts service.StockMutationListOptions) (service.StockMutationListResult, error) {
+	return a.core.ProductService.ListMutations(ctx, opts)
+}
+
+func (a *API) CheckStockReconciliation(ctx context.Context) (*service.StockReconciliation, error) {
+	return a.core.ReconcileService.Check(ctx)
+}
+
+func (a *API) LatestStockReconciliation() *service.StockReconciliation {
+	return a.core.ReconcileService.Latest()
+}
+
+func (a *API) ApplyStockReconciliation(ctx context.Context, input service.ApplyReconciliationInput) (*service.ReconciliationResult, error) {
+	return a.core.ReconcileService.Apply(ctx, input)
+}
+
+func (a *API) RestockReport(ctx context.Context, filters service.RestockReportFilters) (*service.RestockReport, error) {
+	return a.core.RestockService.Report(ctx, filters)
+}
+
+func (a *API) ApplyRestockThresholds(ctx context.Context, productIDs []string) ([]service.ThresholdChange, error) {
+	return a.core.RestockService.ApplyThresholds(ctx, productIDs)
+}
+
+func (a *API) ExpiringLots(ctx context.Context, withinDays int) ([]domain.ExpiringLot, error) {
+	return a.core.ProductService.ExpiringLots(ctx, withinDays)
+}
+
+func (a *API) OpenOpnameSession(ctx context.Context, input service.OpenOpnameSessionInput) (*domain.OpnameSession, error) {
+	return a.core.StockOpnameService.OpenSession(ctx, input)
+}
+
+func (a *API) GetOpnameSession(ctx context.Context, id string) (*domain.OpnameSession, error) {
+	return a.core.StockOpnameService.GetSession(ctx, id)
+}
+
+func (a *API) ListOpnameSessions(ctx context.Context, statuses []domain.OpnameSessionStatus, limit int) ([]domain.OpnameSession, error) {
+	return a.core.StockOpnameService.ListSessions(ctx, statuses, limit)
+}
+
+func (a *API) RecordOpnameCounts(ctx context.Context, id string, input service.RecordOpnameCountsInput) (*domain.OpnameSession, error) {
+	return a.core.StockOpnameService.RecordCounts(ctx, id, input)
+}
+
+func (a *API) RemoveOpnameCount(ctx context.Context, id, productID, counter string) (*domain.OpnameSession, error) {
+	return a.core.StockOpnameService.RemoveCount(ctx, id, productID, counter)
+}
+
+func (a *API) SubmitOpnameSessionForReview(ctx context.Context, id string, input service.OpnameSessionActionInput) (*domain.OpnameSession, error) {
+	return a.core.StockOpnameService.SubmitForReview(ctx, id, input)
+}
+
+func (a *API) ReopenOpnameSession(ctx context.Context, id string) (*domain.OpnameSession, error) {
+	return a.core.StockOpnameService.Reopen(ctx, id)
+}
+
+func (a *API) ApproveOpnameSession(ctx context.Context, id string, input service.OpnameSessionActionInput) (*domain.OpnameSession, error) {
+	return a.core.StockOpnameService.Approve(ctx, id, input)
+}
+
+func (a *API) CancelOpnameSession(ctx context.Context, id string, input service.OpnameSessionActionInput) (*domain.OpnameSession, error) {
+	return a.core.StockOpnameService.Cancel(ctx, id, input)
+}
+
+func (a *API) OpnameVarianceReport(ctx context.Context, opnameID string) (*service.OpnameVarianceReport, error) {
+	return a.core.ReportService.OpnameVariance(ctx, opnameID)
+}
+
+func (a *API) OpnameVariancePDF(ctx context.Context, opnameID string) ([]byte, error) {
+	return a.core.ReportService.OpnameVariancePDF(ctx, opnameID)
+}
+
+func (a *API) OpnameVarianceCSV(ctx context.Context, opnameID string) ([]byte, error) {
+	return a.core.ReportService.OpnameVarianceCSV(ctx, opnameID)
+}
+
+func (a *API) ShrinkageTrend(ctx context.Context, start, end *time.Time) (*service.ShrinkageTrend, error) {
+	return a.core.ReportService.ShrinkageTrend(ctx, start, end)
+}
+
+func (a *API) ListProductOpnames(ctx context.Context, productID string, page, pageSize int) (service.ProductOpnameListResult, error) {
+	return a.core.StockOpnameService.ListForProduct(ctx, productID, page, pageSize)
+}
+
+func (a *API) ListAdjustmentReasons(ctx context.Context, activeOnly bool) ([]domain.AdjustmentReason, error) {
+	return a.core.AdjustmentService.Reasons(ctx, activeOnly)
+}
+
+func (a *API) CreateAdjustmentReason(ctx context.Context, payload service.AdjustmentReasonInput) (*domain.AdjustmentReason, error) {
+	return a.core.AdjustmentService.CreateReason(ctx, payload)
+}
+
+func (a *API) UpdateAdjustmentReason(ctx context.Context, payload service.AdjustmentReasonInput) (*domain.AdjustmentReason, error) {
+	return a.core.AdjustmentService.UpdateReason(ctx, payload)
+}
+
+func (a *API) DeleteAdjustmentReason(ctx context.Context, code string) error {
+	return a.core.AdjustmentService.DeleteReason(ctx, code)
+}
+
+func (a *API) AdjustmentReport(ctx context.Context, start, end *time.Time, period string) (*service.AdjustmentReport, error) {
+	return a.core.ReportService.AdjustmentReport(ctx, start, end, period)
+}
+
+func (a *API) AdjustmentReportCSV(ctx context.Context, start, end *time.Time, period string) ([]byte, error) {
+	return a.core.ReportService.AdjustmentReportCSV(ctx, start, end, period)
+}
+
+// ListenEvents registers a live listener for domain events; see events.Stream.Listen.
+func (a *API) ListenEvents(lastEventID string) ([]events.Event, <-chan events.Event, func()) {
+	return a.core.Stream.Listen(lastEventID)
+}
+
+func (a *API) ListProductImages(ctx context.Context, productID string) ([]domain.ProductImage, error) {
+	return a.core.ProductService.Images(ctx, productID)
+}
+
+func (a *API) AddProductImage(ctx context.Context, productID string, input service.AddProductImageInput) ([]domain.ProductImage, error) {
+	return a.core.ProductService.AddImage(ctx, productID, input)
+}
+
+func (a *API) UpdateProductImage(ctx context.Context, productID, imageID string, input service.UpdateProductImageInput) ([]domain.ProductImage, error) {
+	return a.core.ProductService.UpdateImage(ctx, productID, imageID, input)
+}
+
+func (a *API) ReorderProductImages(ctx context.Context, productID string, imageIDs []string) ([]domain.ProductImage, error) {
+	return a.core.ProductService.ReorderImages(ctx, productID, imageIDs)
+}
+
+func (a *API) DeleteProductImage(ctx context.Context, productID, imageID string) ([]domain.ProductImage, error) {
+	return a.core.ProductService.DeleteImage(ctx, productID, imageID)
+}
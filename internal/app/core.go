@@ -2,58 +2,103 @@ package app
 
 import (
 	"context"
+	"time"
 
 	"smartseller-lite-starter/internal/db"
+	"smartseller-lite-starter/internal/events"
 	"smartseller-lite-starter/internal/media"
 	"smartseller-lite-starter/internal/service"
+	"smartseller-lite-starter/internal/tracking"
 )
 
 // CoreConfig defines runtime configuration defaults for the app domain services.
 type CoreConfig struct {
-	DefaultBrandName string
-	MediaManager     *media.Manager
+	DefaultBrandName  string
+	MediaManager      *media.Manager
+	TrackingProviders *tracking.Registry
+	TrackingInterval  time.Duration
+	ReconcileInterval time.Duration
 }
 
 // Core wires repositories, services, and lifecycle events together.
 type Core struct {
 	store              *db.Store
+	Events             *events.Bus
+	Stream             *events.Stream
 	CustomerService    *service.CustomerService
 	OrderService       *service.OrderService
 	ProductService     *service.ProductService
+	CategoryService    *service.CategoryService
 	SettingsService    *service.SettingsService
 	CourierService     *service.CourierService
 	BackupService      *service.BackupService
 	StockOpnameService *service.StockOpnameService
 	ReportService      *service.ReportService
+	TrackingService    *service.TrackingService
+	WebhookService     *service.WebhookService
+	SupplierService    *service.SupplierService
+	PurchaseService    *service.PurchaseOrderService
+	LocationService    *service.LocationService
+	ReconcileService   *service.StockReconcileService
+	RestockService     *service.RestockService
+	AdjustmentService  *service.AdjustmentService
 }
 
 func NewCore(store *db.Store, cfg CoreConfig) *Core {
 	productRepo := store.ProductRepository()
+	categoryRepo := store.CategoryRepository()
 	customerRepo := store.CustomerRepository()
 	orderRepo := store.OrderRepository()
 	settingsRepo := store.SettingsRepository()
 	courierRepo := store.CourierRepository()
 	stockOpnameRepo := store.StockOpnameRepository()
+	trackingRepo := store.TrackingRepository()
 
-	productSvc := service.NewProductService(productRepo, cfg.MediaManager)
+	bus := events.NewBus()
+
+	productSvc := service.NewProductService(productRepo, categoryRepo, cfg.MediaManager, bus)
+	categorySvc := service.NewCategoryService(categoryRepo)
 	customerSvc := service.NewCustomerService(customerRepo)
-	settingsSvc := service.NewSettingsService(settingsRepo, cfg.DefaultBrandName, cfg.MediaManager)
+	settingsSvc := service.NewSettingsService(settingsRepo, cfg.DefaultBrandName, cfg.MediaManager, bus)
 	courierSvc := service.NewCourierService(courierRepo, cfg.MediaManager)
-	orderSvc := service.NewOrderService(orderRepo, productSvc, customerSvc, settingsSvc)
-	stockOpnameSvc := service.NewStockOpnameService(stockOpnameRepo, productSvc)
+	locationSvc := service.NewLocationService(store.LocationRepository(), productRepo)
+	orderSvc := service.NewOrderService(orderRepo, productSvc, customerSvc, settingsSvc, locationSvc, bus)
+	stockOpnameSvc := service.NewStockOpnameService(stockOpnameRepo, productSvc, locationSvc, bus)
 	backupSvc := service.NewBackupService(store, cfg.MediaManager)
 	reportSvc := service.NewReportService(store)
+	trackingProviders := cfg.TrackingProviders
+	if trackingProviders == nil {
+		trackingProviders = tracking.NewRegistry()
+	}
+	trackingSvc := service.NewTrackingService(trackingRepo, trackingProviders, cfg.TrackingInterval)
+	webhookSvc := service.NewWebhookService(store.WebhookRepository(), bus)
+	supplierSvc := service.NewSupplierService(store.SupplierRepository())
+	purchaseSvc := service.NewPurchaseOrderService(store.PurchaseOrderRepository(), supplierSvc, productSvc)
+	reconcileSvc := service.NewStockReconcileService(productRepo, productSvc, bus, cfg.ReconcileInterval)
+	restockSvc := service.NewRestockService(productRepo, settingsSvc)
+	adjustmentSvc := service.NewAdjustmentService(store.AdjustmentRepository(), productSvc)
 
 	return &Core{
 		store:              store,
+		Events:             bus,
+		Stream:             events.NewStream(bus, 256),
 		CustomerService:    customerSvc,
 		OrderService:       orderSvc,
 		ProductService:     productSvc,
+		CategoryService:    categorySvc,
 		SettingsService:    settingsSvc,
 		CourierService:     courierSvc,
 		BackupService:      backupSvc,
 		StockOpnameService: stockOpnameSvc,
 		ReportService:      reportSvc,
+		TrackingService:    trackingSvc,
+		WebhookService:     webhookSvc,
+		SupplierService:    supplierSvc,
+		PurchaseService:    purchaseSvc,
+		LocationService:    locationSvc,
+		ReconcileService:   reconcileSvc,
+		RestockService:     restockSvc,
+		AdjustmentService:  adjustmentSvc,
 	}
 }
 
@@ -69,6 +114,22 @@ func (c *Core) Warm(ctx context.Context) {
 	}
 }
 
+// Start launches background workers. They stop when ctx is cancelled.
+func (c *Core) Start(ctx context.Context) {
+	if c.TrackingService != nil {
+		c.TrackingService.Start(ctx)
+	}
+	if c.WebhookService != nil {
+		c.WebhookService.Start(ctx)
+	}
+	if c.ReconcileService != nil {
+		c.ReconcileService.Start(ctx)
+	}
+	if c.RestockService != nil {
+		c.RestockService.Start(ctx)
+	}
+}
+
 // Close releases the underlying store connection.
 func (c *Core) Close() error {
 	if c == nil {
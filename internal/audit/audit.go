@@ -0,0 +1,53 @@
+// Package audit carries who made a change, and through which channel, in the request
+// context so repositories can record it without widening every signature.
+package audit
+
+import (
+	"context"
+	"strings"
+)
+
+// ActorHeader is the request header clients use to name the operator.
+const ActorHeader = "X-SmartSeller-User"
+
+const (
+	defaultActor  = "system"
+	defaultSource = "api"
+)
+
+type contextKey int
+
+const (
+	actorKey contextKey = iota
+	sourceKey
+)
+
+// WithActor returns a context naming the operator behind the change.
+func WithActor(ctx context.Context, actor string) context.Context {
+	actor = strings.TrimSpace(actor)
+	if actor == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// Actor returns the operator recorded in ctx, or "system".
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey).(string); ok && actor != "" {
+		return actor
+	}
+	return defaultActor
+}
+
+// WithSource tags changes made through ctx, e.g. "import".
+func WithSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceKey, source)
+}
+
+// Source returns the channel recorded in ctx, or "api".
+func Source(ctx context.Context) string {
+	if source, ok := ctx.Value(sourceKey).(string); ok && source != "" {
+		return source
+	}
+	return defaultSource
+}
@@ -0,0 +1,171 @@
+package barcode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckDigit(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   byte
+	}{
+		{"400638133393", '1'},
+		{"590123412345", '7'},
+		{"978020137962", '4'},
+		{"003600029145", '2'},
+		{"871125300120", '2'},
+		{"000000000000", '0'},
+	}
+	for _, tt := range tests {
+		if got := CheckDigit(tt.digits); got != tt.want {
+			t.Errorf("CheckDigit(%q) = %q, want %q", tt.digits, got, tt.want)
+		}
+	}
+}
+
+func TestNormaliseGTIN(t *testing.T) {
+	tests := []struct {
+		code    string
+		want    string
+		wantErr bool
+	}{
+		{code: "4006381333931", want: "4006381333931"},
+		{code: " 590-1234-12345-7 ", want: "5901234123457"},
+		{code: "9780 2013 7962 4", want: "9780201379624"},
+		{code: "036000291452", want: "0036000291452"},
+		{code: "4006381333932", wantErr: true},
+		{code: "03600029145", wantErr: true},
+		{code: "40063813339310", wantErr: true},
+		{code: "40063813339A1", wantErr: true},
+		{code: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := NormaliseGTIN(tt.code)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("NormaliseGTIN(%q) = %q, want error", tt.code, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("NormaliseGTIN(%q) = %q, %v, want %q", tt.code, got, err, tt.want)
+		}
+	}
+}
+
+func TestEAN13(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{
+			// First digit 5 selects LGGLLG for the left half.
+			code: "5901234123457",
+			want: "101" + "0001011" + "0100111" + "0110011" + "0010011" + "0111101" + "0011101" +
+				"01010" + "1100110" + "1101100" + "1000010" + "1011100" + "1001110" + "1000100" + "101",
+		},
+		{
+			// UPC-A reads as EAN-13 with a leading 0, so the left half is all L.
+			code: "036000291452",
+			want: "101" + "0001101" + "0111101" + "0101111" + "0001101" + "0001101" + "0001101" +
+				"01010" + "1101100" + "1110100" + "1100110" + "1011100" + "1001110" + "1101100" + "101",
+		},
+	}
+	for _, tt := range tests {
+		b, err := EAN13(tt.code)
+		if err != nil {
+			t.Fatalf("EAN13(%q): %v", tt.code, err)
+		}
+		if got := bits(b.Modules); got != tt.want {
+			t.Errorf("EAN13(%q) modules\n got %s\nwant %s", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestCode128Patterns(t *testing.T) {
+	known := map[int]string{
+		0:   "212222",
+		16:  "123122",
+		99:  "113141",
+		102: "411131",
+		103: "211412",
+		104: "211214",
+		105: "211232",
+		106: "2331112",
+	}
+	for value, want := range known {
+		if got := code128Patterns[value]; got != want {
+			t.Errorf("code128Patterns[%d] = %q, want %q", value, got, want)
+		}
+	}
+	seen := make(map[string]int)
+	for value, pattern := range code128Patterns {
+		width, bars := 0, 0
+		for i, ch := range pattern {
+			width += int(ch - '0')
+			if i%2 == 0 {
+				bars += int(ch - '0')
+			}
+		}
+		wantWidth := 11
+		if value == code128Stop {
+			wantWidth = 13
+		}
+		if width != wantWidth {
+			t.Errorf("code128Patterns[%d] = %q is %d modules wide, want %d", value, pattern, width, wantWidth)
+		}
+		if bars%2 != 0 {
+			t.Errorf("code128Patterns[%d] = %q has an odd bar width", value, pattern)
+		}
+		if prev, ok := seen[pattern]; ok {
+			t.Errorf("code128Patterns[%d] repeats value %d", value, prev)
+		}
+		seen[pattern] = value
+	}
+}
+
+func TestCode128(t *testing.T) {
+	tests := []struct {
+		text   string
+		values []int
+	}{
+		// Code set B: start, one symbol per character, checksum, stop.
+		{"PJJ123C", []int{104, 48, 42, 42, 17, 18, 19, 35, 55, 106}},
+		{"A", []int{104, 33, 34, 106}},
+		// Even-length digits use code set C, two digits per symbol.
+		{"123456", []int{105, 12, 34, 56, 44, 106}},
+		// Odd-length digits stay in code set B.
+		{"123", []int{104, 17, 18, 19, 8, 106}},
+	}
+	for _, tt := range tests {
+		b, err := Code128(tt.text)
+		if err != nil {
+			t.Fatalf("Code128(%q): %v", tt.text, err)
+		}
+		var want strings.Builder
+		for _, v := range tt.values {
+			want.WriteString(bits(appendWidths(nil, code128Patterns[v])))
+		}
+		if got := bits(b.Modules); got != want.String() {
+			t.Errorf("Code128(%q) modules\n got %s\nwant %s", tt.text, got, want.String())
+		}
+	}
+	for _, text := range []string{"", "café", "tab\there"} {
+		if _, err := Code128(text); err == nil {
+			t.Errorf("Code128(%q) succeeded, want error", text)
+		}
+	}
+}
+
+func bits(modules []bool) string {
+	var sb strings.Builder
+	for _, dark := range modules {
+		if dark {
+			sb.WriteByte('1')
+		} else {
+			sb.WriteByte('0')
+		}
+	}
+	return sb.String()
+}
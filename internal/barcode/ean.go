@@ -0,0 +1,70 @@
+package barcode
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	eanLeft  = [10]string{"0001101", "0011001", "0010011", "0111101", "0100011", "0110001", "0101111", "0111011", "0110111", "0001011"}
+	eanEven  = [10]string{"0100111", "0110011", "0011011", "0100001", "0011101", "0111001", "0000101", "0010001", "0001001", "0010111"}
+	eanRight = [10]string{"1110010", "1100110", "1101100", "1000010", "1011100", "1001110", "1010000", "1000100", "1001000", "1110100"}
+	// eanParity selects odd (L) or even (G) encoding for the left half from the first digit.
+	eanParity = [10]string{"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG", "LGGLLG", "LGGGLL", "LGLGLG", "LGLGGL", "LGGLGL"}
+)
+
+// NormaliseGTIN validates an EAN-13 or UPC-A code and returns it as 13 digits.
+// UPC-A codes gain a leading zero, which is how EAN-13 scanners read them.
+func NormaliseGTIN(code string) (string, error) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	code = strings.ReplaceAll(code, "-", "")
+	if !isDigits(code) || (len(code) != 12 && len(code) != 13) {
+		return "", errors.New("barcode harus berupa 12 digit UPC-A atau 13 digit EAN-13")
+	}
+	if len(code) == 12 {
+		code = "0" + code
+	}
+	if CheckDigit(code[:12]) != code[12] {
+		return "", errors.New("check digit barcode tidak valid")
+	}
+	return code, nil
+}
+
+// CheckDigit computes the EAN-13 check digit of the first 12 digits.
+func CheckDigit(digits string) byte {
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// EAN13 encodes a 13-digit EAN or 12-digit UPC-A code.
+func EAN13(code string) (*Barcode, error) {
+	code, err := NormaliseGTIN(code)
+	if err != nil {
+		return nil, err
+	}
+
+	parity := eanParity[code[0]-'0']
+	modules := make([]bool, 0, 95)
+	modules = appendBits(modules, "101")
+	for i := 1; i <= 6; i++ {
+		d := code[i] - '0'
+		if parity[i-1] == 'L' {
+			modules = appendBits(modules, eanLeft[d])
+		} else {
+			modules = appendBits(modules, eanEven[d])
+		}
+	}
+	modules = appendBits(modules, "01010")
+	for i := 7; i <= 12; i++ {
+		modules = appendBits(modules, eanRight[code[i]-'0'])
+	}
+	modules = appendBits(modules, "101")
+	return &Barcode{Kind: KindEAN13, Text: code, Modules: modules}, nil
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
@@ -23,6 +24,13 @@ type Store struct {
 	settingsRepo    *repo.SettingsRepository
 	courierRepo     *repo.CourierRepository
 	stockOpnameRepo *repo.StockOpnameRepository
+	trackingRepo    *repo.TrackingRepository
+	webhookRepo     *repo.WebhookRepository
+	categoryRepo    *repo.CategoryRepository
+	supplierRepo    *repo.SupplierRepository
+	purchaseRepo    *repo.PurchaseOrderRepository
+	locationRepo    *repo.LocationRepository
+	adjustmentRepo  *repo.AdjustmentRepository
 }
 
 // NewStore initialises a new Store using the provided MySQL DSN.
@@ -84,10 +92,12 @@ func (s *Store) initSchema() error {
             id VARCHAR(36) NOT NULL PRIMARY KEY,
             name VARCHAR(255) NOT NULL,
             sku VARCHAR(191) NULL,
+            barcode VARCHAR(32) NULL,
             cost_price DOUBLE NOT NULL DEFAULT 0,
             sale_price DOUBLE NOT NULL DEFAULT 0,
             stock INT NOT NULL DEFAULT 0,
             category VARCHAR(191),
+            category_id VARCHAR(36) NULL,
             low_stock_threshold INT NOT NULL DEFAULT 5,
             description TEXT,
             image_path VARCHAR(255),
@@ -99,10 +109,29 @@ func (s *Store) initSchema() error {
             thumb_width INT,
             thumb_height INT,
             thumb_size_bytes BIGINT,
+            is_bundle BOOLEAN NOT NULL DEFAULT FALSE,
+            parent_id VARCHAR(36),
+            option_axes TEXT,
+            variant_options TEXT,
             deleted_at VARCHAR(64),
             created_at VARCHAR(64) NOT NULL,
             updated_at VARCHAR(64) NOT NULL,
-            INDEX idx_products_name (name)
+            INDEX idx_products_name (name),
+            INDEX idx_products_parent (parent_id),
+            INDEX idx_products_category (category_id),
+            UNIQUE KEY idx_products_sku (sku),
+            UNIQUE KEY idx_products_barcode (barcode)
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS categories (
+            id VARCHAR(36) NOT NULL PRIMARY KEY,
+            parent_id VARCHAR(36) NULL,
+            name VARCHAR(191) NOT NULL,
+            slug VARCHAR(191) NOT NULL,
+            sort_order INT NOT NULL DEFAULT 0,
+            created_at VARCHAR(64) NOT NULL,
+            updated_at VARCHAR(64) NOT NULL,
+            UNIQUE KEY idx_categories_slug (slug),
+            KEY idx_categories_parent (parent_id)
         ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
 		`CREATE TABLE IF NOT EXISTS customers (
             id VARCHAR(36) NOT NULL PRIMARY KEY,
@@ -150,6 +179,37 @@ func (s *Store) initSchema() error {
             KEY idx_order_items_order (order_id),
             CONSTRAINT fk_order_items_order FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE,
             CONSTRAINT fk_order_items_product FOREIGN KEY (product_id) REFERENCES products(id)
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS product_bundle_items (
+            bundle_id VARCHAR(36) NOT NULL,
+            component_id VARCHAR(36) NOT NULL,
+            quantity INT NOT NULL,
+            PRIMARY KEY (bundle_id, component_id),
+            KEY idx_product_bundle_items_component (component_id),
+            CONSTRAINT fk_product_bundle_items_bundle FOREIGN KEY (bundle_id) REFERENCES products(id) ON DELETE CASCADE,
+            CONSTRAINT fk_product_bundle_items_component FOREIGN KEY (component_id) REFERENCES products(id)
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS order_item_components (
+            id VARCHAR(36) NOT NULL PRIMARY KEY,
+            order_item_id VARCHAR(36) NOT NULL,
+            product_id VARCHAR(36) NOT NULL,
+            quantity INT NOT NULL,
+            cost_price DOUBLE NOT NULL DEFAULT 0,
+            KEY idx_order_item_components_item (order_item_id),
+            CONSTRAINT fk_order_item_components_item FOREIGN KEY (order_item_id) REFERENCES order_items(id) ON DELETE CASCADE,
+            CONSTRAINT fk_order_item_components_product FOREIGN KEY (product_id) REFERENCES products(id)
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS order_item_lots (
+            id VARCHAR(36) NOT NULL PRIMARY KEY,
+            order_item_id VARCHAR(36) NOT NULL,
+            product_id VARCHAR(36) NOT NULL,
+            batch_id VARCHAR(36) NULL,
+            lot_number VARCHAR(64) NULL,
+            expires_at VARCHAR(64) NULL,
+            quantity INT NOT NULL,
+            KEY idx_order_item_lots_item (order_item_id),
+            KEY idx_order_item_lots_batch (batch_id),
+            CONSTRAINT fk_order_item_lots_item FOREIGN KEY (order_item_id) REFERENCES order_items(id) ON DELETE CASCADE
         ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
 		`CREATE TABLE IF NOT EXISTS stock_mutations (
             id VARCHAR(36) NOT NULL PRIMARY KEY,
@@ -159,6 +219,153 @@ func (s *Store) initSchema() error {
             created_at VARCHAR(64) NOT NULL,
             KEY idx_stock_mutations_product (product_id),
             CONSTRAINT fk_stock_mutations_product FOREIGN KEY (product_id) REFERENCES products(id)
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS product_images (
+            id VARCHAR(36) NOT NULL PRIMARY KEY,
+            product_id VARCHAR(36) NOT NULL,
+            path VARCHAR(255) NOT NULL,
+            thumb_path VARCHAR(255) NOT NULL DEFAULT '',
+            hash VARCHAR(128) NOT NULL DEFAULT '',
+            width INT NOT NULL DEFAULT 0,
+            height INT NOT NULL DEFAULT 0,
+            size_bytes BIGINT NOT NULL DEFAULT 0,
+            thumb_width INT NOT NULL DEFAULT 0,
+            thumb_height INT NOT NULL DEFAULT 0,
+            thumb_size_bytes BIGINT NOT NULL DEFAULT 0,
+            alt_text VARCHAR(255) NOT NULL DEFAULT '',
+            sort_order INT NOT NULL DEFAULT 0,
+            is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+            created_at VARCHAR(64) NOT NULL,
+            KEY idx_product_images_product (product_id, sort_order),
+            CONSTRAINT fk_product_images_product FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS product_price_history (
+            id VARCHAR(36) NOT NULL PRIMARY KEY,
+            product_id VARCHAR(36) NOT NULL,
+            cost_price DOUBLE NOT NULL DEFAULT 0,
+            sale_price DOUBLE NOT NULL DEFAULT 0,
+            previous_cost_price DOUBLE NOT NULL DEFAULT 0,
+            previous_sale_price DOUBLE NOT NULL DEFAULT 0,
+            actor VARCHAR(191) NOT NULL,
+            source VARCHAR(32) NOT NULL,
+            changed_at VARCHAR(64) NOT NULL,
+            KEY idx_product_price_history_product (product_id, changed_at),
+            CONSTRAINT fk_product_price_history_product FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS stock_batches (
+            id VARCHAR(36) NOT NULL PRIMARY KEY,
+            product_id VARCHAR(36) NOT NULL,
+            reference VARCHAR(255) NOT NULL,
+            note TEXT,
+            unit_cost DOUBLE NOT NULL DEFAULT 0,
+            quantity INT NOT NULL,
+            remaining INT NOT NULL,
+            received_at VARCHAR(64) NOT NULL,
+            created_at VARCHAR(64) NOT NULL,
+            KEY idx_stock_batches_product (product_id, received_at),
+            CONSTRAINT fk_stock_batches_product FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS stock_batch_consumptions (
+            id VARCHAR(36) NOT NULL PRIMARY KEY,
+            batch_id VARCHAR(36) NULL,
+            product_id VARCHAR(36) NOT NULL,
+            reason VARCHAR(255) NOT NULL,
+            quantity INT NOT NULL,
+            unit_cost DOUBLE NOT NULL DEFAULT 0,
+            created_at VARCHAR(64) NOT NULL,
+            KEY idx_stock_batch_consumptions_batch (batch_id),
+            KEY idx_stock_batch_consumptions_reason (reason),
+            CONSTRAINT fk_stock_batch_consumptions_product FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS suppliers (
+            id VARCHAR(36) NOT NULL PRIMARY KEY,
+            name VARCHAR(191) NOT NULL,
+            contact VARCHAR(191),
+            phone VARCHAR(64),
+            email VARCHAR(191),
+            address TEXT,
+            payment_term_days INT NOT NULL DEFAULT 0,
+            notes TEXT,
+            created_at VARCHAR(64) NOT NULL,
+            updated_at VARCHAR(64) NOT NULL,
+            KEY idx_suppliers_name (name)
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS purchase_orders (
+            id VARCHAR(36) NOT NULL PRIMARY KEY,
+            code VARCHAR(64) NOT NULL,
+            supplier_id VARCHAR(36) NOT NULL,
+            status VARCHAR(32) NOT NULL DEFAULT 'draft',
+            notes TEXT,
+            expected_at VARCHAR(64),
+            ordered_at VARCHAR(64),
+            last_received_at VARCHAR(64),
+            created_at VARCHAR(64) NOT NULL,
+            updated_at VARCHAR(64) NOT NULL,
+            UNIQUE KEY idx_purchase_orders_code (code),
+            KEY idx_purchase_orders_supplier (supplier_id),
+            CONSTRAINT fk_purchase_orders_supplier FOREIGN KEY (supplier_id) REFERENCES suppliers(id)
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS purchase_order_lines (
+            id VARCHAR(36) NOT NULL PRIMARY KEY,
+            purchase_order_id VARCHAR(36) NOT NULL,
+            product_id VARCHAR(36) NOT NULL,
+            quantity INT NOT NULL,
+            unit_cost DOUBLE NOT NULL DEFAULT 0,
+            received_quantity INT NOT NULL DEFAULT 0,
+            received_value DOUBLE NOT NULL DEFAULT 0,
+            sort_order INT NOT NULL DEFAULT 0,
+            KEY idx_purchase_order_lines_order (purchase_order_id),
+            CONSTRAINT fk_purchase_order_lines_order FOREIGN KEY (purchase_order_id) REFERENCES purchase_orders(id) ON DELETE CASCADE,
+            CONSTRAINT fk_purchase_order_lines_product FOREIGN KEY (product_id) REFERENCES products(id)
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS purchase_payments (
+            id VARCHAR(36) NOT NULL PRIMARY KEY,
+            purchase_order_id VARCHAR(36) NOT NULL,
+            amount DOUBLE NOT NULL,
+            note TEXT,
+            paid_at VARCHAR(64) NOT NULL,
+            created_at VARCHAR(64) NOT NULL,
+            KEY idx_purchase_payments_order (purchase_order_id),
+            CONSTRAINT fk_purchase_payments_order FOREIGN KEY (purchase_order_id) REFERENCES purchase_orders(id) ON DELETE CASCADE
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS locations (
+            id VARCHAR(36) NOT NULL PRIMARY KEY,
+            code VARCHAR(64) NOT NULL,
+            name VARCHAR(191) NOT NULL,
+            kind VARCHAR(32) NOT NULL DEFAULT 'warehouse',
+            is_default BOOLEAN NOT NULL DEFAULT FALSE,
+            notes TEXT,
+            created_at VARCHAR(64) NOT NULL,
+            updated_at VARCHAR(64) NOT NULL,
+            UNIQUE KEY idx_locations_code (code)
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS product_location_stock (
+            product_id VARCHAR(36) NOT NULL,
+            location_id VARCHAR(36) NOT NULL,
+            quantity INT NOT NULL DEFAULT 0,
+            PRIMARY KEY (product_id, location_id),
+            KEY idx_product_location_stock_location (location_id),
+            CONSTRAINT fk_product_location_stock_product FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+            CONSTRAINT fk_product_location_stock_location FOREIGN KEY (location_id) REFERENCES locations(id)
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS stock_transfers (
+            id VARCHAR(36) NOT NULL PRIMARY KEY,
+            code VARCHAR(64) NOT NULL,
+            from_location_id VARCHAR(36) NOT NULL,
+            to_location_id VARCHAR(36) NOT NULL,
+            note TEXT,
+            actor VARCHAR(191) NOT NULL,
+            created_at VARCHAR(64) NOT NULL,
+            UNIQUE KEY idx_stock_transfers_code (code)
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS stock_transfer_items (
+            id VARCHAR(36) NOT NULL PRIMARY KEY,
+            transfer_id VARCHAR(36) NOT NULL,
+            product_id VARCHAR(36) NOT NULL,
+            quantity INT NOT NULL,
+            KEY idx_stock_transfer_items_transfer (transfer_id),
+            CONSTRAINT fk_stock_transfer_items_transfer FOREIGN KEY (transfer_id) REFERENCES stock_transfers(id) ON DELETE CASCADE,
+            CONSTRAINT fk_stock_transfer_items_product FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
         ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
 		`CREATE TABLE IF NOT EXISTS stock_opnames (
             id VARCHAR(36) NOT NULL PRIMARY KEY,
@@ -177,6 +384,66 @@ func (s *Store) initSchema() error {
             KEY idx_stock_opname_items_opname (stock_opname_id),
             CONSTRAINT fk_stock_opname_items_opname FOREIGN KEY (stock_opname_id) REFERENCES stock_opnames(id) ON DELETE CASCADE,
             CONSTRAINT fk_stock_opname_items_product FOREIGN KEY (product_id) REFERENCES products(id)
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS opname_sessions (
+            id VARCHAR(36) NOT NULL PRIMARY KEY,
+            code VARCHAR(32) NOT NULL UNIQUE,
+            location_id VARCHAR(36) NULL,
+            note TEXT,
+            status VARCHAR(16) NOT NULL,
+            blind_count BOOLEAN NOT NULL DEFAULT FALSE,
+            opened_by VARCHAR(191) NOT NULL,
+            reviewed_by VARCHAR(191) NULL,
+            approved_by VARCHAR(191) NULL,
+            cancelled_by VARCHAR(191) NULL,
+            stock_opname_id VARCHAR(36) NULL,
+            opened_at VARCHAR(64) NOT NULL,
+            review_at VARCHAR(64) NULL,
+            closed_at VARCHAR(64) NULL,
+            updated_at VARCHAR(64) NOT NULL,
+            KEY idx_opname_sessions_status (status, opened_at)
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS opname_session_items (
+            session_id VARCHAR(36) NOT NULL,
+            product_id VARCHAR(36) NOT NULL,
+            system_stock INT NULL,
+            PRIMARY KEY (session_id, product_id),
+            CONSTRAINT fk_opname_session_items_session FOREIGN KEY (session_id) REFERENCES opname_sessions(id) ON DELETE CASCADE,
+            CONSTRAINT fk_opname_session_items_product FOREIGN KEY (product_id) REFERENCES products(id)
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS opname_session_counts (
+            session_id VARCHAR(36) NOT NULL,
+            product_id VARCHAR(36) NOT NULL,
+            counter VARCHAR(191) NOT NULL,
+            quantity INT NOT NULL,
+            counted_at VARCHAR(64) NOT NULL,
+            PRIMARY KEY (session_id, product_id, counter),
+            CONSTRAINT fk_opname_session_counts_session FOREIGN KEY (session_id) REFERENCES opname_sessions(id) ON DELETE CASCADE
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS adjustment_reasons (
+            code VARCHAR(32) NOT NULL PRIMARY KEY,
+            label VARCHAR(191) NOT NULL,
+            direction VARCHAR(8) NOT NULL DEFAULT 'both',
+            requires_note BOOLEAN NOT NULL DEFAULT FALSE,
+            active BOOLEAN NOT NULL DEFAULT TRUE,
+            is_system BOOLEAN NOT NULL DEFAULT FALSE,
+            sort_order INT NOT NULL DEFAULT 0,
+            created_at VARCHAR(64) NOT NULL,
+            updated_at VARCHAR(64) NOT NULL
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS stock_adjustments (
+            id VARCHAR(36) NOT NULL PRIMARY KEY,
+            product_id VARCHAR(36) NOT NULL,
+            location_id VARCHAR(36) NULL,
+            reason_code VARCHAR(32) NOT NULL,
+            delta INT NOT NULL,
+            value DOUBLE NOT NULL DEFAULT 0,
+            note TEXT,
+            actor VARCHAR(191) NULL,
+            created_at VARCHAR(64) NOT NULL,
+            KEY idx_stock_adjustments_reason (reason_code, created_at),
+            KEY idx_stock_adjustments_created (created_at),
+            CONSTRAINT fk_stock_adjustments_product FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
         ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
 		`CREATE TABLE IF NOT EXISTS settings (
 			` + "`key`" + ` VARCHAR(191) NOT NULL PRIMARY KEY,
@@ -200,6 +467,46 @@ func (s *Store) initSchema() error {
             created_at VARCHAR(64) NOT NULL,
             updated_at VARCHAR(64) NOT NULL,
             KEY idx_couriers_code (code)
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS shipment_events (
+            id VARCHAR(36) NOT NULL PRIMARY KEY,
+            order_id VARCHAR(36) NOT NULL,
+            status VARCHAR(32) NOT NULL,
+            description TEXT,
+            location VARCHAR(191),
+            fingerprint CHAR(64) NOT NULL,
+            occurred_at VARCHAR(64) NOT NULL,
+            created_at VARCHAR(64) NOT NULL,
+            UNIQUE KEY idx_shipment_events_fingerprint (order_id, fingerprint),
+            KEY idx_shipment_events_order (order_id, occurred_at),
+            CONSTRAINT fk_shipment_events_order FOREIGN KEY (order_id) REFERENCES orders(id) ON DELETE CASCADE
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+            id VARCHAR(36) NOT NULL PRIMARY KEY,
+            name VARCHAR(191) NOT NULL,
+            url VARCHAR(512) NOT NULL,
+            secret VARCHAR(191) NOT NULL,
+            events TEXT,
+            active BOOLEAN NOT NULL DEFAULT TRUE,
+            created_at VARCHAR(64) NOT NULL,
+            updated_at VARCHAR(64) NOT NULL
+        ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		`CREATE TABLE IF NOT EXISTS webhook_outbox (
+            id VARCHAR(36) NOT NULL PRIMARY KEY,
+            subscription_id VARCHAR(36) NOT NULL,
+            event_id VARCHAR(36) NOT NULL,
+            event_type VARCHAR(64) NOT NULL,
+            payload LONGTEXT NOT NULL,
+            status VARCHAR(16) NOT NULL DEFAULT 'pending',
+            attempts INT NOT NULL DEFAULT 0,
+            next_attempt_at VARCHAR(64),
+            last_status_code INT NOT NULL DEFAULT 0,
+            last_error TEXT,
+            created_at VARCHAR(64) NOT NULL,
+            delivered_at VARCHAR(64),
+            KEY idx_webhook_outbox_due (status, next_attempt_at),
+            KEY idx_webhook_outbox_subscription (subscription_id, created_at),
+            CONSTRAINT fk_webhook_outbox_subscription FOREIGN KEY (subscription_id) REFERENCES webhook_subscriptions(id) ON DELETE CASCADE
         ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
 	}
 
@@ -222,6 +529,32 @@ func (s *Store) initSchema() error {
 		`ALTER TABLE products ADD COLUMN category VARCHAR(191);`,
 		`ALTER TABLE products ADD COLUMN low_stock_threshold INT NOT NULL DEFAULT 5;`,
 		`ALTER TABLE products ADD COLUMN deleted_at VARCHAR(64);`,
+		`ALTER TABLE products ADD COLUMN is_bundle BOOLEAN NOT NULL DEFAULT FALSE;`,
+		`ALTER TABLE products ADD COLUMN parent_id VARCHAR(36);`,
+		`ALTER TABLE products ADD COLUMN option_axes TEXT;`,
+		`ALTER TABLE products ADD COLUMN variant_options TEXT;`,
+		`ALTER TABLE products ADD INDEX idx_products_parent (parent_id);`,
+		`ALTER TABLE products ADD COLUMN barcode VARCHAR(32) NULL;`,
+		`UPDATE products SET sku = NULL WHERE TRIM(sku) = '';`,
+		`UPDATE products SET barcode = NULL WHERE TRIM(barcode) = '';`,
+		`ALTER TABLE products ADD COLUMN category_id VARCHAR(36) NULL;`,
+		`ALTER TABLE products ADD INDEX idx_products_category (category_id);`,
+		`ALTER TABLE products ADD COLUMN average_cost DOUBLE NULL;`,
+		`ALTER TABLE stock_mutations ADD COLUMN location_id VARCHAR(36) NULL;`,
+		// seq orders mutations written within the same second.
+		`ALTER TABLE stock_mutations ADD COLUMN seq BIGINT NOT NULL AUTO_INCREMENT UNIQUE;`,
+		`ALTER TABLE stock_mutations ADD INDEX idx_stock_mutations_created (created_at);`,
+		`ALTER TABLE stock_opnames ADD COLUMN location_id VARCHAR(36) NULL;`,
+		`ALTER TABLE stock_batches ADD COLUMN lot_number VARCHAR(64) NULL;`,
+		`ALTER TABLE stock_batches ADD COLUMN expires_at VARCHAR(64) NULL;`,
+		`ALTER TABLE stock_batches ADD INDEX idx_stock_batches_expiry (expires_at);`,
+		`ALTER TABLE orders ADD COLUMN location_id VARCHAR(36) NULL;`,
+		`ALTER TABLE stock_opname_items ADD COLUMN unit_cost DOUBLE NULL;`,
+		`ALTER TABLE stock_opname_items ADD COLUMN sale_price DOUBLE NULL;`,
+		// Products created before price history existed get their current prices as a baseline.
+		`INSERT INTO product_price_history (id, product_id, cost_price, sale_price, previous_cost_price, previous_sale_price, actor, source, changed_at)
+            SELECT UUID(), p.id, p.cost_price, p.sale_price, 0, 0, 'system', 'baseline', p.created_at FROM products p
+            WHERE NOT EXISTS (SELECT 1 FROM product_price_history h WHERE h.product_id = p.id);`,
 		`ALTER TABLE couriers ADD COLUMN logo_path VARCHAR(255);`,
 		`ALTER TABLE couriers ADD COLUMN logo_hash CHAR(64);`,
 		`ALTER TABLE couriers ADD COLUMN logo_width INT;`,
@@ -230,17 +563,67 @@ func (s *Store) initSchema() error {
 		`ALTER TABLE couriers ADD COLUMN logo_mime VARCHAR(64);`,
 		`ALTER TABLE stock_opnames ADD COLUMN performed_by VARCHAR(191);`,
 		`ALTER TABLE orders ADD COLUMN is_buyer_paying_shipping BOOLEAN NOT NULL DEFAULT FALSE;`,
+		`ALTER TABLE orders ADD COLUMN shipment_status VARCHAR(32) NOT NULL DEFAULT 'pending';`,
+		`ALTER TABLE orders ADD COLUMN shipment_status_at VARCHAR(64);`,
+		`ALTER TABLE orders ADD COLUMN shipment_checked_at VARCHAR(64);`,
 	}
 
 	for _, stmt := range migrations {
 		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
 			lower := strings.ToLower(err.Error())
-			if !strings.Contains(lower, "duplicate column") && !strings.Contains(lower, "exists") {
+			if !strings.Contains(lower, "duplicate column") && !strings.Contains(lower, "duplicate key name") && !strings.Contains(lower, "exists") {
 				return fmt.Errorf("migrate: %w", err)
 			}
 		}
 	}
 
+	// Older databases may still hold duplicate codes. The oldest product keeps a code;
+	// later holders of a SKU get their id appended to it, and later holders of a barcode
+	// lose it, since a changed barcode would no longer scan. A unique index that still
+	// cannot be built fails the migration.
+	uniqueIndexes := []struct{ resolve, index string }{
+		{
+			`UPDATE products p JOIN products keep ON keep.sku = p.sku AND (keep.created_at < p.created_at OR (keep.created_at = p.created_at AND keep.id < p.id))
+                SET p.sku = CONCAT(LEFT(p.sku, 182), '-', LEFT(p.id, 8));`,
+			`ALTER TABLE products ADD UNIQUE INDEX idx_products_sku (sku);`,
+		},
+		{
+			`UPDATE products p JOIN products keep ON keep.barcode = p.barcode AND (keep.created_at < p.created_at OR (keep.created_at = p.created_at AND keep.id < p.id))
+                SET p.barcode = NULL;`,
+			`ALTER TABLE products ADD UNIQUE INDEX idx_products_barcode (barcode);`,
+		},
+	}
+	for _, step := range uniqueIndexes {
+		res, err := s.db.ExecContext(ctx, step.resolve)
+		if err != nil {
+			return fmt.Errorf("migrate duplicate product codes: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			log.Printf("migrate: resolved %d duplicate product codes", n)
+		}
+		if _, err := s.db.ExecContext(ctx, step.index); err != nil {
+			if !strings.Contains(strings.ToLower(err.Error()), "duplicate key name") {
+				return fmt.Errorf("migrate unique product codes: %w", err)
+			}
+		}
+	}
+
+	if err := s.CategoryRepository().AdoptLegacyCategories(ctx); err != nil {
+		return fmt.Errorf("migrate categories: %w", err)
+	}
+	if err := s.ProductRepository().SeedOpeningBatches(ctx); err != nil {
+		return fmt.Errorf("migrate stock batches: %w", err)
+	}
+	if err := s.ProductRepository().AdoptLegacyImages(ctx); err != nil {
+		return fmt.Errorf("migrate product images: %w", err)
+	}
+	if err := s.LocationRepository().EnsureDefault(ctx); err != nil {
+		return fmt.Errorf("migrate locations: %w", err)
+	}
+	if err := s.AdjustmentRepository().EnsureDefaultReasons(ctx); err != nil {
+		return fmt.Errorf("migrate adjustment reasons: %w", err)
+	}
+
 	return nil
 }
 
@@ -259,6 +642,41 @@ func (s *Store) ProductRepository() *repo.ProductRepository {
 	return s.productRepo
 }
 
+func (s *Store) SupplierRepository() *repo.SupplierRepository {
+	if s.supplierRepo == nil {
+		s.supplierRepo = repo.NewSupplierRepository(s.db)
+	}
+	return s.supplierRepo
+}
+
+func (s *Store) PurchaseOrderRepository() *repo.PurchaseOrderRepository {
+	if s.purchaseRepo == nil {
+		s.purchaseRepo = repo.NewPurchaseOrderRepository(s.db)
+	}
+	return s.purchaseRepo
+}
+
+func (s *Store) LocationRepository() *repo.LocationRepository {
+	if s.locationRepo == nil {
+		s.locationRepo = repo.NewLocationRepository(s.db)
+	}
+	return s.locationRepo
+}
+
+func (s *Store) AdjustmentRepository() *repo.AdjustmentRepository {
+	if s.adjustmentRepo == nil {
+		s.adjustmentRepo = repo.NewAdjustmentRepository(s.db)
+	}
+	return s.adjustmentRepo
+}
+
+func (s *Store) CategoryRepository() *repo.CategoryRepository {
+	if s.categoryRepo == nil {
+		s.categoryRepo = repo.NewCategoryRepository(s.db)
+	}
+	return s.categoryRepo
+}
+
 func (s *Store) CustomerRepository() *repo.CustomerRepository {
 	if s.customerRepo == nil {
 		s.customerRepo = repo.NewCustomerRepository(s.db)
@@ -294,6 +712,20 @@ func (s *Store) StockOpnameRepository() *repo.StockOpnameRepository {
 	return s.stockOpnameRepo
 }
 
+func (s *Store) TrackingRepository() *repo.TrackingRepository {
+	if s.trackingRepo == nil {
+		s.trackingRepo = repo.NewTrackingRepository(s.db)
+	}
+	return s.trackingRepo
+}
+
+func (s *Store) WebhookRepository() *repo.WebhookRepository {
+	if s.webhookRepo == nil {
+		s.webhookRepo = repo.NewWebhookRepository(s.db)
+	}
+	return s.webhookRepo
+}
+
 // DB exposes the raw database connection for advanced use cases.
 func (s *Store) DB() *sql.DB {
 	return s.db
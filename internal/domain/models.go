@@ -10,31 +10,299 @@ const (
 	CustomerTypeReseller CustomerType = "reseller"
 )
 
+// Product is a sellable item. Bundles (IsBundle) hold no stock of their own; their
+// availability is derived from Components. A product with OptionAxes is a variant
+// parent: stock, SKU and price live on its Variants, which are product rows
+// pointing back through ParentID.
 type Product struct {
-	ID                string     `json:"id"`
-	Name              string     `json:"name"`
-	SKU               string     `json:"sku"`
-	CostPrice         float64    `json:"costPrice"`
-	SalePrice         float64    `json:"salePrice"`
-	Stock             int        `json:"stock"`
-	Category          string     `json:"category"`
-	LowStockThreshold int        `json:"lowStockThreshold"`
-	Description       string     `json:"description"`
-	ImagePath         string     `json:"imagePath"`
-	ThumbPath         string     `json:"thumbPath"`
-	ImageURL          string     `json:"imageUrl"`
-	ThumbURL          string     `json:"thumbUrl"`
-	ImageHash         string     `json:"imageHash"`
-	ImageWidth        int        `json:"imageWidth"`
-	ImageHeight       int        `json:"imageHeight"`
-	ImageSizeBytes    int64      `json:"imageSizeBytes"`
-	ThumbWidth        int        `json:"thumbWidth"`
-	ThumbHeight       int        `json:"thumbHeight"`
-	ThumbSizeBytes    int64      `json:"thumbSizeBytes"`
-	ImageData         string     `json:"imageData,omitempty"`
-	CreatedAt         time.Time  `json:"createdAt"`
-	UpdatedAt         time.Time  `json:"updatedAt"`
-	DeletedAt         *time.Time `json:"deletedAt"`
+	ID                string            `json:"id"`
+	Name              string            `json:"name"`
+	SKU               string            `json:"sku"`
+	Barcode           string            `json:"barcode"`
+	CostPrice         float64           `json:"costPrice"`
+	SalePrice         float64           `json:"salePrice"`
+	Stock             int               `json:"stock"`
+	Category          string            `json:"category"`
+	CategoryID        string            `json:"categoryId"`
+	LowStockThreshold int               `json:"lowStockThreshold"`
+	Description       string            `json:"description"`
+	ImagePath         string            `json:"imagePath"`
+	ThumbPath         string            `json:"thumbPath"`
+	ImageURL          string            `json:"imageUrl"`
+	ThumbURL          string            `json:"thumbUrl"`
+	ImageHash         string            `json:"imageHash"`
+	ImageWidth        int               `json:"imageWidth"`
+	ImageHeight       int               `json:"imageHeight"`
+	ImageSizeBytes    int64             `json:"imageSizeBytes"`
+	ThumbWidth        int               `json:"thumbWidth"`
+	ThumbHeight       int               `json:"thumbHeight"`
+	ThumbSizeBytes    int64             `json:"thumbSizeBytes"`
+	ImageData         string            `json:"imageData,omitempty"`
+	Images            []ProductImage    `json:"images,omitempty"`
+	IsBundle          bool              `json:"isBundle"`
+	Components        []BundleComponent `json:"components,omitempty"`
+	ParentID          string            `json:"parentId"`
+	OptionAxes        []string          `json:"optionAxes,omitempty"`
+	Options           []VariantOption   `json:"options,omitempty"`
+	Variants          []Product         `json:"variants,omitempty"`
+	Locations         []LocationStock   `json:"locations,omitempty"`
+	CreatedAt         time.Time         `json:"createdAt"`
+	UpdatedAt         time.Time         `json:"updatedAt"`
+	DeletedAt         *time.Time        `json:"deletedAt"`
+}
+
+// ProductImage is one picture in a product's gallery. The primary image is mirrored
+// in the product's own image fields, which labels and exports read.
+type ProductImage struct {
+	ID             string    `json:"id"`
+	ProductID      string    `json:"productId"`
+	Path           string    `json:"path"`
+	ThumbPath      string    `json:"thumbPath"`
+	URL            string    `json:"url"`
+	ThumbURL       string    `json:"thumbUrl"`
+	Hash           string    `json:"hash"`
+	Width          int       `json:"width"`
+	Height         int       `json:"height"`
+	SizeBytes      int64     `json:"sizeBytes"`
+	ThumbWidth     int       `json:"thumbWidth"`
+	ThumbHeight    int       `json:"thumbHeight"`
+	ThumbSizeBytes int64     `json:"thumbSizeBytes"`
+	AltText        string    `json:"altText"`
+	SortOrder      int       `json:"sortOrder"`
+	IsPrimary      bool      `json:"isPrimary"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// Location kinds.
+const (
+	LocationHome        = "home"
+	LocationWarehouse   = "warehouse"
+	LocationConsignment = "consignment"
+)
+
+// Location is a place stock is kept. Exactly one location is the default; stock
+// changes that do not name a location happen there.
+type Location struct {
+	ID         string    `json:"id"`
+	Code       string    `json:"code"`
+	Name       string    `json:"name"`
+	Kind       string    `json:"kind"`
+	IsDefault  bool      `json:"isDefault"`
+	Notes      string    `json:"notes"`
+	StockUnits int       `json:"stockUnits"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// LocationStock is the quantity of a product held at one location. Product.Stock is
+// the sum over all locations.
+type LocationStock struct {
+	LocationID   string `json:"locationId"`
+	LocationCode string `json:"locationCode"`
+	LocationName string `json:"locationName"`
+	Quantity     int    `json:"quantity"`
+}
+
+// StockTransfer moves stock between two locations without changing the total.
+type StockTransfer struct {
+	ID             string              `json:"id"`
+	Code           string              `json:"code"`
+	FromLocationID string              `json:"fromLocationId"`
+	ToLocationID   string              `json:"toLocationId"`
+	Note           string              `json:"note"`
+	Actor          string              `json:"actor"`
+	Items          []StockTransferItem `json:"items"`
+	CreatedAt      time.Time           `json:"createdAt"`
+}
+
+type StockTransferItem struct {
+	ProductID   string `json:"productId"`
+	ProductName string `json:"productName"`
+	SKU         string `json:"sku"`
+	Quantity    int    `json:"quantity"`
+}
+
+// HasVariants reports whether the product is a variant parent.
+func (p Product) HasVariants() bool {
+	return len(p.OptionAxes) > 0
+}
+
+// FitsAxes reports whether the variant takes exactly one option on each of axes.
+func (p Product) FitsAxes(axes []string) bool {
+	if len(p.Options) != len(axes) {
+		return false
+	}
+	for _, axis := range axes {
+		found := false
+		for _, option := range p.Options {
+			found = found || option.Axis == axis
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// VariantOption is the value a variant takes on one option axis, e.g. Ukuran=XL.
+type VariantOption struct {
+	Axis  string `json:"axis"`
+	Value string `json:"value"`
+}
+
+// PriceChange is one entry of a product's price and cost history. Margin figures are
+// derived from the new prices.
+type PriceChange struct {
+	ID                string    `json:"id"`
+	ProductID         string    `json:"productId"`
+	CostPrice         float64   `json:"costPrice"`
+	SalePrice         float64   `json:"salePrice"`
+	PreviousCostPrice float64   `json:"previousCostPrice"`
+	PreviousSalePrice float64   `json:"previousSalePrice"`
+	Margin            float64   `json:"margin"`
+	MarginPercent     float64   `json:"marginPercent"`
+	Actor             string    `json:"actor"`
+	Source            string    `json:"source"`
+	ChangedAt         time.Time `json:"changedAt"`
+}
+
+// Costing methods decide the unit cost of stock leaving the warehouse.
+const (
+	CostingFIFO    = "fifo"
+	CostingAverage = "average"
+)
+
+// StockBatch is a quantity of a product received at one unit cost. Remaining drops as
+// the batch is consumed; valuation under FIFO is the cost of what remains.
+type StockBatch struct {
+	ID         string     `json:"id"`
+	ProductID  string     `json:"productId"`
+	Reference  string     `json:"reference"`
+	Note       string     `json:"note"`
+	UnitCost   float64    `json:"unitCost"`
+	Quantity   int        `json:"quantity"`
+	Remaining  int        `json:"remaining"`
+	LotNumber  string     `json:"lotNumber"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	ReceivedAt time.Time  `json:"receivedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// LotAllocation is how many units of one lot a stock movement took.
+type LotAllocation struct {
+	ProductID string     `json:"productId"`
+	BatchID   string     `json:"batchId"`
+	LotNumber string     `json:"lotNumber"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	Quantity  int        `json:"quantity"`
+}
+
+// ExpiringLot is a lot with stock left that expires soon or already has.
+type ExpiringLot struct {
+	BatchID   string    `json:"batchId"`
+	ProductID string    `json:"productId"`
+	Name      string    `json:"name"`
+	SKU       string    `json:"sku"`
+	LotNumber string    `json:"lotNumber"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Remaining int       `json:"remaining"`
+	Value     float64   `json:"value"`
+	DaysLeft  int       `json:"daysLeft"`
+	Expired   bool      `json:"expired"`
+}
+
+// Supplier is a vendor stock is purchased from. PaymentTermDays is how long after
+// receiving goods an invoice falls due.
+type Supplier struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	Contact         string    `json:"contact"`
+	Phone           string    `json:"phone"`
+	Email           string    `json:"email"`
+	Address         string    `json:"address"`
+	PaymentTermDays int       `json:"paymentTermDays"`
+	Notes           string    `json:"notes"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+type PurchaseOrderStatus string
+
+const (
+	PurchaseOrderDraft             PurchaseOrderStatus = "draft"
+	PurchaseOrderOrdered           PurchaseOrderStatus = "ordered"
+	PurchaseOrderPartiallyReceived PurchaseOrderStatus = "partially_received"
+	PurchaseOrderReceived          PurchaseOrderStatus = "received"
+)
+
+// PurchaseOrder is an order placed with a supplier. Total is the expected cost of all
+// lines, ReceivedValue what the received goods actually cost, and Outstanding what is
+// still owed for them.
+type PurchaseOrder struct {
+	ID             string              `json:"id"`
+	Code           string              `json:"code"`
+	SupplierID     string              `json:"supplierId"`
+	SupplierName   string              `json:"supplierName"`
+	Status         PurchaseOrderStatus `json:"status"`
+	Notes          string              `json:"notes"`
+	ExpectedAt     *time.Time          `json:"expectedAt"`
+	OrderedAt      *time.Time          `json:"orderedAt"`
+	LastReceivedAt *time.Time          `json:"lastReceivedAt"`
+	Total          float64             `json:"total"`
+	ReceivedValue  float64             `json:"receivedValue"`
+	PaidAmount     float64             `json:"paidAmount"`
+	Outstanding    float64             `json:"outstanding"`
+	Lines          []PurchaseOrderLine `json:"lines"`
+	Payments       []PurchasePayment   `json:"payments,omitempty"`
+	CreatedAt      time.Time           `json:"createdAt"`
+	UpdatedAt      time.Time           `json:"updatedAt"`
+}
+
+// PurchaseOrderLine orders Quantity units of a product at an expected UnitCost.
+type PurchaseOrderLine struct {
+	ID               string  `json:"id"`
+	PurchaseOrderID  string  `json:"purchaseOrderId"`
+	ProductID        string  `json:"productId"`
+	ProductName      string  `json:"productName"`
+	SKU              string  `json:"sku"`
+	Quantity         int     `json:"quantity"`
+	UnitCost         float64 `json:"unitCost"`
+	ReceivedQuantity int     `json:"receivedQuantity"`
+	ReceivedValue    float64 `json:"receivedValue"`
+}
+
+// PurchasePayment is money paid to the supplier against a purchase order.
+type PurchasePayment struct {
+	ID              string    `json:"id"`
+	PurchaseOrderID string    `json:"purchaseOrderId"`
+	Amount          float64   `json:"amount"`
+	Note            string    `json:"note"`
+	PaidAt          time.Time `json:"paidAt"`
+}
+
+// BundleComponent is a product consumed by each unit of a bundle.
+type BundleComponent struct {
+	ProductID string  `json:"productId"`
+	Name      string  `json:"name"`
+	SKU       string  `json:"sku"`
+	Quantity  int     `json:"quantity"`
+	Stock     int     `json:"stock"`
+	CostPrice float64 `json:"costPrice"`
+}
+
+// Category groups products in a tree. Slug is unique across the tree; Path joins the
+// names from the root, e.g. "Hijab > Segi Empat".
+type Category struct {
+	ID           string    `json:"id"`
+	ParentID     string    `json:"parentId"`
+	Name         string    `json:"name"`
+	Slug         string    `json:"slug"`
+	SortOrder    int       `json:"sortOrder"`
+	Path         string    `json:"path"`
+	Depth        int       `json:"depth"`
+	ProductCount int       `json:"productCount"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
 }
 
 type Customer struct {
@@ -56,6 +324,7 @@ type Customer struct {
 type Order struct {
 	ID            string      `json:"id"`
 	Code          string      `json:"code"`
+	LocationID    string      `json:"locationId"`
 	BuyerID       string      `json:"buyerId"`
 	RecipientID   string      `json:"recipientId"`
 	Shipment      Shipment    `json:"shipment"`
@@ -69,23 +338,132 @@ type Order struct {
 }
 
 type OrderItem struct {
-	ID           string  `json:"id"`
-	OrderID      string  `json:"orderId"`
-	ProductID    string  `json:"productId"`
-	SKU          string  `json:"sku"`
-	Quantity     int     `json:"quantity"`
-	UnitPrice    float64 `json:"unitPrice"`
-	DiscountItem float64 `json:"discountItem"`
-	CostPrice    float64 `json:"costPrice"`
-	Profit       float64 `json:"profit"`
+	ID           string               `json:"id"`
+	OrderID      string               `json:"orderId"`
+	ProductID    string               `json:"productId"`
+	SKU          string               `json:"sku"`
+	Quantity     int                  `json:"quantity"`
+	UnitPrice    float64              `json:"unitPrice"`
+	DiscountItem float64              `json:"discountItem"`
+	CostPrice    float64              `json:"costPrice"`
+	Profit       float64              `json:"profit"`
+	Components   []OrderItemComponent `json:"components,omitempty"`
+	// Lots lists the expiry-tracked lots the line was picked from.
+	Lots []LotAllocation `json:"lots,omitempty"`
+}
+
+// StockQuantities returns the stocked products and quantities the line moves: the
+// bundle components when present, otherwise the product itself.
+func (item OrderItem) StockQuantities() map[string]int {
+	moves := make(map[string]int)
+	if len(item.Components) == 0 {
+		moves[item.ProductID] = item.Quantity
+		return moves
+	}
+	for _, c := range item.Components {
+		moves[c.ProductID] += c.Quantity
+	}
+	return moves
+}
+
+// ApplyUnitCosts sets the line cost from the unit costs of the stock it consumed and
+// recomputes its profit.
+func (item *OrderItem) ApplyUnitCosts(unitCosts map[string]float64) {
+	if len(item.Components) == 0 {
+		item.CostPrice = unitCosts[item.ProductID]
+	} else {
+		var total float64
+		for i := range item.Components {
+			c := &item.Components[i]
+			c.CostPrice = unitCosts[c.ProductID]
+			total += c.CostPrice * float64(c.Quantity)
+		}
+		item.CostPrice = total / float64(item.Quantity)
+	}
+	revenue := item.UnitPrice*float64(item.Quantity) - item.DiscountItem
+	if revenue < 0 {
+		revenue = 0
+	}
+	item.Profit = revenue - item.CostPrice*float64(item.Quantity)
+}
+
+// ComputeProfit returns the order margin after discounts and, when the seller pays for
+// it, shipping. It never goes below zero.
+func (o *Order) ComputeProfit() float64 {
+	var subtotal, cost float64
+	for _, item := range o.Items {
+		revenue := item.UnitPrice*float64(item.Quantity) - item.DiscountItem
+		if revenue < 0 {
+			revenue = 0
+		}
+		subtotal += revenue
+		cost += item.CostPrice * float64(item.Quantity)
+	}
+	profit := subtotal - o.DiscountOrder - cost
+	if !o.Shipment.ShippingByBuyer {
+		profit -= o.Shipment.ShippingCost
+	}
+	if profit < 0 {
+		profit = 0
+	}
+	return profit
+}
+
+// OrderItemComponent snapshots a bundle component at the time the order was placed.
+// Quantity is the total for the line, CostPrice the unit cost.
+type OrderItemComponent struct {
+	ProductID string  `json:"productId"`
+	SKU       string  `json:"sku"`
+	Quantity  int     `json:"quantity"`
+	CostPrice float64 `json:"costPrice"`
 }
 
 type Shipment struct {
-	Courier         string  `json:"courier"`
-	TrackingCode    string  `json:"trackingCode"`
-	ServiceLevel    string  `json:"serviceLevel"`
-	ShippingCost    float64 `json:"shippingCost"`
-	ShippingByBuyer bool    `json:"shippingByBuyer"`
+	Courier         string         `json:"courier"`
+	TrackingCode    string         `json:"trackingCode"`
+	ServiceLevel    string         `json:"serviceLevel"`
+	ShippingCost    float64        `json:"shippingCost"`
+	ShippingByBuyer bool           `json:"shippingByBuyer"`
+	Status          ShipmentStatus `json:"status"`
+	StatusUpdatedAt *time.Time     `json:"statusUpdatedAt"`
+}
+
+type ShipmentStatus string
+
+const (
+	ShipmentStatusPending   ShipmentStatus = "pending"
+	ShipmentStatusPickedUp  ShipmentStatus = "picked_up"
+	ShipmentStatusInTransit ShipmentStatus = "in_transit"
+	ShipmentStatusDelivered ShipmentStatus = "delivered"
+	ShipmentStatusReturned  ShipmentStatus = "returned"
+)
+
+// IsFinal reports whether no further courier updates are expected for the status.
+func (s ShipmentStatus) IsFinal() bool {
+	return s == ShipmentStatusDelivered || s == ShipmentStatusReturned
+}
+
+// ShipmentEvent is a single courier checkpoint recorded for an order.
+type ShipmentEvent struct {
+	ID          string         `json:"id"`
+	OrderID     string         `json:"orderId"`
+	Status      ShipmentStatus `json:"status"`
+	Description string         `json:"description"`
+	Location    string         `json:"location"`
+	OccurredAt  time.Time      `json:"occurredAt"`
+	CreatedAt   time.Time      `json:"createdAt"`
+}
+
+// ShipmentTracking summarises the delivery state and checkpoints of an order.
+type ShipmentTracking struct {
+	OrderID         string          `json:"orderId"`
+	OrderCode       string          `json:"orderCode"`
+	Courier         string          `json:"courier"`
+	TrackingCode    string          `json:"trackingCode"`
+	Status          ShipmentStatus  `json:"status"`
+	StatusUpdatedAt *time.Time      `json:"statusUpdatedAt"`
+	CheckedAt       *time.Time      `json:"checkedAt"`
+	Events          []ShipmentEvent `json:"events"`
 }
 
 // LabelData is a flattened view to render PDF labels.
@@ -101,18 +479,67 @@ type LabelData struct {
 	CreatedAt       time.Time `json:"createdAt"`
 }
 
-// StockMutation tracks manual stock adjustments for audit.
+// Directions an adjustment reason allows stock to move in.
+const (
+	AdjustmentIn   = "in"
+	AdjustmentOut  = "out"
+	AdjustmentBoth = "both"
+)
+
+// AdjustmentReason is a code manual stock adjustments are booked under. System reasons
+// ship with the app and can be deactivated but not deleted.
+type AdjustmentReason struct {
+	Code         string    `json:"code"`
+	Label        string    `json:"label"`
+	Direction    string    `json:"direction"`
+	RequiresNote bool      `json:"requiresNote"`
+	Active       bool      `json:"active"`
+	IsSystem     bool      `json:"isSystem"`
+	SortOrder    int       `json:"sortOrder"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// Allows reports whether the reason permits a change of the given sign.
+func (r AdjustmentReason) Allows(delta int) bool {
+	switch r.Direction {
+	case AdjustmentIn:
+		return delta > 0
+	case AdjustmentOut:
+		return delta < 0
+	}
+	return true
+}
+
+// StockMutation is an entry of the stock ledger. Balance is the product's total stock
+// right after the mutation.
 type StockMutation struct {
-	ID        string    `json:"id"`
-	ProductID string    `json:"productId"`
-	Delta     int       `json:"delta"`
-	Reason    string    `json:"reason"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID           string               `json:"id"`
+	ProductID    string               `json:"productId"`
+	ProductName  string               `json:"productName"`
+	SKU          string               `json:"sku"`
+	LocationID   string               `json:"locationId"`
+	LocationName string               `json:"locationName"`
+	Delta        int                  `json:"delta"`
+	Reason       string               `json:"reason"`
+	Balance      int                  `json:"balance"`
+	Source       *StockMutationSource `json:"source,omitempty"`
+	CreatedAt    time.Time            `json:"createdAt"`
+}
+
+// StockMutationSource identifies the document a mutation came from, derived from its
+// reason. Link is the API path of the document when it still exists.
+type StockMutationSource struct {
+	Type string `json:"type"`
+	ID   string `json:"id,omitempty"`
+	Code string `json:"code,omitempty"`
+	Link string `json:"link,omitempty"`
 }
 
 // StockOpname captures the result of a stock take session.
 type StockOpname struct {
 	ID          string            `json:"id"`
+	LocationID  string            `json:"locationId"`
 	Note        string            `json:"note"`
 	PerformedBy string            `json:"performedBy"`
 	PerformedAt time.Time         `json:"performedAt"`
@@ -130,6 +557,117 @@ type StockOpnameItem struct {
 	Counted       int    `json:"counted"`
 	PreviousStock int    `json:"previousStock"`
 	Difference    int    `json:"difference"`
+	// UnitCost and SalePrice value the difference as it stood when the opname was
+	// applied; losses are costed at the batches they actually took out.
+	UnitCost  float64 `json:"unitCost"`
+	SalePrice float64 `json:"salePrice"`
+}
+
+// StockOpnameSummary is an opname in history listings: its header with the size of
+// its variance instead of the items.
+type StockOpnameSummary struct {
+	ID            string    `json:"id"`
+	LocationID    string    `json:"locationId"`
+	LocationName  string    `json:"locationName"`
+	Note          string    `json:"note"`
+	PerformedBy   string    `json:"performedBy"`
+	PerformedAt   time.Time `json:"performedAt"`
+	CreatedAt     time.Time `json:"createdAt"`
+	ItemCount     int       `json:"itemCount"`
+	IncreaseUnits int       `json:"increaseUnits"`
+	DecreaseUnits int       `json:"decreaseUnits"`
+}
+
+// ProductOpnameEntry is how one opname found one product, for auditing a product's
+// stock takes.
+type ProductOpnameEntry struct {
+	StockOpnameID string    `json:"stockOpnameId"`
+	LocationID    string    `json:"locationId"`
+	LocationName  string    `json:"locationName"`
+	Note          string    `json:"note"`
+	PerformedBy   string    `json:"performedBy"`
+	PerformedAt   time.Time `json:"performedAt"`
+	Counted       int       `json:"counted"`
+	PreviousStock int       `json:"previousStock"`
+	Difference    int       `json:"difference"`
+	UnitCost      float64   `json:"unitCost"`
+	SalePrice     float64   `json:"salePrice"`
+}
+
+// OpnameSessionStatus is the stage of a multi-step stock take.
+type OpnameSessionStatus string
+
+const (
+	OpnameSessionOpen      OpnameSessionStatus = "open"
+	OpnameSessionCounting  OpnameSessionStatus = "counting"
+	OpnameSessionReview    OpnameSessionStatus = "review"
+	OpnameSessionApplied   OpnameSessionStatus = "applied"
+	OpnameSessionCancelled OpnameSessionStatus = "cancelled"
+)
+
+// OpnameSession is a stock take that runs over hours and several counters. Counts are
+// saved as they come in; nothing touches stock until the variance review is approved,
+// which posts a StockOpname. With BlindCount, system stock stays hidden until review.
+type OpnameSession struct {
+	ID            string              `json:"id"`
+	Code          string              `json:"code"`
+	LocationID    string              `json:"locationId"`
+	Note          string              `json:"note"`
+	Status        OpnameSessionStatus `json:"status"`
+	BlindCount    bool                `json:"blindCount"`
+	OpenedBy      string              `json:"openedBy"`
+	ReviewedBy    string              `json:"reviewedBy,omitempty"`
+	ApprovedBy    string              `json:"approvedBy,omitempty"`
+	CancelledBy   string              `json:"cancelledBy,omitempty"`
+	StockOpnameID string              `json:"stockOpnameId,omitempty"`
+	Counters      []string            `json:"counters"`
+	Items         []OpnameSessionItem `json:"items"`
+	OpenedAt      time.Time           `json:"openedAt"`
+	ReviewAt      *time.Time          `json:"reviewAt,omitempty"`
+	ClosedAt      *time.Time          `json:"closedAt,omitempty"`
+	UpdatedAt     time.Time           `json:"updatedAt"`
+}
+
+// OpnameSessionItem is one product of a session. Counted sums every counter's count;
+// SystemStock and Difference are nil while a blind count is still being counted, and
+// Counted is nil for products in scope that nobody has counted yet.
+type OpnameSessionItem struct {
+	ProductID   string        `json:"productId"`
+	ProductName string        `json:"productName"`
+	ProductSKU  string        `json:"productSku"`
+	Counted     *int          `json:"counted"`
+	SystemStock *int          `json:"systemStock"`
+	Difference  *int          `json:"difference"`
+	Counts      []OpnameCount `json:"counts"`
+}
+
+// OpnameCount is what one counter found of one product.
+type OpnameCount struct {
+	Counter   string    `json:"counter"`
+	Quantity  int       `json:"quantity"`
+	CountedAt time.Time `json:"countedAt"`
+}
+
+// OpnameScanSession accumulates scanner counts before they are submitted as a stock opname.
+type OpnameScanSession struct {
+	ID         string           `json:"id"`
+	LocationID string           `json:"locationId"`
+	User       string           `json:"user"`
+	Note       string           `json:"note"`
+	Items      []OpnameScanItem `json:"items"`
+	LastScan   *OpnameScanItem  `json:"lastScan,omitempty"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	UpdatedAt  time.Time        `json:"updatedAt"`
+}
+
+// OpnameScanItem is the running count of one product within a scan session.
+type OpnameScanItem struct {
+	ProductID     string    `json:"productId"`
+	ProductName   string    `json:"productName"`
+	ProductSKU    string    `json:"productSku"`
+	Barcode       string    `json:"barcode"`
+	Counted       int       `json:"counted"`
+	LastScannedAt time.Time `json:"lastScannedAt"`
 }
 
 // AppSettings represents lightweight configuration persisted for the desktop app.
@@ -143,8 +681,22 @@ type AppSettings struct {
 	LogoSizeBytes int64  `json:"logoSizeBytes"`
 	LogoMime      string `json:"logoMime"`
 	LogoData      string `json:"logoData,omitempty"`
+	CostingMethod string `json:"costingMethod"`
+	// Restock planning defaults; a zero lead time or cover period, or a nil safety
+	// days or auto threshold, keeps the stored setting on update. Safety days may be 0.
+	RestockLeadTimeDays  int   `json:"restockLeadTimeDays"`
+	RestockSafetyDays    *int  `json:"restockSafetyDays,omitempty"`
+	RestockCoverDays     int   `json:"restockCoverDays"`
+	RestockAutoThreshold *bool `json:"restockAutoThreshold,omitempty"`
 }
 
+// Restock planning defaults used until the settings say otherwise.
+const (
+	DefaultRestockLeadTimeDays = 7
+	DefaultRestockSafetyDays   = 3
+	DefaultRestockCoverDays    = 30
+)
+
 // Courier represents an expedition/shipping partner.
 type Courier struct {
 	ID            string    `json:"id"`
@@ -165,3 +717,41 @@ type Courier struct {
 	CreatedAt     time.Time `json:"createdAt"`
 	UpdatedAt     time.Time `json:"updatedAt"`
 }
+
+// WebhookSubscription is an outbound HTTP endpoint notified about domain events. The
+// signing secret is never serialised; SecretSet tells clients whether one is stored.
+type WebhookSubscription struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"`
+	SecretSet bool      `json:"secretSet"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is an outbox entry holding one event for one subscription.
+type WebhookDelivery struct {
+	ID             string                `json:"id"`
+	SubscriptionID string                `json:"subscriptionId"`
+	EventID        string                `json:"eventId"`
+	EventType      string                `json:"eventType"`
+	Payload        string                `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  *time.Time            `json:"nextAttemptAt"`
+	LastStatusCode int                   `json:"lastStatusCode"`
+	LastError      string                `json:"lastError"`
+	CreatedAt      time.Time             `json:"createdAt"`
+	DeliveredAt    *time.Time            `json:"deliveredAt"`
+}
@@ -0,0 +1,126 @@
+package events
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Type names a domain event. The values are part of the public webhook contract.
+type Type string
+
+const (
+	OrderCreated      Type = "order.created"
+	OrderDeleted      Type = "order.deleted"
+	StockAdjusted     Type = "stock.adjusted"
+	StockLow          Type = "stock.low"
+	OpnamePerformed   Type = "opname.performed"
+	StockDrift        Type = "stock.drift"
+	ProductCreated    Type = "product.created"
+	ProductUpdated    Type = "product.updated"
+	ProductArchived   Type = "product.archived"
+	ProductUnarchived Type = "product.unarchived"
+	ProductDeleted    Type = "product.deleted"
+	SettingsUpdated   Type = "settings.updated"
+)
+
+// Types lists every event type that can be subscribed to.
+func Types() []Type {
+	return []Type{OrderCreated, OrderDeleted, StockAdjusted, StockLow, OpnamePerformed, StockDrift,
+		ProductCreated, ProductUpdated, ProductArchived, ProductUnarchived, ProductDeleted, SettingsUpdated}
+}
+
+// Event is a single domain occurrence published by the services.
+type Event struct {
+	ID         string    `json:"id"`
+	Type       Type      `json:"type"`
+	OccurredAt time.Time `json:"occurredAt"`
+	Data       any       `json:"data"`
+}
+
+// Handler reacts to a published event. Handlers run synchronously on the publisher's
+// goroutine, so they should only record the event and defer slow work.
+type Handler func(ctx context.Context, event Event)
+
+// Bus is an in-process publish/subscribe hub for domain events.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers a handler for every future event.
+func (b *Bus) Subscribe(handler Handler) {
+	if b == nil || handler == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+// Publish stamps the event and hands it to every subscriber. A nil bus is a no-op so
+// services can be constructed without eventing.
+func (b *Bus) Publish(ctx context.Context, eventType Type, data any) {
+	if b == nil {
+		return
+	}
+	event := Event{ID: uuid.New().String(), Type: eventType, OccurredAt: time.Now().UTC(), Data: data}
+
+	b.mu.RLock()
+	handlers := append([]Handler(nil), b.handlers...)
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("event handler panic on %s: %v", event.Type, r)
+				}
+			}()
+			handler(ctx, event)
+		}()
+	}
+}
+
+// StockAdjustedData is the payload of stock.adjusted.
+type StockAdjustedData struct {
+	ProductID     string `json:"productId"`
+	Name          string `json:"name"`
+	SKU           string `json:"sku"`
+	Delta         int    `json:"delta"`
+	Reason        string `json:"reason"`
+	PreviousStock int    `json:"previousStock"`
+	Stock         int    `json:"stock"`
+}
+
+// ProductChangedData is the payload of the product.* events. Clients refetch the
+// product when they need more than its name.
+type ProductChangedData struct {
+	ProductID string `json:"productId"`
+	Name      string `json:"name"`
+	SKU       string `json:"sku"`
+}
+
+// StockLowData is the payload of stock.low, sent when stock drops to or below the threshold.
+type StockLowData struct {
+	ProductID         string `json:"productId"`
+	Name              string `json:"name"`
+	SKU               string `json:"sku"`
+	Stock             int    `json:"stock"`
+	LowStockThreshold int    `json:"lowStockThreshold"`
+}
+
+// StockDriftData is the payload of stock.drift, sent when the periodic reconciliation
+// finds products whose stock disagrees with the ledger or their orders.
+type StockDriftData struct {
+	CheckedAt  time.Time `json:"checkedAt"`
+	Products   int       `json:"products"`
+	ProductIDs []string  `json:"productIds"`
+}
@@ -0,0 +1,72 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// Stream fans bus events out to live listeners such as browser tabs. It keeps the
+// most recent events so a listener that reconnects can catch up on what it missed.
+type Stream struct {
+	mu        sync.Mutex
+	listeners map[chan Event]struct{}
+	recent    []Event
+	backlog   int
+}
+
+// NewStream subscribes a stream to the bus, remembering up to backlog events.
+func NewStream(bus *Bus, backlog int) *Stream {
+	s := &Stream{listeners: make(map[chan Event]struct{}), backlog: backlog}
+	bus.Subscribe(s.publish)
+	return s
+}
+
+// publish records the event and offers it to every listener. A listener whose buffer
+// is full is dropped and its channel closed rather than holding up the publisher; it
+// reconnects and replays what it missed from the recent events.
+func (s *Stream) publish(_ context.Context, event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.backlog > 0 {
+		s.recent = append(s.recent, event)
+		if len(s.recent) > s.backlog {
+			s.recent = append([]Event(nil), s.recent[len(s.recent)-s.backlog:]...)
+		}
+	}
+	for ch := range s.listeners {
+		select {
+		case ch <- event:
+		default:
+			delete(s.listeners, ch)
+			close(ch)
+		}
+	}
+}
+
+// Listen registers a listener. When lastEventID names a remembered event, the events
+// published after it are returned for replay; an unknown id replays nothing. events
+// is closed when the listener falls too far behind. Call cancel once the listener goes
+// away.
+func (s *Stream) Listen(lastEventID string) (missed []Event, events <-chan Event, cancel func()) {
+	ch := make(chan Event, 64)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lastEventID != "" {
+		for i, event := range s.recent {
+			if event.ID == lastEventID {
+				missed = append(missed, s.recent[i+1:]...)
+				break
+			}
+		}
+	}
+	s.listeners[ch] = struct{}{}
+	var once sync.Once
+	cancel = func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.listeners, ch)
+			s.mu.Unlock()
+		})
+	}
+	return missed, ch, cancel
+}
@@ -0,0 +1,215 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"smartseller-lite-starter/internal/domain"
+)
+
+// AdjustmentReasonPrefix prefixes the mutation reason of a manual adjustment; the
+// reason code follows it.
+const AdjustmentReasonPrefix = "adjust:"
+
+// ErrAdjustmentReasonInUse is returned when deleting a reason adjustments were booked under.
+var ErrAdjustmentReasonInUse = errors.New("alasan penyesuaian sudah dipakai; nonaktifkan saja")
+
+// AdjustmentRepository stores adjustment reasons and the manual adjustments booked
+// under them.
+type AdjustmentRepository struct {
+	db *sql.DB
+}
+
+func NewAdjustmentRepository(db *sql.DB) *AdjustmentRepository {
+	return &AdjustmentRepository{db: db}
+}
+
+// defaultAdjustmentReasons are seeded as system reasons on every start.
+var defaultAdjustmentReasons = []domain.AdjustmentReason{
+	{Code: "damaged", Label: "Rusak", Direction: domain.AdjustmentOut, RequiresNote: true},
+	{Code: "lost", Label: "Hilang", Direction: domain.AdjustmentOut, RequiresNote: true},
+	{Code: "expired", Label: "Kedaluwarsa", Direction: domain.AdjustmentOut},
+	{Code: "sample", Label: "Sampel", Direction: domain.AdjustmentOut},
+	{Code: "gift", Label: "Hadiah/giveaway", Direction: domain.AdjustmentOut},
+	{Code: "found", Label: "Ditemukan", Direction: domain.AdjustmentIn},
+	{Code: "correction", Label: "Koreksi", Direction: domain.AdjustmentBoth, RequiresNote: true},
+	{Code: DefaultAdjustmentReason, Label: "Lainnya", Direction: domain.AdjustmentBoth},
+}
+
+// DefaultAdjustmentReason is the system reason of adjustments made without one.
+const DefaultAdjustmentReason = "other"
+
+const adjustmentReasonColumns = `code, label, direction, requires_note, active, is_system, sort_order, created_at, updated_at`
+
+func scanAdjustmentReason(row rowScanner) (*domain.AdjustmentReason, error) {
+	var a domain.AdjustmentReason
+	var created, updated string
+	if err := row.Scan(&a.Code, &a.Label, &a.Direction, &a.RequiresNote, &a.Active, &a.IsSystem, &a.SortOrder, &created, &updated); err != nil {
+		return nil, err
+	}
+	a.CreatedAt, _ = time.Parse(time.RFC3339, created)
+	a.UpdatedAt, _ = time.Parse(time.RFC3339, updated)
+	return &a, nil
+}
+
+// EnsureDefaultReasons adds the system reasons missing from the table. Reasons already
+// present keep their label and settings.
+func (r *AdjustmentRepository) EnsureDefaultReasons(ctx context.Context) error {
+	const stmt = `INSERT IGNORE INTO adjustment_reasons (` + adjustmentReasonColumns + `) VALUES (?, ?, ?, ?, TRUE, TRUE, ?, ?, ?);`
+	now := time.Now().UTC().Format(time.RFC3339)
+	for i, reason := range defaultAdjustmentReasons {
+		if _, err := r.db.ExecContext(ctx, stmt, reason.Code, reason.Label, reason.Direction, reason.RequiresNote, (i+1)*10, now, now); err != nil {
+			return fmt.Errorf("seed adjustment reason %s: %w", reason.Code, err)
+		}
+	}
+	return nil
+}
+
+// ListReasons returns every reason in display order.
+func (r *AdjustmentRepository) ListReasons(ctx context.Context) ([]domain.AdjustmentReason, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+adjustmentReasonColumns+` FROM adjustment_reasons ORDER BY sort_order, label;`)
+	if err != nil {
+		return nil, fmt.Errorf("list adjustment reasons: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]domain.AdjustmentReason, 0)
+	for rows.Next() {
+		a, err := scanAdjustmentReason(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan adjustment reason: %w", err)
+		}
+		items = append(items, *a)
+	}
+	return items, rows.Err()
+}
+
+func (r *AdjustmentRepository) GetReason(ctx context.Context, code string) (*domain.AdjustmentReason, error) {
+	a, err := scanAdjustmentReason(r.db.QueryRowContext(ctx, `SELECT `+adjustmentReasonColumns+` FROM adjustment_reasons WHERE code = ?;`, code))
+	if err != nil {
+		return nil, fmt.Errorf("get adjustment reason: %w", err)
+	}
+	return a, nil
+}
+
+// SaveReason inserts or updates a reason. Whether a reason is a system reason never
+// changes through here.
+func (r *AdjustmentRepository) SaveReason(ctx context.Context, a *domain.AdjustmentReason) (*domain.AdjustmentReason, error) {
+	const stmt = `INSERT INTO adjustment_reasons (` + adjustmentReasonColumns + `) VALUES (?, ?, ?, ?, ?, FALSE, ?, ?, ?)
+        ON DUPLICATE KEY UPDATE label = VALUES(label), direction = VALUES(direction), requires_note = VALUES(requires_note),
+            active = VALUES(active), sort_order = VALUES(sort_order), updated_at = VALUES(updated_at);`
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := r.db.ExecContext(ctx, stmt, a.Code, a.Label, a.Direction, a.RequiresNote, a.Active, a.SortOrder, now, now); err != nil {
+		return nil, fmt.Errorf("save adjustment reason: %w", err)
+	}
+	return r.GetReason(ctx, a.Code)
+}
+
+// DeleteReason removes a reason no adjustment was booked under.
+func (r *AdjustmentRepository) DeleteReason(ctx context.Context, code string) error {
+	var used int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM stock_adjustments WHERE reason_code = ?;`, code).Scan(&used); err != nil {
+		return fmt.Errorf("count adjustment reason uses: %w", err)
+	}
+	if used > 0 {
+		return ErrAdjustmentReasonInUse
+	}
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM adjustment_reasons WHERE code = ?;`, code); err != nil {
+		return fmt.Errorf("delete adjustment reason: %w", err)
+	}
+	return nil
+}
+
+// AdjustmentEntry describes a manual adjustment booked under a reason code.
+type AdjustmentEntry struct {
+	Move       StockMove
+	ReasonCode string
+	Actor      string
+}
+
+// Adjust applies the stock change and records it against its reason in one
+// transaction. The recorded value is what the units moved were worth: negative for
+// stock written off, positive for stock found.
+func (r *AdjustmentRepository) Adjust(ctx context.Context, entry AdjustmentEntry) (adj *StockAdjustment, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			adj = nil
+		}
+	}()
+
+	move := entry.Move
+	move.Reason = AdjustmentReasonPrefix + entry.ReasonCode
+	adj, err = moveStock(ctx, tx, move)
+	if err != nil {
+		return nil, err
+	}
+	value := adj.Cost
+	if move.Delta < 0 {
+		value = -value
+	}
+	const stmt = `INSERT INTO stock_adjustments (id, product_id, location_id, reason_code, delta, value, note, actor, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	if _, err = tx.ExecContext(ctx, stmt, uuid.New().String(), move.ProductID, nullIfEmpty(adj.LocationID), entry.ReasonCode, move.Delta, value, nullIfEmpty(move.Note), nullIfEmpty(entry.Actor), time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return nil, fmt.Errorf("insert stock adjustment: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit stock adjustment: %w", err)
+	}
+	return adj, nil
+}
+
+// AdjustmentDay totals the adjustments booked under one reason on one UTC day. Units
+// are split by direction; Value is signed like the adjustments it sums.
+type AdjustmentDay struct {
+	Day        string
+	ReasonCode string
+	Count      int
+	UnitsIn    int
+	UnitsOut   int
+	Value      float64
+}
+
+// DailyTotals sums adjustments per reason per day, oldest first. Nil bounds leave that
+// side open.
+func (r *AdjustmentRepository) DailyTotals(ctx context.Context, start, end *time.Time) ([]AdjustmentDay, error) {
+	stmt := `SELECT SUBSTRING(created_at, 1, 10) AS day, reason_code, COUNT(*),
+            IFNULL(SUM(CASE WHEN delta > 0 THEN delta ELSE 0 END), 0),
+            IFNULL(SUM(CASE WHEN delta < 0 THEN -delta ELSE 0 END), 0),
+            IFNULL(SUM(value), 0)
+        FROM stock_adjustments WHERE 1 = 1`
+	args := make([]any, 0, 2)
+	if start != nil {
+		stmt += " AND created_at >= ?"
+		args = append(args, start.UTC().Format(time.RFC3339))
+	}
+	if end != nil {
+		stmt += " AND created_at <= ?"
+		args = append(args, end.UTC().Format(time.RFC3339))
+	}
+	stmt += " GROUP BY day, reason_code ORDER BY day, reason_code;"
+
+	rows, err := r.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query adjustment totals: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]AdjustmentDay, 0)
+	for rows.Next() {
+		var d AdjustmentDay
+		if err := rows.Scan(&d.Day, &d.ReasonCode, &d.Count, &d.UnitsIn, &d.UnitsOut, &d.Value); err != nil {
+			return nil, fmt.Errorf("scan adjustment totals: %w", err)
+		}
+		items = append(items, d)
+	}
+	return items, rows.Err()
+}
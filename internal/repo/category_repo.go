@@ -0,0 +1,279 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+
+	"smartseller-lite-starter/internal/domain"
+)
+
+type CategoryRepository struct {
+	db *sql.DB
+}
+
+func NewCategoryRepository(db *sql.DB) *CategoryRepository {
+	return &CategoryRepository{db: db}
+}
+
+// categoryPathSeparator joins category names in paths and splits legacy free-text
+// categories such as "Hijab > Segi Empat" into a hierarchy.
+const categoryPathSeparator = " > "
+
+// Slugify lower-cases name and replaces every run of non-alphanumerics with a dash.
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// SplitCategoryPath turns "Hijab > Segi Empat" into its trimmed, non-empty names.
+func SplitCategoryPath(raw string) []string {
+	parts := strings.Split(raw, ">")
+	names := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if name := strings.Join(strings.Fields(part), " "); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// List returns every category in tree order (parents before their children, siblings
+// by sort order then name) with Path, Depth and the number of active products.
+func (r *CategoryRepository) List(ctx context.Context) ([]domain.Category, error) {
+	const stmt = `SELECT id, IFNULL(parent_id,''), name, slug, sort_order, created_at, updated_at,
+        (SELECT COUNT(*) FROM products p WHERE p.category_id = categories.id AND p.deleted_at IS NULL AND p.parent_id IS NULL)
+        FROM categories ORDER BY sort_order, name;`
+	rows, err := r.db.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, fmt.Errorf("list categories: %w", err)
+	}
+	defer rows.Close()
+
+	flat := make([]domain.Category, 0)
+	for rows.Next() {
+		var c domain.Category
+		var created, updated string
+		if err := rows.Scan(&c.ID, &c.ParentID, &c.Name, &c.Slug, &c.SortOrder, &created, &updated, &c.ProductCount); err != nil {
+			return nil, fmt.Errorf("scan category: %w", err)
+		}
+		c.CreatedAt, _ = time.Parse(time.RFC3339, created)
+		c.UpdatedAt, _ = time.Parse(time.RFC3339, updated)
+		flat = append(flat, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate categories: %w", err)
+	}
+	return orderCategoryTree(flat), nil
+}
+
+func orderCategoryTree(flat []domain.Category) []domain.Category {
+	known := make(map[string]bool, len(flat))
+	for _, c := range flat {
+		known[c.ID] = true
+	}
+	children := make(map[string][]domain.Category)
+	for _, c := range flat {
+		parent := c.ParentID
+		if !known[parent] {
+			parent = ""
+		}
+		children[parent] = append(children[parent], c)
+	}
+
+	ordered := make([]domain.Category, 0, len(flat))
+	visited := make(map[string]bool, len(flat))
+	var walk func(parentID, prefix string, depth int)
+	walk = func(parentID, prefix string, depth int) {
+		for _, c := range children[parentID] {
+			if visited[c.ID] {
+				continue
+			}
+			visited[c.ID] = true
+			c.Depth = depth
+			c.Path = c.Name
+			if prefix != "" {
+				c.Path = prefix + categoryPathSeparator + c.Name
+			}
+			ordered = append(ordered, c)
+			walk(c.ID, c.Path, depth+1)
+		}
+	}
+	walk("", "", 0)
+	return ordered
+}
+
+func (r *CategoryRepository) Get(ctx context.Context, id string) (*domain.Category, error) {
+	return r.find(ctx, func(c domain.Category) bool { return c.ID == id })
+}
+
+func (r *CategoryRepository) GetBySlug(ctx context.Context, slug string) (*domain.Category, error) {
+	return r.find(ctx, func(c domain.Category) bool { return c.Slug == slug })
+}
+
+func (r *CategoryRepository) find(ctx context.Context, match func(domain.Category) bool) (*domain.Category, error) {
+	items, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		if match(items[i]) {
+			return &items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("get category: %w", sql.ErrNoRows)
+}
+
+// SlugInUse reports whether another category already uses the slug.
+func (r *CategoryRepository) SlugInUse(ctx context.Context, slug, excludeID string) (bool, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM categories WHERE slug = ? AND id <> ?;`, slug, excludeID).Scan(&count); err != nil {
+		return false, fmt.Errorf("check category slug: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (r *CategoryRepository) Create(ctx context.Context, c *domain.Category) (*domain.Category, error) {
+	now := time.Now().UTC()
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	c.CreatedAt = now
+	c.UpdatedAt = now
+	const stmt = `INSERT INTO categories (id, parent_id, name, slug, sort_order, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?);`
+	if _, err := r.db.ExecContext(ctx, stmt, c.ID, nullIfEmpty(c.ParentID), c.Name, c.Slug, c.SortOrder, now.Format(time.RFC3339), now.Format(time.RFC3339)); err != nil {
+		return nil, fmt.Errorf("insert category: %w", err)
+	}
+	return c, nil
+}
+
+func (r *CategoryRepository) Update(ctx context.Context, c *domain.Category) (*domain.Category, error) {
+	c.UpdatedAt = time.Now().UTC()
+	const stmt = `UPDATE categories SET parent_id = ?, name = ?, slug = ?, sort_order = ?, updated_at = ? WHERE id = ?;`
+	if _, err := r.db.ExecContext(ctx, stmt, nullIfEmpty(c.ParentID), c.Name, c.Slug, c.SortOrder, c.UpdatedAt.Format(time.RFC3339), c.ID); err != nil {
+		return nil, fmt.Errorf("update category: %w", err)
+	}
+	// products.category keeps the name for search and older backups.
+	if _, err := r.db.ExecContext(ctx, `UPDATE products SET category = ? WHERE category_id = ?;`, c.Name, c.ID); err != nil {
+		return nil, fmt.Errorf("rename product category: %w", err)
+	}
+	return c, nil
+}
+
+// Delete removes a category that has no subcategories and no products, archived ones included.
+func (r *CategoryRepository) Delete(ctx context.Context, id string) error {
+	var children, products int
+	if err := r.db.QueryRowContext(ctx, `SELECT (SELECT COUNT(*) FROM categories WHERE parent_id = ?), (SELECT COUNT(*) FROM products WHERE category_id = ?);`, id, id).Scan(&children, &products); err != nil {
+		return fmt.Errorf("check category usage: %w", err)
+	}
+	if children > 0 {
+		return errors.New("kategori masih memiliki subkategori")
+	}
+	if products > 0 {
+		return fmt.Errorf("kategori masih dipakai %d produk; pindahkan produknya terlebih dahulu", products)
+	}
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM categories WHERE id = ?;`, id); err != nil {
+		return fmt.Errorf("delete category: %w", err)
+	}
+	return nil
+}
+
+// EnsurePath returns the category at the end of names, creating missing levels. Each
+// level is matched by slug, so "Hijab", "hijab " and "HIJAB" resolve to the same row.
+func (r *CategoryRepository) EnsurePath(ctx context.Context, names []string) (*domain.Category, error) {
+	if len(names) == 0 {
+		return nil, errors.New("nama kategori wajib diisi")
+	}
+	items, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bySlug := make(map[string]*domain.Category, len(items))
+	for i := range items {
+		bySlug[items[i].Slug] = &items[i]
+	}
+
+	var current *domain.Category
+	for _, name := range names {
+		slug := Slugify(name)
+		if slug == "" {
+			return nil, fmt.Errorf("nama kategori %q tidak valid", name)
+		}
+		if current != nil {
+			slug = current.Slug + "-" + slug
+		}
+		if existing, ok := bySlug[slug]; ok {
+			current = existing
+			continue
+		}
+		created := &domain.Category{Name: name, Slug: slug}
+		if current != nil {
+			created.ParentID = current.ID
+			created.Depth = current.Depth + 1
+			created.Path = current.Path + categoryPathSeparator + name
+		} else {
+			created.Path = name
+		}
+		if _, err := r.Create(ctx, created); err != nil {
+			return nil, err
+		}
+		bySlug[slug] = created
+		current = created
+	}
+	return current, nil
+}
+
+// AdoptLegacyCategories links products that only carry a free-text category to
+// category rows, creating them on the way. It is idempotent and runs on start-up.
+func (r *CategoryRepository) AdoptLegacyCategories(ctx context.Context) error {
+	rows, err := r.db.QueryContext(ctx, `SELECT DISTINCT category FROM products WHERE category_id IS NULL AND TRIM(IFNULL(category,'')) <> '';`)
+	if err != nil {
+		return fmt.Errorf("list legacy categories: %w", err)
+	}
+	legacy := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan legacy category: %w", err)
+		}
+		legacy = append(legacy, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate legacy categories: %w", err)
+	}
+
+	for _, raw := range legacy {
+		names := SplitCategoryPath(raw)
+		if len(names) == 0 || Slugify(strings.Join(names, " ")) == "" {
+			continue
+		}
+		category, err := r.EnsurePath(ctx, names)
+		if err != nil {
+			return err
+		}
+		if _, err := r.db.ExecContext(ctx, `UPDATE products SET category_id = ?, category = ? WHERE category_id IS NULL AND category = ?;`, category.ID, category.Name, raw); err != nil {
+			return fmt.Errorf("link legacy category: %w", err)
+		}
+	}
+	return nil
+}
@@ -14,6 +14,9 @@ import (
 	"smartseller-lite-starter/internal/domain"
 )
 
+// ErrCustomerNotFound is returned when a customer id matches no contact.
+var ErrCustomerNotFound = errors.New("kontak tidak ditemukan")
+
 type CustomerRepository struct {
 	db *sql.DB
 }
@@ -244,3 +247,118 @@ func (r *CustomerRepository) ReplaceAll(ctx context.Context, items []domain.Cust
 	}
 	return nil
 }
+
+// OrderCounts returns, per customer, how many orders name them as buyer or recipient.
+func (r *CustomerRepository) OrderCounts(ctx context.Context) (map[string]int, error) {
+	const stmt = `SELECT customer_id, COUNT(DISTINCT order_id) FROM (
+            SELECT buyer_id AS customer_id, id AS order_id FROM orders
+            UNION ALL
+            SELECT recipient_id, id FROM orders
+        ) refs GROUP BY customer_id;`
+	rows, err := r.db.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, fmt.Errorf("count customer orders: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var id string
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, fmt.Errorf("scan customer orders: %w", err)
+		}
+		counts[id] = count
+	}
+	return counts, rows.Err()
+}
+
+// Merge folds duplicate customers into the survivor in one transaction. The survivor
+// and duplicates are locked and read, combine fills in the survivor from the
+// duplicates as they are now, orders naming a duplicate as buyer or recipient are
+// re-pointed to the survivor, the survivor is saved, and the duplicates are deleted.
+// It returns the saved survivor and how many orders were re-pointed.
+func (r *CustomerRepository) Merge(ctx context.Context, survivorID string, duplicateIDs []string, combine func(survivor *domain.Customer, duplicates []domain.Customer)) (survivor *domain.Customer, moved int, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	ids := append([]string{survivorID}, duplicateIDs...)
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	const columns = `id, type, name, phone, email, address, city, province, postal, notes, created_at, updated_at`
+	rows, err := tx.QueryContext(ctx, `SELECT `+columns+` FROM customers WHERE id IN (`+placeholders+`) ORDER BY id FOR UPDATE;`, args...)
+	if err != nil {
+		err = fmt.Errorf("lock customers: %w", err)
+		return nil, 0, err
+	}
+	locked := make(map[string]domain.Customer, len(ids))
+	for rows.Next() {
+		var c domain.Customer
+		var created, updated string
+		if err = rows.Scan(&c.ID, &c.Type, &c.Name, &c.Phone, &c.Email, &c.Address, &c.City, &c.Province, &c.Postal, &c.Notes, &created, &updated); err != nil {
+			rows.Close()
+			err = fmt.Errorf("scan customer: %w", err)
+			return nil, 0, err
+		}
+		c.CreatedAt, _ = time.Parse(time.RFC3339, created)
+		c.UpdatedAt, _ = time.Parse(time.RFC3339, updated)
+		locked[c.ID] = c
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		err = fmt.Errorf("lock customers: %w", err)
+		return nil, 0, err
+	}
+	if len(locked) != len(ids) {
+		for _, id := range ids {
+			if _, ok := locked[id]; !ok {
+				err = fmt.Errorf("%w: %s", ErrCustomerNotFound, id)
+				return nil, 0, err
+			}
+		}
+	}
+	merged := locked[survivorID]
+	survivor = &merged
+	duplicates := make([]domain.Customer, 0, len(duplicateIDs))
+	for _, id := range duplicateIDs {
+		duplicates = append(duplicates, locked[id])
+	}
+	combine(survivor, duplicates)
+
+	dupArgs := args[1:]
+	dupPlaceholders := strings.TrimSuffix(strings.Repeat("?,", len(duplicateIDs)), ",")
+	if err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM orders WHERE buyer_id IN (`+dupPlaceholders+`) OR recipient_id IN (`+dupPlaceholders+`);`, append(append([]any{}, dupArgs...), dupArgs...)...).Scan(&moved); err != nil {
+		return nil, 0, fmt.Errorf("count merged orders: %w", err)
+	}
+	for _, column := range []string{"buyer_id", "recipient_id"} {
+		stmt := `UPDATE orders SET ` + column + ` = ? WHERE ` + column + ` IN (` + dupPlaceholders + `);`
+		if _, err = tx.ExecContext(ctx, stmt, append([]any{survivor.ID}, dupArgs...)...); err != nil {
+			return nil, 0, fmt.Errorf("re-point orders: %w", err)
+		}
+	}
+
+	survivor.UpdatedAt = time.Now().UTC()
+	const update = `UPDATE customers SET type = ?, name = ?, phone = ?, email = ?, address = ?, city = ?, province = ?, postal = ?, notes = ?, updated_at = ? WHERE id = ?;`
+	if _, err = tx.ExecContext(ctx, update, survivor.Type, survivor.Name, survivor.Phone, survivor.Email, survivor.Address, survivor.City, survivor.Province, survivor.Postal, survivor.Notes, survivor.UpdatedAt.Format(time.RFC3339), survivor.ID); err != nil {
+		return nil, 0, fmt.Errorf("update customer: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM customers WHERE id IN (`+dupPlaceholders+`);`, dupArgs...); err != nil {
+		return nil, 0, fmt.Errorf("delete merged customers: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, 0, fmt.Errorf("commit customer merge: %w", err)
+	}
+	return survivor, moved, nil
+}
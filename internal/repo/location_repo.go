@@ -0,0 +1,163 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"smartseller-lite-starter/internal/domain"
+)
+
+type LocationRepository struct {
+	db *sql.DB
+}
+
+func NewLocationRepository(db *sql.DB) *LocationRepository {
+	return &LocationRepository{db: db}
+}
+
+const locationColumns = `l.id, l.code, l.name, l.kind, l.is_default, IFNULL(l.notes,''), l.created_at, l.updated_at,
+        (SELECT IFNULL(SUM(s.quantity),0) FROM product_location_stock s WHERE s.location_id = l.id)`
+
+func scanLocation(row rowScanner) (*domain.Location, error) {
+	var l domain.Location
+	var created, updated string
+	if err := row.Scan(&l.ID, &l.Code, &l.Name, &l.Kind, &l.IsDefault, &l.Notes, &created, &updated, &l.StockUnits); err != nil {
+		return nil, err
+	}
+	l.CreatedAt, _ = time.Parse(time.RFC3339, created)
+	l.UpdatedAt, _ = time.Parse(time.RFC3339, updated)
+	return &l, nil
+}
+
+// List returns all locations, the default first.
+func (r *LocationRepository) List(ctx context.Context) ([]domain.Location, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+locationColumns+` FROM locations l ORDER BY l.is_default DESC, l.name;`)
+	if err != nil {
+		return nil, fmt.Errorf("list locations: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]domain.Location, 0)
+	for rows.Next() {
+		l, err := scanLocation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan location: %w", err)
+		}
+		items = append(items, *l)
+	}
+	return items, rows.Err()
+}
+
+// Find returns the location whose id or code is ref.
+func (r *LocationRepository) Find(ctx context.Context, ref string) (*domain.Location, error) {
+	l, err := scanLocation(r.db.QueryRowContext(ctx, `SELECT `+locationColumns+` FROM locations l WHERE l.id = ? OR l.code = ? LIMIT 1;`, ref, strings.ToUpper(ref)))
+	if err != nil {
+		return nil, fmt.Errorf("get location: %w", err)
+	}
+	return l, nil
+}
+
+func (r *LocationRepository) Default(ctx context.Context) (*domain.Location, error) {
+	l, err := scanLocation(r.db.QueryRowContext(ctx, `SELECT `+locationColumns+` FROM locations l WHERE l.is_default = TRUE LIMIT 1;`))
+	if err != nil {
+		return nil, fmt.Errorf("get default location: %w", err)
+	}
+	return l, nil
+}
+
+// Save inserts or updates a location. Marking it default clears the flag elsewhere.
+func (r *LocationRepository) Save(ctx context.Context, l *domain.Location) (*domain.Location, error) {
+	now := time.Now().UTC()
+	l.UpdatedAt = now
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if l.IsDefault {
+		if _, err = tx.ExecContext(ctx, `UPDATE locations SET is_default = FALSE WHERE id <> ?;`, l.ID); err != nil {
+			err = fmt.Errorf("clear default location: %w", err)
+			return nil, err
+		}
+	}
+	if l.ID == "" {
+		l.ID = uuid.New().String()
+		l.CreatedAt = now
+		const stmt = `INSERT INTO locations (id, code, name, kind, is_default, notes, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`
+		_, err = tx.ExecContext(ctx, stmt, l.ID, l.Code, l.Name, l.Kind, l.IsDefault, l.Notes, now.Format(time.RFC3339), now.Format(time.RFC3339))
+	} else {
+		const stmt = `UPDATE locations SET code = ?, name = ?, kind = ?, is_default = ?, notes = ?, updated_at = ? WHERE id = ?;`
+		_, err = tx.ExecContext(ctx, stmt, l.Code, l.Name, l.Kind, l.IsDefault, l.Notes, now.Format(time.RFC3339), l.ID)
+	}
+	if err != nil {
+		if dup := duplicateCodeError(err); dup != nil {
+			err = fmt.Errorf("kode lokasi %s sudah digunakan", l.Code)
+			return nil, err
+		}
+		err = fmt.Errorf("save location: %w", err)
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("commit location: %w", err)
+		return nil, err
+	}
+	return l, nil
+}
+
+// Delete removes an empty location that is not the default.
+func (r *LocationRepository) Delete(ctx context.Context, id string) error {
+	l, err := r.Find(ctx, id)
+	if err != nil {
+		return err
+	}
+	if l.IsDefault {
+		return errors.New("lokasi default tidak dapat dihapus")
+	}
+	if l.StockUnits > 0 {
+		return fmt.Errorf("lokasi %s masih menyimpan %d unit; pindahkan stoknya terlebih dahulu", l.Name, l.StockUnits)
+	}
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM product_location_stock WHERE location_id = ?;`, l.ID); err != nil {
+		return fmt.Errorf("clear location stock: %w", err)
+	}
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM locations WHERE id = ?;`, l.ID); err != nil {
+		return fmt.Errorf("delete location: %w", err)
+	}
+	return nil
+}
+
+// EnsureDefault makes sure a default location exists, creating "Gudang Utama" on
+// first start, and places any stock not yet assigned to a location there. It is
+// idempotent and runs on start-up.
+func (r *LocationRepository) EnsureDefault(ctx context.Context) error {
+	if _, err := r.Default(ctx); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		var firstID string
+		err := r.db.QueryRowContext(ctx, `SELECT id FROM locations ORDER BY created_at LIMIT 1;`).Scan(&firstID)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			if _, err := r.Save(ctx, &domain.Location{Code: "MAIN", Name: "Gudang Utama", Kind: domain.LocationWarehouse, IsDefault: true}); err != nil {
+				return err
+			}
+		case err != nil:
+			return fmt.Errorf("select first location: %w", err)
+		default:
+			if _, err := r.db.ExecContext(ctx, `UPDATE locations SET is_default = TRUE WHERE id = ?;`, firstID); err != nil {
+				return fmt.Errorf("set default location: %w", err)
+			}
+		}
+	}
+	return reconcileLocationStock(ctx, r.db, "")
+}
@@ -0,0 +1,372 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"smartseller-lite-starter/internal/domain"
+)
+
+// ErrOpnameSessionState is returned when a session is not in a status that allows the change.
+var ErrOpnameSessionState = errors.New("status sesi opname tidak mengizinkan perubahan ini")
+
+// OpnameSessionCount is the quantity one counter found of one product.
+type OpnameSessionCount struct {
+	ProductID string
+	Quantity  int
+}
+
+// OpnameSessionTransition moves a session from one of From to To. Actor is stored in
+// the column matching To; SystemStock, when set, snapshots the stock of each product
+// for the variance review and ZeroCounts records a zero count under Actor for the
+// products listed.
+type OpnameSessionTransition struct {
+	ID            string
+	From          []domain.OpnameSessionStatus
+	To            domain.OpnameSessionStatus
+	Actor         string
+	SystemStock   map[string]int
+	ZeroCounts    []string
+	StockOpnameID string
+}
+
+const opnameSessionColumns = `id, code, IFNULL(location_id,''), IFNULL(note,''), status, blind_count, opened_by,
+        IFNULL(reviewed_by,''), IFNULL(approved_by,''), IFNULL(cancelled_by,''), IFNULL(stock_opname_id,''),
+        opened_at, review_at, closed_at, updated_at`
+
+// CreateSession stores a new session in the open status with the products in scope
+// and numbers it OPN-YYYYMMDD-NNN.
+func (r *StockOpnameRepository) CreateSession(ctx context.Context, session *domain.OpnameSession, productIDs []string) (err error) {
+	now := time.Now().UTC()
+	if session.ID == "" {
+		session.ID = uuid.New().String()
+	}
+	session.Status = domain.OpnameSessionOpen
+	session.OpenedAt = now
+	session.UpdatedAt = now
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	prefix := fmt.Sprintf("OPN-%s-", now.Format("20060102"))
+	var last int
+	if err = tx.QueryRowContext(ctx, `SELECT IFNULL(MAX(CAST(SUBSTRING(code, ?) AS UNSIGNED)), 0) FROM opname_sessions WHERE code LIKE ? FOR UPDATE;`, len(prefix)+1, prefix+"%").Scan(&last); err != nil {
+		err = fmt.Errorf("number opname session: %w", err)
+		return err
+	}
+	session.Code = fmt.Sprintf("%s%03d", prefix, last+1)
+
+	const stmt = `INSERT INTO opname_sessions (id, code, location_id, note, status, blind_count, opened_by, opened_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	if _, err = tx.ExecContext(ctx, stmt, session.ID, session.Code, nullIfEmpty(session.LocationID), nullIfEmpty(session.Note), session.Status, session.BlindCount, session.OpenedBy, now.Format(time.RFC3339), now.Format(time.RFC3339)); err != nil {
+		err = fmt.Errorf("insert opname session: %w", err)
+		return err
+	}
+	for _, productID := range productIDs {
+		if _, err = tx.ExecContext(ctx, `INSERT IGNORE INTO opname_session_items (session_id, product_id) VALUES (?, ?);`, session.ID, productID); err != nil {
+			err = fmt.Errorf("insert opname session item: %w", err)
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("commit opname session: %w", err)
+		return err
+	}
+	return nil
+}
+
+// SaveSessionCounts replaces what counter found of each product and moves an open
+// session to counting. Counts are only accepted while the session is open or counting.
+func (r *StockOpnameRepository) SaveSessionCounts(ctx context.Context, sessionID, counter string, counts []OpnameSessionCount) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var status domain.OpnameSessionStatus
+	if err = tx.QueryRowContext(ctx, `SELECT status FROM opname_sessions WHERE id = ? FOR UPDATE;`, sessionID).Scan(&status); err != nil {
+		err = fmt.Errorf("select opname session: %w", err)
+		return err
+	}
+	if status != domain.OpnameSessionOpen && status != domain.OpnameSessionCounting {
+		err = fmt.Errorf("%w: sesi berstatus %s", ErrOpnameSessionState, status)
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, c := range counts {
+		if _, err = tx.ExecContext(ctx, `INSERT IGNORE INTO opname_session_items (session_id, product_id) VALUES (?, ?);`, sessionID, c.ProductID); err != nil {
+			err = fmt.Errorf("insert opname session item: %w", err)
+			return err
+		}
+		const stmt = `INSERT INTO opname_session_counts (session_id, product_id, counter, quantity, counted_at) VALUES (?, ?, ?, ?, ?)
+            ON DUPLICATE KEY UPDATE quantity = VALUES(quantity), counted_at = VALUES(counted_at);`
+		if _, err = tx.ExecContext(ctx, stmt, sessionID, c.ProductID, counter, c.Quantity, now); err != nil {
+			err = fmt.Errorf("save opname count: %w", err)
+			return err
+		}
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE opname_sessions SET status = ?, updated_at = ? WHERE id = ?;`, domain.OpnameSessionCounting, now, sessionID); err != nil {
+		err = fmt.Errorf("update opname session: %w", err)
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("commit opname counts: %w", err)
+		return err
+	}
+	return nil
+}
+
+// DeleteSessionCount removes what counter recorded for a product while the session is
+// still being counted.
+func (r *StockOpnameRepository) DeleteSessionCount(ctx context.Context, sessionID, productID, counter string) error {
+	const stmt = `DELETE c FROM opname_session_counts c JOIN opname_sessions s ON s.id = c.session_id
+        WHERE c.session_id = ? AND c.product_id = ? AND c.counter = ? AND s.status IN (?, ?);`
+	res, err := r.db.ExecContext(ctx, stmt, sessionID, productID, counter, domain.OpnameSessionOpen, domain.OpnameSessionCounting)
+	if err != nil {
+		return fmt.Errorf("delete opname count: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: tidak ada hitungan yang bisa dihapus", ErrOpnameSessionState)
+	}
+	return nil
+}
+
+// TransitionSession applies t atomically; the session must still be in one of t.From.
+func (r *StockOpnameRepository) TransitionSession(ctx context.Context, t OpnameSessionTransition) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var status domain.OpnameSessionStatus
+	if err = tx.QueryRowContext(ctx, `SELECT status FROM opname_sessions WHERE id = ? FOR UPDATE;`, t.ID).Scan(&status); err != nil {
+		err = fmt.Errorf("select opname session: %w", err)
+		return err
+	}
+	allowed := false
+	for _, from := range t.From {
+		allowed = allowed || status == from
+	}
+	if !allowed {
+		err = fmt.Errorf("%w: sesi berstatus %s", ErrOpnameSessionState, status)
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, productID := range t.ZeroCounts {
+		const stmt = `INSERT INTO opname_session_counts (session_id, product_id, counter, quantity, counted_at) VALUES (?, ?, ?, 0, ?)
+            ON DUPLICATE KEY UPDATE quantity = 0, counted_at = VALUES(counted_at);`
+		if _, err = tx.ExecContext(ctx, stmt, t.ID, productID, t.Actor, now); err != nil {
+			err = fmt.Errorf("save opname count: %w", err)
+			return err
+		}
+	}
+	if t.SystemStock != nil {
+		if _, err = tx.ExecContext(ctx, `UPDATE opname_session_items SET system_stock = NULL WHERE session_id = ?;`, t.ID); err != nil {
+			err = fmt.Errorf("reset opname system stock: %w", err)
+			return err
+		}
+		for productID, stock := range t.SystemStock {
+			const stmt = `INSERT INTO opname_session_items (session_id, product_id, system_stock) VALUES (?, ?, ?)
+                ON DUPLICATE KEY UPDATE system_stock = VALUES(system_stock);`
+			if _, err = tx.ExecContext(ctx, stmt, t.ID, productID, stock); err != nil {
+				err = fmt.Errorf("snapshot opname system stock: %w", err)
+				return err
+			}
+		}
+	}
+
+	var stmt string
+	args := []any{t.To, now}
+	switch t.To {
+	case domain.OpnameSessionReview:
+		stmt = `UPDATE opname_sessions SET status = ?, updated_at = ?, reviewed_by = ?, review_at = ? WHERE id = ?;`
+		args = append(args, t.Actor, now)
+	case domain.OpnameSessionApplied:
+		stmt = `UPDATE opname_sessions SET status = ?, updated_at = ?, approved_by = ?, stock_opname_id = ?, closed_at = ? WHERE id = ?;`
+		args = append(args, t.Actor, nullIfEmpty(t.StockOpnameID), now)
+	case domain.OpnameSessionCancelled:
+		stmt = `UPDATE opname_sessions SET status = ?, updated_at = ?, cancelled_by = ?, closed_at = ? WHERE id = ?;`
+		args = append(args, t.Actor, now)
+	default:
+		stmt = `UPDATE opname_sessions SET status = ?, updated_at = ?, reviewed_by = NULL, review_at = NULL WHERE id = ?;`
+	}
+	if _, err = tx.ExecContext(ctx, stmt, append(args, t.ID)...); err != nil {
+		err = fmt.Errorf("update opname session: %w", err)
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("commit opname session: %w", err)
+		return err
+	}
+	return nil
+}
+
+// GetSession loads a session with its products, each with every counter's count.
+func (r *StockOpnameRepository) GetSession(ctx context.Context, id string) (*domain.OpnameSession, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+opnameSessionColumns+` FROM opname_sessions WHERE id = ?;`, id)
+	session, err := scanOpnameSession(row)
+	if err != nil {
+		return nil, err
+	}
+
+	const itemStmt = `SELECT i.product_id, IFNULL(p.name,''), IFNULL(p.sku,''), i.system_stock
+        FROM opname_session_items i LEFT JOIN products p ON p.id = i.product_id
+        WHERE i.session_id = ? ORDER BY p.name;`
+	rows, err := r.db.QueryContext(ctx, itemStmt, id)
+	if err != nil {
+		return nil, fmt.Errorf("list opname session items: %w", err)
+	}
+	defer rows.Close()
+	index := make(map[string]int)
+	for rows.Next() {
+		var item domain.OpnameSessionItem
+		var system sql.NullInt64
+		if err := rows.Scan(&item.ProductID, &item.ProductName, &item.ProductSKU, &system); err != nil {
+			return nil, fmt.Errorf("scan opname session item: %w", err)
+		}
+		if system.Valid {
+			stock := int(system.Int64)
+			item.SystemStock = &stock
+		}
+		item.Counts = []domain.OpnameCount{}
+		index[item.ProductID] = len(session.Items)
+		session.Items = append(session.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	countRows, err := r.db.QueryContext(ctx, `SELECT product_id, counter, quantity, counted_at FROM opname_session_counts WHERE session_id = ? ORDER BY counted_at, counter;`, id)
+	if err != nil {
+		return nil, fmt.Errorf("list opname counts: %w", err)
+	}
+	defer countRows.Close()
+	counters := make(map[string]bool)
+	for countRows.Next() {
+		var productID, counted string
+		var count domain.OpnameCount
+		if err := countRows.Scan(&productID, &count.Counter, &count.Quantity, &counted); err != nil {
+			return nil, fmt.Errorf("scan opname count: %w", err)
+		}
+		count.CountedAt, _ = time.Parse(time.RFC3339, counted)
+		idx, ok := index[productID]
+		if !ok {
+			continue
+		}
+		item := &session.Items[idx]
+		item.Counts = append(item.Counts, count)
+		total := count.Quantity
+		if item.Counted != nil {
+			total += *item.Counted
+		}
+		item.Counted = &total
+		if !counters[count.Counter] {
+			counters[count.Counter] = true
+			session.Counters = append(session.Counters, count.Counter)
+		}
+	}
+	sort.Strings(session.Counters)
+	return session, countRows.Err()
+}
+
+// ListSessions returns the most recent sessions without their items, optionally only
+// those in the given statuses.
+func (r *StockOpnameRepository) ListSessions(ctx context.Context, statuses []domain.OpnameSessionStatus, limit int) ([]domain.OpnameSession, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	stmt := `SELECT ` + opnameSessionColumns + ` FROM opname_sessions`
+	args := make([]any, 0, len(statuses)+1)
+	if len(statuses) > 0 {
+		stmt += ` WHERE status IN (` + strings.TrimSuffix(strings.Repeat("?,", len(statuses)), ",") + `)`
+		for _, status := range statuses {
+			args = append(args, status)
+		}
+	}
+	rows, err := r.db.QueryContext(ctx, stmt+` ORDER BY opened_at DESC LIMIT ?;`, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("list opname sessions: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]domain.OpnameSession, 0)
+	for rows.Next() {
+		session, err := scanOpnameSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *session)
+	}
+	return items, rows.Err()
+}
+
+func scanOpnameSession(row interface{ Scan(...any) error }) (*domain.OpnameSession, error) {
+	var session domain.OpnameSession
+	var opened, updated string
+	var review, closed sql.NullString
+	if err := row.Scan(&session.ID, &session.Code, &session.LocationID, &session.Note, &session.Status, &session.BlindCount, &session.OpenedBy,
+		&session.ReviewedBy, &session.ApprovedBy, &session.CancelledBy, &session.StockOpnameID, &opened, &review, &closed, &updated); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("scan opname session: %w", err)
+	}
+	session.OpenedAt, _ = time.Parse(time.RFC3339, opened)
+	session.UpdatedAt, _ = time.Parse(time.RFC3339, updated)
+	session.ReviewAt = parseNullTime(review)
+	session.ClosedAt = parseNullTime(closed)
+	session.Counters = []string{}
+	session.Items = []domain.OpnameSessionItem{}
+	return &session, nil
+}
+
+// SessionByOpname returns the session that posted an opname, with its counters but
+// without items. It returns sql.ErrNoRows for opnames performed directly.
+func (r *StockOpnameRepository) SessionByOpname(ctx context.Context, opnameID string) (*domain.OpnameSession, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+opnameSessionColumns+` FROM opname_sessions WHERE stock_opname_id = ? LIMIT 1;`, opnameID)
+	session, err := scanOpnameSession(row)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, `SELECT DISTINCT counter FROM opname_session_counts WHERE session_id = ? ORDER BY counter;`, session.ID)
+	if err != nil {
+		return nil, fmt.Errorf("list opname counters: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var counter string
+		if err := rows.Scan(&counter); err != nil {
+			return nil, fmt.Errorf("scan opname counter: %w", err)
+		}
+		session.Counters = append(session.Counters, counter)
+	}
+	return session, rows.Err()
+}
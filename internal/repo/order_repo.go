@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -74,7 +75,7 @@ func (r *OrderRepository) ListPaged(ctx context.Context, opts OrderListOptions)
 		listArgs = append(listArgs, pageSize, offset)
 	}
 
-	stmt := "SELECT o.id, o.code, o.buyer_id, o.recipient_id, o.shipment_courier, o.shipment_service, o.shipment_tracking, o.shipment_cost, o.is_buyer_paying_shipping, o.discount_order, o.total, o.profit, o.notes, o.created_at, o.updated_at FROM orders o " + whereClause + " ORDER BY o.created_at DESC" + limitClause + ";"
+	stmt := "SELECT o.id, o.code, IFNULL(o.location_id,''), o.buyer_id, o.recipient_id, o.shipment_courier, o.shipment_service, o.shipment_tracking, o.shipment_cost, o.is_buyer_paying_shipping, o.shipment_status, o.shipment_status_at, o.discount_order, o.total, o.profit, o.notes, o.created_at, o.updated_at FROM orders o " + whereClause + " ORDER BY o.created_at DESC" + limitClause + ";"
 
 	rows, err := r.db.QueryContext(ctx, stmt, listArgs...)
 	if err != nil {
@@ -86,11 +87,13 @@ func (r *OrderRepository) ListPaged(ctx context.Context, opts OrderListOptions)
 	for rows.Next() {
 		var o domain.Order
 		var created, updated string
-		if err := rows.Scan(&o.ID, &o.Code, &o.BuyerID, &o.RecipientID, &o.Shipment.Courier, &o.Shipment.ServiceLevel, &o.Shipment.TrackingCode, &o.Shipment.ShippingCost, &o.Shipment.ShippingByBuyer, &o.DiscountOrder, &o.Total, &o.Profit, &o.Notes, &created, &updated); err != nil {
+		var statusAt sql.NullString
+		if err := rows.Scan(&o.ID, &o.Code, &o.LocationID, &o.BuyerID, &o.RecipientID, &o.Shipment.Courier, &o.Shipment.ServiceLevel, &o.Shipment.TrackingCode, &o.Shipment.ShippingCost, &o.Shipment.ShippingByBuyer, &o.Shipment.Status, &statusAt, &o.DiscountOrder, &o.Total, &o.Profit, &o.Notes, &created, &updated); err != nil {
 			return OrderListResult{}, err
 		}
 		o.CreatedAt, _ = time.Parse(time.RFC3339, created)
 		o.UpdatedAt, _ = time.Parse(time.RFC3339, updated)
+		o.Shipment.StatusUpdatedAt = parseNullTime(statusAt)
 		itemsList, err := r.itemsByOrder(ctx, o.ID)
 		if err != nil {
 			return OrderListResult{}, err
@@ -183,7 +186,12 @@ type OrderListResult struct {
 	Couriers []string         `json:"couriers"`
 }
 
-func (r *OrderRepository) Create(ctx context.Context, o *domain.Order) (*domain.Order, error) {
+// Create stores an order and takes its stock in one transaction, so an order never
+// exists without its stock deducted, nor a bundle with only some components taken.
+// The batches each line consumes, earliest expiry first, decide its cost of goods
+// sold, replacing the list-cost estimate, and the lots it ships from. The returned
+// adjustments are the stock changes posted.
+func (r *OrderRepository) Create(ctx context.Context, o *domain.Order) (_ *domain.Order, adjustments []*StockAdjustment, err error) {
 	now := time.Now().UTC()
 	if o.ID == "" {
 		o.ID = uuid.New().String()
@@ -193,10 +201,13 @@ func (r *OrderRepository) Create(ctx context.Context, o *domain.Order) (*domain.
 	}
 	o.CreatedAt = now
 	o.UpdatedAt = now
+	if o.Shipment.Status == "" {
+		o.Shipment.Status = domain.ShipmentStatusPending
+	}
 
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	defer func() {
@@ -205,18 +216,44 @@ func (r *OrderRepository) Create(ctx context.Context, o *domain.Order) (*domain.
 		}
 	}()
 
+	reason := fmt.Sprintf("order:%s", o.Code)
+	for i := range o.Items {
+		item := &o.Items[i]
+		unitCosts := make(map[string]float64)
+		quantities := item.StockQuantities()
+		productIDs := make([]string, 0, len(quantities))
+		for productID := range quantities {
+			productIDs = append(productIDs, productID)
+		}
+		// Each line locks its products in a fixed order to keep lock waits predictable.
+		sort.Strings(productIDs)
+		for _, productID := range productIDs {
+			qty := quantities[productID]
+			adj, moveErr := moveStock(ctx, tx, StockMove{ProductID: productID, Delta: -qty, Reason: reason, LocationID: o.LocationID, SkipExpired: true})
+			if moveErr != nil {
+				err = moveErr
+				return nil, nil, err
+			}
+			adjustments = append(adjustments, adj)
+			unitCosts[productID] = adj.Cost / float64(qty)
+			item.Lots = append(item.Lots, adj.Lots...)
+		}
+		item.ApplyUnitCosts(unitCosts)
+	}
+	o.Profit = o.ComputeProfit()
+
 	const orderStmt = `INSERT INTO orders (
-        id, code, buyer_id, recipient_id, shipment_courier, shipment_service, shipment_tracking, shipment_cost, is_buyer_paying_shipping, discount_order, total, profit, notes, created_at, updated_at
-    ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+        id, code, location_id, buyer_id, recipient_id, shipment_courier, shipment_service, shipment_tracking, shipment_cost, is_buyer_paying_shipping, discount_order, total, profit, notes, created_at, updated_at
+    ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
 
 	_, err = tx.ExecContext(ctx, orderStmt,
-		o.ID, o.Code, o.BuyerID, o.RecipientID,
+		o.ID, o.Code, nullIfEmpty(o.LocationID), o.BuyerID, o.RecipientID,
 		o.Shipment.Courier, o.Shipment.ServiceLevel, o.Shipment.TrackingCode, o.Shipment.ShippingCost, o.Shipment.ShippingByBuyer,
 		o.DiscountOrder, o.Total, o.Profit, o.Notes,
 		o.CreatedAt.Format(time.RFC3339), o.UpdatedAt.Format(time.RFC3339),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("insert order: %w", err)
+		return nil, nil, fmt.Errorf("insert order: %w", err)
 	}
 
 	const itemStmt = `INSERT INTO order_items (id, order_id, product_id, quantity, unit_price, discount_item, cost_price, profit) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`
@@ -227,14 +264,20 @@ func (r *OrderRepository) Create(ctx context.Context, o *domain.Order) (*domain.
 		}
 		item.OrderID = o.ID
 		if _, err = tx.ExecContext(ctx, itemStmt, item.ID, item.OrderID, item.ProductID, item.Quantity, item.UnitPrice, item.DiscountItem, item.CostPrice, item.Profit); err != nil {
-			return nil, fmt.Errorf("insert order item: %w", err)
+			return nil, nil, fmt.Errorf("insert order item: %w", err)
+		}
+		if err = insertItemComponents(ctx, tx, item.ID, item.Components); err != nil {
+			return nil, nil, err
+		}
+		if err = insertItemLots(ctx, tx, item.ID, item.Lots); err != nil {
+			return nil, nil, err
 		}
 	}
 
 	if err = tx.Commit(); err != nil {
-		return nil, fmt.Errorf("commit order: %w", err)
+		return nil, nil, fmt.Errorf("commit order: %w", err)
 	}
-	return o, nil
+	return o, adjustments, nil
 }
 
 func (r *OrderRepository) List(ctx context.Context, limit int) ([]domain.Order, error) {
@@ -246,7 +289,7 @@ func (r *OrderRepository) List(ctx context.Context, limit int) ([]domain.Order,
 }
 
 func (r *OrderRepository) ListAll(ctx context.Context) ([]domain.Order, error) {
-	const stmt = `SELECT id, code, buyer_id, recipient_id, shipment_courier, shipment_service, shipment_tracking, shipment_cost, is_buyer_paying_shipping, discount_order, total, profit, notes, created_at, updated_at FROM orders ORDER BY created_at DESC;`
+	const stmt = `SELECT id, code, IFNULL(location_id,''), buyer_id, recipient_id, shipment_courier, shipment_service, shipment_tracking, shipment_cost, is_buyer_paying_shipping, shipment_status, shipment_status_at, discount_order, total, profit, notes, created_at, updated_at FROM orders ORDER BY created_at DESC;`
 
 	rows, err := r.db.QueryContext(ctx, stmt)
 	if err != nil {
@@ -258,11 +301,13 @@ func (r *OrderRepository) ListAll(ctx context.Context) ([]domain.Order, error) {
 	for rows.Next() {
 		var o domain.Order
 		var created, updated string
-		if err := rows.Scan(&o.ID, &o.Code, &o.BuyerID, &o.RecipientID, &o.Shipment.Courier, &o.Shipment.ServiceLevel, &o.Shipment.TrackingCode, &o.Shipment.ShippingCost, &o.Shipment.ShippingByBuyer, &o.DiscountOrder, &o.Total, &o.Profit, &o.Notes, &created, &updated); err != nil {
+		var statusAt sql.NullString
+		if err := rows.Scan(&o.ID, &o.Code, &o.LocationID, &o.BuyerID, &o.RecipientID, &o.Shipment.Courier, &o.Shipment.ServiceLevel, &o.Shipment.TrackingCode, &o.Shipment.ShippingCost, &o.Shipment.ShippingByBuyer, &o.Shipment.Status, &statusAt, &o.DiscountOrder, &o.Total, &o.Profit, &o.Notes, &created, &updated); err != nil {
 			return nil, err
 		}
 		o.CreatedAt, _ = time.Parse(time.RFC3339, created)
 		o.UpdatedAt, _ = time.Parse(time.RFC3339, updated)
+		o.Shipment.StatusUpdatedAt = parseNullTime(statusAt)
 		items, err := r.itemsByOrder(ctx, o.ID)
 		if err != nil {
 			return nil, err
@@ -274,15 +319,17 @@ func (r *OrderRepository) ListAll(ctx context.Context) ([]domain.Order, error) {
 }
 
 func (r *OrderRepository) Get(ctx context.Context, id string) (*domain.Order, error) {
-	const stmt = `SELECT id, code, buyer_id, recipient_id, shipment_courier, shipment_service, shipment_tracking, shipment_cost,is_buyer_paying_shipping, discount_order, total, profit, notes, created_at, updated_at
+	const stmt = `SELECT id, code, IFNULL(location_id,''), buyer_id, recipient_id, shipment_courier, shipment_service, shipment_tracking, shipment_cost, is_buyer_paying_shipping, shipment_status, shipment_status_at, discount_order, total, profit, notes, created_at, updated_at
                   FROM orders WHERE id = ?;`
 	var o domain.Order
 	var created, updated string
-	if err := r.db.QueryRowContext(ctx, stmt, id).Scan(&o.ID, &o.Code, &o.BuyerID, &o.RecipientID, &o.Shipment.Courier, &o.Shipment.ServiceLevel, &o.Shipment.TrackingCode, &o.Shipment.ShippingCost, &o.Shipment.ShippingByBuyer, &o.DiscountOrder, &o.Total, &o.Profit, &o.Notes, &created, &updated); err != nil {
+	var statusAt sql.NullString
+	if err := r.db.QueryRowContext(ctx, stmt, id).Scan(&o.ID, &o.Code, &o.LocationID, &o.BuyerID, &o.RecipientID, &o.Shipment.Courier, &o.Shipment.ServiceLevel, &o.Shipment.TrackingCode, &o.Shipment.ShippingCost, &o.Shipment.ShippingByBuyer, &o.Shipment.Status, &statusAt, &o.DiscountOrder, &o.Total, &o.Profit, &o.Notes, &created, &updated); err != nil {
 		return nil, fmt.Errorf("get order: %w", err)
 	}
 	o.CreatedAt, _ = time.Parse(time.RFC3339, created)
 	o.UpdatedAt, _ = time.Parse(time.RFC3339, updated)
+	o.Shipment.StatusUpdatedAt = parseNullTime(statusAt)
 	items, err := r.itemsByOrder(ctx, o.ID)
 	if err != nil {
 		return nil, err
@@ -291,10 +338,14 @@ func (r *OrderRepository) Get(ctx context.Context, id string) (*domain.Order, er
 	return &o, nil
 }
 
-func (r *OrderRepository) Delete(ctx context.Context, id string) error {
+// Delete removes an order and returns its stock in one transaction, so an order is
+// never gone with its stock still taken. Units go back at the cost they were sold at
+// and into the lots they were picked from; a move that fails fails the delete. The
+// returned adjustments are the stock changes posted.
+func (r *OrderRepository) Delete(ctx context.Context, o *domain.Order) (adjustments []*StockAdjustment, err error) {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	defer func() {
@@ -303,19 +354,78 @@ func (r *OrderRepository) Delete(ctx context.Context, id string) error {
 		}
 	}()
 
-	if _, err = tx.ExecContext(ctx, `DELETE FROM order_items WHERE order_id = ?;`, id); err != nil {
-		return fmt.Errorf("delete order items: %w", err)
+	// Locking the order first lets only one of two concurrent deletes restore its stock.
+	var id string
+	if err = tx.QueryRowContext(ctx, `SELECT id FROM orders WHERE id = ? FOR UPDATE;`, o.ID).Scan(&id); err != nil {
+		return nil, fmt.Errorf("lock order: %w", err)
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM order_items WHERE order_id = ?;`, o.ID); err != nil {
+		return nil, fmt.Errorf("delete order items: %w", err)
 	}
 
-	if _, err = tx.ExecContext(ctx, `DELETE FROM orders WHERE id = ?;`, id); err != nil {
-		return fmt.Errorf("delete order: %w", err)
+	if _, err = tx.ExecContext(ctx, `DELETE FROM orders WHERE id = ?;`, o.ID); err != nil {
+		return nil, fmt.Errorf("delete order: %w", err)
+	}
+
+	reason := fmt.Sprintf("order-deleted:%s", o.Code)
+	for _, item := range o.Items {
+		quantities := item.StockQuantities()
+		productIDs := make([]string, 0, len(quantities))
+		for productID := range quantities {
+			productIDs = append(productIDs, productID)
+		}
+		sort.Strings(productIDs)
+		for _, productID := range productIDs {
+			unitCost := itemUnitCost(item, productID)
+			for _, move := range restockMoves(item, productID, quantities[productID]) {
+				move.Reason, move.UnitCost, move.LocationID = reason, &unitCost, o.LocationID
+				adj, moveErr := moveStock(ctx, tx, move)
+				if moveErr != nil {
+					err = fmt.Errorf("restore stock: %w", moveErr)
+					return nil, err
+				}
+				adjustments = append(adjustments, adj)
+			}
+		}
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("commit delete order: %w", err)
+		return nil, fmt.Errorf("commit delete order: %w", err)
 	}
 
-	return nil
+	return adjustments, nil
+}
+
+// itemUnitCost returns the unit cost an order line recorded for a stocked product.
+func itemUnitCost(item domain.OrderItem, productID string) float64 {
+	if len(item.Components) == 0 {
+		return item.CostPrice
+	}
+	for _, c := range item.Components {
+		if c.ProductID == productID {
+			return c.CostPrice
+		}
+	}
+	return 0
+}
+
+// restockMoves splits the qty units of a product an order line returns into one move
+// per lot it was picked from, plus one for any units that came from no lot.
+func restockMoves(item domain.OrderItem, productID string, qty int) []StockMove {
+	moves := make([]StockMove, 0, 1)
+	for _, lot := range item.Lots {
+		if lot.ProductID != productID || lot.Quantity <= 0 || qty <= 0 {
+			continue
+		}
+		take := min(lot.Quantity, qty)
+		moves = append(moves, StockMove{ProductID: productID, Delta: take, LotNumber: lot.LotNumber, ExpiresAt: lot.ExpiresAt})
+		qty -= take
+	}
+	if qty > 0 {
+		moves = append(moves, StockMove{ProductID: productID, Delta: qty})
+	}
+	return moves
 }
 
 func (r *OrderRepository) itemsByOrder(ctx context.Context, orderID string) ([]domain.OrderItem, error) {
@@ -341,9 +451,104 @@ func (r *OrderRepository) itemsByOrder(ctx context.Context, orderID string) ([]d
 		}
 		items = append(items, item)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	components, err := r.componentsByOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	lots, err := r.lotsByOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Components = components[items[i].ID]
+		items[i].Lots = lots[items[i].ID]
+	}
 	return items, nil
 }
 
+// componentsByOrder loads the bundle component snapshots of an order keyed by order item id.
+func (r *OrderRepository) componentsByOrder(ctx context.Context, orderID string) (map[string][]domain.OrderItemComponent, error) {
+	const stmt = `SELECT c.order_item_id, c.product_id, p.sku, c.quantity, c.cost_price
+                FROM order_item_components c
+                JOIN order_items i ON i.id = c.order_item_id
+                LEFT JOIN products p ON p.id = c.product_id
+                WHERE i.order_id = ?;`
+	rows, err := r.db.QueryContext(ctx, stmt, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("list order item components: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]domain.OrderItemComponent)
+	for rows.Next() {
+		var itemID string
+		var c domain.OrderItemComponent
+		var sku sql.NullString
+		if err := rows.Scan(&itemID, &c.ProductID, &sku, &c.Quantity, &c.CostPrice); err != nil {
+			return nil, err
+		}
+		c.SKU = sku.String
+		result[itemID] = append(result[itemID], c)
+	}
+	return result, rows.Err()
+}
+
+// lotsByOrder loads the lots each line of an order was picked from, keyed by order item id.
+func (r *OrderRepository) lotsByOrder(ctx context.Context, orderID string) (map[string][]domain.LotAllocation, error) {
+	const stmt = `SELECT l.order_item_id, l.product_id, IFNULL(l.batch_id,''), IFNULL(l.lot_number,''), l.expires_at, l.quantity
+                FROM order_item_lots l
+                JOIN order_items i ON i.id = l.order_item_id
+                WHERE i.order_id = ?
+                ORDER BY l.expires_at, l.lot_number;`
+	rows, err := r.db.QueryContext(ctx, stmt, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("list order item lots: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]domain.LotAllocation)
+	for rows.Next() {
+		var itemID string
+		var lot domain.LotAllocation
+		var expires sql.NullString
+		if err := rows.Scan(&itemID, &lot.ProductID, &lot.BatchID, &lot.LotNumber, &expires, &lot.Quantity); err != nil {
+			return nil, err
+		}
+		lot.ExpiresAt = parseNullTime(expires)
+		result[itemID] = append(result[itemID], lot)
+	}
+	return result, rows.Err()
+}
+
+func insertItemComponents(ctx context.Context, tx *sql.Tx, itemID string, components []domain.OrderItemComponent) error {
+	const stmt = `INSERT INTO order_item_components (id, order_item_id, product_id, quantity, cost_price) VALUES (?, ?, ?, ?, ?);`
+	for _, c := range components {
+		if _, err := tx.ExecContext(ctx, stmt, uuid.New().String(), itemID, c.ProductID, c.Quantity, c.CostPrice); err != nil {
+			return fmt.Errorf("insert order item component: %w", err)
+		}
+	}
+	return nil
+}
+
+func insertItemLots(ctx context.Context, tx *sql.Tx, itemID string, lots []domain.LotAllocation) error {
+	const stmt = `INSERT INTO order_item_lots (id, order_item_id, product_id, batch_id, lot_number, expires_at, quantity) VALUES (?, ?, ?, ?, ?, ?, ?);`
+	for _, lot := range lots {
+		var expires any
+		if lot.ExpiresAt != nil {
+			expires = lot.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+		if _, err := tx.ExecContext(ctx, stmt, uuid.New().String(), itemID, lot.ProductID, nullIfEmpty(lot.BatchID), nullIfEmpty(lot.LotNumber), expires, lot.Quantity); err != nil {
+			return fmt.Errorf("insert order item lot: %w", err)
+		}
+	}
+	return nil
+}
+
 func (r *OrderRepository) ReplaceAll(ctx context.Context, orders []domain.Order) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -362,7 +567,7 @@ func (r *OrderRepository) ReplaceAll(ctx context.Context, orders []domain.Order)
 		return fmt.Errorf("clear orders: %w", err)
 	}
 
-	orderStmt, err := tx.PrepareContext(ctx, `INSERT INTO orders (id, code, buyer_id, recipient_id, shipment_courier, shipment_service, shipment_tracking, shipment_cost, is_buyer_paying_shipping,  discount_order, total, profit, notes, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`)
+	orderStmt, err := tx.PrepareContext(ctx, `INSERT INTO orders (id, code, location_id, buyer_id, recipient_id, shipment_courier, shipment_service, shipment_tracking, shipment_cost, is_buyer_paying_shipping, shipment_status, shipment_status_at, discount_order, total, profit, notes, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`)
 	if err != nil {
 		return fmt.Errorf("prepare order insert: %w", err)
 	}
@@ -392,8 +597,16 @@ func (r *OrderRepository) ReplaceAll(ctx context.Context, orders []domain.Order)
 		if code == "" {
 			code = fmt.Sprintf("ORD-%s", created.Format("200601021504"))
 		}
+		status := order.Shipment.Status
+		if status == "" {
+			status = domain.ShipmentStatusPending
+		}
+		var statusAt interface{}
+		if order.Shipment.StatusUpdatedAt != nil {
+			statusAt = order.Shipment.StatusUpdatedAt.Format(time.RFC3339)
+		}
 
-		if _, err = orderStmt.ExecContext(ctx, id, code, order.BuyerID, order.RecipientID, order.Shipment.Courier, order.Shipment.ServiceLevel, order.Shipment.TrackingCode, order.Shipment.ShippingCost, order.Shipment.ShippingByBuyer, order.DiscountOrder, order.Total, order.Profit, order.Notes, created.Format(time.RFC3339), updated.Format(time.RFC3339)); err != nil {
+		if _, err = orderStmt.ExecContext(ctx, id, code, nullIfEmpty(order.LocationID), order.BuyerID, order.RecipientID, order.Shipment.Courier, order.Shipment.ServiceLevel, order.Shipment.TrackingCode, order.Shipment.ShippingCost, order.Shipment.ShippingByBuyer, status, statusAt, order.DiscountOrder, order.Total, order.Profit, order.Notes, created.Format(time.RFC3339), updated.Format(time.RFC3339)); err != nil {
 			return fmt.Errorf("insert order from backup: %w", err)
 		}
 
@@ -405,6 +618,12 @@ func (r *OrderRepository) ReplaceAll(ctx context.Context, orders []domain.Order)
 			if _, err = itemStmt.ExecContext(ctx, itemID, id, item.ProductID, item.Quantity, item.UnitPrice, item.DiscountItem, item.CostPrice, item.Profit); err != nil {
 				return fmt.Errorf("insert order item from backup: %w", err)
 			}
+			if err = insertItemComponents(ctx, tx, itemID, item.Components); err != nil {
+				return err
+			}
+			if err = insertItemLots(ctx, tx, itemID, item.Lots); err != nil {
+				return err
+			}
 		}
 	}
 
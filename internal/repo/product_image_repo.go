@@ -0,0 +1,303 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"smartseller-lite-starter/internal/domain"
+)
+
+// ErrProductImageNotFound is returned when an image id matches nothing in the gallery
+// of the given product.
+var ErrProductImageNotFound = errors.New("gambar produk tidak ditemukan")
+
+// ErrProductGalleryFull is returned when an image would take a gallery over its limit.
+var ErrProductGalleryFull = errors.New("galeri produk sudah penuh; hapus salah satu gambar terlebih dahulu")
+
+const productImageColumns = `id, product_id, path, thumb_path, hash, width, height, size_bytes, thumb_width, thumb_height, thumb_size_bytes, alt_text, sort_order, is_primary, created_at`
+
+func scanProductImage(row rowScanner) (*domain.ProductImage, error) {
+	var img domain.ProductImage
+	var created string
+	if err := row.Scan(&img.ID, &img.ProductID, &img.Path, &img.ThumbPath, &img.Hash, &img.Width, &img.Height, &img.SizeBytes,
+		&img.ThumbWidth, &img.ThumbHeight, &img.ThumbSizeBytes, &img.AltText, &img.SortOrder, &img.IsPrimary, &created); err != nil {
+		return nil, err
+	}
+	img.CreatedAt, _ = time.Parse(time.RFC3339, created)
+	return &img, nil
+}
+
+// AdoptLegacyImages gives products that only have the single image columns a gallery
+// holding that image as primary. It is idempotent and runs on start-up.
+func (r *ProductRepository) AdoptLegacyImages(ctx context.Context) error {
+	const stmt = `INSERT INTO product_images (` + productImageColumns + `)
+        SELECT UUID(), p.id, p.image_path, IFNULL(p.thumb_path,''), IFNULL(p.image_hash,''), IFNULL(p.image_width,0), IFNULL(p.image_height,0),
+            IFNULL(p.image_size_bytes,0), IFNULL(p.thumb_width,0), IFNULL(p.thumb_height,0), IFNULL(p.thumb_size_bytes,0), '', 0, TRUE, p.updated_at
+        FROM products p
+        WHERE IFNULL(p.image_path,'') <> ''
+          AND NOT EXISTS (SELECT 1 FROM product_images i WHERE i.product_id = p.id);`
+	if _, err := r.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("adopt product images: %w", err)
+	}
+	return nil
+}
+
+// Images loads the galleries of the given products keyed by product id, each in display
+// order.
+func (r *ProductRepository) Images(ctx context.Context, productIDs []string) (map[string][]domain.ProductImage, error) {
+	result := make(map[string][]domain.ProductImage)
+	if len(productIDs) == 0 {
+		return result, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(productIDs)), ",")
+	args := make([]any, 0, len(productIDs))
+	for _, id := range productIDs {
+		args = append(args, id)
+	}
+	rows, err := r.db.QueryContext(ctx, `SELECT `+productImageColumns+` FROM product_images WHERE product_id IN (`+placeholders+`) ORDER BY sort_order, created_at, id;`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list product images: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		img, err := scanProductImage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan product image: %w", err)
+		}
+		result[img.ProductID] = append(result[img.ProductID], *img)
+	}
+	return result, rows.Err()
+}
+
+// AddImage appends an image to the end of a product's gallery. The first image of a
+// gallery is always primary. A gallery already holding limit images is refused with
+// ErrProductGalleryFull; limit 0 means no limit.
+func (r *ProductRepository) AddImage(ctx context.Context, img *domain.ProductImage, limit int) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = addImage(ctx, tx, img, limit); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit product image: %w", err)
+	}
+	return nil
+}
+
+// addImage is AddImage inside the caller's transaction.
+func addImage(ctx context.Context, tx *sql.Tx, img *domain.ProductImage, limit int) error {
+	if err := lockProductRow(ctx, tx, img.ProductID); err != nil {
+		return err
+	}
+	var count, next int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*), IFNULL(MAX(sort_order),-1)+1 FROM product_images WHERE product_id = ?;`, img.ProductID).Scan(&count, &next); err != nil {
+		return fmt.Errorf("select image order: %w", err)
+	}
+	if limit > 0 && count >= limit {
+		return fmt.Errorf("%w (%d gambar)", ErrProductGalleryFull, limit)
+	}
+	img.ID = uuid.New().String()
+	img.SortOrder = next
+	img.IsPrimary = img.IsPrimary || count == 0
+	img.CreatedAt = time.Now().UTC()
+	if img.IsPrimary {
+		if _, err := tx.ExecContext(ctx, `UPDATE product_images SET is_primary = FALSE WHERE product_id = ?;`, img.ProductID); err != nil {
+			return fmt.Errorf("clear primary image: %w", err)
+		}
+	}
+	if err := insertProductImage(ctx, tx, img); err != nil {
+		return err
+	}
+	return syncPrimaryImage(ctx, tx, img.ProductID)
+}
+
+func insertProductImage(ctx context.Context, q execQuerier, img *domain.ProductImage) error {
+	const stmt = `INSERT INTO product_images (` + productImageColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	if _, err := q.ExecContext(ctx, stmt, img.ID, img.ProductID, img.Path, img.ThumbPath, img.Hash, img.Width, img.Height, img.SizeBytes,
+		img.ThumbWidth, img.ThumbHeight, img.ThumbSizeBytes, img.AltText, img.SortOrder, img.IsPrimary, img.CreatedAt.UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("insert product image: %w", err)
+	}
+	return nil
+}
+
+// UpdateImage changes the alt text of an image and, when primary is set, makes it the
+// product's primary image.
+func (r *ProductRepository) UpdateImage(ctx context.Context, productID, imageID, altText string, primary bool) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = lockProductRow(ctx, tx, productID); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `UPDATE product_images SET alt_text = ? WHERE id = ? AND product_id = ?;`, altText, imageID, productID)
+	if err != nil {
+		return fmt.Errorf("update product image: %w", err)
+	}
+	if err = expectImageRow(ctx, tx, res, productID, imageID); err != nil {
+		return err
+	}
+	if primary {
+		if _, err = tx.ExecContext(ctx, `UPDATE product_images SET is_primary = (id = ?) WHERE product_id = ?;`, imageID, productID); err != nil {
+			return fmt.Errorf("set primary image: %w", err)
+		}
+		if err = syncPrimaryImage(ctx, tx, productID); err != nil {
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit product image: %w", err)
+	}
+	return nil
+}
+
+// ReorderImages sets the gallery order. imageIDs must list every image of the product
+// exactly once.
+func (r *ProductRepository) ReorderImages(ctx context.Context, productID string, imageIDs []string) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = lockProductRow(ctx, tx, productID); err != nil {
+		return err
+	}
+	var count int
+	if err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM product_images WHERE product_id = ?;`, productID).Scan(&count); err != nil {
+		return fmt.Errorf("count product images: %w", err)
+	}
+	if len(imageIDs) != count {
+		err = fmt.Errorf("urutan harus memuat semua %d gambar produk", count)
+		return err
+	}
+	seen := make(map[string]bool, len(imageIDs))
+	for i, id := range imageIDs {
+		if seen[id] {
+			err = fmt.Errorf("gambar %s disebut lebih dari sekali", id)
+			return err
+		}
+		seen[id] = true
+		res, execErr := tx.ExecContext(ctx, `UPDATE product_images SET sort_order = ? WHERE id = ? AND product_id = ?;`, i, id, productID)
+		if execErr != nil {
+			err = fmt.Errorf("reorder product images: %w", execErr)
+			return err
+		}
+		if err = expectImageRow(ctx, tx, res, productID, id); err != nil {
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit product image order: %w", err)
+	}
+	return nil
+}
+
+// DeleteImage removes an image from the gallery and returns it so its files can be
+// cleaned up. When the primary image goes, the next image in order takes its place.
+func (r *ProductRepository) DeleteImage(ctx context.Context, productID, imageID string) (removed *domain.ProductImage, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			removed = nil
+		}
+	}()
+
+	if err = lockProductRow(ctx, tx, productID); err != nil {
+		return nil, err
+	}
+	removed, err = scanProductImage(tx.QueryRowContext(ctx, `SELECT `+productImageColumns+` FROM product_images WHERE id = ? AND product_id = ?;`, imageID, productID))
+	if errors.Is(err, sql.ErrNoRows) {
+		err = ErrProductImageNotFound
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get product image: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM product_images WHERE id = ?;`, imageID); err != nil {
+		return nil, fmt.Errorf("delete product image: %w", err)
+	}
+	if removed.IsPrimary {
+		const promote = `UPDATE product_images SET is_primary = TRUE WHERE product_id = ? ORDER BY sort_order, created_at, id LIMIT 1;`
+		if _, err = tx.ExecContext(ctx, promote, productID); err != nil {
+			return nil, fmt.Errorf("promote product image: %w", err)
+		}
+	}
+	if err = syncPrimaryImage(ctx, tx, productID); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit product image: %w", err)
+	}
+	return removed, nil
+}
+
+// lockProductRow locks a product so concurrent gallery edits apply one at a time.
+func lockProductRow(ctx context.Context, tx *sql.Tx, productID string) error {
+	var id string
+	err := tx.QueryRowContext(ctx, `SELECT id FROM products WHERE id = ? FOR UPDATE;`, productID).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("lock product: %w", err)
+	}
+	return nil
+}
+
+// expectImageRow turns an update that matched no row into ErrProductImageNotFound. An
+// update that left the row unchanged affects no rows either, so the row is looked up.
+func expectImageRow(ctx context.Context, tx *sql.Tx, res sql.Result, productID, imageID string) error {
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		return nil
+	}
+	var id string
+	err := tx.QueryRowContext(ctx, `SELECT id FROM product_images WHERE id = ? AND product_id = ?;`, imageID, productID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrProductImageNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("get product image: %w", err)
+	}
+	return nil
+}
+
+// syncPrimaryImage mirrors the primary image into the product's image columns, or
+// clears them when the gallery is empty.
+func syncPrimaryImage(ctx context.Context, q execQuerier, productID string) error {
+	const stmt = `UPDATE products p LEFT JOIN product_images i ON i.product_id = p.id AND i.is_primary = TRUE
+        SET p.image_path = IFNULL(i.path,''), p.thumb_path = IFNULL(i.thumb_path,''), p.image_hash = IFNULL(i.hash,''),
+            p.image_width = IFNULL(i.width,0), p.image_height = IFNULL(i.height,0), p.image_size_bytes = IFNULL(i.size_bytes,0),
+            p.thumb_width = IFNULL(i.thumb_width,0), p.thumb_height = IFNULL(i.thumb_height,0), p.thumb_size_bytes = IFNULL(i.thumb_size_bytes,0)
+        WHERE p.id = ?;`
+	if _, err := q.ExecContext(ctx, stmt, productID); err != nil {
+		return fmt.Errorf("sync primary image: %w", err)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package repo
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	"github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
 
+	"smartseller-lite-starter/internal/audit"
 	"smartseller-lite-starter/internal/domain"
 )
 
@@ -22,6 +24,127 @@ func NewProductRepository(db *sql.DB) *ProductRepository {
 	return &ProductRepository{db: db}
 }
 
+// ErrBundleStock is returned when stock is adjusted on a bundle instead of its components.
+var ErrBundleStock = errors.New("stok bundle mengikuti stok komponen; sesuaikan stok komponennya")
+
+// ErrVariantParentStock is returned when stock is adjusted on a parent instead of one of its variants.
+var ErrVariantParentStock = errors.New("stok produk bervarian dikelola per varian; pilih variannya")
+
+// productStockExpr yields the on-hand stock of a products row. Bundles report how many
+// complete kits the current component stock can assemble, variant parents the total of
+// their active variants.
+const productStockExpr = `(CASE
+    WHEN products.is_bundle THEN IFNULL((SELECT MIN(c.stock DIV b.quantity) FROM product_bundle_items b JOIN products c ON c.id = b.component_id WHERE b.bundle_id = products.id), 0)
+    WHEN IFNULL(products.option_axes,'') <> '' THEN IFNULL((SELECT SUM(v.stock) FROM products v WHERE v.parent_id = products.id AND v.deleted_at IS NULL), 0)
+    ELSE products.stock END)`
+
+const productColumns = "id, name, IFNULL(sku,''), IFNULL(barcode,''), cost_price, sale_price, " + productStockExpr + " AS stock, IFNULL((SELECT c.name FROM categories c WHERE c.id = products.category_id), IFNULL(products.category,'')), IFNULL(products.category_id,''), low_stock_threshold, description, image_path, thumb_path, image_hash, image_width, image_height, image_size_bytes, thumb_width, thumb_height, thumb_size_bytes, is_bundle, parent_id, option_axes, variant_options, deleted_at, created_at, updated_at"
+
+// ErrDuplicateSKU and ErrDuplicateBarcode report a code already used by another product.
+var (
+	ErrDuplicateSKU     = errors.New("SKU sudah digunakan produk lain")
+	ErrDuplicateBarcode = errors.New("barcode sudah digunakan produk lain")
+)
+
+// FindByCode returns the active, stock-keeping product whose SKU or barcode equals one
+// of the codes. SKU matches win over barcode matches.
+func (r *ProductRepository) FindByCode(ctx context.Context, codes []string) (*domain.Product, error) {
+	if len(codes) == 0 {
+		return nil, fmt.Errorf("find product by code: %w", sql.ErrNoRows)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(codes)), ",")
+	args := make([]any, 0, len(codes)*3)
+	for _, code := range codes {
+		args = append(args, code)
+	}
+	for _, code := range codes {
+		args = append(args, code)
+	}
+	for _, code := range codes {
+		args = append(args, code)
+	}
+	stmt := "SELECT " + productColumns + " FROM products WHERE deleted_at IS NULL AND IFNULL(option_axes,'') = '' AND (sku IN (" + placeholders + ") OR barcode IN (" + placeholders + ")) ORDER BY IFNULL(sku IN (" + placeholders + "), 0) DESC LIMIT 1;"
+	p, err := scanProduct(r.db.QueryRowContext(ctx, stmt, args...))
+	if err != nil {
+		return nil, fmt.Errorf("find product by code: %w", err)
+	}
+	return p, nil
+}
+
+// CodeInUse reports whether another product already uses the SKU or barcode.
+// column must be "sku" or "barcode".
+func (r *ProductRepository) CodeInUse(ctx context.Context, column, code, excludeID string) (bool, error) {
+	if column != "sku" && column != "barcode" {
+		return false, fmt.Errorf("unknown product code column %q", column)
+	}
+	var count int
+	stmt := "SELECT COUNT(*) FROM products WHERE " + column + " = ? AND id <> ?;"
+	if err := r.db.QueryRowContext(ctx, stmt, code, excludeID).Scan(&count); err != nil {
+		return false, fmt.Errorf("check product %s: %w", column, err)
+	}
+	return count > 0, nil
+}
+
+func duplicateCodeError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != 1062 {
+		return nil
+	}
+	if strings.Contains(mysqlErr.Message, "idx_products_barcode") {
+		return ErrDuplicateBarcode
+	}
+	return ErrDuplicateSKU
+}
+
+func nullIfEmpty(value string) interface{} {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	return value
+}
+
+// variantColumns encodes the variant metadata of a product for storage.
+func variantColumns(p *domain.Product) (parentID, axes, options interface{}) {
+	if p.ParentID != "" {
+		parentID = p.ParentID
+	}
+	if len(p.OptionAxes) > 0 {
+		raw, _ := json.Marshal(p.OptionAxes)
+		axes = string(raw)
+	}
+	if len(p.Options) > 0 {
+		raw, _ := json.Marshal(p.Options)
+		options = string(raw)
+	}
+	return parentID, axes, options
+}
+
+func scanProduct(row rowScanner) (*domain.Product, error) {
+	var p domain.Product
+	var created, updated string
+	var deleted, parentID, axes, options sql.NullString
+	if err := row.Scan(&p.ID, &p.Name, &p.SKU, &p.Barcode, &p.CostPrice, &p.SalePrice, &p.Stock, &p.Category, &p.CategoryID, &p.LowStockThreshold, &p.Description, &p.ImagePath, &p.ThumbPath, &p.ImageHash, &p.ImageWidth, &p.ImageHeight, &p.ImageSizeBytes, &p.ThumbWidth, &p.ThumbHeight, &p.ThumbSizeBytes, &p.IsBundle, &parentID, &axes, &options, &deleted, &created, &updated); err != nil {
+		return nil, err
+	}
+	p.ParentID = parentID.String
+	if axes.String != "" {
+		_ = json.Unmarshal([]byte(axes.String), &p.OptionAxes)
+	}
+	if options.String != "" {
+		_ = json.Unmarshal([]byte(options.String), &p.Options)
+	}
+	p.CreatedAt, _ = time.Parse(time.RFC3339, created)
+	p.UpdatedAt, _ = time.Parse(time.RFC3339, updated)
+	if deleted.Valid {
+		ts, _ := time.Parse(time.RFC3339, deleted.String)
+		p.DeletedAt = &ts
+	}
+	if p.LowStockThreshold <= 0 {
+		p.LowStockThreshold = 5
+	}
+	return &p, nil
+}
+
 func (r *ProductRepository) ListPaged(ctx context.Context, opts ProductListOptions) (ProductListResult, error) {
 	const maxPageSize = 200
 
@@ -38,7 +161,10 @@ func (r *ProductRepository) ListPaged(ctx context.Context, opts ProductListOptio
 	}
 
 	whereParts := make([]string, 0)
-	if !opts.IncludeArchived {
+	switch {
+	case opts.ArchivedOnly:
+		whereParts = append(whereParts, "(deleted_at IS NOT NULL OR EXISTS (SELECT 1 FROM products v WHERE v.parent_id = products.id AND v.deleted_at IS NOT NULL))")
+	case !opts.IncludeArchived:
 		whereParts = append(whereParts, "deleted_at IS NULL")
 	}
 	args := make([]any, 0)
@@ -46,15 +172,24 @@ func (r *ProductRepository) ListPaged(ctx context.Context, opts ProductListOptio
 	query := strings.TrimSpace(strings.ToLower(opts.Query))
 	if query != "" {
 		like := "%" + query + "%"
-		whereParts = append(whereParts, "(LOWER(name) LIKE ? OR LOWER(sku) LIKE ? OR LOWER(IFNULL(category,'')) LIKE ?)")
-		args = append(args, like, like, like)
+		whereParts = append(whereParts, "(LOWER(name) LIKE ? OR LOWER(sku) LIKE ? OR barcode LIKE ? OR LOWER(IFNULL(category,'')) LIKE ? OR EXISTS (SELECT 1 FROM products v WHERE v.parent_id = products.id AND (LOWER(v.sku) LIKE ? OR v.barcode LIKE ?)))")
+		args = append(args, like, like, like, like, like, like)
 	}
 
-	whereClause := ""
-	if len(whereParts) > 0 {
-		whereClause = "WHERE " + strings.Join(whereParts, " AND ")
+	if len(opts.CategoryIDs) > 0 {
+		whereParts = append(whereParts, "category_id IN ("+strings.TrimSuffix(strings.Repeat("?,", len(opts.CategoryIDs)), ",")+")")
+		for _, id := range opts.CategoryIDs {
+			args = append(args, id)
+		}
+	} else if opts.Uncategorised {
+		whereParts = append(whereParts, "category_id IS NULL")
 	}
 
+	// The listing is grouped by parent, so variants are left out of the items while
+	// the stock counters and highlights look at sellable rows, skipping parents.
+	whereClause := "WHERE " + strings.Join(append(whereParts, "parent_id IS NULL"), " AND ")
+	stockClause := "WHERE " + strings.Join(append(whereParts, "IFNULL(option_axes,'') = ''"), " AND ")
+
 	limitClause := ""
 	listArgs := append([]any{}, args...)
 	if pageSize > 0 {
@@ -63,7 +198,7 @@ func (r *ProductRepository) ListPaged(ctx context.Context, opts ProductListOptio
 		listArgs = append(listArgs, pageSize, offset)
 	}
 
-	stmt := "SELECT id, name, sku, cost_price, sale_price, stock, category, low_stock_threshold, description, image_path, thumb_path, image_hash, image_width, image_height, image_size_bytes, thumb_width, thumb_height, thumb_size_bytes, deleted_at, created_at, updated_at FROM products " + whereClause + " ORDER BY name" + limitClause + ";"
+	stmt := "SELECT " + productColumns + " FROM products " + whereClause + " ORDER BY name" + limitClause + ";"
 
 	rows, err := r.db.QueryContext(ctx, stmt, listArgs...)
 	if err != nil {
@@ -73,22 +208,11 @@ func (r *ProductRepository) ListPaged(ctx context.Context, opts ProductListOptio
 
 	items := make([]domain.Product, 0)
 	for rows.Next() {
-		var p domain.Product
-		var created, updated string
-		var deleted sql.NullString
-		if err := rows.Scan(&p.ID, &p.Name, &p.SKU, &p.CostPrice, &p.SalePrice, &p.Stock, &p.Category, &p.LowStockThreshold, &p.Description, &p.ImagePath, &p.ThumbPath, &p.ImageHash, &p.ImageWidth, &p.ImageHeight, &p.ImageSizeBytes, &p.ThumbWidth, &p.ThumbHeight, &p.ThumbSizeBytes, &deleted, &created, &updated); err != nil {
+		p, err := scanProduct(rows)
+		if err != nil {
 			return ProductListResult{}, err
 		}
-		p.CreatedAt, _ = time.Parse(time.RFC3339, created)
-		p.UpdatedAt, _ = time.Parse(time.RFC3339, updated)
-		if deleted.Valid {
-			ts, _ := time.Parse(time.RFC3339, deleted.String)
-			p.DeletedAt = &ts
-		}
-		if p.LowStockThreshold <= 0 {
-			p.LowStockThreshold = 5
-		}
-		items = append(items, p)
+		items = append(items, *p)
 	}
 
 	countStmt := "SELECT COUNT(*) FROM products " + whereClause + ";"
@@ -100,26 +224,14 @@ func (r *ProductRepository) ListPaged(ctx context.Context, opts ProductListOptio
 	// counts for out of stock and warning stock (stock >0 && <= threshold)
 	thresholdExpr := "COALESCE(NULLIF(low_stock_threshold,0),5)"
 
-	outClause := whereClause
-	if outClause != "" {
-		outClause += " AND "
-	} else {
-		outClause = "WHERE "
-	}
-	outClause += "stock <= 0"
+	outClause := stockClause + " AND " + productStockExpr + " <= 0"
 	outStmt := "SELECT COUNT(*) FROM products " + outClause + ";"
 	var outOfStock int
 	if err := r.db.QueryRowContext(ctx, outStmt, args...).Scan(&outOfStock); err != nil {
 		return ProductListResult{}, fmt.Errorf("count out of stock: %w", err)
 	}
 
-	warnClause := whereClause
-	if warnClause != "" {
-		warnClause += " AND "
-	} else {
-		warnClause = "WHERE "
-	}
-	warnClause += fmt.Sprintf("stock > 0 AND stock <= %s", thresholdExpr)
+	warnClause := stockClause + " AND " + fmt.Sprintf("%s > 0 AND %s <= %s", productStockExpr, productStockExpr, thresholdExpr)
 	warnStmt := "SELECT COUNT(*) FROM products " + warnClause + ";"
 	var warning int
 	if err := r.db.QueryRowContext(ctx, warnStmt, args...).Scan(&warning); err != nil {
@@ -127,14 +239,8 @@ func (r *ProductRepository) ListPaged(ctx context.Context, opts ProductListOptio
 	}
 
 	// highlights - up to 5 products low stock (including zero)
-	highlightClause := whereClause
-	if highlightClause != "" {
-		highlightClause += " AND "
-	} else {
-		highlightClause = "WHERE "
-	}
-	highlightClause += fmt.Sprintf("stock <= %s", thresholdExpr)
-	highlightStmt := "SELECT id, name, sku, cost_price, sale_price, stock, category, low_stock_threshold, description, image_path, thumb_path, image_hash, image_width, image_height, image_size_bytes, thumb_width, thumb_height, thumb_size_bytes, deleted_at, created_at, updated_at FROM products " + highlightClause + " ORDER BY stock ASC, name ASC LIMIT 5;"
+	highlightClause := stockClause + " AND " + fmt.Sprintf("%s <= %s", productStockExpr, thresholdExpr)
+	highlightStmt := "SELECT " + productColumns + " FROM products " + highlightClause + " ORDER BY " + productStockExpr + " ASC, name ASC LIMIT 5;"
 	highlightRows, err := r.db.QueryContext(ctx, highlightStmt, args...)
 	if err != nil {
 		return ProductListResult{}, fmt.Errorf("highlight low stock: %w", err)
@@ -143,22 +249,22 @@ func (r *ProductRepository) ListPaged(ctx context.Context, opts ProductListOptio
 
 	highlights := make([]domain.Product, 0)
 	for highlightRows.Next() {
-		var p domain.Product
-		var created, updated string
-		var deleted sql.NullString
-		if err := highlightRows.Scan(&p.ID, &p.Name, &p.SKU, &p.CostPrice, &p.SalePrice, &p.Stock, &p.Category, &p.LowStockThreshold, &p.Description, &p.ImagePath, &p.ThumbPath, &p.ImageHash, &p.ImageWidth, &p.ImageHeight, &p.ImageSizeBytes, &p.ThumbWidth, &p.ThumbHeight, &p.ThumbSizeBytes, &deleted, &created, &updated); err != nil {
+		p, err := scanProduct(highlightRows)
+		if err != nil {
 			return ProductListResult{}, err
 		}
-		p.CreatedAt, _ = time.Parse(time.RFC3339, created)
-		p.UpdatedAt, _ = time.Parse(time.RFC3339, updated)
-		if deleted.Valid {
-			ts, _ := time.Parse(time.RFC3339, deleted.String)
-			p.DeletedAt = &ts
-		}
-		if p.LowStockThreshold <= 0 {
-			p.LowStockThreshold = 5
-		}
-		highlights = append(highlights, p)
+		highlights = append(highlights, *p)
+	}
+
+	// expiry warnings - lots of the listed products expiring within the warning window
+	expiryCutoff := time.Now().UTC().AddDate(0, 0, ExpiryWarningDays)
+	expiringCount, err := r.countExpiringLots(ctx, stockClause, args, expiryCutoff)
+	if err != nil {
+		return ProductListResult{}, err
+	}
+	expiryWarnings, err := r.expiringLots(ctx, stockClause, args, expiryCutoff, 5)
+	if err != nil {
+		return ProductListResult{}, err
 	}
 
 	result := ProductListResult{
@@ -169,6 +275,8 @@ func (r *ProductRepository) ListPaged(ctx context.Context, opts ProductListOptio
 		OutOfStockCount:    outOfStock,
 		WarningStockCount:  warning,
 		LowStockHighlights: highlights,
+		ExpiringLotCount:   expiringCount,
+		ExpiryWarnings:     expiryWarnings,
 	}
 
 	if pageSize <= 0 {
@@ -179,11 +287,18 @@ func (r *ProductRepository) ListPaged(ctx context.Context, opts ProductListOptio
 	return result, nil
 }
 
+// ProductListOptions filters ListPaged. CategoryIDs limits the listing to those
+// categories; Uncategorised to products without one. ArchivedOnly lists archived
+// products together with parents that have an archived variant and takes precedence
+// over IncludeArchived.
 type ProductListOptions struct {
 	Query           string
 	Page            int
 	PageSize        int
 	IncludeArchived bool
+	ArchivedOnly    bool
+	CategoryIDs     []string
+	Uncategorised   bool
 }
 
 type ProductListResult struct {
@@ -194,9 +309,67 @@ type ProductListResult struct {
 	OutOfStockCount    int
 	WarningStockCount  int
 	LowStockHighlights []domain.Product
+	ExpiringLotCount   int
+	ExpiryWarnings     []domain.ExpiringLot
 }
 
-func (r *ProductRepository) Create(ctx context.Context, p *domain.Product) (*domain.Product, error) {
+// FormStockReason labels the mutations that bring a product or variant to the stock
+// entered on the product form.
+const FormStockReason = "manual"
+
+// ProductSave is what a Save does besides writing the product row.
+type ProductSave struct {
+	// Stock is the stock to bring the product to, posted as a mutation of StockReason;
+	// nil leaves the stock as it is.
+	Stock       *int
+	StockReason string
+	// Image joins the gallery, subject to ImageLimit as in AddImage.
+	Image      *domain.ProductImage
+	ImageLimit int
+}
+
+// Save creates the product when it has no id and updates it otherwise, in one
+// transaction with the stock change and gallery image in opts, so a product is never
+// saved without them. The stock column is never written directly. The returned
+// adjustment is the stock change posted, if any.
+func (r *ProductRepository) Save(ctx context.Context, p *domain.Product, opts ProductSave) (_ *domain.Product, adj *StockAdjustment, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	if p.ID == "" {
+		err = insertProduct(ctx, tx, p)
+	} else {
+		err = updateProduct(ctx, tx, p)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.Stock != nil {
+		if adj, err = setStock(ctx, tx, p, *opts.Stock, opts.StockReason); err != nil {
+			return nil, nil, err
+		}
+	}
+	if opts.Image != nil {
+		opts.Image.ProductID = p.ID
+		if err = addImage(ctx, tx, opts.Image, opts.ImageLimit); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("commit product save: %w", err)
+		return nil, nil, err
+	}
+	return p, adj, nil
+}
+
+// insertProduct writes a new product row with no stock; stock comes in through setStock.
+func insertProduct(ctx context.Context, tx *sql.Tx, p *domain.Product) error {
 	now := time.Now().UTC()
 	if p.ID == "" {
 		p.ID = uuid.New().String()
@@ -206,79 +379,429 @@ func (r *ProductRepository) Create(ctx context.Context, p *domain.Product) (*dom
 	}
 	p.CreatedAt = now
 	p.UpdatedAt = now
+	p.Stock = 0
 
-	const stmt = `INSERT INTO products (id, name, sku, cost_price, sale_price, stock, category, low_stock_threshold, description, image_path, thumb_path, image_hash, image_width, image_height, image_size_bytes, thumb_width, thumb_height, thumb_size_bytes, deleted_at, created_at, updated_at)
-                  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	const stmt = `INSERT INTO products (id, name, sku, barcode, cost_price, sale_price, stock, category, category_id, low_stock_threshold, description, image_path, thumb_path, image_hash, image_width, image_height, image_size_bytes, thumb_width, thumb_height, thumb_size_bytes, is_bundle, parent_id, option_axes, variant_options, deleted_at, created_at, updated_at)
+                  VALUES (?, ?, ?, ?, ?, ?, 0, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
 	var deleted interface{}
 	if p.DeletedAt != nil {
 		deleted = p.DeletedAt.Format(time.RFC3339)
 	}
-	_, err := r.db.ExecContext(ctx, stmt, p.ID, p.Name, p.SKU, p.CostPrice, p.SalePrice, p.Stock, p.Category, p.LowStockThreshold, p.Description, p.ImagePath, p.ThumbPath, p.ImageHash, p.ImageWidth, p.ImageHeight, p.ImageSizeBytes, p.ThumbWidth, p.ThumbHeight, p.ThumbSizeBytes, deleted, p.CreatedAt.Format(time.RFC3339), p.UpdatedAt.Format(time.RFC3339))
-	if err != nil {
-		return nil, fmt.Errorf("insert product: %w", err)
+	parentID, axes, options := variantColumns(p)
+
+	if _, err := tx.ExecContext(ctx, stmt, p.ID, p.Name, nullIfEmpty(p.SKU), nullIfEmpty(p.Barcode), p.CostPrice, p.SalePrice, p.Category, nullIfEmpty(p.CategoryID), p.LowStockThreshold, p.Description, p.ImagePath, p.ThumbPath, p.ImageHash, p.ImageWidth, p.ImageHeight, p.ImageSizeBytes, p.ThumbWidth, p.ThumbHeight, p.ThumbSizeBytes, p.IsBundle, parentID, axes, options, deleted, p.CreatedAt.Format(time.RFC3339), p.UpdatedAt.Format(time.RFC3339)); err != nil {
+		if dup := duplicateCodeError(err); dup != nil {
+			return dup
+		}
+		return fmt.Errorf("insert product: %w", err)
 	}
-	return p, nil
+	if err := insertPriceChange(ctx, tx, p, 0, 0, now); err != nil {
+		return err
+	}
+	return reconcileLocationStock(ctx, tx, p.ID)
 }
 
-func (r *ProductRepository) Update(ctx context.Context, p *domain.Product) (*domain.Product, error) {
+// updateProduct writes a product row, leaving its stock alone; p.Stock is set to what
+// the product holds.
+func updateProduct(ctx context.Context, tx *sql.Tx, p *domain.Product) error {
 	p.UpdatedAt = time.Now().UTC()
 	if p.LowStockThreshold <= 0 {
 		p.LowStockThreshold = 5
 	}
-	const stmt = `UPDATE products SET name = ?, sku = ?, cost_price = ?, sale_price = ?, stock = ?, category = ?, low_stock_threshold = ?, description = ?, image_path = ?, thumb_path = ?, image_hash = ?, image_width = ?, image_height = ?, image_size_bytes = ?, thumb_width = ?, thumb_height = ?, thumb_size_bytes = ?, deleted_at = ?, updated_at = ? WHERE id = ?;`
+	const stmt = `UPDATE products SET name = ?, sku = ?, barcode = ?, cost_price = ?, sale_price = ?, category = ?, category_id = ?, low_stock_threshold = ?, description = ?, image_path = ?, thumb_path = ?, image_hash = ?, image_width = ?, image_height = ?, image_size_bytes = ?, thumb_width = ?, thumb_height = ?, thumb_size_bytes = ?, is_bundle = ?, parent_id = ?, option_axes = ?, variant_options = ?, deleted_at = ?, updated_at = ? WHERE id = ?;`
 	var deleted interface{}
 	if p.DeletedAt != nil {
 		deleted = p.DeletedAt.Format(time.RFC3339)
 	}
-	if _, err := r.db.ExecContext(ctx, stmt, p.Name, p.SKU, p.CostPrice, p.SalePrice, p.Stock, p.Category, p.LowStockThreshold, p.Description, p.ImagePath, p.ThumbPath, p.ImageHash, p.ImageWidth, p.ImageHeight, p.ImageSizeBytes, p.ThumbWidth, p.ThumbHeight, p.ThumbSizeBytes, deleted, p.UpdatedAt.Format(time.RFC3339), p.ID); err != nil {
-		return nil, fmt.Errorf("update product: %w", err)
+	parentID, axes, options := variantColumns(p)
+
+	var prevCost, prevSale float64
+	if err := tx.QueryRowContext(ctx, `SELECT cost_price, sale_price, stock FROM products WHERE id = ? FOR UPDATE;`, p.ID).Scan(&prevCost, &prevSale, &p.Stock); err != nil {
+		return fmt.Errorf("select product prices: %w", err)
 	}
-	return p, nil
+	if _, err := tx.ExecContext(ctx, stmt, p.Name, nullIfEmpty(p.SKU), nullIfEmpty(p.Barcode), p.CostPrice, p.SalePrice, p.Category, nullIfEmpty(p.CategoryID), p.LowStockThreshold, p.Description, p.ImagePath, p.ThumbPath, p.ImageHash, p.ImageWidth, p.ImageHeight, p.ImageSizeBytes, p.ThumbWidth, p.ThumbHeight, p.ThumbSizeBytes, p.IsBundle, parentID, axes, options, deleted, p.UpdatedAt.Format(time.RFC3339), p.ID); err != nil {
+		if dup := duplicateCodeError(err); dup != nil {
+			return dup
+		}
+		return fmt.Errorf("update product: %w", err)
+	}
+	if prevCost != p.CostPrice || prevSale != p.SalePrice {
+		if err := insertPriceChange(ctx, tx, p, prevCost, prevSale, p.UpdatedAt); err != nil {
+			return err
+		}
+	}
+	return reconcileLocationStock(ctx, tx, p.ID)
+}
+
+// setStock brings a product locked by insertProduct or updateProduct to target by
+// posting the difference from what it holds through the ledger. Bundles and variant
+// parents keep no stock of their own and are left alone.
+func setStock(ctx context.Context, tx *sql.Tx, p *domain.Product, target int, reason string) (*StockAdjustment, error) {
+	if p.IsBundle || p.HasVariants() || target == p.Stock {
+		return nil, nil
+	}
+	adj, err := moveStock(ctx, tx, StockMove{ProductID: p.ID, Delta: target - p.Stock, Reason: reason})
+	if err != nil {
+		return nil, err
+	}
+	p.Stock = target
+	return adj, nil
+}
+
+// SaveVariants writes the variant rows of a parent and archives the variants in
+// archiveIDs in one transaction. Stock is never written directly: the difference
+// between each variant's Stock and what it holds is posted through the ledger, so
+// every change has a mutation and cost batch. The returned adjustments are the stock
+// changes posted.
+func (r *ProductRepository) SaveVariants(ctx context.Context, variants []domain.Product, archiveIDs []string) (adjustments []*StockAdjustment, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for i := range variants {
+		v := &variants[i]
+		target := v.Stock
+		if v.ID == "" {
+			err = insertProduct(ctx, tx, v)
+		} else {
+			err = updateProduct(ctx, tx, v)
+		}
+		if err != nil {
+			return nil, err
+		}
+		adj, stockErr := setStock(ctx, tx, v, target, FormStockReason)
+		if stockErr != nil {
+			err = stockErr
+			return nil, err
+		}
+		if adj != nil {
+			adjustments = append(adjustments, adj)
+		}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, id := range archiveIDs {
+		if _, err = tx.ExecContext(ctx, `UPDATE products SET deleted_at = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL;`, now, now, id); err != nil {
+			err = fmt.Errorf("archive variant: %w", err)
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit variants: %w", err)
+	}
+	return adjustments, nil
 }
 
-func (r *ProductRepository) AdjustStock(ctx context.Context, productID string, delta int, reason string) (err error) {
+// insertPriceChange appends a row to the price history, attributed to the actor and
+// source carried by ctx.
+func insertPriceChange(ctx context.Context, tx *sql.Tx, p *domain.Product, prevCost, prevSale float64, at time.Time) error {
+	const stmt = `INSERT INTO product_price_history (id, product_id, cost_price, sale_price, previous_cost_price, previous_sale_price, actor, source, changed_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	if _, err := tx.ExecContext(ctx, stmt, uuid.New().String(), p.ID, p.CostPrice, p.SalePrice, prevCost, prevSale, audit.Actor(ctx), audit.Source(ctx), at.UTC().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("insert price history: %w", err)
+	}
+	return nil
+}
+
+// SetCostPrice changes the list cost of a product, recording the change in the price
+// history. It is a no-op when the cost is unchanged.
+func (r *ProductRepository) SetCostPrice(ctx context.Context, productID string, cost float64) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	if err = setCostPrice(ctx, tx, productID, cost); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("commit cost price: %w", err)
+		return err
+	}
+	return nil
+}
+
+// setCostPrice is SetCostPrice inside the caller's transaction.
+func setCostPrice(ctx context.Context, tx *sql.Tx, productID string, cost float64) error {
+	p := &domain.Product{ID: productID, CostPrice: cost}
+	var prevCost float64
+	if err := tx.QueryRowContext(ctx, `SELECT cost_price, sale_price FROM products WHERE id = ? FOR UPDATE;`, productID).Scan(&prevCost, &p.SalePrice); err != nil {
+		return fmt.Errorf("select product prices: %w", err)
+	}
+	if prevCost == cost {
+		return nil
+	}
+	now := time.Now().UTC()
+	if _, err := tx.ExecContext(ctx, `UPDATE products SET cost_price = ?, updated_at = ? WHERE id = ?;`, cost, now.Format(time.RFC3339), productID); err != nil {
+		return fmt.Errorf("update cost price: %w", err)
+	}
+	return insertPriceChange(ctx, tx, p, prevCost, p.SalePrice, now)
+}
+
+// PriceHistory lists the price and cost changes of a product, newest first.
+func (r *ProductRepository) PriceHistory(ctx context.Context, productID string, limit int) ([]domain.PriceChange, error) {
+	stmt := `SELECT id, product_id, cost_price, sale_price, previous_cost_price, previous_sale_price, actor, source, changed_at FROM product_price_history WHERE product_id = ? ORDER BY changed_at DESC, id`
+	args := []any{productID}
+	if limit > 0 {
+		stmt += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := r.db.QueryContext(ctx, stmt+";", args...)
+	if err != nil {
+		return nil, fmt.Errorf("list price history: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]domain.PriceChange, 0)
+	for rows.Next() {
+		var c domain.PriceChange
+		var changed string
+		if err := rows.Scan(&c.ID, &c.ProductID, &c.CostPrice, &c.SalePrice, &c.PreviousCostPrice, &c.PreviousSalePrice, &c.Actor, &c.Source, &changed); err != nil {
+			return nil, fmt.Errorf("scan price history: %w", err)
+		}
+		c.ChangedAt, _ = time.Parse(time.RFC3339, changed)
+		items = append(items, c)
+	}
+	return items, rows.Err()
+}
+
+// StockAdjustment describes the outcome of a stock change for callers that react to it.
+type StockAdjustment struct {
+	ProductID         string
+	Name              string
+	SKU               string
+	Delta             int
+	Reason            string
+	PreviousStock     int
+	Stock             int
+	LowStockThreshold int
+	// Cost is the total cost of the units moved: what leaving units were worth under
+	// the costing method, or what arriving units were received at.
+	Cost       float64
+	LocationID string
+	// Lots lists the dated or numbered lots outgoing units were picked from.
+	Lots []domain.LotAllocation
+}
+
+// StockMove describes a stock change. UnitCost prices incoming units; when nil they
+// come in at the product's current average cost. LocationID names where the units
+// arrive or leave; empty means the default location. LotNumber and ExpiresAt label
+// incoming units; SkipExpired keeps outgoing units away from expired lots while
+// others are left.
+type StockMove struct {
+	ProductID   string
+	Delta       int
+	Reason      string
+	UnitCost    *float64
+	Note        string
+	ReceivedAt  time.Time
+	LocationID  string
+	LotNumber   string
+	ExpiresAt   *time.Time
+	SkipExpired bool
+}
+
+func (r *ProductRepository) AdjustStock(ctx context.Context, productID string, delta int, reason string) (*StockAdjustment, error) {
+	return r.MoveStock(ctx, StockMove{ProductID: productID, Delta: delta, Reason: reason})
+}
+
+// MoveStock applies a stock change, records the mutation and keeps the cost batches in
+// step: incoming units open a batch, outgoing units consume the earliest-expiring, then
+// the oldest batches first.
+func (r *ProductRepository) MoveStock(ctx context.Context, move StockMove) (adj *StockAdjustment, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
 
 	defer func() {
 		if err != nil {
 			_ = tx.Rollback()
+			adj = nil
 			return
 		}
 		if commitErr := tx.Commit(); commitErr != nil {
 			err = fmt.Errorf("commit stock adjustment: %w", commitErr)
+			adj = nil
 		}
 	}()
 
-	const selectStmt = `SELECT stock FROM products WHERE id = ? AND deleted_at IS NULL;`
-	var stock int
-	if err = tx.QueryRowContext(ctx, selectStmt, productID).Scan(&stock); err != nil {
-		err = fmt.Errorf("select stock: %w", err)
-		return err
+	return moveStock(ctx, tx, move)
+}
+
+// moveStock is MoveStock inside the caller's transaction. The product row is locked
+// until the transaction ends.
+func moveStock(ctx context.Context, tx *sql.Tx, move StockMove) (*StockAdjustment, error) {
+	productID := move.ProductID
+	const selectStmt = `SELECT name, IFNULL(sku,''), stock, low_stock_threshold, is_bundle, IFNULL(option_axes,'') <> '', IFNULL(average_cost, cost_price) FROM products WHERE id = ? AND deleted_at IS NULL FOR UPDATE;`
+	adj := &StockAdjustment{ProductID: productID, Delta: move.Delta, Reason: move.Reason}
+	var isBundle, hasVariants bool
+	var averageCost float64
+	if err := tx.QueryRowContext(ctx, selectStmt, productID).Scan(&adj.Name, &adj.SKU, &adj.PreviousStock, &adj.LowStockThreshold, &isBundle, &hasVariants, &averageCost); err != nil {
+		return nil, fmt.Errorf("select stock: %w", err)
 	}
-	stock += delta
-	if stock < 0 {
-		err = fmt.Errorf("insufficient stock for product %s", productID)
-		return err
+	if isBundle {
+		return nil, ErrBundleStock
+	}
+	if hasVariants {
+		return nil, ErrVariantParentStock
+	}
+	if adj.LowStockThreshold <= 0 {
+		adj.LowStockThreshold = 5
+	}
+	adj.Stock = adj.PreviousStock + move.Delta
+	if adj.Stock < 0 {
+		return nil, fmt.Errorf("insufficient stock for product %s", productID)
+	}
+	locationID, locationName, err := stockLocation(ctx, tx, move.LocationID)
+	if err != nil {
+		return nil, err
+	}
+	adj.LocationID = locationID
+	if err := reconcileLocationStock(ctx, tx, productID); err != nil {
+		return nil, err
+	}
+	if err := shiftLocationStock(ctx, tx, productID, adj.LocationID, locationName, adj.Name, move.Delta); err != nil {
+		return nil, err
 	}
 
+	now := time.Now().UTC()
 	const updateStmt = `UPDATE products SET stock = ?, updated_at = ? WHERE id = ?;`
-	if _, err = tx.ExecContext(ctx, updateStmt, stock, time.Now().UTC().Format(time.RFC3339), productID); err != nil {
-		err = fmt.Errorf("update stock: %w", err)
-		return err
+	if _, err := tx.ExecContext(ctx, updateStmt, adj.Stock, now.Format(time.RFC3339), productID); err != nil {
+		return nil, fmt.Errorf("update stock: %w", err)
+	}
+
+	const mutationStmt = `INSERT INTO stock_mutations (id, product_id, location_id, delta, reason, created_at) VALUES (?, ?, ?, ?, ?, ?);`
+	if _, err := tx.ExecContext(ctx, mutationStmt, uuid.New().String(), productID, adj.LocationID, move.Delta, move.Reason, now.Format(time.RFC3339)); err != nil {
+		return nil, fmt.Errorf("insert stock mutation: %w", err)
+	}
+
+	if move.Delta > 0 {
+		adj.Cost, err = receiveBatch(ctx, tx, move, adj.PreviousStock, averageCost, now)
+	} else {
+		adj.Cost, adj.Lots, err = consumeBatches(ctx, tx, productID, adj.PreviousStock, -move.Delta, move.Reason, averageCost, move.SkipExpired, now)
+	}
+	if err != nil {
+		if errors.Is(err, ErrExpiredStock) {
+			return nil, fmt.Errorf("%w: %s", err, adj.Name)
+		}
+		return nil, err
+	}
+	return adj, nil
+}
+
+// BundleComponents loads the components of the given bundles keyed by bundle id.
+func (r *ProductRepository) BundleComponents(ctx context.Context, bundleIDs []string) (map[string][]domain.BundleComponent, error) {
+	result := make(map[string][]domain.BundleComponent)
+	if len(bundleIDs) == 0 {
+		return result, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(bundleIDs)), ",")
+	args := make([]any, 0, len(bundleIDs))
+	for _, id := range bundleIDs {
+		args = append(args, id)
+	}
+	stmt := `SELECT b.bundle_id, c.id, c.name, IFNULL(c.sku,''), b.quantity, c.stock, c.cost_price
+                FROM product_bundle_items b
+                JOIN products c ON c.id = b.component_id
+                WHERE b.bundle_id IN (` + placeholders + `)
+                ORDER BY c.name;`
+	rows, err := r.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list bundle components: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bundleID string
+		var c domain.BundleComponent
+		if err := rows.Scan(&bundleID, &c.ProductID, &c.Name, &c.SKU, &c.Quantity, &c.Stock, &c.CostPrice); err != nil {
+			return nil, err
+		}
+		result[bundleID] = append(result[bundleID], c)
 	}
+	return result, rows.Err()
+}
 
-	const mutationStmt = `INSERT INTO stock_mutations (id, product_id, delta, reason, created_at) VALUES (?, ?, ?, ?, ?);`
-	if _, err = tx.ExecContext(ctx, mutationStmt, uuid.New().String(), productID, delta, reason, time.Now().UTC().Format(time.RFC3339)); err != nil {
-		err = fmt.Errorf("insert stock mutation: %w", err)
+// SetBundleComponents replaces the component list of a bundle.
+func (r *ProductRepository) SetBundleComponents(ctx context.Context, bundleID string, components []domain.BundleComponent) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
 		return err
 	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM product_bundle_items WHERE bundle_id = ?;`, bundleID); err != nil {
+		return fmt.Errorf("clear bundle components: %w", err)
+	}
+	const stmt = `INSERT INTO product_bundle_items (bundle_id, component_id, quantity) VALUES (?, ?, ?);`
+	for _, c := range components {
+		if _, err = tx.ExecContext(ctx, stmt, bundleID, c.ProductID, c.Quantity); err != nil {
+			return fmt.Errorf("insert bundle component: %w", err)
+		}
+	}
 
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit bundle components: %w", err)
+	}
 	return nil
 }
 
+// Variants loads the variants of the given parents keyed by parent id, ordered by name.
+func (r *ProductRepository) Variants(ctx context.Context, parentIDs []string, includeArchived bool) (map[string][]domain.Product, error) {
+	result := make(map[string][]domain.Product)
+	if len(parentIDs) == 0 {
+		return result, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(parentIDs)), ",")
+	args := make([]any, 0, len(parentIDs))
+	for _, id := range parentIDs {
+		args = append(args, id)
+	}
+	stmt := "SELECT " + productColumns + " FROM products WHERE parent_id IN (" + placeholders + ")"
+	if !includeArchived {
+		stmt += " AND deleted_at IS NULL"
+	}
+	stmt += " ORDER BY created_at, name;"
+	rows, err := r.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list variants: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		result[p.ParentID] = append(result[p.ParentID], *p)
+	}
+	return result, rows.Err()
+}
+
+// CountBundlesUsing reports how many bundles include the product as a component.
+func (r *ProductRepository) CountBundlesUsing(ctx context.Context, productID string) (int, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM product_bundle_items WHERE component_id = ?;`, productID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count bundles using product: %w", err)
+	}
+	return count, nil
+}
+
 func (r *ProductRepository) List(ctx context.Context) ([]domain.Product, error) {
 	res, err := r.ListPaged(ctx, ProductListOptions{Page: 1, PageSize: 0, IncludeArchived: false})
 	if err != nil {
@@ -296,45 +819,117 @@ func (r *ProductRepository) ListIncludingArchived(ctx context.Context) ([]domain
 }
 
 func (r *ProductRepository) Get(ctx context.Context, id string) (*domain.Product, error) {
-	const stmt = `SELECT id, name, sku, cost_price, sale_price, stock, category, low_stock_threshold, description, image_path, thumb_path, image_hash, image_width, image_height, image_size_bytes, thumb_width, thumb_height, thumb_size_bytes, deleted_at, created_at, updated_at FROM products WHERE id = ?;`
-	var p domain.Product
-	var created, updated string
-	var deleted sql.NullString
-	if err := r.db.QueryRowContext(ctx, stmt, id).Scan(&p.ID, &p.Name, &p.SKU, &p.CostPrice, &p.SalePrice, &p.Stock, &p.Category, &p.LowStockThreshold, &p.Description, &p.ImagePath, &p.ThumbPath, &p.ImageHash, &p.ImageWidth, &p.ImageHeight, &p.ImageSizeBytes, &p.ThumbWidth, &p.ThumbHeight, &p.ThumbSizeBytes, &deleted, &created, &updated); err != nil {
+	stmt := "SELECT " + productColumns + " FROM products WHERE id = ?;"
+	p, err := scanProduct(r.db.QueryRowContext(ctx, stmt, id))
+	if err != nil {
 		return nil, fmt.Errorf("get product: %w", err)
 	}
-	p.CreatedAt, _ = time.Parse(time.RFC3339, created)
-	p.UpdatedAt, _ = time.Parse(time.RFC3339, updated)
-	if deleted.Valid {
-		ts, _ := time.Parse(time.RFC3339, deleted.String)
-		p.DeletedAt = &ts
-	}
-	if p.LowStockThreshold <= 0 {
-		p.LowStockThreshold = 5
-	}
-	return &p, nil
+	return p, nil
 }
 
 func (r *ProductRepository) Archive(ctx context.Context, id string) error {
-	const stmt = `UPDATE products SET deleted_at = ?, updated_at = ? WHERE id = ?;`
+	const stmt = `UPDATE products SET deleted_at = ?, updated_at = ? WHERE (id = ? OR parent_id = ?) AND deleted_at IS NULL;`
 	now := time.Now().UTC()
-	if _, err := r.db.ExecContext(ctx, stmt, now.Format(time.RFC3339), now.Format(time.RFC3339), id); err != nil {
+	if _, err := r.db.ExecContext(ctx, stmt, now.Format(time.RFC3339), now.Format(time.RFC3339), id, id); err != nil {
 		return fmt.Errorf("archive product: %w", err)
 	}
 	return nil
 }
 
+// Unarchive clears the archived mark of a product and of the variants archived
+// together with it, those carrying the parent's own archive time. Variants archived
+// earlier, or whose options no longer fit the parent's option axes, stay archived.
+// It reports whether the product was archived at all.
+func (r *ProductRepository) Unarchive(ctx context.Context, id string) (restored bool, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var archivedAt string
+	if err = tx.QueryRowContext(ctx, `SELECT IFNULL(deleted_at,'') FROM products WHERE id = ? FOR UPDATE;`, id).Scan(&archivedAt); err != nil {
+		err = fmt.Errorf("lock product: %w", err)
+		return false, err
+	}
+	if archivedAt == "" {
+		return false, tx.Rollback()
+	}
+	parent, err := scanProduct(tx.QueryRowContext(ctx, "SELECT "+productColumns+" FROM products WHERE id = ?;", id))
+	if err != nil {
+		err = fmt.Errorf("get product: %w", err)
+		return false, err
+	}
+
+	restore := []string{id}
+	if parent.HasVariants() {
+		rows, queryErr := tx.QueryContext(ctx, "SELECT "+productColumns+" FROM products WHERE parent_id = ? AND deleted_at = ? FOR UPDATE;", id, archivedAt)
+		if queryErr != nil {
+			err = fmt.Errorf("select archived variants: %w", queryErr)
+			return false, err
+		}
+		for rows.Next() {
+			variant, scanErr := scanProduct(rows)
+			if scanErr != nil {
+				rows.Close()
+				err = fmt.Errorf("scan variant: %w", scanErr)
+				return false, err
+			}
+			if variant.FitsAxes(parent.OptionAxes) {
+				restore = append(restore, variant.ID)
+			}
+		}
+		if err = rows.Err(); err != nil {
+			rows.Close()
+			return false, err
+		}
+		rows.Close()
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, productID := range restore {
+		if _, err = tx.ExecContext(ctx, `UPDATE products SET deleted_at = NULL, updated_at = ? WHERE id = ?;`, now, productID); err != nil {
+			err = fmt.Errorf("unarchive product: %w", err)
+			return false, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit unarchive product: %w", err)
+	}
+	return true, nil
+}
+
 func (r *ProductRepository) Delete(ctx context.Context, id string) error {
 	if strings.TrimSpace(id) == "" {
 		return fmt.Errorf("product id required")
 	}
-	const stmt = `DELETE FROM products WHERE id = ?;`
-	if _, err := r.db.ExecContext(ctx, stmt, id); err != nil {
-		var mysqlErr *mysql.MySQLError
-		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1451 {
-			return fmt.Errorf("delete product: produk masih digunakan pada transaksi")
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, stmt := range []string{`DELETE FROM products WHERE parent_id = ?;`, `DELETE FROM products WHERE id = ?;`} {
+		if _, err = tx.ExecContext(ctx, stmt, id); err != nil {
+			var mysqlErr *mysql.MySQLError
+			if errors.As(err, &mysqlErr) && mysqlErr.Number == 1451 {
+				err = fmt.Errorf("delete product: produk masih digunakan pada transaksi atau bundle")
+				return err
+			}
+			return fmt.Errorf("delete product: %w", err)
 		}
-		return fmt.Errorf("delete product: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit delete product: %w", err)
 	}
 	return nil
 }
@@ -353,11 +948,23 @@ func (r *ProductRepository) ReplaceAll(ctx context.Context, items []domain.Produ
 	if _, err = tx.ExecContext(ctx, `DELETE FROM stock_mutations;`); err != nil {
 		return fmt.Errorf("clear stock mutations: %w", err)
 	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM stock_batch_consumptions;`); err != nil {
+		return fmt.Errorf("clear batch consumptions: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM stock_batches;`); err != nil {
+		return fmt.Errorf("clear stock batches: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM product_location_stock;`); err != nil {
+		return fmt.Errorf("clear location stock: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM product_bundle_items;`); err != nil {
+		return fmt.Errorf("clear bundle components: %w", err)
+	}
 	if _, err = tx.ExecContext(ctx, `DELETE FROM products;`); err != nil {
 		return fmt.Errorf("clear products: %w", err)
 	}
 
-	stmt, err := tx.PrepareContext(ctx, `INSERT INTO products (id, name, sku, cost_price, sale_price, stock, category, low_stock_threshold, description, image_path, thumb_path, image_hash, image_width, image_height, image_size_bytes, thumb_width, thumb_height, thumb_size_bytes, deleted_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`)
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO products (id, name, sku, barcode, cost_price, sale_price, stock, category, category_id, low_stock_threshold, description, image_path, thumb_path, image_hash, image_width, image_height, image_size_bytes, thumb_width, thumb_height, thumb_size_bytes, is_bundle, parent_id, option_axes, variant_options, deleted_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`)
 	if err != nil {
 		return fmt.Errorf("prepare product insert: %w", err)
 	}
@@ -386,9 +993,31 @@ func (r *ProductRepository) ReplaceAll(ctx context.Context, items []domain.Produ
 		if threshold <= 0 {
 			threshold = 5
 		}
-		if _, err = stmt.ExecContext(ctx, id, item.Name, item.SKU, item.CostPrice, item.SalePrice, item.Stock, item.Category, threshold, item.Description, item.ImagePath, item.ThumbPath, item.ImageHash, item.ImageWidth, item.ImageHeight, item.ImageSizeBytes, item.ThumbWidth, item.ThumbHeight, item.ThumbSizeBytes, deleted, created.Format(time.RFC3339), updated.Format(time.RFC3339)); err != nil {
+		parentID, axes, options := variantColumns(&item)
+		if _, err = stmt.ExecContext(ctx, id, item.Name, nullIfEmpty(item.SKU), nullIfEmpty(item.Barcode), item.CostPrice, item.SalePrice, item.Stock, item.Category, nullIfEmpty(item.CategoryID), threshold, item.Description, item.ImagePath, item.ThumbPath, item.ImageHash, item.ImageWidth, item.ImageHeight, item.ImageSizeBytes, item.ThumbWidth, item.ThumbHeight, item.ThumbSizeBytes, item.IsBundle, parentID, axes, options, deleted, created.Format(time.RFC3339), updated.Format(time.RFC3339)); err != nil {
 			return fmt.Errorf("insert product from backup: %w", err)
 		}
+		if err = restoreLocationStock(ctx, tx, id, item.Locations); err != nil {
+			return err
+		}
+		for _, img := range item.Images {
+			img.ProductID = id
+			if img.ID == "" {
+				img.ID = uuid.New().String()
+			}
+			if img.CreatedAt.IsZero() {
+				img.CreatedAt = created
+			}
+			if err = insertProductImage(ctx, tx, &img); err != nil {
+				return err
+			}
+		}
+	}
+	if err = seedOpeningBatches(ctx, tx, ""); err != nil {
+		return err
+	}
+	if err = reconcileLocationStock(ctx, tx, ""); err != nil {
+		return err
 	}
 
 	if err = tx.Commit(); err != nil {
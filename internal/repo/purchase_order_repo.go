@@ -0,0 +1,472 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"smartseller-lite-starter/internal/domain"
+)
+
+type PurchaseOrderRepository struct {
+	db *sql.DB
+}
+
+func NewPurchaseOrderRepository(db *sql.DB) *PurchaseOrderRepository {
+	return &PurchaseOrderRepository{db: db}
+}
+
+// PurchaseOrderListOptions filters purchase orders; empty fields match everything.
+type PurchaseOrderListOptions struct {
+	Status     domain.PurchaseOrderStatus
+	SupplierID string
+}
+
+const purchaseOrderColumns = `po.id, po.code, po.supplier_id, IFNULL(s.name,''), po.status, IFNULL(po.notes,''), IFNULL(po.expected_at,''), IFNULL(po.ordered_at,''), IFNULL(po.last_received_at,''), po.created_at, po.updated_at,
+        (SELECT IFNULL(SUM(l.quantity * l.unit_cost),0) FROM purchase_order_lines l WHERE l.purchase_order_id = po.id),
+        (SELECT IFNULL(SUM(l.received_value),0) FROM purchase_order_lines l WHERE l.purchase_order_id = po.id),
+        (SELECT IFNULL(SUM(p.amount),0) FROM purchase_payments p WHERE p.purchase_order_id = po.id)`
+
+func scanPurchaseOrder(row rowScanner) (*domain.PurchaseOrder, error) {
+	var po domain.PurchaseOrder
+	var expected, ordered, received, created, updated string
+	if err := row.Scan(&po.ID, &po.Code, &po.SupplierID, &po.SupplierName, &po.Status, &po.Notes, &expected, &ordered, &received, &created, &updated, &po.Total, &po.ReceivedValue, &po.PaidAmount); err != nil {
+		return nil, err
+	}
+	po.ExpectedAt = parseOptionalTime(expected)
+	po.OrderedAt = parseOptionalTime(ordered)
+	po.LastReceivedAt = parseOptionalTime(received)
+	po.CreatedAt, _ = time.Parse(time.RFC3339, created)
+	po.UpdatedAt, _ = time.Parse(time.RFC3339, updated)
+	po.Outstanding = po.ReceivedValue - po.PaidAmount
+	po.Lines = []domain.PurchaseOrderLine{}
+	return &po, nil
+}
+
+func parseOptionalTime(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	ts, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+	return &ts
+}
+
+func formatOptionalTime(value *time.Time) interface{} {
+	if value == nil || value.IsZero() {
+		return nil
+	}
+	return value.UTC().Format(time.RFC3339)
+}
+
+// List returns purchase orders, newest first, with their lines.
+func (r *PurchaseOrderRepository) List(ctx context.Context, opts PurchaseOrderListOptions) ([]domain.PurchaseOrder, error) {
+	stmt := `SELECT ` + purchaseOrderColumns + ` FROM purchase_orders po LEFT JOIN suppliers s ON s.id = po.supplier_id`
+	var conditions []string
+	args := make([]any, 0, 2)
+	if opts.Status != "" {
+		conditions = append(conditions, "po.status = ?")
+		args = append(args, opts.Status)
+	}
+	if opts.SupplierID != "" {
+		conditions = append(conditions, "po.supplier_id = ?")
+		args = append(args, opts.SupplierID)
+	}
+	if len(conditions) > 0 {
+		stmt += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	rows, err := r.db.QueryContext(ctx, stmt+" ORDER BY po.created_at DESC, po.code DESC;", args...)
+	if err != nil {
+		return nil, fmt.Errorf("list purchase orders: %w", err)
+	}
+	items := make([]domain.PurchaseOrder, 0)
+	for rows.Next() {
+		po, err := scanPurchaseOrder(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan purchase order: %w", err)
+		}
+		items = append(items, *po)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate purchase orders: %w", err)
+	}
+
+	refs := make([]*domain.PurchaseOrder, len(items))
+	for i := range items {
+		refs[i] = &items[i]
+	}
+	if err := r.attachLines(ctx, refs); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// Get returns a purchase order with its lines and payments.
+func (r *PurchaseOrderRepository) Get(ctx context.Context, id string) (*domain.PurchaseOrder, error) {
+	po, err := scanPurchaseOrder(r.db.QueryRowContext(ctx, `SELECT `+purchaseOrderColumns+` FROM purchase_orders po LEFT JOIN suppliers s ON s.id = po.supplier_id WHERE po.id = ?;`, id))
+	if err != nil {
+		return nil, fmt.Errorf("get purchase order: %w", err)
+	}
+	if err := r.attachLines(ctx, []*domain.PurchaseOrder{po}); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT id, purchase_order_id, amount, IFNULL(note,''), paid_at FROM purchase_payments WHERE purchase_order_id = ? ORDER BY paid_at, created_at;`, id)
+	if err != nil {
+		return nil, fmt.Errorf("list purchase payments: %w", err)
+	}
+	defer rows.Close()
+	po.Payments = make([]domain.PurchasePayment, 0)
+	for rows.Next() {
+		var p domain.PurchasePayment
+		var paid string
+		if err := rows.Scan(&p.ID, &p.PurchaseOrderID, &p.Amount, &p.Note, &paid); err != nil {
+			return nil, fmt.Errorf("scan purchase payment: %w", err)
+		}
+		p.PaidAt, _ = time.Parse(time.RFC3339, paid)
+		po.Payments = append(po.Payments, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate purchase payments: %w", err)
+	}
+	return po, nil
+}
+
+func (r *PurchaseOrderRepository) attachLines(ctx context.Context, orders []*domain.PurchaseOrder) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	byID := make(map[string]*domain.PurchaseOrder, len(orders))
+	placeholders := make([]string, 0, len(orders))
+	args := make([]any, 0, len(orders))
+	for _, po := range orders {
+		byID[po.ID] = po
+		placeholders = append(placeholders, "?")
+		args = append(args, po.ID)
+	}
+	stmt := `SELECT l.id, l.purchase_order_id, l.product_id, IFNULL(p.name,''), IFNULL(p.sku,''), l.quantity, l.unit_cost, l.received_quantity, l.received_value
+        FROM purchase_order_lines l
+        LEFT JOIN products p ON p.id = l.product_id
+        WHERE l.purchase_order_id IN (` + strings.Join(placeholders, ",") + `)
+        ORDER BY l.sort_order, l.id;`
+	rows, err := r.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return fmt.Errorf("list purchase order lines: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var l domain.PurchaseOrderLine
+		if err := rows.Scan(&l.ID, &l.PurchaseOrderID, &l.ProductID, &l.ProductName, &l.SKU, &l.Quantity, &l.UnitCost, &l.ReceivedQuantity, &l.ReceivedValue); err != nil {
+			return fmt.Errorf("scan purchase order line: %w", err)
+		}
+		if po := byID[l.PurchaseOrderID]; po != nil {
+			po.Lines = append(po.Lines, l)
+		}
+	}
+	return rows.Err()
+}
+
+// Create inserts a purchase order and its lines. Codes run per day, e.g. PO-20240131-003.
+func (r *PurchaseOrderRepository) Create(ctx context.Context, po *domain.PurchaseOrder) (*domain.PurchaseOrder, error) {
+	now := time.Now().UTC()
+	if po.ID == "" {
+		po.ID = uuid.New().String()
+	}
+	po.CreatedAt = now
+	po.UpdatedAt = now
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	prefix := fmt.Sprintf("PO-%s-", now.Format("20060102"))
+	var last int
+	if err = tx.QueryRowContext(ctx, `SELECT IFNULL(MAX(CAST(SUBSTRING(code, ?) AS UNSIGNED)), 0) FROM purchase_orders WHERE code LIKE ? FOR UPDATE;`, len(prefix)+1, prefix+"%").Scan(&last); err != nil {
+		err = fmt.Errorf("number purchase order: %w", err)
+		return nil, err
+	}
+	po.Code = fmt.Sprintf("%s%03d", prefix, last+1)
+
+	const stmt = `INSERT INTO purchase_orders (id, code, supplier_id, status, notes, expected_at, ordered_at, last_received_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	if _, err = tx.ExecContext(ctx, stmt, po.ID, po.Code, po.SupplierID, po.Status, po.Notes, formatOptionalTime(po.ExpectedAt), formatOptionalTime(po.OrderedAt), nil, now.Format(time.RFC3339), now.Format(time.RFC3339)); err != nil {
+		err = fmt.Errorf("insert purchase order: %w", err)
+		return nil, err
+	}
+	if err = insertPurchaseOrderLines(ctx, tx, po); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("commit purchase order: %w", err)
+		return nil, err
+	}
+	return po, nil
+}
+
+// Update rewrites the header and replaces the lines of a purchase order.
+func (r *PurchaseOrderRepository) Update(ctx context.Context, po *domain.PurchaseOrder) (*domain.PurchaseOrder, error) {
+	po.UpdatedAt = time.Now().UTC()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	const stmt = `UPDATE purchase_orders SET supplier_id = ?, status = ?, notes = ?, expected_at = ?, ordered_at = ?, updated_at = ? WHERE id = ?;`
+	if _, err = tx.ExecContext(ctx, stmt, po.SupplierID, po.Status, po.Notes, formatOptionalTime(po.ExpectedAt), formatOptionalTime(po.OrderedAt), po.UpdatedAt.Format(time.RFC3339), po.ID); err != nil {
+		err = fmt.Errorf("update purchase order: %w", err)
+		return nil, err
+	}
+	if _, err = tx.ExecContext(ctx, `DELETE FROM purchase_order_lines WHERE purchase_order_id = ?;`, po.ID); err != nil {
+		err = fmt.Errorf("clear purchase order lines: %w", err)
+		return nil, err
+	}
+	if err = insertPurchaseOrderLines(ctx, tx, po); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("commit purchase order: %w", err)
+		return nil, err
+	}
+	return po, nil
+}
+
+func insertPurchaseOrderLines(ctx context.Context, tx *sql.Tx, po *domain.PurchaseOrder) error {
+	const stmt = `INSERT INTO purchase_order_lines (id, purchase_order_id, product_id, quantity, unit_cost, received_quantity, received_value, sort_order) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`
+	for i := range po.Lines {
+		line := &po.Lines[i]
+		if line.ID == "" {
+			line.ID = uuid.New().String()
+		}
+		line.PurchaseOrderID = po.ID
+		if _, err := tx.ExecContext(ctx, stmt, line.ID, po.ID, line.ProductID, line.Quantity, line.UnitCost, line.ReceivedQuantity, line.ReceivedValue, i); err != nil {
+			return fmt.Errorf("insert purchase order line: %w", err)
+		}
+	}
+	return nil
+}
+
+// SetStatus moves a purchase order to status, stamping ordered_at when it is placed.
+func (r *PurchaseOrderRepository) SetStatus(ctx context.Context, id string, status domain.PurchaseOrderStatus) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	stmt := `UPDATE purchase_orders SET status = ?, updated_at = ? WHERE id = ?;`
+	if status == domain.PurchaseOrderOrdered {
+		stmt = `UPDATE purchase_orders SET status = ?, updated_at = ?, ordered_at = IFNULL(ordered_at, updated_at) WHERE id = ?;`
+	}
+	if _, err := r.db.ExecContext(ctx, stmt, status, now, id); err != nil {
+		return fmt.Errorf("update purchase order status: %w", err)
+	}
+	return nil
+}
+
+// PurchaseReceiptLine is the quantity of one purchase order line received. UnitCost
+// is the line's ordered cost when nil.
+type PurchaseReceiptLine struct {
+	LineID    string
+	Quantity  int
+	UnitCost  *float64
+	LotNumber string
+	ExpiresAt *time.Time
+}
+
+// PurchaseReceipt is a delivery booked against a purchase order.
+type PurchaseReceipt struct {
+	Reason     string
+	Note       string
+	ReceivedAt time.Time
+	Lines      []PurchaseReceiptLine
+}
+
+// Receive books a delivery in one transaction. The order and its lines are locked and
+// the open quantities re-checked, each line is posted to the ledger with a cost batch
+// at the received cost, the receipt and the product's list cost are recorded, and the
+// order moves to received or partially received. The returned adjustments are the
+// stock changes posted.
+func (r *PurchaseOrderRepository) Receive(ctx context.Context, id string, receipt PurchaseReceipt) (adjustments []*StockAdjustment, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var status domain.PurchaseOrderStatus
+	if err = tx.QueryRowContext(ctx, `SELECT status FROM purchase_orders WHERE id = ? FOR UPDATE;`, id).Scan(&status); err != nil {
+		err = fmt.Errorf("select purchase order: %w", err)
+		return nil, err
+	}
+	if status != domain.PurchaseOrderOrdered && status != domain.PurchaseOrderPartiallyReceived {
+		err = fmt.Errorf("purchase order berstatus %s tidak dapat diterima", status)
+		return nil, err
+	}
+
+	type openLine struct {
+		productID, productName string
+		quantity, received     int
+		unitCost               float64
+	}
+	lines := make(map[string]*openLine)
+	rows, err := tx.QueryContext(ctx, `SELECT l.id, l.product_id, IFNULL(p.name,''), l.quantity, l.received_quantity, l.unit_cost
+        FROM purchase_order_lines l LEFT JOIN products p ON p.id = l.product_id
+        WHERE l.purchase_order_id = ? FOR UPDATE;`, id)
+	if err != nil {
+		err = fmt.Errorf("lock purchase order lines: %w", err)
+		return nil, err
+	}
+	for rows.Next() {
+		var lineID string
+		l := &openLine{}
+		if err = rows.Scan(&lineID, &l.productID, &l.productName, &l.quantity, &l.received, &l.unitCost); err != nil {
+			rows.Close()
+			err = fmt.Errorf("scan purchase order line: %w", err)
+			return nil, err
+		}
+		lines[lineID] = l
+	}
+	if err = rows.Err(); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	rows.Close()
+
+	stamp := receipt.ReceivedAt.UTC().Format(time.RFC3339)
+	for _, in := range receipt.Lines {
+		line, ok := lines[in.LineID]
+		if !ok {
+			err = fmt.Errorf("baris %s tidak ada pada purchase order ini", in.LineID)
+			return nil, err
+		}
+		if open := line.quantity - line.received; in.Quantity > open {
+			err = fmt.Errorf("%s hanya tersisa %d unit untuk diterima", line.productName, open)
+			return nil, err
+		}
+		unitCost := line.unitCost
+		if in.UnitCost != nil {
+			unitCost = *in.UnitCost
+		}
+		adj, moveErr := moveStock(ctx, tx, StockMove{
+			ProductID:  line.productID,
+			Delta:      in.Quantity,
+			Reason:     receipt.Reason,
+			UnitCost:   &unitCost,
+			Note:       receipt.Note,
+			ReceivedAt: receipt.ReceivedAt,
+			LotNumber:  in.LotNumber,
+			ExpiresAt:  in.ExpiresAt,
+		})
+		if moveErr != nil {
+			err = fmt.Errorf("terima %s: %w", line.productName, moveErr)
+			return nil, err
+		}
+		adjustments = append(adjustments, adj)
+		if _, err = tx.ExecContext(ctx, `UPDATE purchase_order_lines SET received_quantity = received_quantity + ?, received_value = received_value + ? WHERE id = ?;`, in.Quantity, unitCost*float64(in.Quantity), in.LineID); err != nil {
+			err = fmt.Errorf("record purchase receipt: %w", err)
+			return nil, err
+		}
+		line.received += in.Quantity
+		if err = setCostPrice(ctx, tx, line.productID, unitCost); err != nil {
+			return nil, err
+		}
+	}
+
+	status = domain.PurchaseOrderReceived
+	for _, l := range lines {
+		if l.received < l.quantity {
+			status = domain.PurchaseOrderPartiallyReceived
+			break
+		}
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err = tx.ExecContext(ctx, `UPDATE purchase_orders SET status = ?, last_received_at = ?, updated_at = ? WHERE id = ?;`, status, stamp, now, id); err != nil {
+		err = fmt.Errorf("update purchase order status: %w", err)
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit purchase receipt: %w", err)
+	}
+	return adjustments, nil
+}
+
+// AddPayment records a payment against a placed purchase order. The order is locked
+// while its total, received value and earlier payments are summed, so concurrent
+// payments can never together exceed the larger of the total and the received value.
+func (r *PurchaseOrderRepository) AddPayment(ctx context.Context, p *domain.PurchasePayment) (_ *domain.PurchasePayment, err error) {
+	now := time.Now().UTC()
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	if p.PaidAt.IsZero() {
+		p.PaidAt = now
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var status domain.PurchaseOrderStatus
+	if err = tx.QueryRowContext(ctx, `SELECT status FROM purchase_orders WHERE id = ? FOR UPDATE;`, p.PurchaseOrderID).Scan(&status); err != nil {
+		err = fmt.Errorf("select purchase order: %w", err)
+		return nil, err
+	}
+	if status == domain.PurchaseOrderDraft {
+		err = fmt.Errorf("purchase order draft belum dapat dibayar")
+		return nil, err
+	}
+	var total, received, paid float64
+	const sumStmt = `SELECT
+        (SELECT IFNULL(SUM(quantity * unit_cost),0) FROM purchase_order_lines WHERE purchase_order_id = ?),
+        (SELECT IFNULL(SUM(received_value),0) FROM purchase_order_lines WHERE purchase_order_id = ?),
+        (SELECT IFNULL(SUM(amount),0) FROM purchase_payments WHERE purchase_order_id = ?);`
+	if err = tx.QueryRowContext(ctx, sumStmt, p.PurchaseOrderID, p.PurchaseOrderID, p.PurchaseOrderID).Scan(&total, &received, &paid); err != nil {
+		err = fmt.Errorf("sum purchase order payments: %w", err)
+		return nil, err
+	}
+	limit := max(total, received)
+	if paid+p.Amount > limit+0.005 {
+		err = fmt.Errorf("pembayaran melebihi nilai purchase order; sisa %.2f", limit-paid)
+		return nil, err
+	}
+
+	const stmt = `INSERT INTO purchase_payments (id, purchase_order_id, amount, note, paid_at, created_at) VALUES (?, ?, ?, ?, ?, ?);`
+	if _, err = tx.ExecContext(ctx, stmt, p.ID, p.PurchaseOrderID, p.Amount, nullIfEmpty(p.Note), p.PaidAt.UTC().Format(time.RFC3339), now.Format(time.RFC3339)); err != nil {
+		err = fmt.Errorf("insert purchase payment: %w", err)
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit purchase payment: %w", err)
+	}
+	return p, nil
+}
+
+func (r *PurchaseOrderRepository) Delete(ctx context.Context, id string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM purchase_orders WHERE id = ?;`, id); err != nil {
+		return fmt.Errorf("delete purchase order: %w", err)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package repo
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
@@ -31,14 +32,23 @@ func (r *SettingsRepository) EnsureDefaults(ctx context.Context, brand string) e
 }
 
 func (r *SettingsRepository) Get(ctx context.Context) (*domain.AppSettings, error) {
-	const stmt = `SELECT ` + "`key`" + `, value FROM settings WHERE ` + "`key`" + ` IN ('brand_name', 'logo_path', 'logo_hash', 'logo_width', 'logo_height', 'logo_size_bytes', 'logo_mime');`
+	const stmt = `SELECT ` + "`key`" + `, value FROM settings WHERE ` + "`key`" + ` IN ('brand_name', 'logo_path', 'logo_hash', 'logo_width', 'logo_height', 'logo_size_bytes', 'logo_mime', 'costing_method', 'restock_lead_time_days', 'restock_safety_days', 'restock_cover_days', 'restock_auto_threshold');`
 	rows, err := r.db.QueryContext(ctx, stmt)
 	if err != nil {
 		return nil, fmt.Errorf("select settings: %w", err)
 	}
 	defer rows.Close()
 
-	settings := &domain.AppSettings{BrandName: "SmartSeller Lite"}
+	autoThreshold := false
+	safetyDays := domain.DefaultRestockSafetyDays
+	settings := &domain.AppSettings{
+		BrandName:            "SmartSeller Lite",
+		CostingMethod:        domain.CostingFIFO,
+		RestockLeadTimeDays:  domain.DefaultRestockLeadTimeDays,
+		RestockSafetyDays:    &safetyDays,
+		RestockCoverDays:     domain.DefaultRestockCoverDays,
+		RestockAutoThreshold: &autoThreshold,
+	}
 	for rows.Next() {
 		var key, value string
 		if err := rows.Scan(&key, &value); err != nil {
@@ -67,6 +77,24 @@ func (r *SettingsRepository) Get(ctx context.Context) (*domain.AppSettings, erro
 			}
 		case "logo_mime":
 			settings.LogoMime = value
+		case "costing_method":
+			if value != "" {
+				settings.CostingMethod = value
+			}
+		case "restock_lead_time_days":
+			if days, convErr := strconv.Atoi(value); convErr == nil && days > 0 {
+				settings.RestockLeadTimeDays = days
+			}
+		case "restock_safety_days":
+			if days, convErr := strconv.Atoi(value); convErr == nil && days >= 0 {
+				safetyDays = days
+			}
+		case "restock_cover_days":
+			if days, convErr := strconv.Atoi(value); convErr == nil && days > 0 {
+				settings.RestockCoverDays = days
+			}
+		case "restock_auto_threshold":
+			autoThreshold = value == "true"
 		}
 	}
 	if err := rows.Err(); err != nil {
@@ -110,6 +138,14 @@ func (r *SettingsRepository) Update(ctx context.Context, settings domain.AppSett
 	if _, err = tx.ExecContext(ctx, upsert, "logo_mime", settings.LogoMime, now); err != nil {
 		return nil, fmt.Errorf("save logo mime: %w", err)
 	}
+	if _, err = tx.ExecContext(ctx, upsert, "costing_method", settings.CostingMethod, now); err != nil {
+		return nil, fmt.Errorf("save costing method: %w", err)
+	}
+	for key, value := range restockSettingValues(settings) {
+		if _, err = tx.ExecContext(ctx, upsert, key, value, now); err != nil {
+			return nil, fmt.Errorf("save %s: %w", key, err)
+		}
+	}
 
 	if err = tx.Commit(); err != nil {
 		return nil, err
@@ -155,9 +191,51 @@ func (r *SettingsRepository) ReplaceAll(ctx context.Context, settings domain.App
 	if _, err = tx.ExecContext(ctx, insert, "logo_mime", settings.LogoMime, now); err != nil {
 		return fmt.Errorf("restore logo mime: %w", err)
 	}
+	if _, err = tx.ExecContext(ctx, insert, "costing_method", settings.CostingMethod, now); err != nil {
+		return fmt.Errorf("restore costing method: %w", err)
+	}
+	for key, value := range restockSettingValues(settings) {
+		if _, err = tx.ExecContext(ctx, insert, key, value, now); err != nil {
+			return fmt.Errorf("restore %s: %w", key, err)
+		}
+	}
 
 	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("commit settings restore: %w", err)
 	}
 	return nil
 }
+
+// restockSettingValues returns the stored form of the restock planning settings,
+// leaving out those that are unset.
+func restockSettingValues(settings domain.AppSettings) map[string]string {
+	values := make(map[string]string)
+	if settings.RestockLeadTimeDays > 0 {
+		values["restock_lead_time_days"] = strconv.Itoa(settings.RestockLeadTimeDays)
+	}
+	if settings.RestockSafetyDays != nil && *settings.RestockSafetyDays >= 0 {
+		values["restock_safety_days"] = strconv.Itoa(*settings.RestockSafetyDays)
+	}
+	if settings.RestockCoverDays > 0 {
+		values["restock_cover_days"] = strconv.Itoa(settings.RestockCoverDays)
+	}
+	if settings.RestockAutoThreshold != nil {
+		values["restock_auto_threshold"] = strconv.FormatBool(*settings.RestockAutoThreshold)
+	}
+	return values
+}
+
+// costingMethod reads the configured costing method, defaulting to FIFO.
+func costingMethod(ctx context.Context, q interface {
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+}) (string, error) {
+	var value string
+	err := q.QueryRowContext(ctx, `SELECT value FROM settings WHERE `+"`key`"+` = 'costing_method';`).Scan(&value)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("select costing method: %w", err)
+	}
+	if value != domain.CostingAverage {
+		value = domain.CostingFIFO
+	}
+	return value, nil
+}
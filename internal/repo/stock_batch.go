@@ -0,0 +1,279 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"smartseller-lite-starter/internal/domain"
+)
+
+// openingBatchReference marks batches created for stock that existed before it was
+// tracked in batches, valued at the product's list cost.
+const openingBatchReference = "opening"
+
+type execQuerier interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...any) *sql.Row
+}
+
+// receiveBatch opens a batch for incoming units and folds them into the product's
+// average cost. It returns the value received.
+func receiveBatch(ctx context.Context, tx *sql.Tx, move StockMove, previousStock int, averageCost float64, now time.Time) (float64, error) {
+	unitCost := averageCost
+	if move.UnitCost != nil {
+		unitCost = *move.UnitCost
+	}
+	receivedAt := move.ReceivedAt
+	if receivedAt.IsZero() {
+		receivedAt = now
+	}
+	var expiresAt any
+	if move.ExpiresAt != nil {
+		expiresAt = move.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	const stmt = `INSERT INTO stock_batches (id, product_id, reference, note, unit_cost, quantity, remaining, lot_number, expires_at, received_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	if _, err := tx.ExecContext(ctx, stmt, uuid.New().String(), move.ProductID, move.Reason, nullIfEmpty(move.Note), unitCost, move.Delta, move.Delta, nullIfEmpty(move.LotNumber), expiresAt, receivedAt.UTC().Format(time.RFC3339), now.Format(time.RFC3339)); err != nil {
+		return 0, fmt.Errorf("insert stock batch: %w", err)
+	}
+
+	average := unitCost
+	if previousStock > 0 {
+		average = (float64(previousStock)*averageCost + float64(move.Delta)*unitCost) / float64(previousStock+move.Delta)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE products SET average_cost = ? WHERE id = ?;`, average, move.ProductID); err != nil {
+		return 0, fmt.Errorf("update average cost: %w", err)
+	}
+	return unitCost * float64(move.Delta), nil
+}
+
+type openBatch struct {
+	id        string
+	unitCost  float64
+	remaining int
+	lotNumber string
+	expiresAt *time.Time
+}
+
+// ErrExpiredStock is returned when a move that may not take expired lots needs more
+// than the unexpired lots and unbatched units hold.
+var ErrExpiredStock = errors.New("stok yang belum kedaluwarsa tidak mencukupi")
+
+// consumeBatches takes qty units out of the batches that expire first, then the oldest
+// undated ones, and records what was used. stock is what the product held before the
+// move. With skipExpired, lots past their expiry are never touched: the units come from
+// the other lots and from stock no batch holds, and ErrExpiredStock is returned when
+// those cannot cover qty. Under FIFO each unit costs what its batch
+// cost; under the average method every unit costs the current average. Units beyond
+// what the batches hold, which only happens when stock was edited outside the ledger,
+// are costed at the average. It returns the cost of goods moved out and the dated or
+// numbered lots they came from.
+func consumeBatches(ctx context.Context, tx *sql.Tx, productID string, stock, qty int, reason string, averageCost float64, skipExpired bool, now time.Time) (float64, []domain.LotAllocation, error) {
+	method, err := costingMethod(ctx, tx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	const order = "expires_at IS NULL, expires_at, received_at, created_at, id"
+	rows, err := tx.QueryContext(ctx, `SELECT id, unit_cost, remaining, IFNULL(lot_number,''), expires_at FROM stock_batches WHERE product_id = ? AND remaining > 0 ORDER BY `+order+` FOR UPDATE;`, productID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("select stock batches: %w", err)
+	}
+	batches := make([]openBatch, 0)
+	for rows.Next() {
+		var b openBatch
+		var expires sql.NullString
+		if err := rows.Scan(&b.id, &b.unitCost, &b.remaining, &b.lotNumber, &expires); err != nil {
+			rows.Close()
+			return 0, nil, fmt.Errorf("scan stock batch: %w", err)
+		}
+		b.expiresAt = parseNullTime(expires)
+		batches = append(batches, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, fmt.Errorf("iterate stock batches: %w", err)
+	}
+	expired := func(b openBatch) bool {
+		return skipExpired && b.expiresAt != nil && !b.expiresAt.After(now)
+	}
+	if skipExpired {
+		sellable := stock
+		for _, b := range batches {
+			if expired(b) {
+				sellable -= b.remaining
+			}
+		}
+		if qty > sellable {
+			return 0, nil, ErrExpiredStock
+		}
+	}
+
+	const consumeStmt = `INSERT INTO stock_batch_consumptions (id, batch_id, product_id, reason, quantity, unit_cost, created_at) VALUES (?, ?, ?, ?, ?, ?, ?);`
+	var total, remainingValue float64
+	var remainingUnits int
+	lots := make([]domain.LotAllocation, 0)
+	need := qty
+	for _, b := range batches {
+		take := b.remaining
+		if take > need {
+			take = need
+		}
+		if expired(b) {
+			take = 0
+		}
+		if take > 0 {
+			unitCost := b.unitCost
+			if method == domain.CostingAverage {
+				unitCost = averageCost
+			}
+			if _, err := tx.ExecContext(ctx, `UPDATE stock_batches SET remaining = remaining - ? WHERE id = ?;`, take, b.id); err != nil {
+				return 0, nil, fmt.Errorf("consume stock batch: %w", err)
+			}
+			if _, err := tx.ExecContext(ctx, consumeStmt, uuid.New().String(), b.id, productID, reason, take, unitCost, now.Format(time.RFC3339)); err != nil {
+				return 0, nil, fmt.Errorf("insert batch consumption: %w", err)
+			}
+			if b.lotNumber != "" || b.expiresAt != nil {
+				lots = append(lots, domain.LotAllocation{ProductID: productID, BatchID: b.id, LotNumber: b.lotNumber, ExpiresAt: b.expiresAt, Quantity: take})
+			}
+			total += unitCost * float64(take)
+			need -= take
+		}
+		remainingUnits += b.remaining - take
+		remainingValue += b.unitCost * float64(b.remaining-take)
+	}
+	if need > 0 {
+		if _, err := tx.ExecContext(ctx, consumeStmt, uuid.New().String(), nil, productID, reason, need, averageCost, now.Format(time.RFC3339)); err != nil {
+			return 0, nil, fmt.Errorf("insert batch consumption: %w", err)
+		}
+		total += averageCost * float64(need)
+	}
+
+	// Under FIFO the average follows what is left in the batches, so units received
+	// without a cost later come in at the value of the stock on hand.
+	if method == domain.CostingFIFO && remainingUnits > 0 {
+		if _, err := tx.ExecContext(ctx, `UPDATE products SET average_cost = ? WHERE id = ?;`, remainingValue/float64(remainingUnits), productID); err != nil {
+			return 0, nil, fmt.Errorf("update average cost: %w", err)
+		}
+	}
+	return total, lots, nil
+}
+
+// SeedOpeningBatches gives stocked products without any batch an opening batch at
+// their list cost. It is idempotent and runs on start-up.
+func (r *ProductRepository) SeedOpeningBatches(ctx context.Context) error {
+	return seedOpeningBatches(ctx, r.db, "")
+}
+
+// seedOpeningBatches opens batches for one product, or for all when productID is empty.
+func seedOpeningBatches(ctx context.Context, db execQuerier, productID string) error {
+	stmt := `INSERT INTO stock_batches (id, product_id, reference, note, unit_cost, quantity, remaining, received_at, created_at)
+        SELECT UUID(), p.id, '` + openingBatchReference + `', NULL, p.cost_price, p.stock, p.stock, p.created_at, ?
+        FROM products p
+        WHERE p.stock > 0 AND p.is_bundle = FALSE AND IFNULL(p.option_axes,'') = ''
+          AND NOT EXISTS (SELECT 1 FROM stock_batches b WHERE b.product_id = p.id)`
+	args := []any{time.Now().UTC().Format(time.RFC3339)}
+	if productID != "" {
+		stmt += " AND p.id = ?"
+		args = append(args, productID)
+	}
+	if _, err := db.ExecContext(ctx, stmt+";", args...); err != nil {
+		return fmt.Errorf("seed opening batches: %w", err)
+	}
+	return nil
+}
+
+// ListBatches returns the batches of a product, newest first. Depleted batches are
+// left out unless includeDepleted is set.
+func (r *ProductRepository) ListBatches(ctx context.Context, productID string, includeDepleted bool) ([]domain.StockBatch, error) {
+	stmt := `SELECT id, product_id, reference, IFNULL(note,''), unit_cost, quantity, remaining, IFNULL(lot_number,''), expires_at, received_at, created_at FROM stock_batches WHERE product_id = ?`
+	if !includeDepleted {
+		stmt += " AND remaining > 0"
+	}
+	rows, err := r.db.QueryContext(ctx, stmt+" ORDER BY received_at DESC, created_at DESC, id;", productID)
+	if err != nil {
+		return nil, fmt.Errorf("list stock batches: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]domain.StockBatch, 0)
+	for rows.Next() {
+		var b domain.StockBatch
+		var received, created string
+		var expires sql.NullString
+		if err := rows.Scan(&b.ID, &b.ProductID, &b.Reference, &b.Note, &b.UnitCost, &b.Quantity, &b.Remaining, &b.LotNumber, &expires, &received, &created); err != nil {
+			return nil, fmt.Errorf("scan stock batch: %w", err)
+		}
+		b.ExpiresAt = parseNullTime(expires)
+		b.ReceivedAt, _ = time.Parse(time.RFC3339, received)
+		b.CreatedAt, _ = time.Parse(time.RFC3339, created)
+		items = append(items, b)
+	}
+	return items, rows.Err()
+}
+
+// StockValuation is the value of one product's stock on hand.
+type StockValuation struct {
+	ProductID  string
+	SKU        string
+	Name       string
+	CategoryID string
+	Stock      int
+	Value      float64
+	ListCost   float64
+	SalePrice  float64
+}
+
+// InventoryValuation values every stock-keeping product under the configured costing
+// method: FIFO sums what remains in the batches, the average method multiplies the
+// stock by the moving average cost. Stock the batches do not cover is valued at the
+// average cost.
+func (r *ProductRepository) InventoryValuation(ctx context.Context) (string, []StockValuation, error) {
+	method, err := costingMethod(ctx, r.db)
+	if err != nil {
+		return "", nil, err
+	}
+	const stmt = `SELECT p.id, IFNULL(p.sku,''), p.name, IFNULL(p.category_id,''), p.stock, p.cost_price, p.sale_price, IFNULL(p.average_cost, p.cost_price),
+        IFNULL(SUM(b.remaining),0), IFNULL(SUM(b.remaining * b.unit_cost),0)
+        FROM products p
+        LEFT JOIN stock_batches b ON b.product_id = p.id AND b.remaining > 0
+        WHERE p.deleted_at IS NULL AND p.is_bundle = FALSE AND IFNULL(p.option_axes,'') = ''
+        GROUP BY p.id, p.sku, p.name, p.category_id, p.stock, p.cost_price, p.sale_price, p.average_cost
+        ORDER BY p.name;`
+	rows, err := r.db.QueryContext(ctx, stmt)
+	if err != nil {
+		return "", nil, fmt.Errorf("query inventory valuation: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]StockValuation, 0)
+	for rows.Next() {
+		var v StockValuation
+		var costPrice, averageCost, batchValue float64
+		var batchUnits int
+		if err := rows.Scan(&v.ProductID, &v.SKU, &v.Name, &v.CategoryID, &v.Stock, &costPrice, &v.SalePrice, &averageCost, &batchUnits, &batchValue); err != nil {
+			return "", nil, fmt.Errorf("scan inventory valuation: %w", err)
+		}
+		v.ListCost = costPrice * float64(v.Stock)
+		switch {
+		case method == domain.CostingAverage:
+			v.Value = averageCost * float64(v.Stock)
+		case batchUnits >= v.Stock:
+			if batchUnits > 0 {
+				v.Value = batchValue * float64(v.Stock) / float64(batchUnits)
+			}
+		default:
+			v.Value = batchValue + averageCost*float64(v.Stock-batchUnits)
+		}
+		items = append(items, v)
+	}
+	if err := rows.Err(); err != nil {
+		return "", nil, fmt.Errorf("iterate inventory valuation: %w", err)
+	}
+	return method, items, nil
+}
@@ -0,0 +1,372 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"smartseller-lite-starter/internal/audit"
+	"smartseller-lite-starter/internal/domain"
+)
+
+// ErrLocationNotFound is returned when a stock change names an unknown location.
+var ErrLocationNotFound = errors.New("lokasi tidak ditemukan")
+
+// transferReasonPrefix prefixes the mutation reason of both legs of a transfer.
+const transferReasonPrefix = "transfer:"
+
+// stockLocation resolves a location id or code, or the default location when ref is
+// empty, to its id and name.
+func stockLocation(ctx context.Context, q execQuerier, ref string) (id, name string, err error) {
+	if ref == "" {
+		err = q.QueryRowContext(ctx, `SELECT id, name FROM locations WHERE is_default = TRUE LIMIT 1;`).Scan(&id, &name)
+	} else {
+		err = q.QueryRowContext(ctx, `SELECT id, name FROM locations WHERE id = ? OR code = ? LIMIT 1;`, ref, strings.ToUpper(ref)).Scan(&id, &name)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", ErrLocationNotFound
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("select location: %w", err)
+	}
+	return id, name, nil
+}
+
+// shiftLocationStock changes the quantity of a product at a location, refusing to take
+// it below zero. The row is locked for the rest of the transaction.
+func shiftLocationStock(ctx context.Context, tx *sql.Tx, productID, locationID, locationName, productName string, delta int) error {
+	var held int
+	err := tx.QueryRowContext(ctx, `SELECT quantity FROM product_location_stock WHERE product_id = ? AND location_id = ? FOR UPDATE;`, productID, locationID).Scan(&held)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("select location stock: %w", err)
+	}
+	if held+delta < 0 {
+		return fmt.Errorf("stok %s di lokasi %s tidak cukup (tersedia %d)", productName, locationName, held)
+	}
+	const stmt = `INSERT INTO product_location_stock (product_id, location_id, quantity) VALUES (?, ?, ?)
+        ON DUPLICATE KEY UPDATE quantity = quantity + VALUES(quantity);`
+	if _, err := tx.ExecContext(ctx, stmt, productID, locationID, delta); err != nil {
+		return fmt.Errorf("update location stock: %w", err)
+	}
+	return nil
+}
+
+// reconcileLocationStock makes the per-location quantities of one product, or of all
+// products when productID is empty, add up to products.stock. Stock edited directly on
+// the product lands in, or is taken from, the default location first.
+func reconcileLocationStock(ctx context.Context, q execQuerier, productID string) error {
+	const held = `IFNULL((SELECT SUM(s.quantity) FROM product_location_stock s WHERE s.product_id = p.id), 0)`
+	filter := ""
+	args := []any{}
+	if productID != "" {
+		filter = " AND p.id = ?"
+		args = append(args, productID)
+	}
+
+	surplus := `INSERT INTO product_location_stock (product_id, location_id, quantity)
+        SELECT p.id, d.id, p.stock - ` + held + `
+        FROM products p JOIN locations d ON d.is_default = TRUE
+        WHERE p.stock > ` + held + filter + `
+        ON DUPLICATE KEY UPDATE quantity = quantity + VALUES(quantity);`
+	if _, err := q.ExecContext(ctx, surplus, args...); err != nil {
+		return fmt.Errorf("reconcile location stock: %w", err)
+	}
+
+	rows, err := q.QueryContext(ctx, `SELECT p.id, `+held+` - p.stock FROM products p WHERE p.stock < `+held+filter+`;`, args...)
+	if err != nil {
+		return fmt.Errorf("select excess location stock: %w", err)
+	}
+	excess := make(map[string]int)
+	for rows.Next() {
+		var id string
+		var qty int
+		if err := rows.Scan(&id, &qty); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan excess location stock: %w", err)
+		}
+		excess[id] = qty
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate excess location stock: %w", err)
+	}
+	for id, qty := range excess {
+		if err := trimLocationStock(ctx, q, id, qty); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trimLocationStock removes qty units of a product from its locations, default first.
+func trimLocationStock(ctx context.Context, q execQuerier, productID string, qty int) error {
+	rows, err := q.QueryContext(ctx, `SELECT s.location_id, s.quantity FROM product_location_stock s JOIN locations l ON l.id = s.location_id
+        WHERE s.product_id = ? AND s.quantity > 0 ORDER BY l.is_default DESC, s.quantity DESC;`, productID)
+	if err != nil {
+		return fmt.Errorf("select location stock: %w", err)
+	}
+	type held struct {
+		locationID string
+		quantity   int
+	}
+	holdings := make([]held, 0)
+	for rows.Next() {
+		var h held
+		if err := rows.Scan(&h.locationID, &h.quantity); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan location stock: %w", err)
+		}
+		holdings = append(holdings, h)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate location stock: %w", err)
+	}
+	for _, h := range holdings {
+		if qty == 0 {
+			break
+		}
+		take := h.quantity
+		if take > qty {
+			take = qty
+		}
+		if _, err := q.ExecContext(ctx, `UPDATE product_location_stock SET quantity = quantity - ? WHERE product_id = ? AND location_id = ?;`, take, productID, h.locationID); err != nil {
+			return fmt.Errorf("trim location stock: %w", err)
+		}
+		qty -= take
+	}
+	return nil
+}
+
+// restoreLocationStock puts back per-location quantities carried by a backup, skipping
+// locations that no longer exist.
+func restoreLocationStock(ctx context.Context, tx *sql.Tx, productID string, locations []domain.LocationStock) error {
+	const stmt = `INSERT INTO product_location_stock (product_id, location_id, quantity)
+        SELECT ?, id, ? FROM locations WHERE id = ?
+        ON DUPLICATE KEY UPDATE quantity = VALUES(quantity);`
+	for _, l := range locations {
+		if l.Quantity <= 0 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, stmt, productID, l.Quantity, l.LocationID); err != nil {
+			return fmt.Errorf("restore location stock: %w", err)
+		}
+	}
+	return nil
+}
+
+// ProductLocations returns the per-location quantities of the given products.
+func (r *ProductRepository) ProductLocations(ctx context.Context, productIDs []string) (map[string][]domain.LocationStock, error) {
+	result := make(map[string][]domain.LocationStock)
+	if len(productIDs) == 0 {
+		return result, nil
+	}
+	placeholders := make([]string, len(productIDs))
+	args := make([]any, len(productIDs))
+	for i, id := range productIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	stmt := `SELECT s.product_id, l.id, l.code, l.name, s.quantity
+        FROM product_location_stock s
+        JOIN locations l ON l.id = s.location_id
+        WHERE s.quantity <> 0 AND s.product_id IN (` + strings.Join(placeholders, ",") + `)
+        ORDER BY l.is_default DESC, l.name;`
+	rows, err := r.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list product locations: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var productID string
+		var ls domain.LocationStock
+		if err := rows.Scan(&productID, &ls.LocationID, &ls.LocationCode, &ls.LocationName, &ls.Quantity); err != nil {
+			return nil, fmt.Errorf("scan product location: %w", err)
+		}
+		result[productID] = append(result[productID], ls)
+	}
+	return result, rows.Err()
+}
+
+// StockAt returns how many units of each product are held at a location.
+func (r *ProductRepository) StockAt(ctx context.Context, locationID string, productIDs []string) (map[string]int, error) {
+	result := make(map[string]int, len(productIDs))
+	if len(productIDs) == 0 {
+		return result, nil
+	}
+	placeholders := make([]string, len(productIDs))
+	args := []any{locationID}
+	for i, id := range productIDs {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+	stmt := `SELECT product_id, quantity FROM product_location_stock WHERE location_id = ? AND product_id IN (` + strings.Join(placeholders, ",") + `);`
+	rows, err := r.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("select location stock: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var qty int
+		if err := rows.Scan(&id, &qty); err != nil {
+			return nil, fmt.Errorf("scan location stock: %w", err)
+		}
+		result[id] = qty
+	}
+	return result, rows.Err()
+}
+
+// Transfer moves stock between two locations in one transaction. The product totals
+// and cost batches do not change; each item is recorded as a pair of mutations.
+func (r *ProductRepository) Transfer(ctx context.Context, t *domain.StockTransfer) (*domain.StockTransfer, error) {
+	now := time.Now().UTC()
+	t.ID = uuid.New().String()
+	t.Actor = audit.Actor(ctx)
+	t.CreatedAt = now
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var fromName, toName string
+	if t.FromLocationID, fromName, err = stockLocation(ctx, tx, t.FromLocationID); err != nil {
+		return nil, err
+	}
+	if t.ToLocationID, toName, err = stockLocation(ctx, tx, t.ToLocationID); err != nil {
+		return nil, err
+	}
+	if t.FromLocationID == t.ToLocationID {
+		err = errors.New("lokasi asal dan tujuan harus berbeda")
+		return nil, err
+	}
+
+	prefix := fmt.Sprintf("TRF-%s-", now.Format("20060102"))
+	var last int
+	if err = tx.QueryRowContext(ctx, `SELECT IFNULL(MAX(CAST(SUBSTRING(code, ?) AS UNSIGNED)), 0) FROM stock_transfers WHERE code LIKE ? FOR UPDATE;`, len(prefix)+1, prefix+"%").Scan(&last); err != nil {
+		err = fmt.Errorf("number stock transfer: %w", err)
+		return nil, err
+	}
+	t.Code = fmt.Sprintf("%s%03d", prefix, last+1)
+	reason := transferReasonPrefix + t.Code
+
+	const transferStmt = `INSERT INTO stock_transfers (id, code, from_location_id, to_location_id, note, actor, created_at) VALUES (?, ?, ?, ?, ?, ?, ?);`
+	if _, err = tx.ExecContext(ctx, transferStmt, t.ID, t.Code, t.FromLocationID, t.ToLocationID, nullIfEmpty(t.Note), t.Actor, now.Format(time.RFC3339)); err != nil {
+		err = fmt.Errorf("insert stock transfer: %w", err)
+		return nil, err
+	}
+
+	const itemStmt = `INSERT INTO stock_transfer_items (id, transfer_id, product_id, quantity) VALUES (?, ?, ?, ?);`
+	const mutationStmt = `INSERT INTO stock_mutations (id, product_id, location_id, delta, reason, created_at) VALUES (?, ?, ?, ?, ?, ?);`
+	for i := range t.Items {
+		item := &t.Items[i]
+		var isBundle, hasVariants bool
+		if err = tx.QueryRowContext(ctx, `SELECT name, IFNULL(sku,''), is_bundle, IFNULL(option_axes,'') <> '' FROM products WHERE id = ? AND deleted_at IS NULL FOR UPDATE;`, item.ProductID).Scan(&item.ProductName, &item.SKU, &isBundle, &hasVariants); err != nil {
+			err = fmt.Errorf("select product %s: %w", item.ProductID, err)
+			return nil, err
+		}
+		if isBundle {
+			err = ErrBundleStock
+			return nil, err
+		}
+		if hasVariants {
+			err = ErrVariantParentStock
+			return nil, err
+		}
+		if err = reconcileLocationStock(ctx, tx, item.ProductID); err != nil {
+			return nil, err
+		}
+		if err = shiftLocationStock(ctx, tx, item.ProductID, t.FromLocationID, fromName, item.ProductName, -item.Quantity); err != nil {
+			return nil, err
+		}
+		if err = shiftLocationStock(ctx, tx, item.ProductID, t.ToLocationID, toName, item.ProductName, item.Quantity); err != nil {
+			return nil, err
+		}
+		if _, err = tx.ExecContext(ctx, itemStmt, uuid.New().String(), t.ID, item.ProductID, item.Quantity); err != nil {
+			err = fmt.Errorf("insert stock transfer item: %w", err)
+			return nil, err
+		}
+		if _, err = tx.ExecContext(ctx, mutationStmt, uuid.New().String(), item.ProductID, t.FromLocationID, -item.Quantity, reason, now.Format(time.RFC3339)); err != nil {
+			err = fmt.Errorf("insert stock mutation: %w", err)
+			return nil, err
+		}
+		if _, err = tx.ExecContext(ctx, mutationStmt, uuid.New().String(), item.ProductID, t.ToLocationID, item.Quantity, reason, now.Format(time.RFC3339)); err != nil {
+			err = fmt.Errorf("insert stock mutation: %w", err)
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("commit stock transfer: %w", err)
+		return nil, err
+	}
+	return t, nil
+}
+
+// ListTransfers returns the most recent stock transfers, newest first.
+func (r *ProductRepository) ListTransfers(ctx context.Context, limit int) ([]domain.StockTransfer, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := r.db.QueryContext(ctx, `SELECT id, code, from_location_id, to_location_id, IFNULL(note,''), actor, created_at FROM stock_transfers ORDER BY created_at DESC, code DESC LIMIT ?;`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list stock transfers: %w", err)
+	}
+	transfers := make([]domain.StockTransfer, 0)
+	index := make(map[string]int)
+	for rows.Next() {
+		var t domain.StockTransfer
+		var created string
+		if err := rows.Scan(&t.ID, &t.Code, &t.FromLocationID, &t.ToLocationID, &t.Note, &t.Actor, &created); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan stock transfer: %w", err)
+		}
+		t.CreatedAt, _ = time.Parse(time.RFC3339, created)
+		t.Items = make([]domain.StockTransferItem, 0)
+		index[t.ID] = len(transfers)
+		transfers = append(transfers, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate stock transfers: %w", err)
+	}
+	if len(transfers) == 0 {
+		return transfers, nil
+	}
+
+	placeholders := make([]string, 0, len(transfers))
+	args := make([]any, 0, len(transfers))
+	for _, t := range transfers {
+		placeholders = append(placeholders, "?")
+		args = append(args, t.ID)
+	}
+	itemRows, err := r.db.QueryContext(ctx, `SELECT i.transfer_id, i.product_id, p.name, IFNULL(p.sku,''), i.quantity
+        FROM stock_transfer_items i JOIN products p ON p.id = i.product_id
+        WHERE i.transfer_id IN (`+strings.Join(placeholders, ",")+`) ORDER BY p.name;`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list stock transfer items: %w", err)
+	}
+	defer itemRows.Close()
+	for itemRows.Next() {
+		var transferID string
+		var item domain.StockTransferItem
+		if err := itemRows.Scan(&transferID, &item.ProductID, &item.ProductName, &item.SKU, &item.Quantity); err != nil {
+			return nil, fmt.Errorf("scan stock transfer item: %w", err)
+		}
+		if i, ok := index[transferID]; ok {
+			transfers[i].Items = append(transfers[i].Items, item)
+		}
+	}
+	return transfers, itemRows.Err()
+}
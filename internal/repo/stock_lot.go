@@ -0,0 +1,96 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"smartseller-lite-starter/internal/domain"
+)
+
+// ExpiryWarningDays is how far ahead the product listing warns about expiring lots.
+const ExpiryWarningDays = 30
+
+// ExpiringLots returns the lots with stock left that expire before the given time,
+// expired ones included, soonest first. A limit of zero lists them all.
+func (r *ProductRepository) ExpiringLots(ctx context.Context, before time.Time, limit int) ([]domain.ExpiringLot, error) {
+	return r.expiringLots(ctx, "WHERE products.deleted_at IS NULL", nil, before, limit)
+}
+
+// expiringLots lists expiring lots of the products matching a WHERE clause on products.
+func (r *ProductRepository) expiringLots(ctx context.Context, productClause string, args []any, before time.Time, limit int) ([]domain.ExpiringLot, error) {
+	stmt := `SELECT b.id, b.product_id, products.name, IFNULL(products.sku,''), IFNULL(b.lot_number,''), b.expires_at, b.remaining, b.unit_cost
+        FROM stock_batches b
+        JOIN products ON products.id = b.product_id ` + productClause + `
+          AND b.remaining > 0 AND b.expires_at IS NOT NULL AND b.expires_at < ?
+        ORDER BY b.expires_at, products.name`
+	args = append(append([]any{}, args...), before.UTC().Format(time.RFC3339))
+	if limit > 0 {
+		stmt += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := r.db.QueryContext(ctx, stmt+";", args...)
+	if err != nil {
+		return nil, fmt.Errorf("list expiring lots: %w", err)
+	}
+	defer rows.Close()
+
+	now := time.Now().UTC()
+	items := make([]domain.ExpiringLot, 0)
+	for rows.Next() {
+		var lot domain.ExpiringLot
+		var expires string
+		var unitCost float64
+		if err := rows.Scan(&lot.BatchID, &lot.ProductID, &lot.Name, &lot.SKU, &lot.LotNumber, &expires, &lot.Remaining, &unitCost); err != nil {
+			return nil, fmt.Errorf("scan expiring lot: %w", err)
+		}
+		lot.ExpiresAt, _ = time.Parse(time.RFC3339, expires)
+		lot.Value = unitCost * float64(lot.Remaining)
+		lot.Expired = !lot.ExpiresAt.After(now)
+		lot.DaysLeft = int(math.Ceil(lot.ExpiresAt.Sub(now).Hours() / 24))
+		items = append(items, lot)
+	}
+	return items, rows.Err()
+}
+
+// countExpiringLots counts what expiringLots would list without a limit.
+func (r *ProductRepository) countExpiringLots(ctx context.Context, productClause string, args []any, before time.Time) (int, error) {
+	stmt := `SELECT COUNT(*) FROM stock_batches b JOIN products ON products.id = b.product_id ` + productClause + `
+          AND b.remaining > 0 AND b.expires_at IS NOT NULL AND b.expires_at < ?;`
+	var count int
+	if err := r.db.QueryRowContext(ctx, stmt, append(append([]any{}, args...), before.UTC().Format(time.RFC3339))...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count expiring lots: %w", err)
+	}
+	return count, nil
+}
+
+// ExpiredStock returns, per product, the units left in lots that have expired.
+func (r *ProductRepository) ExpiredStock(ctx context.Context, productIDs []string) (map[string]int, error) {
+	result := make(map[string]int)
+	if len(productIDs) == 0 {
+		return result, nil
+	}
+	args := []any{time.Now().UTC().Format(time.RFC3339)}
+	for _, id := range productIDs {
+		args = append(args, id)
+	}
+	stmt := `SELECT product_id, SUM(remaining) FROM stock_batches
+        WHERE remaining > 0 AND expires_at IS NOT NULL AND expires_at <= ? AND product_id IN (` + strings.TrimSuffix(strings.Repeat("?,", len(productIDs)), ",") + `)
+        GROUP BY product_id;`
+	rows, err := r.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("select expired stock: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var units int
+		if err := rows.Scan(&id, &units); err != nil {
+			return nil, fmt.Errorf("scan expired stock: %w", err)
+		}
+		result[id] = units
+	}
+	return result, rows.Err()
+}
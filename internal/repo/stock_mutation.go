@@ -0,0 +1,219 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"smartseller-lite-starter/internal/domain"
+)
+
+// StockMutationListOptions filters the stock ledger. Reasons are prefixes such as
+// "order:", "stock_opname:" or "manual"; a mutation matching any of them is listed.
+// "manual" also matches adjustments booked under a reason code ("adjust:<code>").
+type StockMutationListOptions struct {
+	ProductID string
+	Reasons   []string
+	DateStart *time.Time
+	DateEnd   *time.Time
+	Page      int
+	PageSize  int
+}
+
+type StockMutationListResult struct {
+	Items    []domain.StockMutation
+	Total    int
+	Page     int
+	PageSize int
+}
+
+// likePrefix escapes a literal prefix for use in a LIKE pattern.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+}
+
+// ListMutations pages through the stock ledger, newest first. Each entry carries the
+// product's stock right after it, summed from the ledger up to and including the
+// entry, and the document it came from.
+func (r *ProductRepository) ListMutations(ctx context.Context, opts StockMutationListOptions) (StockMutationListResult, error) {
+	page := opts.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := opts.PageSize
+	if pageSize <= 0 || pageSize > 500 {
+		pageSize = 50
+	}
+
+	whereParts := make([]string, 0)
+	args := make([]any, 0)
+	if opts.ProductID != "" {
+		whereParts = append(whereParts, "m.product_id = ?")
+		args = append(args, opts.ProductID)
+	}
+	reasonParts := make([]string, 0, len(opts.Reasons))
+	for _, reason := range opts.Reasons {
+		if reason = strings.TrimSpace(reason); reason == "" {
+			continue
+		}
+		reasonParts = append(reasonParts, "m.reason LIKE ?")
+		args = append(args, likePrefix(reason))
+		if reason == "manual" {
+			reasonParts = append(reasonParts, "m.reason LIKE ?")
+			args = append(args, likePrefix(AdjustmentReasonPrefix))
+		}
+	}
+	if len(reasonParts) > 0 {
+		whereParts = append(whereParts, "("+strings.Join(reasonParts, " OR ")+")")
+	}
+	if opts.DateStart != nil {
+		whereParts = append(whereParts, "m.created_at >= ?")
+		args = append(args, opts.DateStart.UTC().Format(time.RFC3339))
+	}
+	if opts.DateEnd != nil {
+		end := opts.DateEnd.UTC().Add(24 * time.Hour)
+		whereParts = append(whereParts, "m.created_at < ?")
+		args = append(args, end.Format(time.RFC3339))
+	}
+	whereClause := ""
+	if len(whereParts) > 0 {
+		whereClause = " WHERE " + strings.Join(whereParts, " AND ")
+	}
+
+	result := StockMutationListResult{Items: make([]domain.StockMutation, 0), Page: page, PageSize: pageSize}
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM stock_mutations m"+whereClause+";", args...).Scan(&result.Total); err != nil {
+		return StockMutationListResult{}, fmt.Errorf("count stock mutations: %w", err)
+	}
+
+	stmt := `SELECT m.id, m.product_id, p.name, IFNULL(p.sku,''), IFNULL(m.location_id,''), IFNULL(l.name,''), m.delta, m.reason, m.created_at,
+        (SELECT SUM(n.delta) FROM stock_mutations n
+            WHERE n.product_id = m.product_id AND (n.created_at < m.created_at OR (n.created_at = m.created_at AND n.seq <= m.seq)))
+        FROM stock_mutations m
+        JOIN products p ON p.id = m.product_id
+        LEFT JOIN locations l ON l.id = m.location_id` + whereClause + `
+        ORDER BY m.created_at DESC, m.seq DESC LIMIT ? OFFSET ?;`
+	rows, err := r.db.QueryContext(ctx, stmt, append(args, pageSize, (page-1)*pageSize)...)
+	if err != nil {
+		return StockMutationListResult{}, fmt.Errorf("list stock mutations: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m domain.StockMutation
+		var created string
+		if err := rows.Scan(&m.ID, &m.ProductID, &m.ProductName, &m.SKU, &m.LocationID, &m.LocationName, &m.Delta, &m.Reason, &created, &m.Balance); err != nil {
+			return StockMutationListResult{}, fmt.Errorf("scan stock mutation: %w", err)
+		}
+		m.CreatedAt, _ = time.Parse(time.RFC3339, created)
+		m.Source = mutationSource(m.Reason)
+		result.Items = append(result.Items, m)
+	}
+	if err := rows.Err(); err != nil {
+		return StockMutationListResult{}, fmt.Errorf("iterate stock mutations: %w", err)
+	}
+	rows.Close()
+
+	if err := r.linkMutationSources(ctx, result.Items); err != nil {
+		return StockMutationListResult{}, err
+	}
+	return result, nil
+}
+
+// mutationSource reads the originating document from a mutation reason.
+func mutationSource(reason string) *domain.StockMutationSource {
+	prefix, ref, ok := strings.Cut(reason, ":")
+	if !ok || ref == "" {
+		return nil
+	}
+	switch prefix {
+	case "order", "order-deleted":
+		return &domain.StockMutationSource{Type: "order", Code: ref}
+	case "stock_opname":
+		return &domain.StockMutationSource{Type: "stock_opname", ID: ref}
+	case "po":
+		return &domain.StockMutationSource{Type: "purchase_order", Code: ref}
+	case "reconcile":
+		if code, ok := strings.CutPrefix(ref, "order:"); ok && code != "" {
+			return &domain.StockMutationSource{Type: "order", Code: code}
+		}
+	case strings.TrimSuffix(AdjustmentReasonPrefix, ":"):
+		return &domain.StockMutationSource{Type: "adjustment", Code: ref}
+	case strings.TrimSuffix(transferReasonPrefix, ":"):
+		return &domain.StockMutationSource{Type: "transfer", Code: ref}
+	}
+	return nil
+}
+
+// linkMutationSources resolves the documents behind the listed mutations and sets the
+// API path of those that still exist.
+func (r *ProductRepository) linkMutationSources(ctx context.Context, items []domain.StockMutation) error {
+	lookups := []struct {
+		kind   string
+		stmt   string
+		byCode bool
+		path   string
+	}{
+		{"order", "SELECT id, code FROM orders WHERE code IN (%s);", true, "/api/orders/"},
+		{"purchase_order", "SELECT id, code FROM purchase_orders WHERE code IN (%s);", true, "/api/purchase-orders/"},
+		{"stock_opname", "SELECT id, id FROM stock_opnames WHERE id IN (%s);", false, "/api/stock-opnames/"},
+		{"transfer", "SELECT id, code FROM stock_transfers WHERE code IN (%s);", true, ""},
+	}
+	for _, lookup := range lookups {
+		refs := make([]any, 0)
+		placeholders := make([]string, 0)
+		seen := make(map[string]bool)
+		for _, m := range items {
+			if m.Source == nil || m.Source.Type != lookup.kind {
+				continue
+			}
+			ref := m.Source.ID
+			if lookup.byCode {
+				ref = m.Source.Code
+			}
+			if !seen[ref] {
+				seen[ref] = true
+				refs = append(refs, ref)
+				placeholders = append(placeholders, "?")
+			}
+		}
+		if len(refs) == 0 {
+			continue
+		}
+		rows, err := r.db.QueryContext(ctx, fmt.Sprintf(lookup.stmt, strings.Join(placeholders, ",")), refs...)
+		if err != nil {
+			return fmt.Errorf("resolve %s references: %w", lookup.kind, err)
+		}
+		ids := make(map[string]string)
+		for rows.Next() {
+			var id, ref string
+			if err := rows.Scan(&id, &ref); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan %s reference: %w", lookup.kind, err)
+			}
+			ids[ref] = id
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("iterate %s references: %w", lookup.kind, err)
+		}
+		for i := range items {
+			source := items[i].Source
+			if source == nil || source.Type != lookup.kind {
+				continue
+			}
+			ref := source.ID
+			if lookup.byCode {
+				ref = source.Code
+			}
+			id, ok := ids[ref]
+			if !ok {
+				continue
+			}
+			source.ID = id
+			if lookup.path != "" {
+				source.Link = lookup.path + id
+			}
+		}
+	}
+	return nil
+}
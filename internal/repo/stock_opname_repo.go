@@ -3,7 +3,10 @@ package repo
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,7 +23,25 @@ func NewStockOpnameRepository(db *sql.DB) *StockOpnameRepository {
 	return &StockOpnameRepository{db: db}
 }
 
-func (r *StockOpnameRepository) Create(ctx context.Context, opname *domain.StockOpname) (*domain.StockOpname, error) {
+// StockOpnameReasonPrefix starts the reason of the mutations an opname posts.
+const StockOpnameReasonPrefix = "stock_opname:"
+
+// Apply saves an opname and posts its adjustments in one transaction. Each product and
+// its stock at the location are locked before they are read, so PreviousStock and
+// Difference are what the adjustment actually replaced. Items only need ProductID and
+// Counted; the rest is filled in. The returned adjustments are those actually posted.
+func (r *StockOpnameRepository) Apply(ctx context.Context, opname *domain.StockOpname) ([]*StockAdjustment, error) {
+	return r.apply(ctx, opname, "", "")
+}
+
+// ApplySession is Apply for the counts of an opname session. The session is locked
+// and must be under review; it is marked applied by approver, pointing at the
+// opname, in the same transaction.
+func (r *StockOpnameRepository) ApplySession(ctx context.Context, opname *domain.StockOpname, sessionID, approver string) ([]*StockAdjustment, error) {
+	return r.apply(ctx, opname, sessionID, approver)
+}
+
+func (r *StockOpnameRepository) apply(ctx context.Context, opname *domain.StockOpname, sessionID, approver string) (adjustments []*StockAdjustment, err error) {
 	if opname == nil {
 		return nil, fmt.Errorf("stock opname payload is nil")
 	}
@@ -46,64 +67,250 @@ func (r *StockOpnameRepository) Create(ctx context.Context, opname *domain.Stock
 		}
 	}()
 
-	const headerStmt = `INSERT INTO stock_opnames (id, note, performed_by, performed_at, created_at) VALUES (?, ?, ?, ?, ?);`
-	if _, err = tx.ExecContext(ctx, headerStmt, opname.ID, opname.Note, opname.PerformedBy, opname.PerformedAt.Format(time.RFC3339), opname.CreatedAt.Format(time.RFC3339)); err != nil {
-		return nil, fmt.Errorf("insert stock opname: %w", err)
+	if sessionID != "" {
+		var status domain.OpnameSessionStatus
+		if err = tx.QueryRowContext(ctx, `SELECT status FROM opname_sessions WHERE id = ? FOR UPDATE;`, sessionID).Scan(&status); err != nil {
+			err = fmt.Errorf("select opname session: %w", err)
+			return nil, err
+		}
+		if status != domain.OpnameSessionReview {
+			err = fmt.Errorf("%w: sesi berstatus %s", ErrOpnameSessionState, status)
+			return nil, err
+		}
+	}
+
+	var locationName string
+	if opname.LocationID, locationName, err = stockLocation(ctx, tx, opname.LocationID); err != nil {
+		return nil, err
 	}
 
-	const itemStmt = `INSERT INTO stock_opname_items (id, stock_opname_id, product_id, counted, previous_stock, difference) VALUES (?, ?, ?, ?, ?, ?);`
+	// Lock in product id order so concurrent opnames over the same products cannot deadlock.
+	order := make([]int, len(opname.Items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return opname.Items[order[a]].ProductID < opname.Items[order[b]].ProductID })
+	for n, i := range order {
+		item := &opname.Items[i]
+		if n > 0 && opname.Items[order[n-1]].ProductID == item.ProductID {
+			err = fmt.Errorf("produk %s dihitung lebih dari sekali", item.ProductID)
+			return nil, err
+		}
+		var isBundle, hasVariants bool
+		const productStmt = `SELECT name, IFNULL(sku,''), is_bundle, IFNULL(option_axes,'') <> '', IFNULL(average_cost, cost_price), sale_price FROM products WHERE id = ? AND deleted_at IS NULL FOR UPDATE;`
+		if err = tx.QueryRowContext(ctx, productStmt, item.ProductID).Scan(&item.ProductName, &item.ProductSKU, &isBundle, &hasVariants, &item.UnitCost, &item.SalePrice); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				err = fmt.Errorf("produk %s tidak ditemukan", item.ProductID)
+				return nil, err
+			}
+			err = fmt.Errorf("select product %s: %w", item.ProductID, err)
+			return nil, err
+		}
+		if isBundle {
+			err = fmt.Errorf("%s adalah bundle; hitung stok komponennya", item.ProductName)
+			return nil, err
+		}
+		if hasVariants {
+			err = fmt.Errorf("%s memiliki varian; hitung stok per varian", item.ProductName)
+			return nil, err
+		}
+		if err = reconcileLocationStock(ctx, tx, item.ProductID); err != nil {
+			return nil, err
+		}
+		item.PreviousStock = 0
+		err = tx.QueryRowContext(ctx, `SELECT quantity FROM product_location_stock WHERE product_id = ? AND location_id = ? FOR UPDATE;`, item.ProductID, opname.LocationID).Scan(&item.PreviousStock)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			err = fmt.Errorf("select location stock: %w", err)
+			return nil, err
+		}
+		err = nil
+		item.Difference = item.Counted - item.PreviousStock
+	}
+
+	const headerStmt = `INSERT INTO stock_opnames (id, location_id, note, performed_by, performed_at, created_at) VALUES (?, ?, ?, ?, ?, ?);`
+	if _, err = tx.ExecContext(ctx, headerStmt, opname.ID, nullIfEmpty(opname.LocationID), opname.Note, opname.PerformedBy, opname.PerformedAt.Format(time.RFC3339), opname.CreatedAt.Format(time.RFC3339)); err != nil {
+		err = fmt.Errorf("insert stock opname: %w", err)
+		return nil, err
+	}
+
+	for _, i := range order {
+		item := &opname.Items[i]
+		if item.Difference == 0 {
+			continue
+		}
+		var adj *StockAdjustment
+		adj, err = moveStock(ctx, tx, StockMove{ProductID: item.ProductID, Delta: item.Difference, Reason: StockOpnameReasonPrefix + opname.ID, LocationID: opname.LocationID})
+		if err != nil {
+			err = fmt.Errorf("adjust %s at %s: %w", item.ProductName, locationName, err)
+			return nil, err
+		}
+		if item.Difference < 0 {
+			item.UnitCost = adj.Cost / float64(-item.Difference)
+		}
+		adjustments = append(adjustments, adj)
+	}
+
+	const itemStmt = `INSERT INTO stock_opname_items (id, stock_opname_id, product_id, counted, previous_stock, difference, unit_cost, sale_price) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`
 	for i := range opname.Items {
 		item := &opname.Items[i]
 		if item.ID == "" {
 			item.ID = uuid.New().String()
 		}
 		item.StockOpnameID = opname.ID
-		if _, err = tx.ExecContext(ctx, itemStmt, item.ID, item.StockOpnameID, item.ProductID, item.Counted, item.PreviousStock, item.Difference); err != nil {
-			return nil, fmt.Errorf("insert stock opname item: %w", err)
+		if _, err = tx.ExecContext(ctx, itemStmt, item.ID, item.StockOpnameID, item.ProductID, item.Counted, item.PreviousStock, item.Difference, item.UnitCost, item.SalePrice); err != nil {
+			err = fmt.Errorf("insert stock opname item: %w", err)
+			return nil, err
+		}
+	}
+
+	if sessionID != "" {
+		stamp := now.Format(time.RFC3339)
+		const sessionStmt = `UPDATE opname_sessions SET status = ?, updated_at = ?, approved_by = ?, stock_opname_id = ?, closed_at = ? WHERE id = ?;`
+		if _, err = tx.ExecContext(ctx, sessionStmt, domain.OpnameSessionApplied, stamp, approver, opname.ID, stamp, sessionID); err != nil {
+			err = fmt.Errorf("update opname session: %w", err)
+			return nil, err
 		}
 	}
 
 	if err = tx.Commit(); err != nil {
-		return nil, fmt.Errorf("commit stock opname: %w", err)
+		err = fmt.Errorf("commit stock opname: %w", err)
+		return nil, err
 	}
-	return opname, nil
+	return adjustments, nil
 }
 
-func (r *StockOpnameRepository) List(ctx context.Context, limit int) ([]domain.StockOpname, error) {
-	if limit <= 0 || limit > 100 {
-		limit = 20
+// StockOpnameListOptions filters opname history. PerformedBy matches part of the
+// performer name; ProductID keeps opnames that counted the product.
+type StockOpnameListOptions struct {
+	DateStart   *time.Time
+	DateEnd     *time.Time
+	PerformedBy string
+	ProductID   string
+	LocationID  string
+	Page        int
+	PageSize    int
+}
+
+type StockOpnameListResult struct {
+	Items    []domain.StockOpnameSummary
+	Total    int
+	Page     int
+	PageSize int
+}
+
+type ProductOpnameListResult struct {
+	Items    []domain.ProductOpnameEntry
+	Total    int
+	Page     int
+	PageSize int
+}
+
+// ListPaged pages through opname history, newest first, without the items.
+func (r *StockOpnameRepository) ListPaged(ctx context.Context, opts StockOpnameListOptions) (StockOpnameListResult, error) {
+	page, pageSize := opnamePage(opts.Page, opts.PageSize)
+	whereParts := make([]string, 0)
+	args := make([]any, 0)
+	if opts.DateStart != nil {
+		whereParts = append(whereParts, "o.performed_at >= ?")
+		args = append(args, opts.DateStart.UTC().Format(time.RFC3339))
+	}
+	if opts.DateEnd != nil {
+		whereParts = append(whereParts, "o.performed_at < ?")
+		args = append(args, opts.DateEnd.UTC().Add(24*time.Hour).Format(time.RFC3339))
+	}
+	if performer := strings.TrimSpace(opts.PerformedBy); performer != "" {
+		whereParts = append(whereParts, "o.performed_by LIKE ?")
+		args = append(args, "%"+performer+"%")
+	}
+	if opts.ProductID != "" {
+		whereParts = append(whereParts, "EXISTS (SELECT 1 FROM stock_opname_items f WHERE f.stock_opname_id = o.id AND f.product_id = ?)")
+		args = append(args, opts.ProductID)
+	}
+	if opts.LocationID != "" {
+		whereParts = append(whereParts, "o.location_id = ?")
+		args = append(args, opts.LocationID)
+	}
+	whereClause := ""
+	if len(whereParts) > 0 {
+		whereClause = " WHERE " + strings.Join(whereParts, " AND ")
+	}
+
+	result := StockOpnameListResult{Items: make([]domain.StockOpnameSummary, 0), Page: page, PageSize: pageSize}
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM stock_opnames o"+whereClause+";", args...).Scan(&result.Total); err != nil {
+		return StockOpnameListResult{}, fmt.Errorf("count stock opnames: %w", err)
 	}
-	const stmt = `SELECT id, note, performed_by, performed_at, created_at FROM stock_opnames ORDER BY performed_at DESC LIMIT ?;`
-	rows, err := r.db.QueryContext(ctx, stmt, limit)
+
+	stmt := `SELECT o.id, IFNULL(o.location_id,''), IFNULL(l.name,''), IFNULL(o.note,''), IFNULL(o.performed_by,''), o.performed_at, o.created_at,
+            (SELECT COUNT(*) FROM stock_opname_items i WHERE i.stock_opname_id = o.id),
+            IFNULL((SELECT SUM(i.difference) FROM stock_opname_items i WHERE i.stock_opname_id = o.id AND i.difference > 0), 0),
+            IFNULL((SELECT -SUM(i.difference) FROM stock_opname_items i WHERE i.stock_opname_id = o.id AND i.difference < 0), 0)
+        FROM stock_opnames o
+        LEFT JOIN locations l ON l.id = o.location_id` + whereClause + `
+        ORDER BY o.performed_at DESC, o.id LIMIT ? OFFSET ?;`
+	rows, err := r.db.QueryContext(ctx, stmt, append(args, pageSize, (page-1)*pageSize)...)
 	if err != nil {
-		return nil, fmt.Errorf("list stock opnames: %w", err)
+		return StockOpnameListResult{}, fmt.Errorf("list stock opnames: %w", err)
 	}
 	defer rows.Close()
-
-	var items []domain.StockOpname
 	for rows.Next() {
-		var header domain.StockOpname
+		var o domain.StockOpnameSummary
 		var performed, created string
-		if err := rows.Scan(&header.ID, &header.Note, &header.PerformedBy, &performed, &created); err != nil {
-			return nil, err
+		if err := rows.Scan(&o.ID, &o.LocationID, &o.LocationName, &o.Note, &o.PerformedBy, &performed, &created, &o.ItemCount, &o.IncreaseUnits, &o.DecreaseUnits); err != nil {
+			return StockOpnameListResult{}, fmt.Errorf("scan stock opname: %w", err)
 		}
-		header.PerformedAt, _ = time.Parse(time.RFC3339, performed)
-		header.CreatedAt, _ = time.Parse(time.RFC3339, created)
-		details, err := r.itemsByOpname(ctx, header.ID)
-		if err != nil {
-			return nil, err
+		o.PerformedAt, _ = time.Parse(time.RFC3339, performed)
+		o.CreatedAt, _ = time.Parse(time.RFC3339, created)
+		result.Items = append(result.Items, o)
+	}
+	return result, rows.Err()
+}
+
+// ListForProduct pages through every opname that counted a product, newest first.
+func (r *StockOpnameRepository) ListForProduct(ctx context.Context, productID string, page, pageSize int) (ProductOpnameListResult, error) {
+	page, pageSize = opnamePage(page, pageSize)
+	result := ProductOpnameListResult{Items: make([]domain.ProductOpnameEntry, 0), Page: page, PageSize: pageSize}
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM stock_opname_items WHERE product_id = ?;`, productID).Scan(&result.Total); err != nil {
+		return ProductOpnameListResult{}, fmt.Errorf("count product opnames: %w", err)
+	}
+
+	const stmt = `SELECT o.id, IFNULL(o.location_id,''), IFNULL(l.name,''), IFNULL(o.note,''), IFNULL(o.performed_by,''), o.performed_at,
+            i.counted, i.previous_stock, i.difference,
+            IFNULL(i.unit_cost, IFNULL(p.average_cost, IFNULL(p.cost_price, 0))), IFNULL(i.sale_price, IFNULL(p.sale_price, 0))
+        FROM stock_opname_items i
+        JOIN stock_opnames o ON o.id = i.stock_opname_id
+        LEFT JOIN products p ON p.id = i.product_id
+        LEFT JOIN locations l ON l.id = o.location_id
+        WHERE i.product_id = ?
+        ORDER BY o.performed_at DESC, o.id LIMIT ? OFFSET ?;`
+	rows, err := r.db.QueryContext(ctx, stmt, productID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return ProductOpnameListResult{}, fmt.Errorf("list product opnames: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e domain.ProductOpnameEntry
+		var performed string
+		if err := rows.Scan(&e.StockOpnameID, &e.LocationID, &e.LocationName, &e.Note, &e.PerformedBy, &performed, &e.Counted, &e.PreviousStock, &e.Difference, &e.UnitCost, &e.SalePrice); err != nil {
+			return ProductOpnameListResult{}, fmt.Errorf("scan product opname: %w", err)
 		}
-		header.Items = details
-		items = append(items, header)
+		e.PerformedAt, _ = time.Parse(time.RFC3339, performed)
+		result.Items = append(result.Items, e)
 	}
-	if items == nil {
-		items = []domain.StockOpname{}
+	return result, rows.Err()
+}
+
+func opnamePage(page, pageSize int) (int, int) {
+	if page <= 0 {
+		page = 1
 	}
-	return items, nil
+	if pageSize <= 0 || pageSize > 200 {
+		pageSize = 20
+	}
+	return page, pageSize
 }
 
 func (r *StockOpnameRepository) ListAll(ctx context.Context) ([]domain.StockOpname, error) {
-	const stmt = `SELECT id, note, performed_by, performed_at, created_at FROM stock_opnames ORDER BY performed_at DESC;`
+	const stmt = `SELECT id, IFNULL(location_id,''), note, performed_by, performed_at, created_at FROM stock_opnames ORDER BY performed_at DESC;`
 	rows, err := r.db.QueryContext(ctx, stmt)
 	if err != nil {
 		return nil, fmt.Errorf("list all stock opnames: %w", err)
@@ -114,7 +321,7 @@ func (r *StockOpnameRepository) ListAll(ctx context.Context) ([]domain.StockOpna
 	for rows.Next() {
 		var header domain.StockOpname
 		var performed, created string
-		if err := rows.Scan(&header.ID, &header.Note, &header.PerformedBy, &performed, &created); err != nil {
+		if err := rows.Scan(&header.ID, &header.LocationID, &header.Note, &header.PerformedBy, &performed, &created); err != nil {
 			return nil, err
 		}
 		header.PerformedAt, _ = time.Parse(time.RFC3339, performed)
@@ -132,8 +339,27 @@ func (r *StockOpnameRepository) ListAll(ctx context.Context) ([]domain.StockOpna
 	return items, nil
 }
 
+func (r *StockOpnameRepository) Get(ctx context.Context, id string) (*domain.StockOpname, error) {
+	const stmt = `SELECT id, IFNULL(location_id,''), note, performed_by, performed_at, created_at FROM stock_opnames WHERE id = ?;`
+	var header domain.StockOpname
+	var performed, created string
+	if err := r.db.QueryRowContext(ctx, stmt, id).Scan(&header.ID, &header.LocationID, &header.Note, &header.PerformedBy, &performed, &created); err != nil {
+		return nil, fmt.Errorf("get stock opname: %w", err)
+	}
+	header.PerformedAt, _ = time.Parse(time.RFC3339, performed)
+	header.CreatedAt, _ = time.Parse(time.RFC3339, created)
+	details, err := r.itemsByOpname(ctx, header.ID)
+	if err != nil {
+		return nil, err
+	}
+	header.Items = details
+	return &header, nil
+}
+
 func (r *StockOpnameRepository) itemsByOpname(ctx context.Context, opnameID string) ([]domain.StockOpnameItem, error) {
-	const stmt = `SELECT i.id, i.stock_opname_id, i.product_id, p.name, p.sku, i.counted, i.previous_stock, i.difference
+	// Opnames recorded before items kept their valuation fall back to current prices.
+	const stmt = `SELECT i.id, i.stock_opname_id, i.product_id, IFNULL(p.name,''), IFNULL(p.sku,''), i.counted, i.previous_stock, i.difference,
+                IFNULL(i.unit_cost, IFNULL(p.average_cost, IFNULL(p.cost_price, 0))), IFNULL(i.sale_price, IFNULL(p.sale_price, 0))
                 FROM stock_opname_items i
                 LEFT JOIN products p ON p.id = i.product_id
                 WHERE i.stock_opname_id = ?
@@ -147,7 +373,7 @@ func (r *StockOpnameRepository) itemsByOpname(ctx context.Context, opnameID stri
 	var items []domain.StockOpnameItem
 	for rows.Next() {
 		var item domain.StockOpnameItem
-		if err := rows.Scan(&item.ID, &item.StockOpnameID, &item.ProductID, &item.ProductName, &item.ProductSKU, &item.Counted, &item.PreviousStock, &item.Difference); err != nil {
+		if err := rows.Scan(&item.ID, &item.StockOpnameID, &item.ProductID, &item.ProductName, &item.ProductSKU, &item.Counted, &item.PreviousStock, &item.Difference, &item.UnitCost, &item.SalePrice); err != nil {
 			return nil, err
 		}
 		items = append(items, item)
@@ -169,6 +395,10 @@ func (r *StockOpnameRepository) ReplaceAll(ctx context.Context, opnames []domain
 		}
 	}()
 
+	// Sessions still being counted belong to the stock being replaced.
+	if _, err = tx.ExecContext(ctx, `DELETE FROM opname_sessions;`); err != nil {
+		return fmt.Errorf("clear opname sessions: %w", err)
+	}
 	if _, err = tx.ExecContext(ctx, `DELETE FROM stock_opname_items;`); err != nil {
 		return fmt.Errorf("clear stock opname items: %w", err)
 	}
@@ -176,13 +406,13 @@ func (r *StockOpnameRepository) ReplaceAll(ctx context.Context, opnames []domain
 		return fmt.Errorf("clear stock opnames: %w", err)
 	}
 
-	headerStmt, err := tx.PrepareContext(ctx, `INSERT INTO stock_opnames (id, note, performed_by, performed_at, created_at) VALUES (?, ?, ?, ?, ?);`)
+	headerStmt, err := tx.PrepareContext(ctx, `INSERT INTO stock_opnames (id, location_id, note, performed_by, performed_at, created_at) VALUES (?, ?, ?, ?, ?, ?);`)
 	if err != nil {
 		return fmt.Errorf("prepare stock opname insert: %w", err)
 	}
 	defer headerStmt.Close()
 
-	itemStmt, err := tx.PrepareContext(ctx, `INSERT INTO stock_opname_items (id, stock_opname_id, product_id, counted, previous_stock, difference) VALUES (?, ?, ?, ?, ?, ?);`)
+	itemStmt, err := tx.PrepareContext(ctx, `INSERT INTO stock_opname_items (id, stock_opname_id, product_id, counted, previous_stock, difference, unit_cost, sale_price) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`)
 	if err != nil {
 		return fmt.Errorf("prepare stock opname item insert: %w", err)
 	}
@@ -200,7 +430,7 @@ func (r *StockOpnameRepository) ReplaceAll(ctx context.Context, opnames []domain
 		if opname.ID == "" {
 			opname.ID = uuid.New().String()
 		}
-		if _, err = headerStmt.ExecContext(ctx, opname.ID, opname.Note, opname.PerformedBy, performed.Format(time.RFC3339), created.Format(time.RFC3339)); err != nil {
+		if _, err = headerStmt.ExecContext(ctx, opname.ID, nullIfEmpty(opname.LocationID), opname.Note, opname.PerformedBy, performed.Format(time.RFC3339), created.Format(time.RFC3339)); err != nil {
 			return fmt.Errorf("insert stock opname header: %w", err)
 		}
 
@@ -209,7 +439,7 @@ func (r *StockOpnameRepository) ReplaceAll(ctx context.Context, opnames []domain
 			if itemID == "" {
 				itemID = uuid.New().String()
 			}
-			if _, err = itemStmt.ExecContext(ctx, itemID, opname.ID, item.ProductID, item.Counted, item.PreviousStock, item.Difference); err != nil {
+			if _, err = itemStmt.ExecContext(ctx, itemID, opname.ID, item.ProductID, item.Counted, item.PreviousStock, item.Difference, item.UnitCost, item.SalePrice); err != nil {
 				return fmt.Errorf("insert stock opname item: %w", err)
 			}
 		}
@@ -0,0 +1,69 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// OpnameShrinkage totals the differences of one opname. Loss figures are positive
+// amounts of what went missing; Net figures add gains and losses together.
+type OpnameShrinkage struct {
+	OpnameID    string
+	LocationID  string
+	PerformedBy string
+	PerformedAt time.Time
+	Items       int
+	LossUnits   int
+	GainUnits   int
+	LossCost    float64
+	NetCost     float64
+	LossSale    float64
+	NetSale     float64
+}
+
+// ShrinkageTrend returns the differences of every opname performed in the period,
+// oldest first. Nil bounds leave that side open.
+func (r *StockOpnameRepository) ShrinkageTrend(ctx context.Context, start, end *time.Time) ([]OpnameShrinkage, error) {
+	const cost = `IFNULL(i.unit_cost, IFNULL(p.average_cost, IFNULL(p.cost_price, 0)))`
+	const sale = `IFNULL(i.sale_price, IFNULL(p.sale_price, 0))`
+	stmt := `SELECT o.id, IFNULL(o.location_id,''), IFNULL(o.performed_by,''), o.performed_at, COUNT(i.id),
+            IFNULL(SUM(CASE WHEN i.difference < 0 THEN -i.difference ELSE 0 END), 0),
+            IFNULL(SUM(CASE WHEN i.difference > 0 THEN i.difference ELSE 0 END), 0),
+            IFNULL(SUM(CASE WHEN i.difference < 0 THEN -i.difference * ` + cost + ` ELSE 0 END), 0),
+            IFNULL(SUM(i.difference * ` + cost + `), 0),
+            IFNULL(SUM(CASE WHEN i.difference < 0 THEN -i.difference * ` + sale + ` ELSE 0 END), 0),
+            IFNULL(SUM(i.difference * ` + sale + `), 0)
+        FROM stock_opnames o
+        LEFT JOIN stock_opname_items i ON i.stock_opname_id = o.id
+        LEFT JOIN products p ON p.id = i.product_id
+        WHERE 1 = 1`
+	args := make([]any, 0, 2)
+	if start != nil {
+		stmt += " AND o.performed_at >= ?"
+		args = append(args, start.UTC().Format(time.RFC3339))
+	}
+	if end != nil {
+		stmt += " AND o.performed_at <= ?"
+		args = append(args, end.UTC().Format(time.RFC3339))
+	}
+	stmt += " GROUP BY o.id, o.location_id, o.performed_by, o.performed_at ORDER BY o.performed_at;"
+
+	rows, err := r.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query shrinkage trend: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]OpnameShrinkage, 0)
+	for rows.Next() {
+		var s OpnameShrinkage
+		var performed string
+		if err := rows.Scan(&s.OpnameID, &s.LocationID, &s.PerformedBy, &performed, &s.Items, &s.LossUnits, &s.GainUnits, &s.LossCost, &s.NetCost, &s.LossSale, &s.NetSale); err != nil {
+			return nil, fmt.Errorf("scan shrinkage trend: %w", err)
+		}
+		s.PerformedAt, _ = time.Parse(time.RFC3339, performed)
+		items = append(items, s)
+	}
+	return items, rows.Err()
+}
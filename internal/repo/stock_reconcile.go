@@ -0,0 +1,197 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reasons of corrective mutations posted by the stock reconciliation. Order
+// corrections carry the order code so they net off against that order's movements.
+const (
+	ReconcileLedgerReason      = "reconcile:ledger"
+	ReconcileOrderReasonPrefix = "reconcile:order:"
+)
+
+// LedgerPosition compares a product's on-hand stock with the sum of its mutations.
+type LedgerPosition struct {
+	ProductID     string
+	Name          string
+	SKU           string
+	Stock         int
+	LedgerBalance int
+}
+
+// LedgerPositions returns the on-hand stock and ledger balance of every stock-keeping
+// product, archived ones included.
+func (r *ProductRepository) LedgerPositions(ctx context.Context) ([]LedgerPosition, error) {
+	const stmt = `SELECT p.id, p.name, IFNULL(p.sku,''), p.stock, IFNULL(SUM(m.delta),0)
+        FROM products p
+        LEFT JOIN stock_mutations m ON m.product_id = p.id
+        WHERE p.is_bundle = FALSE AND IFNULL(p.option_axes,'') = ''
+        GROUP BY p.id, p.name, p.sku, p.stock
+        ORDER BY p.name;`
+	rows, err := r.db.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, fmt.Errorf("query ledger positions: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]LedgerPosition, 0)
+	for rows.Next() {
+		var p LedgerPosition
+		if err := rows.Scan(&p.ProductID, &p.Name, &p.SKU, &p.Stock, &p.LedgerBalance); err != nil {
+			return nil, fmt.Errorf("scan ledger position: %w", err)
+		}
+		items = append(items, p)
+	}
+	return items, rows.Err()
+}
+
+// OrderStockLine is what one order should have taken of one product: the line itself,
+// or its bundle components.
+type OrderStockLine struct {
+	OrderID    string
+	OrderCode  string
+	LocationID string
+	CreatedAt  time.Time
+	ProductID  string
+	Quantity   int
+}
+
+// orderDemandSubquery yields (order_id, product_id, quantity) for the stock each order
+// line draws: the product itself, or the components of a bundle.
+const orderDemandSubquery = `(
+            SELECT oi.order_id, oi.product_id, oi.quantity FROM order_items oi
+            WHERE NOT EXISTS (SELECT 1 FROM order_item_components c WHERE c.order_item_id = oi.id)
+            UNION ALL
+            SELECT oi.order_id, c.product_id, c.quantity FROM order_item_components c
+            JOIN order_items oi ON oi.id = c.order_item_id
+        )`
+
+// OrderStockLines lists the stock every existing order should have taken.
+func (r *ProductRepository) OrderStockLines(ctx context.Context) ([]OrderStockLine, error) {
+	const stmt = `SELECT o.id, o.code, IFNULL(o.location_id,''), o.created_at, x.product_id, SUM(x.quantity)
+        FROM orders o
+        JOIN ` + orderDemandSubquery + ` x ON x.order_id = o.id
+        GROUP BY o.id, o.code, o.location_id, o.created_at, x.product_id;`
+	rows, err := r.db.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, fmt.Errorf("query order stock lines: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]OrderStockLine, 0)
+	for rows.Next() {
+		var l OrderStockLine
+		var created string
+		if err := rows.Scan(&l.OrderID, &l.OrderCode, &l.LocationID, &created, &l.ProductID, &l.Quantity); err != nil {
+			return nil, fmt.Errorf("scan order stock line: %w", err)
+		}
+		l.CreatedAt, _ = time.Parse(time.RFC3339, created)
+		items = append(items, l)
+	}
+	return items, rows.Err()
+}
+
+// OrderLedgerLine is the net stock the ledger moved for one order code and product,
+// negative when stock was taken.
+type OrderLedgerLine struct {
+	OrderCode string
+	ProductID string
+	Delta     int
+}
+
+// OrderLedgerLines sums the order, order deletion and order correction mutations per
+// order code and product.
+func (r *ProductRepository) OrderLedgerLines(ctx context.Context) ([]OrderLedgerLine, error) {
+	const stmt = `SELECT reason, product_id, SUM(delta) FROM stock_mutations
+        WHERE reason LIKE 'order:%' OR reason LIKE 'order-deleted:%' OR reason LIKE 'reconcile:order:%'
+        GROUP BY reason, product_id;`
+	rows, err := r.db.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, fmt.Errorf("query order ledger: %w", err)
+	}
+	defer rows.Close()
+
+	totals := make(map[[2]string]int)
+	keys := make([][2]string, 0)
+	for rows.Next() {
+		var reason, productID string
+		var delta int
+		if err := rows.Scan(&reason, &productID, &delta); err != nil {
+			return nil, fmt.Errorf("scan order ledger: %w", err)
+		}
+		code := strings.TrimPrefix(reason, ReconcileOrderReasonPrefix)
+		if code == reason {
+			_, code, _ = strings.Cut(reason, ":")
+		}
+		key := [2]string{code, productID}
+		if _, ok := totals[key]; !ok {
+			keys = append(keys, key)
+		}
+		totals[key] += delta
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate order ledger: %w", err)
+	}
+	items := make([]OrderLedgerLine, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, OrderLedgerLine{OrderCode: key[0], ProductID: key[1], Delta: totals[key]})
+	}
+	return items, nil
+}
+
+// LedgerEpoch returns when the oldest mutation was written, or nil when the ledger is
+// empty. Orders older than this predate the ledger, e.g. after a restore.
+func (r *ProductRepository) LedgerEpoch(ctx context.Context) (*time.Time, error) {
+	var oldest sql.NullString
+	if err := r.db.QueryRowContext(ctx, `SELECT MIN(created_at) FROM stock_mutations;`).Scan(&oldest); err != nil {
+		return nil, fmt.Errorf("select ledger epoch: %w", err)
+	}
+	return parseNullTime(oldest), nil
+}
+
+// PostLedgerCorrection writes a mutation without touching the stock, bringing the
+// ledger in line with stock that changed outside it. The correction is only written
+// while the drift is still the confirmed one.
+func (r *ProductRepository) PostLedgerCorrection(ctx context.Context, productID string, drift int) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var stock int
+	if err = tx.QueryRowContext(ctx, `SELECT stock FROM products WHERE id = ? FOR UPDATE;`, productID).Scan(&stock); err != nil {
+		err = fmt.Errorf("select stock: %w", err)
+		return err
+	}
+	var balance int
+	if err = tx.QueryRowContext(ctx, `SELECT IFNULL(SUM(delta),0) FROM stock_mutations WHERE product_id = ?;`, productID).Scan(&balance); err != nil {
+		err = fmt.Errorf("select ledger balance: %w", err)
+		return err
+	}
+	if stock-balance != drift {
+		err = fmt.Errorf("selisih ledger berubah dari %d menjadi %d; periksa ulang sebelum memperbaiki", drift, stock-balance)
+		return err
+	}
+	const stmt = `INSERT INTO stock_mutations (id, product_id, location_id, delta, reason, created_at) VALUES (?, ?, NULL, ?, ?, ?);`
+	if _, err = tx.ExecContext(ctx, stmt, uuid.New().String(), productID, drift, ReconcileLedgerReason, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		err = fmt.Errorf("insert ledger correction: %w", err)
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("commit ledger correction: %w", err)
+		return err
+	}
+	return nil
+}
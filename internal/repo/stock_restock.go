@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RestockPosition is the stock, open purchase quantity and recent sales of one
+// stock-keeping product. Sold holds the units drawn by orders in each requested
+// window, in the order the windows were given.
+type RestockPosition struct {
+	ProductID         string
+	Name              string
+	SKU               string
+	Category          string
+	Stock             int
+	LowStockThreshold int
+	OnOrder           int
+	Sold              []int
+	CreatedAt         time.Time
+}
+
+// RestockPositions returns every active stock-keeping product with the units its
+// orders drew over each window ending now. Bundle sales count against their components.
+func (r *ProductRepository) RestockPositions(ctx context.Context, windowDays []int) ([]RestockPosition, error) {
+	now := time.Now().UTC()
+	sums := make([]string, 0, len(windowDays))
+	args := make([]any, 0, len(windowDays))
+	for _, days := range windowDays {
+		sums = append(sums, "IFNULL(SUM(CASE WHEN x.created_at >= ? THEN x.quantity END),0)")
+		args = append(args, now.AddDate(0, 0, -days).Format(time.RFC3339))
+	}
+	soldColumns := ""
+	if len(sums) > 0 {
+		soldColumns = ", " + strings.Join(sums, ", ")
+	}
+
+	stmt := `SELECT p.id, p.name, IFNULL(p.sku,''), IFNULL((SELECT c.name FROM categories c WHERE c.id = p.category_id), IFNULL(p.category,'')),
+            p.stock, p.low_stock_threshold, p.created_at,
+            IFNULL((SELECT SUM(GREATEST(l.quantity - l.received_quantity, 0)) FROM purchase_order_lines l
+                JOIN purchase_orders po ON po.id = l.purchase_order_id
+                WHERE l.product_id = p.id AND po.status IN ('ordered','partially_received')), 0)` + soldColumns + `
+        FROM products p
+        LEFT JOIN (
+            SELECT d.product_id, d.quantity, o.created_at FROM ` + orderDemandSubquery + ` d
+            JOIN orders o ON o.id = d.order_id
+        ) x ON x.product_id = p.id
+        WHERE p.deleted_at IS NULL AND p.is_bundle = FALSE AND IFNULL(p.option_axes,'') = ''
+        GROUP BY p.id, p.name, p.sku, p.category_id, p.category, p.stock, p.low_stock_threshold, p.created_at
+        ORDER BY p.name;`
+
+	rows, err := r.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query restock positions: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]RestockPosition, 0)
+	for rows.Next() {
+		var p RestockPosition
+		var created string
+		p.Sold = make([]int, len(windowDays))
+		dest := []any{&p.ProductID, &p.Name, &p.SKU, &p.Category, &p.Stock, &p.LowStockThreshold, &created, &p.OnOrder}
+		for i := range p.Sold {
+			dest = append(dest, &p.Sold[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("scan restock position: %w", err)
+		}
+		p.CreatedAt, _ = time.Parse(time.RFC3339, created)
+		items = append(items, p)
+	}
+	return items, rows.Err()
+}
+
+// UpdateLowStockThresholds sets the low stock threshold of the given products in one
+// transaction.
+func (r *ProductRepository) UpdateLowStockThresholds(ctx context.Context, thresholds map[string]int) (err error) {
+	if len(thresholds) == 0 {
+		return nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for id, threshold := range thresholds {
+		if _, err = tx.ExecContext(ctx, `UPDATE products SET low_stock_threshold = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL;`, threshold, now, id); err != nil {
+			err = fmt.Errorf("update low stock threshold: %w", err)
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		err = fmt.Errorf("commit low stock thresholds: %w", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+
+	"smartseller-lite-starter/internal/domain"
+)
+
+type SupplierRepository struct {
+	db *sql.DB
+}
+
+func NewSupplierRepository(db *sql.DB) *SupplierRepository {
+	return &SupplierRepository{db: db}
+}
+
+const supplierColumns = `id, name, IFNULL(contact,''), IFNULL(phone,''), IFNULL(email,''), IFNULL(address,''), payment_term_days, IFNULL(notes,''), created_at, updated_at`
+
+func scanSupplier(row rowScanner) (*domain.Supplier, error) {
+	var s domain.Supplier
+	var created, updated string
+	if err := row.Scan(&s.ID, &s.Name, &s.Contact, &s.Phone, &s.Email, &s.Address, &s.PaymentTermDays, &s.Notes, &created, &updated); err != nil {
+		return nil, err
+	}
+	s.CreatedAt, _ = time.Parse(time.RFC3339, created)
+	s.UpdatedAt, _ = time.Parse(time.RFC3339, updated)
+	return &s, nil
+}
+
+func (r *SupplierRepository) List(ctx context.Context) ([]domain.Supplier, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+supplierColumns+` FROM suppliers ORDER BY name;`)
+	if err != nil {
+		return nil, fmt.Errorf("list suppliers: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]domain.Supplier, 0)
+	for rows.Next() {
+		s, err := scanSupplier(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan supplier: %w", err)
+		}
+		items = append(items, *s)
+	}
+	return items, rows.Err()
+}
+
+func (r *SupplierRepository) Get(ctx context.Context, id string) (*domain.Supplier, error) {
+	s, err := scanSupplier(r.db.QueryRowContext(ctx, `SELECT `+supplierColumns+` FROM suppliers WHERE id = ?;`, id))
+	if err != nil {
+		return nil, fmt.Errorf("get supplier: %w", err)
+	}
+	return s, nil
+}
+
+func (r *SupplierRepository) Create(ctx context.Context, s *domain.Supplier) (*domain.Supplier, error) {
+	now := time.Now().UTC()
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	s.CreatedAt = now
+	s.UpdatedAt = now
+	const stmt = `INSERT INTO suppliers (id, name, contact, phone, email, address, payment_term_days, notes, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	if _, err := r.db.ExecContext(ctx, stmt, s.ID, s.Name, s.Contact, s.Phone, s.Email, s.Address, s.PaymentTermDays, s.Notes, now.Format(time.RFC3339), now.Format(time.RFC3339)); err != nil {
+		return nil, fmt.Errorf("insert supplier: %w", err)
+	}
+	return s, nil
+}
+
+func (r *SupplierRepository) Update(ctx context.Context, s *domain.Supplier) (*domain.Supplier, error) {
+	s.UpdatedAt = time.Now().UTC()
+	const stmt = `UPDATE suppliers SET name = ?, contact = ?, phone = ?, email = ?, address = ?, payment_term_days = ?, notes = ?, updated_at = ? WHERE id = ?;`
+	if _, err := r.db.ExecContext(ctx, stmt, s.Name, s.Contact, s.Phone, s.Email, s.Address, s.PaymentTermDays, s.Notes, s.UpdatedAt.Format(time.RFC3339), s.ID); err != nil {
+		return nil, fmt.Errorf("update supplier: %w", err)
+	}
+	return s, nil
+}
+
+func (r *SupplierRepository) Delete(ctx context.Context, id string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM suppliers WHERE id = ?;`, id); err != nil {
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1451 {
+			return fmt.Errorf("delete supplier: pemasok masih memiliki purchase order")
+		}
+		return fmt.Errorf("delete supplier: %w", err)
+	}
+	return nil
+}
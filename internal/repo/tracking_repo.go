@@ -0,0 +1,187 @@
+package repo
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"smartseller-lite-starter/internal/domain"
+)
+
+// TrackingRepository persists courier checkpoints and the delivery state stored on orders.
+type TrackingRepository struct {
+	db *sql.DB
+}
+
+func NewTrackingRepository(db *sql.DB) *TrackingRepository {
+	return &TrackingRepository{db: db}
+}
+
+// TrackingCandidate is the minimal order projection needed to poll a courier.
+type TrackingCandidate struct {
+	OrderID      string
+	OrderCode    string
+	Courier      string
+	TrackingCode string
+	Status       domain.ShipmentStatus
+}
+
+// ListPending returns orders with a tracking code that are not delivered or returned yet
+// and were last checked before the given cut-off, oldest check first.
+func (r *TrackingRepository) ListPending(ctx context.Context, checkedBefore time.Time, limit int) ([]TrackingCandidate, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	const stmt = `SELECT id, code, IFNULL(shipment_courier,''), IFNULL(shipment_tracking,''), shipment_status
+                FROM orders
+                WHERE IFNULL(shipment_tracking,'') <> ''
+                  AND shipment_status NOT IN (?, ?)
+                  AND (shipment_checked_at IS NULL OR shipment_checked_at < ?)
+                ORDER BY shipment_checked_at ASC, created_at ASC
+                LIMIT ?;`
+	rows, err := r.db.QueryContext(ctx, stmt, domain.ShipmentStatusDelivered, domain.ShipmentStatusReturned, checkedBefore.UTC().Format(time.RFC3339), limit)
+	if err != nil {
+		return nil, fmt.Errorf("list pending shipments: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]TrackingCandidate, 0)
+	for rows.Next() {
+		var c TrackingCandidate
+		if err := rows.Scan(&c.OrderID, &c.OrderCode, &c.Courier, &c.TrackingCode, &c.Status); err != nil {
+			return nil, err
+		}
+		items = append(items, c)
+	}
+	return items, rows.Err()
+}
+
+// Get returns the delivery state and checkpoints of a single order.
+func (r *TrackingRepository) Get(ctx context.Context, orderID string) (*domain.ShipmentTracking, error) {
+	const stmt = `SELECT id, code, IFNULL(shipment_courier,''), IFNULL(shipment_tracking,''), shipment_status, shipment_status_at, shipment_checked_at FROM orders WHERE id = ?;`
+	var t domain.ShipmentTracking
+	var statusAt, checkedAt sql.NullString
+	if err := r.db.QueryRowContext(ctx, stmt, orderID).Scan(&t.OrderID, &t.OrderCode, &t.Courier, &t.TrackingCode, &t.Status, &statusAt, &checkedAt); err != nil {
+		return nil, fmt.Errorf("get shipment tracking: %w", err)
+	}
+	t.StatusUpdatedAt = parseNullTime(statusAt)
+	t.CheckedAt = parseNullTime(checkedAt)
+
+	events, err := r.Events(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	t.Events = events
+	return &t, nil
+}
+
+// Events lists the stored checkpoints for an order, newest first.
+func (r *TrackingRepository) Events(ctx context.Context, orderID string) ([]domain.ShipmentEvent, error) {
+	const stmt = `SELECT id, order_id, status, description, location, occurred_at, created_at FROM shipment_events WHERE order_id = ? ORDER BY occurred_at DESC, created_at DESC;`
+	rows, err := r.db.QueryContext(ctx, stmt, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("list shipment events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]domain.ShipmentEvent, 0)
+	for rows.Next() {
+		var e domain.ShipmentEvent
+		var description, location sql.NullString
+		var occurred, created string
+		if err := rows.Scan(&e.ID, &e.OrderID, &e.Status, &description, &location, &occurred, &created); err != nil {
+			return nil, err
+		}
+		e.Description = description.String
+		e.Location = location.String
+		e.OccurredAt, _ = time.Parse(time.RFC3339, occurred)
+		e.CreatedAt, _ = time.Parse(time.RFC3339, created)
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// SaveResult stores new checkpoints (duplicates are ignored) and updates the order delivery state.
+// A pending status carries no information, as unknown courier statuses normalise to it,
+// so it never replaces the stored status.
+func (r *TrackingRepository) SaveResult(ctx context.Context, orderID string, status domain.ShipmentStatus, events []domain.ShipmentEvent, checkedAt time.Time) (inserted int, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	now := checkedAt.UTC().Format(time.RFC3339)
+	const eventStmt = `INSERT IGNORE INTO shipment_events (id, order_id, status, description, location, fingerprint, occurred_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`
+	for _, e := range events {
+		occurred := e.OccurredAt.UTC()
+		if e.OccurredAt.IsZero() {
+			occurred = checkedAt.UTC()
+		}
+		res, execErr := tx.ExecContext(ctx, eventStmt, uuid.New().String(), orderID, e.Status, e.Description, e.Location, eventFingerprint(e), occurred.Format(time.RFC3339), now)
+		if execErr != nil {
+			err = fmt.Errorf("insert shipment event: %w", execErr)
+			return 0, err
+		}
+		if affected, _ := res.RowsAffected(); affected > 0 {
+			inserted++
+		}
+	}
+
+	const updateStmt = `UPDATE orders SET
+            shipment_status_at = CASE WHEN ? <> ? AND shipment_status <> ? THEN ? ELSE shipment_status_at END,
+            shipment_status = CASE WHEN ? = ? THEN shipment_status ELSE ? END,
+            shipment_checked_at = ?
+        WHERE id = ?;`
+	pending := domain.ShipmentStatusPending
+	if _, err = tx.ExecContext(ctx, updateStmt, status, pending, status, now, status, pending, status, now, orderID); err != nil {
+		return 0, fmt.Errorf("update shipment status: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit shipment tracking: %w", err)
+	}
+	return inserted, nil
+}
+
+// MarkChecked records a polling attempt without changing the delivery state.
+func (r *TrackingRepository) MarkChecked(ctx context.Context, orderID string, checkedAt time.Time) error {
+	const stmt = `UPDATE orders SET shipment_checked_at = ? WHERE id = ?;`
+	if _, err := r.db.ExecContext(ctx, stmt, checkedAt.UTC().Format(time.RFC3339), orderID); err != nil {
+		return fmt.Errorf("mark shipment checked: %w", err)
+	}
+	return nil
+}
+
+// eventFingerprint identifies a checkpoint independently of when it was fetched, so
+// repeated polls of the same courier history do not create duplicate rows.
+func eventFingerprint(e domain.ShipmentEvent) string {
+	occurred := ""
+	if !e.OccurredAt.IsZero() {
+		occurred = e.OccurredAt.UTC().Format(time.RFC3339)
+	}
+	raw := strings.Join([]string{string(e.Status), occurred, strings.TrimSpace(e.Description), strings.TrimSpace(e.Location)}, "|")
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func parseNullTime(v sql.NullString) *time.Time {
+	if !v.Valid || strings.TrimSpace(v.String) == "" {
+		return nil
+	}
+	ts, err := time.Parse(time.RFC3339, v.String)
+	if err != nil {
+		return nil
+	}
+	return &ts
+}
@@ -0,0 +1,250 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"smartseller-lite-starter/internal/domain"
+)
+
+// WebhookRepository persists webhook subscriptions and their delivery outbox.
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+func (r *WebhookRepository) ListSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	const stmt = `SELECT id, name, url, secret, events, active, created_at, updated_at FROM webhook_subscriptions ORDER BY name;`
+	rows, err := r.db.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, fmt.Errorf("list webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]domain.WebhookSubscription, 0)
+	for rows.Next() {
+		sub, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *sub)
+	}
+	return items, rows.Err()
+}
+
+func (r *WebhookRepository) GetSubscription(ctx context.Context, id string) (*domain.WebhookSubscription, error) {
+	const stmt = `SELECT id, name, url, secret, events, active, created_at, updated_at FROM webhook_subscriptions WHERE id = ?;`
+	sub, err := scanWebhookSubscription(r.db.QueryRowContext(ctx, stmt, id))
+	if err != nil {
+		return nil, fmt.Errorf("get webhook subscription: %w", err)
+	}
+	return sub, nil
+}
+
+func (r *WebhookRepository) SaveSubscription(ctx context.Context, sub *domain.WebhookSubscription) (*domain.WebhookSubscription, error) {
+	now := time.Now().UTC()
+	events := strings.Join(sub.Events, ",")
+	if sub.ID == "" {
+		sub.ID = uuid.New().String()
+		sub.CreatedAt = now
+		sub.UpdatedAt = now
+		const stmt = `INSERT INTO webhook_subscriptions (id, name, url, secret, events, active, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`
+		if _, err := r.db.ExecContext(ctx, stmt, sub.ID, sub.Name, sub.URL, sub.Secret, events, sub.Active, sub.CreatedAt.Format(time.RFC3339), sub.UpdatedAt.Format(time.RFC3339)); err != nil {
+			return nil, fmt.Errorf("insert webhook subscription: %w", err)
+		}
+		return sub, nil
+	}
+
+	sub.UpdatedAt = now
+	const stmt = `UPDATE webhook_subscriptions SET name = ?, url = ?, secret = ?, events = ?, active = ?, updated_at = ? WHERE id = ?;`
+	if _, err := r.db.ExecContext(ctx, stmt, sub.Name, sub.URL, sub.Secret, events, sub.Active, sub.UpdatedAt.Format(time.RFC3339), sub.ID); err != nil {
+		return nil, fmt.Errorf("update webhook subscription: %w", err)
+	}
+	return sub, nil
+}
+
+func (r *WebhookRepository) DeleteSubscription(ctx context.Context, id string) error {
+	if strings.TrimSpace(id) == "" {
+		return fmt.Errorf("webhook id required")
+	}
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = ?;`, id); err != nil {
+		return fmt.Errorf("delete webhook subscription: %w", err)
+	}
+	return nil
+}
+
+// Enqueue stores pending deliveries in the outbox.
+func (r *WebhookRepository) Enqueue(ctx context.Context, deliveries []domain.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	const stmt = `INSERT INTO webhook_outbox (id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at) VALUES (?, ?, ?, ?, ?, ?, 0, ?, ?);`
+	now := time.Now().UTC().Format(time.RFC3339)
+	for i := range deliveries {
+		d := &deliveries[i]
+		if d.ID == "" {
+			d.ID = uuid.New().String()
+		}
+		if _, err = tx.ExecContext(ctx, stmt, d.ID, d.SubscriptionID, d.EventID, d.EventType, d.Payload, domain.WebhookDeliveryPending, now, now); err != nil {
+			return fmt.Errorf("insert webhook delivery: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// WebhookDispatch couples a due delivery with the subscription it targets.
+type WebhookDispatch struct {
+	Delivery     domain.WebhookDelivery
+	Subscription domain.WebhookSubscription
+}
+
+// Due returns pending deliveries whose next attempt is at or before now, oldest first.
+func (r *WebhookRepository) Due(ctx context.Context, now time.Time, limit int) ([]WebhookDispatch, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	const stmt = `SELECT o.id, o.subscription_id, o.event_id, o.event_type, o.payload, o.status, o.attempts, o.next_attempt_at, o.last_status_code, o.last_error, o.created_at, o.delivered_at,
+                       s.id, s.name, s.url, s.secret, s.events, s.active, s.created_at, s.updated_at
+                FROM webhook_outbox o
+                JOIN webhook_subscriptions s ON s.id = o.subscription_id
+                WHERE o.status = ? AND s.active = TRUE AND (o.next_attempt_at IS NULL OR o.next_attempt_at <= ?)
+                ORDER BY o.created_at ASC
+                LIMIT ?;`
+	rows, err := r.db.QueryContext(ctx, stmt, domain.WebhookDeliveryPending, now.UTC().Format(time.RFC3339), limit)
+	if err != nil {
+		return nil, fmt.Errorf("list due webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]WebhookDispatch, 0)
+	for rows.Next() {
+		var item WebhookDispatch
+		var next, delivered, lastError, events sql.NullString
+		var created, subCreated, subUpdated string
+		d := &item.Delivery
+		sub := &item.Subscription
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &next, &d.LastStatusCode, &lastError, &created, &delivered,
+			&sub.ID, &sub.Name, &sub.URL, &sub.Secret, &events, &sub.Active, &subCreated, &subUpdated); err != nil {
+			return nil, err
+		}
+		d.NextAttemptAt = parseNullTime(next)
+		d.DeliveredAt = parseNullTime(delivered)
+		d.LastError = lastError.String
+		d.CreatedAt, _ = time.Parse(time.RFC3339, created)
+		sub.SecretSet = sub.Secret != ""
+		sub.Events = splitWebhookEvents(events.String)
+		sub.CreatedAt, _ = time.Parse(time.RFC3339, subCreated)
+		sub.UpdatedAt, _ = time.Parse(time.RFC3339, subUpdated)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// RecordAttempt stores the outcome of a delivery attempt.
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, d domain.WebhookDelivery) error {
+	var next, delivered interface{}
+	if d.NextAttemptAt != nil {
+		next = d.NextAttemptAt.UTC().Format(time.RFC3339)
+	}
+	if d.DeliveredAt != nil {
+		delivered = d.DeliveredAt.UTC().Format(time.RFC3339)
+	}
+	const stmt = `UPDATE webhook_outbox SET status = ?, attempts = ?, next_attempt_at = ?, last_status_code = ?, last_error = ?, delivered_at = ? WHERE id = ?;`
+	if _, err := r.db.ExecContext(ctx, stmt, d.Status, d.Attempts, next, d.LastStatusCode, d.LastError, delivered, d.ID); err != nil {
+		return fmt.Errorf("update webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// ListDeliveries returns the most recent outbox entries of a subscription.
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, subscriptionID string, limit int) ([]domain.WebhookDelivery, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	const stmt = `SELECT id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at
+                FROM webhook_outbox WHERE subscription_id = ? ORDER BY created_at DESC LIMIT ?;`
+	rows, err := r.db.QueryContext(ctx, stmt, subscriptionID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]domain.WebhookDelivery, 0)
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		var next, delivered, lastError sql.NullString
+		var created string
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &next, &d.LastStatusCode, &lastError, &created, &delivered); err != nil {
+			return nil, err
+		}
+		d.NextAttemptAt = parseNullTime(next)
+		d.DeliveredAt = parseNullTime(delivered)
+		d.LastError = lastError.String
+		d.CreatedAt, _ = time.Parse(time.RFC3339, created)
+		items = append(items, d)
+	}
+	return items, rows.Err()
+}
+
+// Requeue resets a delivery so the dispatcher retries it immediately.
+func (r *WebhookRepository) Requeue(ctx context.Context, id string) error {
+	const stmt = `UPDATE webhook_outbox SET status = ?, attempts = 0, next_attempt_at = ?, delivered_at = NULL WHERE id = ?;`
+	res, err := r.db.ExecContext(ctx, stmt, domain.WebhookDeliveryPending, time.Now().UTC().Format(time.RFC3339), id)
+	if err != nil {
+		return fmt.Errorf("requeue webhook delivery: %w", err)
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return fmt.Errorf("requeue webhook delivery: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWebhookSubscription(row rowScanner) (*domain.WebhookSubscription, error) {
+	var sub domain.WebhookSubscription
+	var events sql.NullString
+	var created, updated string
+	if err := row.Scan(&sub.ID, &sub.Name, &sub.URL, &sub.Secret, &events, &sub.Active, &created, &updated); err != nil {
+		return nil, err
+	}
+	sub.SecretSet = sub.Secret != ""
+	sub.Events = splitWebhookEvents(events.String)
+	sub.CreatedAt, _ = time.Parse(time.RFC3339, created)
+	sub.UpdatedAt, _ = time.Parse(time.RFC3339, updated)
+	return &sub, nil
+}
+
+func splitWebhookEvents(raw string) []string {
+	items := make([]string, 0)
+	for _, part := range strings.Split(raw, ",") {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			items = append(items, trimmed)
+		}
+	}
+	return items
+}
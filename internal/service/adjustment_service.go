@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"smartseller-lite-starter/internal/domain"
+	"smartseller-lite-starter/internal/repo"
+)
+
+// ErrAdjustmentReasonNotFound is returned when a reason code matches nothing.
+var ErrAdjustmentReasonNotFound = errors.New("alasan penyesuaian tidak ditemukan")
+
+// DefaultAdjustmentReason books adjustments made without a reason code.
+const DefaultAdjustmentReason = repo.DefaultAdjustmentReason
+
+var adjustmentReasonCode = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// AdjustmentService books manual stock adjustments under managed reason codes.
+type AdjustmentService struct {
+	repo     *repo.AdjustmentRepository
+	products *ProductService
+}
+
+func NewAdjustmentService(repo *repo.AdjustmentRepository, products *ProductService) *AdjustmentService {
+	return &AdjustmentService{repo: repo, products: products}
+}
+
+// AdjustStockInput is a manual stock change. Reason is an adjustment reason code,
+// DefaultAdjustmentReason when empty; the change must go the way the reason allows,
+// and reasons that require one need a Note.
+type AdjustStockInput struct {
+	Delta      int    `json:"delta"`
+	Reason     string `json:"reason"`
+	Note       string `json:"note"`
+	LocationID string `json:"locationId"`
+	User       string `json:"user"`
+}
+
+// Adjust changes the stock of a product at a location, given by id or code; an empty
+// location means the default one.
+func (s *AdjustmentService) Adjust(ctx context.Context, productID string, input AdjustStockInput) error {
+	if productID == "" {
+		return errors.New("productID required")
+	}
+	if input.Delta == 0 {
+		return nil
+	}
+	code := strings.ToLower(strings.TrimSpace(input.Reason))
+	if code == "" {
+		code = DefaultAdjustmentReason
+	}
+	reason, err := s.Reason(ctx, code)
+	if err != nil {
+		return err
+	}
+	if !reason.Active {
+		return fmt.Errorf("alasan %s sudah tidak aktif", reason.Label)
+	}
+	if !reason.Allows(input.Delta) {
+		if reason.Direction == domain.AdjustmentIn {
+			return fmt.Errorf("alasan %s hanya untuk menambah stok", reason.Label)
+		}
+		return fmt.Errorf("alasan %s hanya untuk mengurangi stok", reason.Label)
+	}
+	note := strings.TrimSpace(input.Note)
+	if reason.RequiresNote && note == "" {
+		return fmt.Errorf("catatan wajib diisi untuk alasan %s", reason.Label)
+	}
+	adj, err := s.repo.Adjust(ctx, repo.AdjustmentEntry{
+		Move:       repo.StockMove{ProductID: productID, Delta: input.Delta, Note: note, LocationID: input.LocationID},
+		ReasonCode: reason.Code,
+		Actor:      strings.TrimSpace(input.User),
+	})
+	if err != nil {
+		return err
+	}
+	s.products.publishStockChange(ctx, adj)
+	return nil
+}
+
+// Reasons lists the adjustment reasons, optionally only the active ones.
+func (s *AdjustmentService) Reasons(ctx context.Context, activeOnly bool) ([]domain.AdjustmentReason, error) {
+	items, err := s.repo.ListReasons(ctx)
+	if err != nil || !activeOnly {
+		return items, err
+	}
+	active := make([]domain.AdjustmentReason, 0, len(items))
+	for _, item := range items {
+		if item.Active {
+			active = append(active, item)
+		}
+	}
+	return active, nil
+}
+
+func (s *AdjustmentService) Reason(ctx context.Context, code string) (*domain.AdjustmentReason, error) {
+	reason, err := s.repo.GetReason(ctx, code)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrAdjustmentReasonNotFound, code)
+		}
+		return nil, err
+	}
+	return reason, nil
+}
+
+// AdjustmentReasonInput creates or changes a reason. On update, fields left out keep
+// their stored value; on create, a reason is active unless Active says otherwise.
+type AdjustmentReasonInput struct {
+	Code         string `json:"code"`
+	Label        string `json:"label"`
+	Direction    string `json:"direction"`
+	RequiresNote *bool  `json:"requiresNote"`
+	Active       *bool  `json:"active"`
+	SortOrder    *int   `json:"sortOrder"`
+}
+
+// CreateReason adds a custom reason.
+func (s *AdjustmentService) CreateReason(ctx context.Context, input AdjustmentReasonInput) (*domain.AdjustmentReason, error) {
+	return s.saveReason(ctx, input, true)
+}
+
+// UpdateReason changes an existing reason. System reasons keep their direction, since
+// reports read their meaning from it.
+func (s *AdjustmentService) UpdateReason(ctx context.Context, input AdjustmentReasonInput) (*domain.AdjustmentReason, error) {
+	return s.saveReason(ctx, input, false)
+}
+
+func (s *AdjustmentService) saveReason(ctx context.Context, input AdjustmentReasonInput, create bool) (*domain.AdjustmentReason, error) {
+	reason := domain.AdjustmentReason{Code: strings.ToLower(strings.TrimSpace(input.Code)), Active: true}
+	if !adjustmentReasonCode.MatchString(reason.Code) {
+		return nil, fmt.Errorf("kode alasan hanya boleh berisi huruf kecil, angka, - dan _ (maks. 32 karakter)")
+	}
+	existing, err := s.repo.GetReason(ctx, reason.Code)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if !create {
+			return nil, fmt.Errorf("%w: %s", ErrAdjustmentReasonNotFound, reason.Code)
+		}
+	case err != nil:
+		return nil, err
+	case create:
+		return nil, fmt.Errorf("kode alasan %s sudah dipakai", reason.Code)
+	default:
+		reason = *existing
+	}
+	if label := strings.TrimSpace(input.Label); label != "" {
+		reason.Label = label
+	}
+	if input.Direction != "" {
+		reason.Direction = input.Direction
+	}
+	if input.RequiresNote != nil {
+		reason.RequiresNote = *input.RequiresNote
+	}
+	if input.Active != nil {
+		reason.Active = *input.Active
+	}
+	if input.SortOrder != nil {
+		reason.SortOrder = *input.SortOrder
+	}
+	if reason.Label == "" {
+		return nil, fmt.Errorf("label alasan wajib diisi")
+	}
+	switch reason.Direction {
+	case domain.AdjustmentIn, domain.AdjustmentOut, domain.AdjustmentBoth:
+	case "":
+		reason.Direction = domain.AdjustmentBoth
+	default:
+		return nil, fmt.Errorf("arah alasan harus in, out atau both")
+	}
+	if existing != nil && existing.IsSystem && existing.Direction != reason.Direction {
+		return nil, fmt.Errorf("arah alasan bawaan %s tidak dapat diubah", existing.Label)
+	}
+	return s.repo.SaveReason(ctx, &reason)
+}
+
+// DeleteReason removes a custom reason nothing was booked under. System reasons and
+// reasons in use can only be deactivated.
+func (s *AdjustmentService) DeleteReason(ctx context.Context, code string) error {
+	reason, err := s.Reason(ctx, code)
+	if err != nil {
+		return err
+	}
+	if reason.IsSystem {
+		return fmt.Errorf("alasan bawaan %s tidak dapat dihapus; nonaktifkan saja", reason.Label)
+	}
+	return s.repo.DeleteReason(ctx, reason.Code)
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"smartseller-lite-starter/internal/domain"
+	"smartseller-lite-starter/internal/repo"
+)
+
+// ErrCategoryNotFound is returned when a category id or slug matches nothing.
+var ErrCategoryNotFound = errors.New("kategori tidak ditemukan")
+
+// CategoryService manages the product category tree.
+type CategoryService struct {
+	repo *repo.CategoryRepository
+}
+
+func NewCategoryService(repo *repo.CategoryRepository) *CategoryService {
+	return &CategoryService{repo: repo}
+}
+
+func (s *CategoryService) List(ctx context.Context) ([]domain.Category, error) {
+	return s.repo.List(ctx)
+}
+
+// Save creates or updates a category. The slug defaults to the parent slug followed by
+// the slugified name, so "Segi Empat" under "Hijab" becomes hijab-segi-empat.
+func (s *CategoryService) Save(ctx context.Context, c domain.Category) (*domain.Category, error) {
+	c.Name = strings.Join(strings.Fields(c.Name), " ")
+	if c.Name == "" {
+		return nil, errors.New("nama kategori wajib diisi")
+	}
+	if strings.Contains(c.Name, ">") {
+		return nil, errors.New("nama kategori tidak boleh mengandung '>'")
+	}
+	c.ParentID = strings.TrimSpace(c.ParentID)
+
+	items, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var existing, parent *domain.Category
+	for i := range items {
+		if c.ID != "" && items[i].ID == c.ID {
+			existing = &items[i]
+		}
+		if c.ParentID != "" && items[i].ID == c.ParentID {
+			parent = &items[i]
+		}
+	}
+	if c.ID != "" && existing == nil {
+		return nil, ErrCategoryNotFound
+	}
+	if c.ParentID != "" {
+		if parent == nil {
+			return nil, errors.New("kategori induk tidak ditemukan")
+		}
+		if existing != nil {
+			for _, id := range categorySubtree(items, existing.ID) {
+				if id == parent.ID {
+					return nil, errors.New("kategori tidak boleh menjadi subkategori dari dirinya sendiri")
+				}
+			}
+		}
+	}
+
+	slug := repo.Slugify(c.Slug)
+	if slug == "" && existing != nil && existing.Name == c.Name && existing.ParentID == c.ParentID {
+		slug = existing.Slug
+	}
+	if slug == "" {
+		slug = repo.Slugify(c.Name)
+		if parent != nil {
+			slug = parent.Slug + "-" + slug
+		}
+	}
+	if slug == "" {
+		return nil, errors.New("slug kategori tidak valid")
+	}
+	taken, err := s.repo.SlugInUse(ctx, slug, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, fmt.Errorf("slug %s sudah digunakan kategori lain", slug)
+	}
+	c.Slug = slug
+
+	var saved *domain.Category
+	if existing == nil {
+		c.ID = ""
+		saved, err = s.repo.Create(ctx, &c)
+	} else {
+		saved, err = s.repo.Update(ctx, &c)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.Get(ctx, saved.ID)
+}
+
+func (s *CategoryService) Delete(ctx context.Context, id string) error {
+	if strings.TrimSpace(id) == "" {
+		return errors.New("category id required")
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// resolveCategory finds a category by id or slug.
+func resolveCategory(ctx context.Context, categories *repo.CategoryRepository, ref string) (*domain.Category, []domain.Category, error) {
+	items, err := categories.List(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	ref = strings.TrimSpace(ref)
+	for i := range items {
+		if items[i].ID == ref || items[i].Slug == ref {
+			return &items[i], items, nil
+		}
+	}
+	return nil, items, fmt.Errorf("%w: %s", ErrCategoryNotFound, ref)
+}
+
+// categorySubtree returns rootID followed by the ids of all its descendants.
+func categorySubtree(items []domain.Category, rootID string) []string {
+	children := make(map[string][]string)
+	for _, c := range items {
+		children[c.ParentID] = append(children[c.ParentID], c.ID)
+	}
+	ids := []string{rootID}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids
+}
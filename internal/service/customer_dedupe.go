@@ -0,0 +1,339 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"smartseller-lite-starter/internal/domain"
+	"smartseller-lite-starter/internal/repo"
+)
+
+// ErrCustomerNotFound is returned when a customer id matches no contact.
+var ErrCustomerNotFound = repo.ErrCustomerNotFound
+
+// Two customers whose normalised names and addresses are at least this similar are
+// reported as duplicates even without a shared phone or email.
+const (
+	duplicateNameSimilarity    = 0.85
+	duplicateAddressSimilarity = 0.75
+)
+
+// Reasons a duplicate group was formed.
+const (
+	DuplicateByPhone       = "phone"
+	DuplicateByEmail       = "email"
+	DuplicateByNameAddress = "name_address"
+)
+
+// CustomerDuplicate is a customer in a duplicate group with the number of orders that
+// name it as buyer or recipient.
+type CustomerDuplicate struct {
+	domain.Customer
+	Orders int `json:"orders"`
+}
+
+// CustomerDuplicateGroup lists customers that look like the same person. SurvivorID
+// suggests the record to keep: the one on most orders, then the most complete, then
+// the oldest. It is listed first.
+type CustomerDuplicateGroup struct {
+	SurvivorID string              `json:"survivorId"`
+	Reasons    []string            `json:"reasons"`
+	Customers  []CustomerDuplicate `json:"customers"`
+}
+
+// MergeCustomersInput names the customer to keep and the duplicates folded into it.
+type MergeCustomersInput struct {
+	SurvivorID   string   `json:"survivorId"`
+	DuplicateIDs []string `json:"duplicateIds"`
+}
+
+// MergeCustomersResult is the surviving customer and how many orders were moved to it.
+type MergeCustomersResult struct {
+	Customer    *domain.Customer `json:"customer"`
+	MergedIDs   []string         `json:"mergedIds"`
+	OrdersMoved int              `json:"ordersMoved"`
+}
+
+// Duplicates groups customers sharing a normalised phone number or email, or with
+// closely matching names and addresses. Names are only compared between customers
+// whose names start with the same letter or who share a postal code.
+func (s *CustomerService) Duplicates(ctx context.Context) ([]CustomerDuplicateGroup, error) {
+	customers, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := s.repo.OrderCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parent := make([]int, len(customers))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	type link struct {
+		a, b   int
+		reason string
+	}
+	links := make([]link, 0)
+	join := func(a, b int, reason string) {
+		links = append(links, link{a: a, b: b, reason: reason})
+		if ra, rb := find(a), find(b); ra != rb {
+			parent[rb] = ra
+		}
+	}
+
+	names := make([]string, len(customers))
+	addresses := make([]string, len(customers))
+	byPhone := make(map[string]int)
+	byEmail := make(map[string]int)
+	blocks := make(map[string][]int)
+	for i, c := range customers {
+		if phone, err := normalisePhone(c.Phone); err == nil && phone != "" {
+			if j, ok := byPhone[phone]; ok {
+				join(j, i, DuplicateByPhone)
+			} else {
+				byPhone[phone] = i
+			}
+		}
+		if email := strings.ToLower(strings.TrimSpace(c.Email)); email != "" {
+			if j, ok := byEmail[email]; ok {
+				join(j, i, DuplicateByEmail)
+			} else {
+				byEmail[email] = i
+			}
+		}
+		names[i] = normaliseCustomerName(c.Name)
+		addresses[i] = normaliseCustomerAddress(c.Address)
+		if names[i] == "" || addresses[i] == "" {
+			continue
+		}
+		initial := "n:" + string([]rune(names[i])[0])
+		blocks[initial] = append(blocks[initial], i)
+		if postal := strings.TrimSpace(c.Postal); postal != "" {
+			blocks["p:"+postal] = append(blocks["p:"+postal], i)
+		}
+	}
+
+	compared := make(map[[2]int]bool)
+	for _, members := range blocks {
+		for x := 0; x < len(members); x++ {
+			for y := x + 1; y < len(members); y++ {
+				a, b := members[x], members[y]
+				if compared[[2]int{a, b}] {
+					continue
+				}
+				compared[[2]int{a, b}] = true
+				if similarity(names[a], names[b]) >= duplicateNameSimilarity && similarity(addresses[a], addresses[b]) >= duplicateAddressSimilarity {
+					join(a, b, DuplicateByNameAddress)
+				}
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	reasons := make(map[int]map[string]bool)
+	for _, l := range links {
+		root := find(l.a)
+		if reasons[root] == nil {
+			reasons[root] = make(map[string]bool)
+		}
+		reasons[root][l.reason] = true
+	}
+	for i := range customers {
+		if root := find(i); reasons[root] != nil {
+			members[root] = append(members[root], i)
+		}
+	}
+
+	groups := make([]CustomerDuplicateGroup, 0, len(members))
+	for root, idx := range members {
+		group := CustomerDuplicateGroup{Customers: make([]CustomerDuplicate, 0, len(idx))}
+		for _, i := range idx {
+			group.Customers = append(group.Customers, CustomerDuplicate{Customer: customers[i], Orders: orders[customers[i].ID]})
+		}
+		sort.SliceStable(group.Customers, func(i, j int) bool {
+			a, b := group.Customers[i], group.Customers[j]
+			if a.Orders != b.Orders {
+				return a.Orders > b.Orders
+			}
+			if ca, cb := customerCompleteness(a.Customer), customerCompleteness(b.Customer); ca != cb {
+				return ca > cb
+			}
+			return a.CreatedAt.Before(b.CreatedAt)
+		})
+		group.SurvivorID = group.Customers[0].ID
+		for _, reason := range []string{DuplicateByPhone, DuplicateByEmail, DuplicateByNameAddress} {
+			if reasons[root][reason] {
+				group.Reasons = append(group.Reasons, reason)
+			}
+		}
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i].Customers) != len(groups[j].Customers) {
+			return len(groups[i].Customers) > len(groups[j].Customers)
+		}
+		return strings.ToLower(groups[i].Customers[0].Name) < strings.ToLower(groups[j].Customers[0].Name)
+	})
+	return groups, nil
+}
+
+// Merge folds the duplicates into the survivor. Their orders are re-pointed to the
+// survivor, contact details the survivor lacks are taken from the duplicates, their
+// notes are appended, and the duplicates are deleted, all in one transaction that
+// reads the customers under lock.
+func (s *CustomerService) Merge(ctx context.Context, input MergeCustomersInput) (*MergeCustomersResult, error) {
+	survivorID := strings.TrimSpace(input.SurvivorID)
+	if survivorID == "" {
+		return nil, errors.New("customer id required")
+	}
+	duplicateIDs := make([]string, 0, len(input.DuplicateIDs))
+	seen := map[string]bool{survivorID: true}
+	for _, id := range input.DuplicateIDs {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		duplicateIDs = append(duplicateIDs, id)
+	}
+	if len(duplicateIDs) == 0 {
+		return nil, errors.New("pilih minimal satu kontak duplikat untuk digabungkan")
+	}
+
+	survivor, moved, err := s.repo.Merge(ctx, survivorID, duplicateIDs, mergeCustomerDetails)
+	if err != nil {
+		return nil, err
+	}
+	return &MergeCustomersResult{Customer: survivor, MergedIDs: duplicateIDs, OrdersMoved: moved}, nil
+}
+
+// mergeCustomerDetails fills the contact details the survivor lacks from the
+// duplicates and appends their notes.
+func mergeCustomerDetails(survivor *domain.Customer, duplicates []domain.Customer) {
+	notes := []string{}
+	if survivor.Notes != "" {
+		notes = append(notes, survivor.Notes)
+	}
+	for _, dup := range duplicates {
+		if survivor.Phone == "" {
+			if phone, err := normalisePhone(dup.Phone); err == nil {
+				survivor.Phone = phone
+			}
+		}
+		fillBlank(&survivor.Email, dup.Email)
+		fillBlank(&survivor.Address, dup.Address)
+		fillBlank(&survivor.City, dup.City)
+		fillBlank(&survivor.Province, dup.Province)
+		fillBlank(&survivor.Postal, dup.Postal)
+		if note := strings.TrimSpace(dup.Notes); note != "" && !strings.Contains(strings.Join(notes, "\n"), note) {
+			notes = append(notes, note)
+		}
+	}
+	survivor.Notes = strings.Join(notes, "\n")
+}
+
+func fillBlank(field *string, value string) {
+	if strings.TrimSpace(*field) == "" {
+		*field = strings.TrimSpace(value)
+	}
+}
+
+// customerCompleteness counts the filled contact fields of a customer.
+func customerCompleteness(c domain.Customer) int {
+	count := 0
+	for _, field := range []string{c.Phone, c.Email, c.Address, c.City, c.Province, c.Postal} {
+		if strings.TrimSpace(field) != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// Honorifics dropped from names, and address abbreviations spelled out, before names
+// and addresses are compared.
+var (
+	nameHonorifics = map[string]bool{
+		"bapak": true, "bpk": true, "pak": true, "ibu": true, "bu": true, "mas": true,
+		"mbak": true, "mba": true, "kak": true, "sdr": true, "sdri": true, "hj": true, "h": true,
+	}
+	addressAbbreviations = map[string]string{
+		"jl": "jalan", "jln": "jalan", "gg": "gang", "kel": "kelurahan", "kec": "kecamatan",
+		"kab": "kabupaten", "no": "", "nomor": "", "blk": "blok",
+	}
+)
+
+// normaliseCustomerName lower-cases a name, drops punctuation and honorifics, and
+// sorts the words so "Siti Aminah" and "Aminah, Siti" compare equal.
+func normaliseCustomerName(name string) string {
+	words := make([]string, 0)
+	for _, word := range textWords(name) {
+		if !nameHonorifics[word] {
+			words = append(words, word)
+		}
+	}
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// normaliseCustomerAddress lower-cases an address, drops punctuation and spells out
+// common abbreviations.
+func normaliseCustomerAddress(address string) string {
+	words := make([]string, 0)
+	for _, word := range textWords(address) {
+		if full, ok := addressAbbreviations[word]; ok {
+			word = full
+		}
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func textWords(value string) []string {
+	return strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// similarity is one minus the edit distance of a and b relative to the longer string:
+// 1 for equal strings, 0 for entirely different ones.
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
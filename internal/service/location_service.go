@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"smartseller-lite-starter/internal/domain"
+	"smartseller-lite-starter/internal/repo"
+)
+
+// ErrLocationNotFound is returned when a location id or code matches nothing.
+var ErrLocationNotFound = repo.ErrLocationNotFound
+
+// TransferStockItem is one product moved by a transfer.
+type TransferStockItem struct {
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+// TransferStockInput moves stock between two locations. Locations may be given by id
+// or code; an empty source or destination means the default location.
+type TransferStockInput struct {
+	FromLocationID string              `json:"fromLocationId"`
+	ToLocationID   string              `json:"toLocationId"`
+	Note           string              `json:"note"`
+	Items          []TransferStockItem `json:"items"`
+}
+
+// LocationService manages the places stock is kept and moves stock between them.
+type LocationService struct {
+	repo     *repo.LocationRepository
+	products *repo.ProductRepository
+}
+
+func NewLocationService(repo *repo.LocationRepository, products *repo.ProductRepository) *LocationService {
+	return &LocationService{repo: repo, products: products}
+}
+
+func (s *LocationService) List(ctx context.Context) ([]domain.Location, error) {
+	return s.repo.List(ctx)
+}
+
+// Resolve returns the location with the given id or code, or the default location
+// when ref is empty.
+func (s *LocationService) Resolve(ctx context.Context, ref string) (*domain.Location, error) {
+	ref = strings.TrimSpace(ref)
+	var (
+		location *domain.Location
+		err      error
+	)
+	if ref == "" {
+		location, err = s.repo.Default(ctx)
+	} else {
+		location, err = s.repo.Find(ctx, ref)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrLocationNotFound, ref)
+		}
+		return nil, err
+	}
+	return location, nil
+}
+
+// Save creates a location, or updates it when ID is set.
+func (s *LocationService) Save(ctx context.Context, location domain.Location) (*domain.Location, error) {
+	location.Code = strings.ToUpper(strings.TrimSpace(location.Code))
+	location.Name = strings.TrimSpace(location.Name)
+	location.Notes = strings.TrimSpace(location.Notes)
+	if location.Code == "" || location.Name == "" {
+		return nil, errors.New("kode dan nama lokasi wajib diisi")
+	}
+	switch location.Kind {
+	case "":
+		location.Kind = domain.LocationWarehouse
+	case domain.LocationHome, domain.LocationWarehouse, domain.LocationConsignment:
+	default:
+		return nil, fmt.Errorf("jenis lokasi %q tidak dikenal", location.Kind)
+	}
+
+	if location.ID != "" {
+		existing, err := s.Resolve(ctx, location.ID)
+		if err != nil {
+			return nil, err
+		}
+		if existing.IsDefault && !location.IsDefault {
+			return nil, errors.New("pilih lokasi lain sebagai default terlebih dahulu")
+		}
+	}
+	saved, err := s.repo.Save(ctx, &location)
+	if err != nil {
+		return nil, err
+	}
+	return s.Resolve(ctx, saved.ID)
+}
+
+func (s *LocationService) Delete(ctx context.Context, id string) error {
+	if strings.TrimSpace(id) == "" {
+		return errors.New("location id required")
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", ErrLocationNotFound, id)
+		}
+		return err
+	}
+	return nil
+}
+
+// StockAt returns how many units of each product are held at a location.
+func (s *LocationService) StockAt(ctx context.Context, locationID string, productIDs []string) (map[string]int, error) {
+	return s.products.StockAt(ctx, locationID, productIDs)
+}
+
+// Transfer moves the listed quantities from one location to another atomically.
+func (s *LocationService) Transfer(ctx context.Context, input TransferStockInput) (*domain.StockTransfer, error) {
+	if len(input.Items) == 0 {
+		return nil, errors.New("pilih minimal satu produk untuk dipindahkan")
+	}
+	from, err := s.Resolve(ctx, input.FromLocationID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.Resolve(ctx, input.ToLocationID)
+	if err != nil {
+		return nil, err
+	}
+	if from.ID == to.ID {
+		return nil, errors.New("lokasi asal dan tujuan harus berbeda")
+	}
+
+	transfer := &domain.StockTransfer{FromLocationID: from.ID, ToLocationID: to.ID, Note: strings.TrimSpace(input.Note)}
+	index := make(map[string]int)
+	for _, item := range input.Items {
+		if item.ProductID == "" {
+			return nil, errors.New("item missing product")
+		}
+		if item.Quantity <= 0 {
+			return nil, errors.New("jumlah yang dipindahkan harus lebih dari nol")
+		}
+		if i, ok := index[item.ProductID]; ok {
+			transfer.Items[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.ProductID] = len(transfer.Items)
+		transfer.Items = append(transfer.Items, domain.StockTransferItem{ProductID: item.ProductID, Quantity: item.Quantity})
+	}
+	return s.products.Transfer(ctx, transfer)
+}
+
+func (s *LocationService) ListTransfers(ctx context.Context, limit int) ([]domain.StockTransfer, error) {
+	return s.products.ListTransfers(ctx, limit)
+}
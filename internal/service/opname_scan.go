@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"smartseller-lite-starter/internal/domain"
+)
+
+// scanSessionIdleTTL is how long an untouched scan session is kept in memory.
+const scanSessionIdleTTL = 12 * time.Hour
+
+// ErrScanSessionNotFound is returned for unknown, submitted or expired scan sessions.
+var ErrScanSessionNotFound = errors.New("sesi scan tidak ditemukan atau sudah berakhir")
+
+// StartScanSessionInput opens a scanner-driven counting session.
+type StartScanSessionInput struct {
+	User       string `json:"user"`
+	Note       string `json:"note"`
+	LocationID string `json:"locationId"`
+}
+
+// ScanInput records a scanned code. Quantity defaults to one; a negative quantity
+// takes back accidental scans.
+type ScanInput struct {
+	Code     string `json:"code"`
+	Quantity int    `json:"quantity"`
+}
+
+// StartScanSession opens a session whose counts are kept server-side until submitted.
+func (s *StockOpnameService) StartScanSession(ctx context.Context, input StartScanSessionInput) (*domain.OpnameScanSession, error) {
+	user := strings.TrimSpace(input.User)
+	if user == "" {
+		return nil, fmt.Errorf("nama petugas opname wajib diisi")
+	}
+	location, err := s.locations.Resolve(ctx, input.LocationID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	session := &domain.OpnameScanSession{
+		ID:         uuid.New().String(),
+		LocationID: location.ID,
+		User:       user,
+		Note:       strings.TrimSpace(input.Note),
+		Items:      []domain.OpnameScanItem{},
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+	s.purgeScanSessionsLocked(now)
+	s.scans[session.ID] = session
+	return cloneScanSession(session), nil
+}
+
+func (s *StockOpnameService) GetScanSession(_ context.Context, id string) (*domain.OpnameScanSession, error) {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+	session, err := s.scanSessionLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	return cloneScanSession(session), nil
+}
+
+// RecordScan resolves the code to a product and adds the quantity to its count.
+func (s *StockOpnameService) RecordScan(ctx context.Context, id string, input ScanInput) (*domain.OpnameScanSession, error) {
+	if _, err := s.GetScanSession(ctx, id); err != nil {
+		return nil, err
+	}
+	product, err := s.countableProduct(ctx, OpnameCountEntry{Code: input.Code})
+	if err != nil {
+		return nil, err
+	}
+	qty := input.Quantity
+	if qty == 0 {
+		qty = 1
+	}
+
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+	session, err := s.scanSessionLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	idx := scanItemIndex(session, product.ID)
+	if idx < 0 {
+		session.Items = append(session.Items, domain.OpnameScanItem{
+			ProductID:   product.ID,
+			ProductName: product.Name,
+			ProductSKU:  product.SKU,
+			Barcode:     product.Barcode,
+		})
+		idx = len(session.Items) - 1
+	}
+	item := &session.Items[idx]
+	if item.Counted+qty < 0 {
+		return nil, fmt.Errorf("jumlah hitungan %s tidak boleh negatif", product.Name)
+	}
+	item.Counted += qty
+	item.LastScannedAt = now
+	last := *item
+	session.LastScan = &last
+	session.UpdatedAt = now
+	return cloneScanSession(session), nil
+}
+
+// SetScanCount overrides the count of a product already in the session.
+func (s *StockOpnameService) SetScanCount(_ context.Context, id, productID string, counted int) (*domain.OpnameScanSession, error) {
+	if counted < 0 {
+		return nil, fmt.Errorf("counted stock cannot be negative")
+	}
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+	session, err := s.scanSessionLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	idx := scanItemIndex(session, productID)
+	if idx < 0 {
+		return nil, fmt.Errorf("produk belum dipindai pada sesi ini")
+	}
+	session.Items[idx].Counted = counted
+	session.UpdatedAt = time.Now().UTC()
+	return cloneScanSession(session), nil
+}
+
+// RemoveScanItem drops a product from the session.
+func (s *StockOpnameService) RemoveScanItem(_ context.Context, id, productID string) (*domain.OpnameScanSession, error) {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+	session, err := s.scanSessionLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	if idx := scanItemIndex(session, productID); idx >= 0 {
+		session.Items = append(session.Items[:idx], session.Items[idx+1:]...)
+		if session.LastScan != nil && session.LastScan.ProductID == productID {
+			session.LastScan = nil
+		}
+	}
+	session.UpdatedAt = time.Now().UTC()
+	return cloneScanSession(session), nil
+}
+
+func (s *StockOpnameService) DiscardScanSession(_ context.Context, id string) error {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+	if _, err := s.scanSessionLocked(id); err != nil {
+		return err
+	}
+	delete(s.scans, id)
+	return nil
+}
+
+// SubmitScanSession performs a stock opname from the session counts and closes the
+// session. The session is taken out before the opname runs, so a second submit finds
+// nothing to post; it is put back if the opname fails.
+func (s *StockOpnameService) SubmitScanSession(ctx context.Context, id string) (*domain.StockOpname, error) {
+	s.scanMu.Lock()
+	session, err := s.scanSessionLocked(id)
+	if err != nil {
+		s.scanMu.Unlock()
+		return nil, err
+	}
+	if len(session.Items) == 0 {
+		s.scanMu.Unlock()
+		return nil, fmt.Errorf("belum ada produk yang dipindai")
+	}
+	delete(s.scans, session.ID)
+	s.scanMu.Unlock()
+
+	input := PerformStockOpnameInput{LocationID: session.LocationID, Note: session.Note, User: session.User}
+	for _, item := range session.Items {
+		input.Items = append(input.Items, PerformStockOpnameItem{ProductID: item.ProductID, Counted: item.Counted})
+	}
+	opname, err := s.Perform(ctx, input)
+	if err != nil {
+		s.scanMu.Lock()
+		session.UpdatedAt = time.Now().UTC()
+		s.scans[session.ID] = session
+		s.scanMu.Unlock()
+		return nil, err
+	}
+	return opname, nil
+}
+
+func (s *StockOpnameService) scanSessionLocked(id string) (*domain.OpnameScanSession, error) {
+	s.purgeScanSessionsLocked(time.Now().UTC())
+	session, ok := s.scans[strings.TrimSpace(id)]
+	if !ok {
+		return nil, ErrScanSessionNotFound
+	}
+	return session, nil
+}
+
+func (s *StockOpnameService) purgeScanSessionsLocked(now time.Time) {
+	for id, session := range s.scans {
+		if now.Sub(session.UpdatedAt) > scanSessionIdleTTL {
+			delete(s.scans, id)
+		}
+	}
+}
+
+func scanItemIndex(session *domain.OpnameScanSession, productID string) int {
+	for i := range session.Items {
+		if session.Items[i].ProductID == productID {
+			return i
+		}
+	}
+	return -1
+}
+
+func cloneScanSession(session *domain.OpnameScanSession) *domain.OpnameScanSession {
+	clone := *session
+	clone.Items = append([]domain.OpnameScanItem{}, session.Items...)
+	if session.LastScan != nil {
+		last := *session.LastScan
+		clone.LastScan = &last
+	}
+	return &clone
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"smartseller-lite-starter/internal/domain"
+	"smartseller-lite-starter/internal/repo"
+)
+
+// ErrOpnameSessionNotFound is returned when a session id matches nothing.
+var ErrOpnameSessionNotFound = errors.New("sesi stock opname tidak ditemukan")
+
+// OpenOpnameSessionInput starts a session. ProductIDs optionally fixes the products
+// that must be counted before review; products outside it may still be counted.
+type OpenOpnameSessionInput struct {
+	LocationID string   `json:"locationId"`
+	Note       string   `json:"note"`
+	User       string   `json:"user"`
+	BlindCount bool     `json:"blindCount"`
+	ProductIDs []string `json:"productIds"`
+}
+
+// OpnameCountEntry is one product counted; Code may name it by SKU or barcode instead
+// of ProductID.
+type OpnameCountEntry struct {
+	ProductID string `json:"productId"`
+	Code      string `json:"code"`
+	Quantity  int    `json:"quantity"`
+}
+
+// RecordOpnameCountsInput saves what one counter found. A new count of a product
+// replaces the counter's previous one; counts from different counters add up.
+type RecordOpnameCountsInput struct {
+	Counter string             `json:"counter"`
+	Items   []OpnameCountEntry `json:"items"`
+}
+
+// OpnameSessionActionInput names who moves a session along. ZeroUncounted lets a
+// review go ahead with products in scope nobody counted, recording them as zero.
+type OpnameSessionActionInput struct {
+	User          string `json:"user"`
+	ZeroUncounted bool   `json:"zeroUncounted"`
+}
+
+// OpenSession starts a multi-step stock take at a location.
+func (s *StockOpnameService) OpenSession(ctx context.Context, input OpenOpnameSessionInput) (*domain.OpnameSession, error) {
+	user := strings.TrimSpace(input.User)
+	if user == "" {
+		return nil, fmt.Errorf("nama petugas opname wajib diisi")
+	}
+	location, err := s.locations.Resolve(ctx, input.LocationID)
+	if err != nil {
+		return nil, err
+	}
+	scope := make([]string, 0, len(input.ProductIDs))
+	for _, id := range input.ProductIDs {
+		product, err := s.countableProduct(ctx, OpnameCountEntry{ProductID: id})
+		if err != nil {
+			return nil, err
+		}
+		scope = append(scope, product.ID)
+	}
+	session := &domain.OpnameSession{LocationID: location.ID, Note: strings.TrimSpace(input.Note), BlindCount: input.BlindCount, OpenedBy: user}
+	if err := s.repo.CreateSession(ctx, session, scope); err != nil {
+		return nil, err
+	}
+	return s.GetSession(ctx, session.ID)
+}
+
+// GetSession returns a session as its participants may see it. While counting, the
+// current system stock is shown unless the count is blind; from review on, the stock
+// snapshotted when the session entered review is shown.
+func (s *StockOpnameService) GetSession(ctx context.Context, id string) (*domain.OpnameSession, error) {
+	session, err := s.repo.GetSession(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrOpnameSessionNotFound, id)
+		}
+		return nil, err
+	}
+	counting := session.Status == domain.OpnameSessionOpen || session.Status == domain.OpnameSessionCounting
+	if counting && !session.BlindCount && len(session.Items) > 0 {
+		ids := make([]string, 0, len(session.Items))
+		for _, item := range session.Items {
+			ids = append(ids, item.ProductID)
+		}
+		held, err := s.locations.StockAt(ctx, session.LocationID, ids)
+		if err != nil {
+			return nil, err
+		}
+		for i := range session.Items {
+			stock := held[session.Items[i].ProductID]
+			session.Items[i].SystemStock = &stock
+		}
+	}
+	for i := range session.Items {
+		item := &session.Items[i]
+		if counting && session.BlindCount {
+			item.SystemStock = nil
+		}
+		if item.Counted != nil && item.SystemStock != nil {
+			diff := *item.Counted - *item.SystemStock
+			item.Difference = &diff
+		}
+	}
+	return session, nil
+}
+
+// ListSessions returns recent sessions, optionally only those in the given statuses.
+func (s *StockOpnameService) ListSessions(ctx context.Context, statuses []domain.OpnameSessionStatus, limit int) ([]domain.OpnameSession, error) {
+	return s.repo.ListSessions(ctx, statuses, limit)
+}
+
+// RecordCounts saves a counter's counts; the first count moves the session to counting.
+func (s *StockOpnameService) RecordCounts(ctx context.Context, id string, input RecordOpnameCountsInput) (*domain.OpnameSession, error) {
+	counter := strings.TrimSpace(input.Counter)
+	if counter == "" {
+		return nil, fmt.Errorf("nama penghitung wajib diisi")
+	}
+	if len(input.Items) == 0 {
+		return nil, fmt.Errorf("isi minimal satu hitungan")
+	}
+	if _, err := s.GetSession(ctx, id); err != nil {
+		return nil, err
+	}
+	counts := make([]repo.OpnameSessionCount, 0, len(input.Items))
+	for _, entry := range input.Items {
+		if entry.Quantity < 0 {
+			return nil, fmt.Errorf("counted stock cannot be negative")
+		}
+		product, err := s.countableProduct(ctx, entry)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, repo.OpnameSessionCount{ProductID: product.ID, Quantity: entry.Quantity})
+	}
+	if err := s.repo.SaveSessionCounts(ctx, id, counter, counts); err != nil {
+		return nil, err
+	}
+	return s.GetSession(ctx, id)
+}
+
+// RemoveCount withdraws what a counter recorded for a product.
+func (s *StockOpnameService) RemoveCount(ctx context.Context, id, productID, counter string) (*domain.OpnameSession, error) {
+	if _, err := s.GetSession(ctx, id); err != nil {
+		return nil, err
+	}
+	if err := s.repo.DeleteSessionCount(ctx, id, productID, strings.TrimSpace(counter)); err != nil {
+		return nil, err
+	}
+	return s.GetSession(ctx, id)
+}
+
+// SubmitForReview closes counting and snapshots the system stock of every product so
+// the variance can be reviewed. Products in scope nobody counted block the review
+// unless ZeroUncounted records them as zero.
+func (s *StockOpnameService) SubmitForReview(ctx context.Context, id string, input OpnameSessionActionInput) (*domain.OpnameSession, error) {
+	user := strings.TrimSpace(input.User)
+	if user == "" {
+		return nil, fmt.Errorf("nama peninjau wajib diisi")
+	}
+	session, err := s.GetSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(session.Items) == 0 {
+		return nil, fmt.Errorf("belum ada produk yang dihitung")
+	}
+	ids := make([]string, 0, len(session.Items))
+	uncounted := make([]string, 0)
+	names := make([]string, 0)
+	for _, item := range session.Items {
+		ids = append(ids, item.ProductID)
+		if item.Counted == nil {
+			uncounted = append(uncounted, item.ProductID)
+			names = append(names, item.ProductName)
+		}
+	}
+	if len(uncounted) > 0 && !input.ZeroUncounted {
+		return nil, fmt.Errorf("produk belum dihitung: %s", strings.Join(names, ", "))
+	}
+	held, err := s.locations.StockAt(ctx, session.LocationID, ids)
+	if err != nil {
+		return nil, err
+	}
+	system := make(map[string]int, len(ids))
+	for _, productID := range ids {
+		system[productID] = held[productID]
+	}
+	transition := repo.OpnameSessionTransition{
+		ID:          id,
+		From:        []domain.OpnameSessionStatus{domain.OpnameSessionCounting},
+		To:          domain.OpnameSessionReview,
+		Actor:       user,
+		SystemStock: system,
+	}
+	if input.ZeroUncounted {
+		transition.ZeroCounts = uncounted
+	}
+	if err := s.repo.TransitionSession(ctx, transition); err != nil {
+		return nil, err
+	}
+	return s.GetSession(ctx, id)
+}
+
+// Reopen sends a session under review back to counting, for recounts.
+func (s *StockOpnameService) Reopen(ctx context.Context, id string) (*domain.OpnameSession, error) {
+	if _, err := s.GetSession(ctx, id); err != nil {
+		return nil, err
+	}
+	transition := repo.OpnameSessionTransition{ID: id, From: []domain.OpnameSessionStatus{domain.OpnameSessionReview}, To: domain.OpnameSessionCounting, SystemStock: map[string]int{}}
+	if err := s.repo.TransitionSession(ctx, transition); err != nil {
+		return nil, err
+	}
+	return s.GetSession(ctx, id)
+}
+
+// Approve posts the reviewed counts as a stock opname and marks the session applied,
+// in one transaction that holds the session lock; sessions not under review are refused.
+// Stock sold since the review is taken into account: the adjustment brings stock to
+// what was counted, and the opname records the stock it actually replaced.
+func (s *StockOpnameService) Approve(ctx context.Context, id string, input OpnameSessionActionInput) (*domain.OpnameSession, error) {
+	user := strings.TrimSpace(input.User)
+	if user == "" {
+		return nil, fmt.Errorf("nama penyetuju wajib diisi")
+	}
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+
+	session, err := s.GetSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != domain.OpnameSessionReview {
+		return nil, fmt.Errorf("%w: sesi berstatus %s", repo.ErrOpnameSessionState, session.Status)
+	}
+	perform := PerformStockOpnameInput{LocationID: session.LocationID, Note: session.Code, User: user}
+	if session.Note != "" {
+		perform.Note += ": " + session.Note
+	}
+	for _, item := range session.Items {
+		if item.Counted != nil {
+			perform.Items = append(perform.Items, PerformStockOpnameItem{ProductID: item.ProductID, Counted: *item.Counted})
+		}
+	}
+	if _, err := s.perform(ctx, perform, id); err != nil {
+		return nil, err
+	}
+	return s.GetSession(ctx, id)
+}
+
+// Cancel abandons a session that has not been applied. Stock is left untouched.
+func (s *StockOpnameService) Cancel(ctx context.Context, id string, input OpnameSessionActionInput) (*domain.OpnameSession, error) {
+	user := strings.TrimSpace(input.User)
+	if user == "" {
+		return nil, fmt.Errorf("nama petugas opname wajib diisi")
+	}
+	if _, err := s.GetSession(ctx, id); err != nil {
+		return nil, err
+	}
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+	transition := repo.OpnameSessionTransition{
+		ID:    id,
+		From:  []domain.OpnameSessionStatus{domain.OpnameSessionOpen, domain.OpnameSessionCounting, domain.OpnameSessionReview},
+		To:    domain.OpnameSessionCancelled,
+		Actor: user,
+	}
+	if err := s.repo.TransitionSession(ctx, transition); err != nil {
+		return nil, err
+	}
+	return s.GetSession(ctx, id)
+}
+
+// countableProduct resolves a count entry to a product whose stock is counted directly.
+func (s *StockOpnameService) countableProduct(ctx context.Context, entry OpnameCountEntry) (*domain.Product, error) {
+	var product *domain.Product
+	var err error
+	switch {
+	case entry.ProductID != "":
+		product, err = s.products.Get(ctx, entry.ProductID)
+	case strings.TrimSpace(entry.Code) != "":
+		product, err = s.products.Lookup(ctx, entry.Code)
+	default:
+		return nil, fmt.Errorf("product id is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if product.IsBundle {
+		return nil, fmt.Errorf("%s adalah bundle; hitung stok komponennya", product.Name)
+	}
+	if product.HasVariants() {
+		return nil, fmt.Errorf("%s memiliki varian; hitung stok per varian", product.Name)
+	}
+	return product, nil
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"image"
@@ -20,10 +21,15 @@ import (
 
 	_ "golang.org/x/image/webp"
 
+	"smartseller-lite-starter/internal/barcode"
 	"smartseller-lite-starter/internal/domain"
+	"smartseller-lite-starter/internal/events"
 	"smartseller-lite-starter/internal/repo"
 )
 
+// ErrOrderNotFound is returned when an order id matches nothing.
+var ErrOrderNotFound = errors.New("pesanan tidak ditemukan")
+
 type OrderItemInput struct {
 	ProductID    string  `json:"productId"`
 	Quantity     int     `json:"quantity"`
@@ -31,7 +37,10 @@ type OrderItemInput struct {
 	DiscountItem float64 `json:"discountItem"`
 }
 
+// CreateOrderInput describes a new order. LocationID names the location the items are
+// picked from, by id or code; empty means the default location.
 type CreateOrderInput struct {
+	LocationID            string           `json:"locationId"`
 	BuyerID               string           `json:"buyerId"`
 	RecipientID           string           `json:"recipientId"`
 	Items                 []OrderItemInput `json:"items"`
@@ -50,6 +59,8 @@ type OrderService struct {
 	products  *ProductService
 	customers *CustomerService
 	settings  *SettingsService
+	locations *LocationService
+	events    *events.Bus
 }
 
 type OrderListOptions struct {
@@ -81,8 +92,8 @@ type OrderListResult struct {
 	Couriers []string         `json:"couriers"`
 }
 
-func NewOrderService(repo *repo.OrderRepository, products *ProductService, customers *CustomerService, settings *SettingsService) *OrderService {
-	return &OrderService{repo: repo, products: products, customers: customers, settings: settings}
+func NewOrderService(repo *repo.OrderRepository, products *ProductService, customers *CustomerService, settings *SettingsService, locations *LocationService, bus *events.Bus) *OrderService {
+	return &OrderService{repo: repo, products: products, customers: customers, settings: settings, locations: locations, events: bus}
 }
 
 func (s *OrderService) Warm(ctx context.Context) {
@@ -109,6 +120,10 @@ func (s *OrderService) Create(ctx context.Context, input CreateOrderInput) (*dom
 	if input.ShippingCost < 0 {
 		input.ShippingCost = 0
 	}
+	location, err := s.locations.Resolve(ctx, input.LocationID)
+	if err != nil {
+		return nil, err
+	}
 
 	var (
 		subtotal  float64
@@ -116,6 +131,10 @@ func (s *OrderService) Create(ctx context.Context, input CreateOrderInput) (*dom
 		items     []domain.OrderItem
 	)
 
+	// Demand is summed per stocked product so bundles sharing components with
+	// other lines cannot oversell them.
+	demand := newStockDemand()
+
 	for _, line := range input.Items {
 		if line.ProductID == "" {
 			return nil, errors.New("item missing product")
@@ -127,8 +146,26 @@ func (s *OrderService) Create(ctx context.Context, input CreateOrderInput) (*dom
 		if err != nil {
 			return nil, err
 		}
-		if prod.Stock < line.Quantity {
-			return nil, fmt.Errorf("stok kurang untuk produk %s", prod.Name)
+		if prod.HasVariants() {
+			return nil, fmt.Errorf("pilih varian untuk produk %s", prod.Name)
+		}
+		var components []domain.OrderItemComponent
+		if prod.IsBundle {
+			if len(prod.Components) == 0 {
+				return nil, fmt.Errorf("bundle %s belum memiliki komponen", prod.Name)
+			}
+			for _, c := range prod.Components {
+				qty := c.Quantity * line.Quantity
+				components = append(components, domain.OrderItemComponent{
+					ProductID: c.ProductID,
+					SKU:       c.SKU,
+					Quantity:  qty,
+					CostPrice: c.CostPrice,
+				})
+				demand.add(c.ProductID, c.Name, c.Stock, qty)
+			}
+		} else {
+			demand.add(prod.ID, prod.Name, prod.Stock, line.Quantity)
 		}
 		unitPrice := line.UnitPrice
 		if unitPrice <= 0 {
@@ -159,9 +196,24 @@ func (s *OrderService) Create(ctx context.Context, input CreateOrderInput) (*dom
 			DiscountItem: itemDiscount,
 			CostPrice:    prod.CostPrice,
 			Profit:       lineProfit,
+			Components:   components,
 		})
 	}
 
+	held, err := s.locations.StockAt(ctx, location.ID, demand.order)
+	if err != nil {
+		return nil, err
+	}
+	demand.limitTo(held)
+	expired, err := s.products.ExpiredStock(ctx, demand.order)
+	if err != nil {
+		return nil, err
+	}
+	demand.exclude(expired)
+	if err := demand.check(); err != nil {
+		return nil, fmt.Errorf("%w di lokasi %s", err, location.Name)
+	}
+
 	var shippingCostToSubtract float64
 	if !input.IsBuyerPayingShipping {
 		shippingCostToSubtract = input.ShippingCost
@@ -173,6 +225,7 @@ func (s *OrderService) Create(ctx context.Context, input CreateOrderInput) (*dom
 	}
 
 	order := &domain.Order{
+		LocationID:    location.ID,
 		BuyerID:       input.BuyerID,
 		RecipientID:   input.RecipientID,
 		Items:         items,
@@ -189,22 +242,73 @@ func (s *OrderService) Create(ctx context.Context, input CreateOrderInput) (*dom
 		Profit: profit,
 	}
 
-	saved, err := s.repo.Create(ctx, order)
+	saved, adjustments, err := s.repo.Create(ctx, order)
 	if err != nil {
 		return nil, err
 	}
-
-	// Reduce stock after persisting order to minimise lost updates.
-	for _, item := range saved.Items {
-		reason := fmt.Sprintf("order:%s", saved.Code)
-		if err := s.products.AdjustStock(ctx, item.ProductID, -item.Quantity, reason); err != nil {
-			return nil, err
-		}
+	for _, adj := range adjustments {
+		s.products.publishStockChange(ctx, adj)
 	}
 
+	s.events.Publish(ctx, events.OrderCreated, saved)
 	return saved, nil
 }
 
+type stockDemandEntry struct {
+	name      string
+	stock     int
+	available int
+	required  int
+}
+
+// stockDemand accumulates required quantities per product while an order is built.
+type stockDemand struct {
+	order   []string
+	entries map[string]*stockDemandEntry
+}
+
+func newStockDemand() *stockDemand {
+	return &stockDemand{entries: make(map[string]*stockDemandEntry)}
+}
+
+func (d *stockDemand) add(productID, name string, available, qty int) {
+	entry, ok := d.entries[productID]
+	if !ok {
+		entry = &stockDemandEntry{name: name, stock: available, available: available}
+		d.entries[productID] = entry
+		d.order = append(d.order, productID)
+	}
+	entry.required += qty
+}
+
+// exclude caps what is available at the product's stock less units that may not be
+// sold, such as expired lots. Lots are not kept per location, so the units are taken
+// off the product's total rather than off one location's share: expired units held
+// elsewhere do not block an order a location can fill with good stock. This only gives
+// an early, readable error; moveStock enforces the same limit under lock.
+func (d *stockDemand) exclude(units map[string]int) {
+	for id, entry := range d.entries {
+		entry.available = min(entry.available, entry.stock-units[id])
+	}
+}
+
+// limitTo caps what is available of each product at the quantities one location holds.
+func (d *stockDemand) limitTo(held map[string]int) {
+	for id, entry := range d.entries {
+		entry.available = held[id]
+	}
+}
+
+func (d *stockDemand) check() error {
+	for _, id := range d.order {
+		entry := d.entries[id]
+		if entry.available < entry.required {
+			return fmt.Errorf("stok kurang untuk produk %s", entry.name)
+		}
+	}
+	return nil
+}
+
 func (s *OrderService) List(ctx context.Context, limit int) ([]domain.Order, error) {
 	return s.repo.List(ctx, limit)
 }
@@ -250,7 +354,14 @@ func (s *OrderService) Get(ctx context.Context, id string) (*domain.Order, error
 	if id == "" {
 		return nil, errors.New("order id required")
 	}
-	return s.repo.Get(ctx, id)
+	order, err := s.repo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrOrderNotFound, id)
+		}
+		return nil, err
+	}
+	return order, nil
 }
 
 func (s *OrderService) Delete(ctx context.Context, id string) error {
@@ -258,25 +369,23 @@ func (s *OrderService) Delete(ctx context.Context, id string) error {
 		return errors.New("order id required")
 	}
 
-	order, err := s.repo.Get(ctx, id)
+	order, err := s.Get(ctx, id)
 	if err != nil {
-		return fmt.Errorf("get order for deletion: %w", err)
-	}
-
-	if err := s.repo.Delete(ctx, id); err != nil {
 		return err
 	}
 
-	// Restore stock after deleting order.
-	for _, item := range order.Items {
-		reason := fmt.Sprintf("order-deleted:%s", order.Code)
-		if err := s.products.AdjustStock(ctx, item.ProductID, item.Quantity, reason); err != nil {
-			// Log this error but don't fail the whole operation,
-			// as the primary goal (order deletion) is complete.
-			fmt.Printf("failed to restore stock for product %s: %v\n", item.ProductID, err)
+	adjustments, err := s.repo.Delete(ctx, order)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", ErrOrderNotFound, id)
 		}
+		return err
+	}
+	for _, adj := range adjustments {
+		s.products.publishStockChange(ctx, adj)
 	}
 
+	s.events.Publish(ctx, events.OrderDeleted, order)
 	return nil
 }
 
@@ -424,6 +533,22 @@ func (s *OrderService) GenerateLabelPDF(ctx context.Context, orderID string) ([]
 
 	writeRow("Produk", describeOrderItems(order.Items))
 
+	// Couriers scan the AWB when it is known; until then the order code identifies the parcel.
+	barcodeText := strings.TrimSpace(order.Shipment.TrackingCode)
+	barcodeLabel := "No. Resi"
+	if barcodeText == "" {
+		barcodeText = order.Code
+		barcodeLabel = "Kode Order"
+	}
+	if code, err := barcode.Code128(barcodeText); err == nil {
+		barW := math.Min(pageW-leftMargin-rightMargin, float64(code.Width())*0.4)
+		barY := pdf.GetY() + 3
+		code.DrawPDF(pdf, leftMargin, barY, barW, 16)
+		pdf.SetFont("Courier", "", 9)
+		pdf.SetXY(leftMargin, barY+17)
+		pdf.CellFormat(barW, 4, fmt.Sprintf("%s: %s", barcodeLabel, barcodeText), "", 1, "C", false, 0, "")
+	}
+
 	var buf bytes.Buffer
 	if err := pdf.Output(&buf); err != nil {
 		return nil, err
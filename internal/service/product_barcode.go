@@ -0,0 +1,191 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/png"
+	"math"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"smartseller-lite-starter/internal/barcode"
+	"smartseller-lite-starter/internal/domain"
+)
+
+const maxBarcodeSheetLabels = 1000
+
+// BarcodeSheetItem selects a product and how many labels to print for it.
+type BarcodeSheetItem struct {
+	ProductID string `json:"productId"`
+	Copies    int    `json:"copies"`
+}
+
+// BarcodeSheetInput is the payload accepted by the barcode sheet generator.
+type BarcodeSheetInput struct {
+	Kind      string             `json:"kind"`
+	ShowPrice bool               `json:"showPrice"`
+	Items     []BarcodeSheetItem `json:"items"`
+}
+
+// BarcodeImage renders the product barcode as PNG. Code128 encodes the SKU (or the
+// barcode when no SKU is set); EAN-13 requires the product barcode.
+func (s *ProductService) BarcodeImage(ctx context.Context, productID, kind string) ([]byte, error) {
+	symbology, err := barcode.ParseKind(kind)
+	if err != nil {
+		return nil, err
+	}
+	product, err := s.Get(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	code, err := productBarcode(product, symbology)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, code.Image(3, 120)); err != nil {
+		return nil, fmt.Errorf("encode barcode png: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// GenerateBarcodeSheetPDF lays out product barcode labels on A4 pages, three columns by eight rows.
+func (s *ProductService) GenerateBarcodeSheetPDF(ctx context.Context, input BarcodeSheetInput) ([]byte, error) {
+	symbology, err := barcode.ParseKind(input.Kind)
+	if err != nil {
+		return nil, err
+	}
+	if len(input.Items) == 0 {
+		return nil, errors.New("pilih minimal satu produk")
+	}
+
+	type sheetLabel struct {
+		product *domain.Product
+		code    *barcode.Barcode
+	}
+	labels := make([]sheetLabel, 0)
+	for _, item := range input.Items {
+		copies := item.Copies
+		if copies <= 0 {
+			copies = 1
+		}
+		product, err := s.Get(ctx, item.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		code, err := productBarcode(product, symbology)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < copies; i++ {
+			labels = append(labels, sheetLabel{product: product, code: code})
+		}
+		if len(labels) > maxBarcodeSheetLabels {
+			return nil, fmt.Errorf("maksimal %d label per lembar cetak", maxBarcodeSheetLabels)
+		}
+	}
+
+	const (
+		cols    = 3
+		rows    = 8
+		marginX = 6.0
+		marginY = 10.0
+		cellW   = 66.0
+		cellH   = 34.6
+		padding = 3.0
+	)
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(marginX, marginY, marginX)
+	pdf.SetAutoPageBreak(false, 0)
+	for i, label := range labels {
+		slot := i % (cols * rows)
+		if slot == 0 {
+			pdf.AddPage()
+		}
+		x := marginX + float64(slot%cols)*cellW
+		y := marginY + float64(slot/cols)*cellH
+		innerW := cellW - 2*padding
+
+		pdf.SetDrawColor(220, 220, 220)
+		pdf.Rect(x, y, cellW, cellH, "D")
+
+		pdf.SetFont("Arial", "B", 8)
+		pdf.SetXY(x+padding, y+padding)
+		pdf.CellFormat(innerW, 4, fitText(pdf, label.product.Name, innerW), "", 0, "L", false, 0, "")
+
+		barY := y + padding + 5
+		barH := 14.0
+		if input.ShowPrice {
+			pdf.SetFont("Arial", "", 8)
+			pdf.SetXY(x+padding, barY)
+			pdf.CellFormat(innerW, 4, formatRupiah(label.product.SalePrice), "", 0, "L", false, 0, "")
+			barY += 5
+			barH = 11
+		}
+
+		barW := math.Min(innerW, float64(label.code.Width())*0.33)
+		label.code.DrawPDF(pdf, x+(cellW-barW)/2, barY, barW, barH)
+
+		pdf.SetFont("Courier", "", 8)
+		pdf.SetXY(x+padding, barY+barH+1)
+		pdf.CellFormat(innerW, 4, label.code.Text, "", 0, "C", false, 0, "")
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func productBarcode(p *domain.Product, kind barcode.Kind) (*barcode.Barcode, error) {
+	if p.HasVariants() {
+		return nil, fmt.Errorf("%s memiliki varian; cetak barcode per varian", p.Name)
+	}
+	if kind == barcode.KindEAN13 {
+		if strings.TrimSpace(p.Barcode) == "" {
+			return nil, fmt.Errorf("produk %s belum memiliki barcode EAN/UPC", p.Name)
+		}
+		return barcode.EAN13(p.Barcode)
+	}
+	code := strings.TrimSpace(p.SKU)
+	if code == "" {
+		code = strings.TrimSpace(p.Barcode)
+	}
+	if code == "" {
+		return nil, fmt.Errorf("produk %s belum memiliki SKU atau barcode", p.Name)
+	}
+	return barcode.Code128(code)
+}
+
+// fitText shortens text with an ellipsis until it fits width in the current font.
+func fitText(pdf *gofpdf.Fpdf, text string, width float64) string {
+	text = strings.TrimSpace(text)
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"...") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+func formatRupiah(value float64) string {
+	digits := fmt.Sprintf("%.0f", math.Abs(value))
+	var b strings.Builder
+	for i, ch := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(ch)
+	}
+	if value < 0 {
+		return "-Rp " + b.String()
+	}
+	return "Rp " + b.String()
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"smartseller-lite-starter/internal/domain"
+	"smartseller-lite-starter/internal/events"
+	"smartseller-lite-starter/internal/media"
+	"smartseller-lite-starter/internal/repo"
+)
+
+// maxProductImages caps the gallery of a single product.
+const maxProductImages = 12
+
+// ErrProductImageNotFound is returned when an image id matches nothing in the gallery.
+var ErrProductImageNotFound = repo.ErrProductImageNotFound
+
+// AddProductImageInput uploads one gallery image as base64 (optionally a data URL).
+// Primary makes it the image labels, lists and exports show.
+type AddProductImageInput struct {
+	ImageData string `json:"imageData"`
+	AltText   string `json:"altText"`
+	Primary   bool   `json:"primary"`
+}
+
+// UpdateProductImageInput changes the alt text of an image; Primary promotes it.
+type UpdateProductImageInput struct {
+	AltText string `json:"altText"`
+	Primary bool   `json:"primary"`
+}
+
+// Images returns the gallery of a product in display order.
+func (s *ProductService) Images(ctx context.Context, productID string) ([]domain.ProductImage, error) {
+	product, err := s.galleryProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	return s.images(ctx, product.ID)
+}
+
+func (s *ProductService) images(ctx context.Context, productID string) ([]domain.ProductImage, error) {
+	galleries, err := s.repo.Images(ctx, []string{productID})
+	if err != nil {
+		return nil, err
+	}
+	items := galleries[productID]
+	if items == nil {
+		items = []domain.ProductImage{}
+	}
+	for i := range items {
+		s.decorateImage(&items[i])
+	}
+	return items, nil
+}
+
+// AddImage processes an uploaded image into a master and thumbnail and appends it to
+// the gallery.
+func (s *ProductService) AddImage(ctx context.Context, productID string, input AddProductImageInput) ([]domain.ProductImage, error) {
+	if s.media == nil {
+		return nil, errors.New("penyimpanan media tidak tersedia")
+	}
+	if strings.TrimSpace(input.ImageData) == "" {
+		return nil, errors.New("gambar wajib diunggah")
+	}
+	product, err := s.galleryProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.images(ctx, product.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(current) >= maxProductImages {
+		return nil, fmt.Errorf("produk sudah memiliki %d gambar; hapus salah satu terlebih dahulu", maxProductImages)
+	}
+	asset, err := s.media.SaveProductImage(ctx, strings.TrimSpace(input.ImageData))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.addAsset(ctx, product.ID, asset, strings.TrimSpace(input.AltText), input.Primary); err != nil {
+		_ = s.media.Remove(asset.Path, asset.ThumbPath)
+		return nil, err
+	}
+	s.publishProductChange(ctx, events.ProductUpdated, product)
+	return s.images(ctx, product.ID)
+}
+
+func (s *ProductService) addAsset(ctx context.Context, productID string, asset *media.Asset, altText string, primary bool) error {
+	return s.repo.AddImage(ctx, galleryImage(productID, asset, altText, primary), maxProductImages)
+}
+
+// galleryImage describes a processed upload as a gallery image of productID.
+func galleryImage(productID string, asset *media.Asset, altText string, primary bool) *domain.ProductImage {
+	return &domain.ProductImage{
+		ProductID:      productID,
+		Path:           asset.Path,
+		ThumbPath:      asset.ThumbPath,
+		Hash:           asset.Hash,
+		Width:          asset.Width,
+		Height:         asset.Height,
+		SizeBytes:      asset.SizeBytes,
+		ThumbWidth:     asset.ThumbWidth,
+		ThumbHeight:    asset.ThumbHeight,
+		ThumbSizeBytes: asset.ThumbSizeBytes,
+		AltText:        altText,
+		IsPrimary:      primary,
+	}
+}
+
+// UpdateImage changes the alt text of an image and optionally makes it primary.
+func (s *ProductService) UpdateImage(ctx context.Context, productID, imageID string, input UpdateProductImageInput) ([]domain.ProductImage, error) {
+	product, err := s.galleryProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateImage(ctx, product.ID, imageID, strings.TrimSpace(input.AltText), input.Primary); err != nil {
+		return nil, err
+	}
+	s.publishProductChange(ctx, events.ProductUpdated, product)
+	return s.images(ctx, product.ID)
+}
+
+// ReorderImages puts the gallery in the given order of image ids.
+func (s *ProductService) ReorderImages(ctx context.Context, productID string, imageIDs []string) ([]domain.ProductImage, error) {
+	product, err := s.galleryProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReorderImages(ctx, product.ID, imageIDs); err != nil {
+		return nil, err
+	}
+	s.publishProductChange(ctx, events.ProductUpdated, product)
+	return s.images(ctx, product.ID)
+}
+
+// DeleteImage removes an image and its files. Removing the primary image promotes the
+// next one in the gallery.
+func (s *ProductService) DeleteImage(ctx context.Context, productID, imageID string) ([]domain.ProductImage, error) {
+	product, err := s.galleryProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	removed, err := s.repo.DeleteImage(ctx, product.ID, imageID)
+	if err != nil {
+		return nil, err
+	}
+	if s.media != nil {
+		_ = s.media.Remove(removed.Path, removed.ThumbPath)
+	}
+	s.publishProductChange(ctx, events.ProductUpdated, product)
+	return s.images(ctx, product.ID)
+}
+
+// galleryProduct loads the product whose gallery is edited.
+func (s *ProductService) galleryProduct(ctx context.Context, productID string) (*domain.Product, error) {
+	if strings.TrimSpace(productID) == "" {
+		return nil, errors.New("product id required")
+	}
+	product, err := s.repo.Get(ctx, productID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrProductNotFound, productID)
+		}
+		return nil, err
+	}
+	return product, nil
+}
+
+// attachImages loads the gallery of each product and of its variants.
+func (s *ProductService) attachImages(ctx context.Context, products []*domain.Product) error {
+	targets := make([]*domain.Product, 0, len(products))
+	for _, p := range products {
+		if p == nil {
+			continue
+		}
+		targets = append(targets, p)
+		for i := range p.Variants {
+			targets = append(targets, &p.Variants[i])
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(targets))
+	for _, p := range targets {
+		ids = append(ids, p.ID)
+	}
+	galleries, err := s.repo.Images(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, p := range targets {
+		p.Images = galleries[p.ID]
+		for i := range p.Images {
+			s.decorateImage(&p.Images[i])
+		}
+	}
+	return nil
+}
+
+func (s *ProductService) decorateImage(img *domain.ProductImage) {
+	if s.media != nil {
+		img.URL = s.media.PublicURL(img.Path)
+		img.ThumbURL = s.media.PublicURL(img.ThumbPath)
+	}
+}
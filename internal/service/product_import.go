@@ -0,0 +1,625 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"smartseller-lite-starter/internal/audit"
+	"smartseller-lite-starter/internal/barcode"
+	"smartseller-lite-starter/internal/domain"
+	"smartseller-lite-starter/internal/sheet"
+)
+
+const (
+	maxImportRows       = 5000
+	maxImportImageBytes = 15 << 20
+	importStockReason   = "import"
+)
+
+// Product sheet columns. Type, Parent SKU and Options describe bundles and variants
+// on export; those rows are skipped on import because they are edited through the
+// product form. Gallery lists the non-primary images, which are exported only.
+var productSheetHeaders = []string{
+	"SKU", "Name", "Barcode", "Category", "Cost Price", "Sale Price", "Stock",
+	"Low Stock Threshold", "Description", "Type", "Parent SKU", "Options", "Archived", "Image", "Gallery",
+}
+
+// productSheetAliases maps normalised header names, English or Indonesian, to fields.
+var productSheetAliases = map[string]string{
+	"sku":                 "sku",
+	"kode":                "sku",
+	"name":                "name",
+	"nama":                "name",
+	"nama produk":         "name",
+	"barcode":             "barcode",
+	"ean":                 "barcode",
+	"upc":                 "barcode",
+	"category":            "category",
+	"kategori":            "category",
+	"cost price":          "cost",
+	"harga modal":         "cost",
+	"sale price":          "price",
+	"harga jual":          "price",
+	"stock":               "stock",
+	"stok":                "stock",
+	"low stock threshold": "threshold",
+	"batas stok":          "threshold",
+	"ambang stok":         "threshold",
+	"description":         "description",
+	"deskripsi":           "description",
+	"type":                "type",
+	"tipe":                "type",
+	"archived":            "archived",
+	"diarsipkan":          "archived",
+}
+
+// productGalleryDir holds the exported gallery images inside the images folder of an
+// export bundle. Import leaves it alone, so gallery files never pose as a SKU's image.
+const productGalleryDir = "gallery/"
+
+var (
+	importImageExts   = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".webp": true, ".gif": true}
+	thousandsGrouping = regexp.MustCompile(`^-?\d{1,3}(\.\d{3})+$`)
+)
+
+// ProductImportInput carries an uploaded CSV, XLSX or ZIP file as base64. A ZIP holds
+// one sheet plus product images named by SKU, e.g. KAOS-01.jpg.
+type ProductImportInput struct {
+	FileName string `json:"fileName"`
+	Data     string `json:"data"`
+	DryRun   bool   `json:"dryRun"`
+}
+
+// ProductImportRow reports what the import did, or would do, with one sheet row.
+type ProductImportRow struct {
+	Row      int      `json:"row"`
+	SKU      string   `json:"sku"`
+	Name     string   `json:"name"`
+	Action   string   `json:"action"`
+	Image    bool     `json:"image"`
+	Errors   []string `json:"errors,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ProductImportResult summarises an import. Nothing is written when DryRun is set or
+// when any row fails validation; Applied tells the two cases apart from a real run.
+type ProductImportResult struct {
+	DryRun  bool               `json:"dryRun"`
+	Applied bool               `json:"applied"`
+	Created int                `json:"created"`
+	Updated int                `json:"updated"`
+	Skipped int                `json:"skipped"`
+	Failed  int                `json:"failed"`
+	Images  int                `json:"images"`
+	Rows    []ProductImportRow `json:"rows"`
+}
+
+type productImportPlan struct {
+	row      *ProductImportRow
+	product  domain.Product
+	existing *domain.Product
+	stock    *int
+	image    []byte
+}
+
+// ExportProducts renders the products matching the archive filter, variants included,
+// as a sheet. With images the sheet is zipped together with the product images named by SKU,
+// the same layout ImportProducts accepts, and the rest of each gallery under
+// images/gallery/.
+func (s *ProductService) ExportProducts(ctx context.Context, format sheet.Format, withImages bool, archived ArchiveFilter) ([]byte, error) {
+	items, err := s.ListByArchive(ctx, archived)
+	if err != nil {
+		return nil, err
+	}
+
+	categories, err := s.categories.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Categories are exported as full paths so a re-import rebuilds the hierarchy.
+	categoryPaths := make(map[string]string, len(categories))
+	for _, c := range categories {
+		categoryPaths[c.ID] = c.Path
+	}
+
+	rows := [][]any{stringCells(productSheetHeaders)}
+	type imageFile struct{ name, path string }
+	images := make([]imageFile, 0)
+	addRow := func(p domain.Product, kind, parentSKU string) {
+		options := make([]string, 0, len(p.Options))
+		for _, opt := range p.Options {
+			options = append(options, opt.Axis+": "+opt.Value)
+		}
+		archived := ""
+		if p.DeletedAt != nil {
+			archived = "yes"
+		}
+		image := ""
+		if p.ImagePath != "" && p.SKU != "" {
+			image = p.SKU + path.Ext(p.ImagePath)
+			images = append(images, imageFile{name: image, path: p.ImagePath})
+		}
+		gallery := make([]string, 0, len(p.Images))
+		for _, img := range p.Images {
+			if img.IsPrimary || img.Path == p.ImagePath || p.SKU == "" {
+				continue
+			}
+			name := fmt.Sprintf("%s%s-%d%s", productGalleryDir, p.SKU, len(gallery)+2, path.Ext(img.Path))
+			gallery = append(gallery, name)
+			images = append(images, imageFile{name: name, path: img.Path})
+		}
+		category := p.Category
+		if path, ok := categoryPaths[p.CategoryID]; ok {
+			category = path
+		}
+		rows = append(rows, []any{
+			p.SKU, p.Name, p.Barcode, category, p.CostPrice, p.SalePrice, p.Stock,
+			p.LowStockThreshold, p.Description, kind, parentSKU, strings.Join(options, " / "), archived, image,
+			strings.Join(gallery, " | "),
+		})
+	}
+	for _, p := range items {
+		switch {
+		case p.IsBundle:
+			addRow(p, "bundle", "")
+		case p.HasVariants():
+			addRow(p, "parent", "")
+			for _, v := range p.Variants {
+				addRow(v, "variant", p.SKU)
+			}
+		default:
+			addRow(p, "product", "")
+		}
+	}
+
+	data, err := sheet.Write(format, rows)
+	if err != nil {
+		return nil, err
+	}
+	if !withImages {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	w, err := archive.Create("products." + string(format))
+	if err != nil {
+		return nil, fmt.Errorf("write export archive: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("write export archive: %w", err)
+	}
+	if s.media != nil {
+		for _, img := range images {
+			raw, err := s.media.Read(img.path)
+			if err != nil {
+				continue
+			}
+			w, err := archive.Create("images/" + img.name)
+			if err != nil {
+				return nil, fmt.Errorf("write export archive: %w", err)
+			}
+			if _, err := w.Write(raw); err != nil {
+				return nil, fmt.Errorf("write export archive: %w", err)
+			}
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("close export archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ImportProducts validates an uploaded sheet and upserts products by SKU. Existing
+// products keep any column that is missing or left blank; stock differences are
+// posted as stock mutations with reason "import" so the ledger stays complete.
+func (s *ProductService) ImportProducts(ctx context.Context, input ProductImportInput) (ProductImportResult, error) {
+	ctx = audit.WithSource(ctx, "import")
+	result := ProductImportResult{DryRun: input.DryRun, Rows: []ProductImportRow{}}
+	raw, err := decodeImportPayload(input.Data)
+	if err != nil {
+		return result, err
+	}
+	sheetName, sheetData, images, err := unpackImport(input.FileName, raw)
+	if err != nil {
+		return result, err
+	}
+	rows, err := sheet.Read(sheet.DetectFormat(sheetName, sheetData), sheetData)
+	if err != nil {
+		return result, err
+	}
+	if len(rows) < 2 {
+		return result, errors.New("file tidak berisi baris produk")
+	}
+	if len(rows)-1 > maxImportRows {
+		return result, fmt.Errorf("maksimal %d baris per impor", maxImportRows)
+	}
+	columns := make(map[string]int)
+	for i, header := range rows[0] {
+		key := strings.Join(strings.Fields(strings.NewReplacer("_", " ", "-", " ").Replace(strings.ToLower(header))), " ")
+		if field, ok := productSheetAliases[key]; ok {
+			if _, dup := columns[field]; !dup {
+				columns[field] = i
+			}
+		}
+	}
+	if _, ok := columns["sku"]; !ok {
+		return result, errors.New("kolom SKU wajib ada")
+	}
+
+	existing, err := s.productsBySKU(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	reported := make([]*ProductImportRow, 0, len(rows)-1)
+	plans := make([]*productImportPlan, 0, len(rows)-1)
+	seenSKU := make(map[string]int)
+	seenBarcode := make(map[string]int)
+	for i, cells := range rows[1:] {
+		rowNum := i + 2
+		cell := func(field string) (string, bool) {
+			idx, ok := columns[field]
+			if !ok || idx >= len(cells) {
+				return "", false
+			}
+			return cells[idx], cells[idx] != ""
+		}
+		sku, _ := cell("sku")
+		name, _ := cell("name")
+		if sku == "" && name == "" {
+			continue
+		}
+		row := &ProductImportRow{Row: rowNum, SKU: sku, Name: name}
+		reported = append(reported, row)
+		fail := func(format string, args ...any) {
+			row.Errors = append(row.Errors, fmt.Sprintf(format, args...))
+		}
+
+		kind, _ := cell("type")
+		kind = strings.ToLower(kind)
+		archivedRaw, _ := cell("archived")
+		if kind == "bundle" || kind == "parent" || kind == "variant" {
+			row.Action = "skip"
+			row.Warnings = append(row.Warnings, "bundle dan varian diubah melalui form produk")
+			continue
+		}
+		if parseSheetBool(archivedRaw) {
+			row.Action = "skip"
+			row.Warnings = append(row.Warnings, "produk diarsipkan dilewati")
+			continue
+		}
+
+		if sku == "" {
+			fail("SKU wajib diisi")
+		} else if prev, ok := seenSKU[strings.ToLower(sku)]; ok {
+			fail("SKU sama dengan baris %d", prev)
+		} else {
+			seenSKU[strings.ToLower(sku)] = rowNum
+		}
+
+		plan := &productImportPlan{row: row}
+		if current := existing[strings.ToLower(sku)]; sku != "" && current != nil {
+			switch {
+			case current.IsBundle || current.HasVariants() || current.ParentID != "":
+				fail("SKU %s milik bundle atau varian; ubah melalui form produk", sku)
+			case current.DeletedAt != nil:
+				fail("produk dengan SKU %s diarsipkan", sku)
+			}
+			plan.existing = current
+			plan.product = *current
+			row.Action = "update"
+			if name == "" {
+				row.Name = current.Name
+			}
+		} else {
+			plan.product = domain.Product{SKU: sku}
+			row.Action = "create"
+			if name == "" {
+				fail("nama produk wajib diisi")
+			}
+		}
+		p := &plan.product
+		if name != "" {
+			p.Name = name
+		}
+		if v, ok := cell("category"); ok {
+			p.Category = v
+		}
+		if v, ok := cell("description"); ok {
+			p.Description = v
+		}
+		if v, ok := cell("barcode"); ok {
+			code, err := barcode.NormaliseGTIN(v)
+			if err != nil {
+				fail("%v", err)
+			} else if prev, dup := seenBarcode[code]; dup {
+				fail("barcode sama dengan baris %d", prev)
+			} else {
+				seenBarcode[code] = rowNum
+				excludeID := ""
+				if plan.existing != nil {
+					excludeID = plan.existing.ID
+				}
+				taken, err := s.repo.CodeInUse(ctx, "barcode", code, excludeID)
+				if err != nil {
+					return result, err
+				}
+				if taken {
+					fail("barcode %s sudah digunakan produk lain", code)
+				}
+				p.Barcode = code
+			}
+		}
+		for _, field := range []struct {
+			key   string
+			label string
+			dest  *float64
+		}{{"cost", "harga modal", &p.CostPrice}, {"price", "harga jual", &p.SalePrice}} {
+			if v, ok := cell(field.key); ok {
+				value, err := parseSheetNumber(v)
+				if err != nil || value < 0 {
+					fail("%s tidak valid: %s", field.label, v)
+					continue
+				}
+				*field.dest = value
+			}
+		}
+		if v, ok := cell("threshold"); ok {
+			value, err := parseSheetInt(v)
+			if err != nil || value < 0 {
+				fail("batas stok tidak valid: %s", v)
+			} else {
+				p.LowStockThreshold = value
+			}
+		}
+		if v, ok := cell("stock"); ok {
+			value, err := parseSheetInt(v)
+			if err != nil || value < 0 {
+				fail("stok tidak valid: %s", v)
+			} else {
+				plan.stock = &value
+			}
+		}
+		if img, ok := images[strings.ToLower(sku)]; ok && sku != "" {
+			data, err := readZipFile(img, maxImportImageBytes)
+			if err != nil {
+				fail("gambar %s: %v", img.Name, err)
+			} else if plan.existing != nil && hasImage(plan.existing, data) {
+				// The file the export wrote comes back on every round trip; adding it
+				// again would fill the gallery with copies.
+				row.Warnings = append(row.Warnings, "gambar sudah ada di galeri, dilewati")
+			} else {
+				plan.image = data
+				row.Image = true
+			}
+		}
+		if plan.existing != nil && plan.stock != nil && *plan.stock != plan.existing.Stock {
+			row.Warnings = append(row.Warnings, fmt.Sprintf("stok %d → %d", plan.existing.Stock, *plan.stock))
+		}
+		plans = append(plans, plan)
+	}
+
+	collect := func() ProductImportResult {
+		for _, row := range reported {
+			result.Rows = append(result.Rows, *row)
+		}
+		return result
+	}
+	for _, row := range reported {
+		switch {
+		case len(row.Errors) > 0:
+			result.Failed++
+		case row.Action == "skip":
+			result.Skipped++
+		case row.Action == "create":
+			result.Created++
+		default:
+			result.Updated++
+		}
+		if row.Image && len(row.Errors) == 0 {
+			result.Images++
+		}
+	}
+	if input.DryRun || result.Failed > 0 {
+		return collect(), nil
+	}
+
+	result.Created, result.Updated, result.Images = 0, 0, 0
+	for _, plan := range plans {
+		if err := s.applyImportPlan(ctx, plan); err != nil {
+			plan.row.Errors = append(plan.row.Errors, err.Error())
+			result.Failed++
+			continue
+		}
+		if plan.existing == nil {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+		if plan.image != nil {
+			result.Images++
+		}
+	}
+	result.Applied = true
+	return collect(), nil
+}
+
+func (s *ProductService) applyImportPlan(ctx context.Context, plan *productImportPlan) error {
+	p := plan.product
+	p.Variants = nil
+	p.Components = nil
+	if plan.image != nil {
+		p.ImageData = base64.StdEncoding.EncodeToString(plan.image)
+	}
+	// Stock never rides on the product row: the save reads it under lock and posts only
+	// the difference from the sheet, so sales since the preview are kept.
+	var err error
+	if plan.existing == nil {
+		_, err = s.create(ctx, p, plan.stock, importStockReason)
+	} else {
+		_, err = s.update(ctx, p, plan.stock, importStockReason)
+	}
+	return err
+}
+
+// hasImage reports whether data is byte for byte an image already in the product's
+// gallery; saved images are hashed on their stored bytes, which is what an export writes.
+func hasImage(p *domain.Product, data []byte) bool {
+	hash := fmt.Sprintf("%x", sha256.Sum256(data))
+	if hash == p.ImageHash {
+		return true
+	}
+	for _, img := range p.Images {
+		if img.Hash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// productsBySKU indexes every product and variant, archived ones included, by lower-cased SKU.
+func (s *ProductService) productsBySKU(ctx context.Context) (map[string]*domain.Product, error) {
+	items, err := s.ListIncludingArchived(ctx)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]*domain.Product)
+	add := func(p *domain.Product) {
+		if p.SKU != "" {
+			index[strings.ToLower(p.SKU)] = p
+		}
+	}
+	for i := range items {
+		add(&items[i])
+		for j := range items[i].Variants {
+			add(&items[i].Variants[j])
+		}
+	}
+	return index, nil
+}
+
+func decodeImportPayload(payload string) ([]byte, error) {
+	data := strings.TrimSpace(payload)
+	if idx := strings.Index(data, ";base64,"); strings.HasPrefix(data, "data:") && idx != -1 {
+		data = data[idx+8:]
+	}
+	if data == "" {
+		return nil, errors.New("file impor wajib diisi")
+	}
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode import file: %w", err)
+	}
+	return raw, nil
+}
+
+// unpackImport returns the sheet to read and, for ZIP bundles, the images keyed by
+// lower-cased file name without extension. An XLSX file is itself a ZIP, so a ZIP is
+// only treated as a bundle when it has no workbook part.
+func unpackImport(name string, raw []byte) (string, []byte, map[string]*zip.File, error) {
+	images := make(map[string]*zip.File)
+	if !bytes.HasPrefix(raw, []byte("PK")) || strings.EqualFold(path.Ext(name), ".xlsx") {
+		return name, raw, images, nil
+	}
+	archive, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("buka zip: %w", err)
+	}
+	var sheetFile *zip.File
+	for _, f := range archive.File {
+		if f.Name == "xl/workbook.xml" {
+			return name, raw, images, nil
+		}
+		if f.FileInfo().IsDir() || strings.HasPrefix(f.Name, "__MACOSX/") || strings.HasPrefix(path.Base(f.Name), ".") {
+			continue
+		}
+		if strings.Contains("/"+f.Name, "/"+productGalleryDir) {
+			continue
+		}
+		ext := strings.ToLower(path.Ext(f.Name))
+		switch {
+		case ext == ".csv" || ext == ".xlsx":
+			if sheetFile == nil {
+				sheetFile = f
+			}
+		case importImageExts[ext]:
+			images[strings.ToLower(strings.TrimSuffix(path.Base(f.Name), path.Ext(f.Name)))] = f
+		}
+	}
+	if sheetFile == nil {
+		return "", nil, nil, errors.New("zip harus berisi satu file .csv atau .xlsx")
+	}
+	data, err := readZipFile(sheetFile, 64<<20)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return sheetFile.Name, data, images, nil
+}
+
+func readZipFile(f *zip.File, limit int64) ([]byte, error) {
+	if int64(f.UncompressedSize64) > limit {
+		return nil, fmt.Errorf("%s melebihi batas ukuran", f.Name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, limit))
+}
+
+// parseSheetNumber accepts plain numbers as well as rupiah-formatted text such as
+// "Rp 12.500" or "12.500,50".
+func parseSheetNumber(raw string) (float64, error) {
+	value := strings.TrimSpace(raw)
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "Rp"), "rp")
+	value = strings.ReplaceAll(value, " ", "")
+	switch {
+	case strings.Contains(value, ",") && strings.Contains(value, "."):
+		value = strings.ReplaceAll(value, ".", "")
+		value = strings.ReplaceAll(value, ",", ".")
+	case strings.Contains(value, ","):
+		value = strings.ReplaceAll(value, ",", ".")
+	case thousandsGrouping.MatchString(value):
+		value = strings.ReplaceAll(value, ".", "")
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+func parseSheetInt(raw string) (int, error) {
+	value, err := parseSheetNumber(raw)
+	if err != nil {
+		return 0, err
+	}
+	if value != float64(int(value)) {
+		return 0, fmt.Errorf("bukan bilangan bulat")
+	}
+	return int(value), nil
+}
+
+func parseSheetBool(raw string) bool {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "yes", "y", "ya", "true", "1", "x":
+		return true
+	}
+	return false
+}
+
+func stringCells(values []string) []any {
+	cells := make([]any, len(values))
+	for i, v := range values {
+		cells[i] = v
+	}
+	return cells
+}
@@ -0,0 +1,73 @@
+package service
+
+import "testing"
+
+func TestParseSheetNumber(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    float64
+		wantErr bool
+	}{
+		{raw: "12500", want: 12500},
+		{raw: " 12500 ", want: 12500},
+		{raw: "12.5", want: 12.5},
+		{raw: "1,25", want: 1.25},
+		{raw: "1,250", want: 1.25},
+		{raw: "12.500", want: 12500},
+		{raw: "1.234.567", want: 1234567},
+		{raw: "-12.500", want: -12500},
+		{raw: "12.500,50", want: 12500.5},
+		{raw: "1.234.567,8", want: 1234567.8},
+		{raw: "Rp 12.500", want: 12500},
+		{raw: "Rp12.500,00", want: 12500},
+		{raw: "rp 7.500", want: 7500},
+		{raw: "1 250", want: 1250},
+		{raw: "12.50", want: 12.5},
+		{raw: "1234.500", want: 1234.5},
+		{raw: "", wantErr: true},
+		{raw: "dua belas", wantErr: true},
+		{raw: "1,2,3", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSheetNumber(tt.raw)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseSheetNumber(%q) = %v, want error", tt.raw, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseSheetNumber(%q) = %v, %v, want %v", tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseSheetInt(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    int
+		wantErr bool
+	}{
+		{raw: "24", want: 24},
+		{raw: "12.500", want: 12500},
+		{raw: "1.000,00", want: 1000},
+		{raw: "-3", want: -3},
+		{raw: "7.0", want: 7},
+		{raw: "1,5", wantErr: true},
+		{raw: "1,250", wantErr: true},
+		{raw: "2.5", wantErr: true},
+		{raw: "banyak", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSheetInt(tt.raw)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseSheetInt(%q) = %d, want error", tt.raw, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseSheetInt(%q) = %d, %v, want %d", tt.raw, got, err, tt.want)
+		}
+	}
+}
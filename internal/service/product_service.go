@@ -2,24 +2,86 @@ package service
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 	"strings"
+	"time"
 
+	"smartseller-lite-starter/internal/barcode"
 	"smartseller-lite-starter/internal/domain"
+	"smartseller-lite-starter/internal/events"
 	"smartseller-lite-starter/internal/media"
 	"smartseller-lite-starter/internal/repo"
 )
 
 // ProductService encapsulates business rules for products and stock.
 type ProductService struct {
-	repo  *repo.ProductRepository
-	media *media.Manager
+	repo       *repo.ProductRepository
+	categories *repo.CategoryRepository
+	media      *media.Manager
+	events     *events.Bus
 }
 
+// ProductListOptions filters the product listing. Category takes a category id or
+// slug and includes its subcategories; "none" lists uncategorised products.
 type ProductListOptions struct {
-	Query    string `json:"query"`
-	Page     int    `json:"page"`
-	PageSize int    `json:"pageSize"`
+	Query    string        `json:"query"`
+	Category string        `json:"category"`
+	Archived ArchiveFilter `json:"archived"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"pageSize"`
+}
+
+// ArchiveFilter selects products by archived state.
+type ArchiveFilter string
+
+const (
+	// ArchivedExclude lists active products only.
+	ArchivedExclude ArchiveFilter = "exclude"
+	// ArchivedInclude lists active and archived products.
+	ArchivedInclude ArchiveFilter = "include"
+	// ArchivedOnly lists archived products, and parents with archived variants showing
+	// just those variants.
+	ArchivedOnly ArchiveFilter = "only"
+)
+
+// ParseArchiveFilter maps user input to an ArchiveFilter; empty input excludes
+// archived products.
+func ParseArchiveFilter(raw string) (ArchiveFilter, error) {
+	switch ArchiveFilter(strings.ToLower(strings.TrimSpace(raw))) {
+	case "", ArchivedExclude:
+		return ArchivedExclude, nil
+	case ArchivedInclude:
+		return ArchivedInclude, nil
+	case ArchivedOnly:
+		return ArchivedOnly, nil
+	default:
+		return "", errors.New("archived harus exclude, include, atau only")
+	}
+}
+
+func (f ArchiveFilter) listOptions(opts repo.ProductListOptions) repo.ProductListOptions {
+	opts.IncludeArchived = f == ArchivedInclude || f == ArchivedOnly
+	opts.ArchivedOnly = f == ArchivedOnly
+	return opts
+}
+
+// keepArchivedVariants drops the active variants of the listed parents, so an
+// archived-only listing shows just what can be restored.
+func keepArchivedVariants(items []domain.Product) {
+	for i := range items {
+		if len(items[i].Variants) == 0 {
+			continue
+		}
+		kept := items[i].Variants[:0]
+		for _, v := range items[i].Variants {
+			if v.DeletedAt != nil {
+				kept = append(kept, v)
+			}
+		}
+		items[i].Variants = kept
+	}
 }
 
 type ProductListResult struct {
@@ -30,36 +92,48 @@ type ProductListResult struct {
 	OutOfStockCount    int              `json:"outOfStockCount"`
 	WarningStockCount  int              `json:"warningStockCount"`
 	LowStockHighlights []domain.Product `json:"lowStockHighlights"`
+	// ExpiryWarnings lists up to five lots expiring within repo.ExpiryWarningDays, or
+	// already expired, out of ExpiringLotCount.
+	ExpiringLotCount int                  `json:"expiringLotCount"`
+	ExpiryWarnings   []domain.ExpiringLot `json:"expiryWarnings"`
 }
 
-func NewProductService(repo *repo.ProductRepository, mediaManager *media.Manager) *ProductService {
-	return &ProductService{repo: repo, media: mediaManager}
+func NewProductService(repo *repo.ProductRepository, categories *repo.CategoryRepository, mediaManager *media.Manager, bus *events.Bus) *ProductService {
+	return &ProductService{repo: repo, categories: categories, media: mediaManager, events: bus}
 }
 
 func (s *ProductService) Warm(ctx context.Context) {
 	_, _ = s.repo.List(ctx)
 }
 
-func (s *ProductService) upsert(ctx context.Context, existing *domain.Product, p domain.Product) (*domain.Product, error) {
+// upsert saves a product and brings it to stock, posted as a mutation of reason; a
+// nil stock leaves the stock as it is.
+func (s *ProductService) upsert(ctx context.Context, existing *domain.Product, p domain.Product, stock *int, reason string) (*domain.Product, error) {
 	if strings.TrimSpace(p.Name) == "" {
 		return nil, errors.New("product name is required")
 	}
-	// if strings.TrimSpace(p.SKU) == "" {
-	// 	return nil, errors.New("product SKU is required")
-	// }
-	p.Category = strings.TrimSpace(p.Category)
+	if stock != nil && *stock < 0 {
+		return nil, errors.New("stok tidak boleh negatif")
+	}
+	if err := s.checkCodes(ctx, &p); err != nil {
+		return nil, err
+	}
+	if err := s.assignCategory(ctx, &p); err != nil {
+		return nil, err
+	}
 	if p.LowStockThreshold <= 0 {
 		p.LowStockThreshold = 5
 	}
+	// An uploaded image joins the gallery as its primary image, in the transaction that
+	// saves the product; earlier images stay and a full gallery refuses the upload.
 	p.ImageData = strings.TrimSpace(p.ImageData)
+	var upload *media.Asset
 	if p.ImageData != "" && s.media != nil {
 		asset, err := s.media.SaveProductImage(ctx, p.ImageData)
 		if err != nil {
 			return nil, err
 		}
-		if existing != nil {
-			_ = s.media.Remove(existing.ImagePath, existing.ThumbPath)
-		}
+		upload = asset
 		p.ImagePath = asset.Path
 		p.ThumbPath = asset.ThumbPath
 		p.ImageHash = asset.Hash
@@ -81,23 +155,360 @@ func (s *ProductService) upsert(ctx context.Context, existing *domain.Product, p
 		p.ThumbSizeBytes = existing.ThumbSizeBytes
 	}
 	p.ImageData = ""
-	if p.ID == "" {
-		return s.repo.Create(ctx, &p)
+
+	if existing != nil && existing.ParentID != "" {
+		return nil, errors.New("varian diubah melalui produk induknya")
+	}
+	p.ParentID = ""
+	p.Options = nil
+	variants := p.Variants
+	p.Variants = nil
+	p.OptionAxes = normaliseOptionAxes(p.OptionAxes)
+	if p.HasVariants() {
+		if err := s.validateVariantParent(ctx, existing, p, variants); err != nil {
+			return nil, err
+		}
+		p.Stock = 0
+	} else if existing != nil && existing.HasVariants() {
+		return nil, errors.New("produk bervarian tidak bisa diubah menjadi produk tunggal")
+	}
+
+	var components []domain.BundleComponent
+	if p.IsBundle {
+		var err error
+		if components, err = s.validateBundle(ctx, existing, p); err != nil {
+			return nil, err
+		}
+		p.Stock = 0
+		p.CostPrice = 0
+	}
+
+	var image *domain.ProductImage
+	if upload != nil {
+		image = galleryImage(p.ID, upload, "", true)
+	}
+	// The stock is posted as a mutation of the difference from what the product holds,
+	// opening stock included, so the ledger explains every unit.
+	saved, adj, err := s.repo.Save(ctx, &p, repo.ProductSave{Stock: stock, StockReason: reason, Image: image, ImageLimit: maxProductImages})
+	if err != nil {
+		if upload != nil {
+			_ = s.media.Remove(upload.Path, upload.ThumbPath)
+		}
+		return nil, err
+	}
+	s.publishStockChange(ctx, adj)
+	if p.IsBundle || (existing != nil && existing.IsBundle) {
+		if err := s.repo.SetBundleComponents(ctx, saved.ID, components); err != nil {
+			return nil, err
+		}
+	}
+	if p.HasVariants() {
+		if err := s.syncVariants(ctx, existing, saved, variants); err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.Get(ctx, saved.ID)
+}
+
+// assignCategory links the product to a category row. A category id wins unless the
+// submitted category text names a different category, in which case the text is
+// resolved (and created if needed) so free-text clients keep working.
+func (s *ProductService) assignCategory(ctx context.Context, p *domain.Product) error {
+	p.Category = strings.TrimSpace(p.Category)
+	p.CategoryID = strings.TrimSpace(p.CategoryID)
+	if p.CategoryID != "" {
+		category, err := s.categories.Get(ctx, p.CategoryID)
+		if err != nil {
+			return ErrCategoryNotFound
+		}
+		if p.Category == "" || strings.EqualFold(p.Category, category.Name) || strings.EqualFold(p.Category, category.Path) {
+			p.Category = category.Name
+			return nil
+		}
+	}
+	if p.Category == "" {
+		p.CategoryID = ""
+		return nil
+	}
+	category, err := s.categories.EnsurePath(ctx, repo.SplitCategoryPath(p.Category))
+	if err != nil {
+		return err
 	}
-	return s.repo.Update(ctx, &p)
+	p.CategoryID = category.ID
+	p.Category = category.Name
+	return nil
+}
+
+// checkCodes trims the SKU, normalises the barcode to 13 digits and makes sure
+// neither is used by another product.
+func (s *ProductService) checkCodes(ctx context.Context, p *domain.Product) error {
+	p.SKU = strings.TrimSpace(p.SKU)
+	if p.SKU != "" {
+		taken, err := s.repo.CodeInUse(ctx, "sku", p.SKU, p.ID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return fmt.Errorf("SKU %s sudah digunakan produk lain", p.SKU)
+		}
+	}
+	p.Barcode = strings.TrimSpace(p.Barcode)
+	if p.Barcode != "" {
+		normalised, err := barcode.NormaliseGTIN(p.Barcode)
+		if err != nil {
+			return err
+		}
+		p.Barcode = normalised
+		taken, err := s.repo.CodeInUse(ctx, "barcode", p.Barcode, p.ID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return fmt.Errorf("barcode %s sudah digunakan produk lain", p.Barcode)
+		}
+	}
+	return nil
+}
+
+func (s *ProductService) validateVariantParent(ctx context.Context, existing *domain.Product, p domain.Product, variants []domain.Product) error {
+	if p.IsBundle {
+		return errors.New("bundle tidak dapat memiliki varian")
+	}
+	if len(variants) == 0 {
+		return errors.New("produk bervarian membutuhkan minimal satu varian")
+	}
+	if existing != nil && !existing.HasVariants() {
+		if existing.Stock > 0 {
+			return errors.New("kosongkan stok produk sebelum menambahkan varian")
+		}
+		used, err := s.repo.CountBundlesUsing(ctx, existing.ID)
+		if err != nil {
+			return err
+		}
+		if used > 0 {
+			return errors.New("produk ini adalah komponen bundle dan tidak bisa diberi varian")
+		}
+	}
+	return nil
+}
+
+// syncVariants creates and updates the variant rows of a parent from the submitted list
+// and archives the variants that were left out, all in one transaction. Stock changes
+// are posted to the ledger. Variants without their own price, or still carrying the
+// parent's previous price, follow the parent price.
+func (s *ProductService) syncVariants(ctx context.Context, previous *domain.Product, parent *domain.Product, inputs []domain.Product) error {
+	current, err := s.repo.Variants(ctx, []string{parent.ID}, false)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool)
+	for _, v := range current[parent.ID] {
+		known[v.ID] = true
+	}
+
+	combos := make(map[string]bool)
+	codes := make(map[string]bool)
+	kept := make(map[string]bool)
+	variants := make([]domain.Product, 0, len(inputs))
+	for _, input := range inputs {
+		options, err := variantOptions(parent.OptionAxes, input.Options)
+		if err != nil {
+			return err
+		}
+		label := variantLabel(options)
+		key := strings.ToLower(label)
+		if combos[key] {
+			return fmt.Errorf("kombinasi varian %s ganda", label)
+		}
+		combos[key] = true
+		if input.ID != "" && !known[input.ID] {
+			return fmt.Errorf("varian %s tidak ditemukan pada produk ini", input.ID)
+		}
+		if input.Stock < 0 {
+			return fmt.Errorf("stok varian %s tidak boleh negatif", label)
+		}
+
+		variant := domain.Product{
+			ID:                input.ID,
+			Name:              fmt.Sprintf("%s (%s)", parent.Name, label),
+			SKU:               strings.TrimSpace(input.SKU),
+			CostPrice:         inheritPrice(input.CostPrice, parent.CostPrice, previous, func(p *domain.Product) float64 { return p.CostPrice }),
+			SalePrice:         inheritPrice(input.SalePrice, parent.SalePrice, previous, func(p *domain.Product) float64 { return p.SalePrice }),
+			Stock:             input.Stock,
+			Category:          parent.Category,
+			CategoryID:        parent.CategoryID,
+			LowStockThreshold: input.LowStockThreshold,
+			Description:       parent.Description,
+			ParentID:          parent.ID,
+			Options:           options,
+		}
+		if variant.LowStockThreshold <= 0 {
+			variant.LowStockThreshold = parent.LowStockThreshold
+		}
+		variant.Barcode = input.Barcode
+		if err := s.checkCodes(ctx, &variant); err != nil {
+			return err
+		}
+		for _, code := range []string{variant.SKU, variant.Barcode} {
+			if code == "" {
+				continue
+			}
+			if codes[code] {
+				return fmt.Errorf("kode %s dipakai lebih dari satu varian", code)
+			}
+			codes[code] = true
+		}
+		if variant.ID != "" {
+			kept[variant.ID] = true
+		}
+		variants = append(variants, variant)
+	}
+
+	archive := make([]string, 0)
+	for id := range known {
+		if !kept[id] {
+			archive = append(archive, id)
+		}
+	}
+	adjustments, err := s.repo.SaveVariants(ctx, variants, archive)
+	if err != nil {
+		return err
+	}
+	for _, adj := range adjustments {
+		s.publishStockChange(ctx, adj)
+	}
+	return nil
+}
+
+func inheritPrice(value, parentValue float64, previous *domain.Product, field func(*domain.Product) float64) float64 {
+	if value <= 0 {
+		return parentValue
+	}
+	if previous != nil && previous.HasVariants() && value == field(previous) {
+		return parentValue
+	}
+	return value
+}
+
+func normaliseOptionAxes(axes []string) []string {
+	result := make([]string, 0, len(axes))
+	seen := make(map[string]bool)
+	for _, axis := range axes {
+		axis = strings.TrimSpace(axis)
+		if axis == "" || seen[strings.ToLower(axis)] {
+			continue
+		}
+		seen[strings.ToLower(axis)] = true
+		result = append(result, axis)
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// variantOptions orders a variant's options by the parent's axes and requires a value for each.
+func variantOptions(axes []string, input []domain.VariantOption) ([]domain.VariantOption, error) {
+	values := make(map[string]string)
+	for _, opt := range input {
+		values[strings.ToLower(strings.TrimSpace(opt.Axis))] = strings.TrimSpace(opt.Value)
+	}
+	options := make([]domain.VariantOption, 0, len(axes))
+	for _, axis := range axes {
+		value := values[strings.ToLower(axis)]
+		if value == "" {
+			return nil, fmt.Errorf("nilai %s wajib diisi untuk setiap varian", axis)
+		}
+		options = append(options, domain.VariantOption{Axis: axis, Value: value})
+	}
+	return options, nil
+}
+
+func variantLabel(options []domain.VariantOption) string {
+	values := make([]string, 0, len(options))
+	for _, opt := range options {
+		values = append(values, opt.Value)
+	}
+	return strings.Join(values, " / ")
+}
+
+// validateBundle checks the component list of a bundle and merges duplicate entries.
+func (s *ProductService) validateBundle(ctx context.Context, existing *domain.Product, p domain.Product) ([]domain.BundleComponent, error) {
+	if existing != nil && !existing.IsBundle {
+		if existing.Stock > 0 {
+			return nil, errors.New("kosongkan stok produk sebelum dijadikan bundle")
+		}
+		used, err := s.repo.CountBundlesUsing(ctx, existing.ID)
+		if err != nil {
+			return nil, err
+		}
+		if used > 0 {
+			return nil, errors.New("produk ini adalah komponen bundle lain dan tidak bisa dijadikan bundle")
+		}
+	}
+	if len(p.Components) == 0 {
+		return nil, errors.New("bundle requires at least one component")
+	}
+
+	merged := make([]domain.BundleComponent, 0, len(p.Components))
+	index := make(map[string]int)
+	for _, c := range p.Components {
+		c.ProductID = strings.TrimSpace(c.ProductID)
+		if c.ProductID == "" {
+			return nil, errors.New("bundle component missing product")
+		}
+		if c.Quantity <= 0 {
+			return nil, fmt.Errorf("invalid quantity for bundle component %s", c.ProductID)
+		}
+		if p.ID != "" && c.ProductID == p.ID {
+			return nil, errors.New("bundle tidak boleh berisi dirinya sendiri")
+		}
+		if i, ok := index[c.ProductID]; ok {
+			merged[i].Quantity += c.Quantity
+			continue
+		}
+		component, err := s.repo.Get(ctx, c.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if component.IsBundle {
+			return nil, fmt.Errorf("bundle %s tidak boleh menjadi komponen bundle lain", component.Name)
+		}
+		if component.HasVariants() {
+			return nil, fmt.Errorf("pilih varian %s sebagai komponen bundle", component.Name)
+		}
+		if component.DeletedAt != nil {
+			return nil, fmt.Errorf("produk %s sudah diarsipkan", component.Name)
+		}
+		index[c.ProductID] = len(merged)
+		merged = append(merged, domain.BundleComponent{ProductID: component.ID, Name: component.Name, SKU: component.SKU, Quantity: c.Quantity})
+	}
+	return merged, nil
 }
 
 func (s *ProductService) Create(ctx context.Context, p domain.Product) (*domain.Product, error) {
+	return s.create(ctx, p, &p.Stock, repo.FormStockReason)
+}
+
+func (s *ProductService) create(ctx context.Context, p domain.Product, stock *int, reason string) (*domain.Product, error) {
 	p.ID = ""
-	created, err := s.upsert(ctx, nil, p)
+	created, err := s.upsert(ctx, nil, p, stock, reason)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.hydrate(ctx, []*domain.Product{created}, false); err != nil {
+		return nil, err
+	}
 	s.decorate(created)
+	s.publishProductChange(ctx, events.ProductCreated, created)
 	return created, nil
 }
 
 func (s *ProductService) Update(ctx context.Context, p domain.Product) (*domain.Product, error) {
+	return s.update(ctx, p, &p.Stock, repo.FormStockReason)
+}
+
+func (s *ProductService) update(ctx context.Context, p domain.Product, stock *int, reason string) (*domain.Product, error) {
 	if p.ID == "" {
 		return nil, errors.New("product ID is required for update")
 	}
@@ -109,25 +520,75 @@ func (s *ProductService) Update(ctx context.Context, p domain.Product) (*domain.
 			return nil, err
 		}
 	}
-	updated, err := s.upsert(ctx, existing, p)
+	updated, err := s.upsert(ctx, existing, p, stock, reason)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.hydrate(ctx, []*domain.Product{updated}, false); err != nil {
+		return nil, err
+	}
 	s.decorate(updated)
+	s.publishProductChange(ctx, events.ProductUpdated, updated)
 	return updated, nil
 }
 
-func (s *ProductService) AdjustStock(ctx context.Context, productID string, delta int, reason string) error {
-	if productID == "" {
-		return errors.New("productID required")
+// publishProductChange emits a product.* event so open clients refresh the product.
+func (s *ProductService) publishProductChange(ctx context.Context, eventType events.Type, p *domain.Product) {
+	if p == nil {
+		return
 	}
-	if delta == 0 {
-		return nil
+	s.events.Publish(ctx, eventType, events.ProductChangedData{ProductID: p.ID, Name: p.Name, SKU: p.SKU})
+}
+
+// ExpiredStock returns, per product, the units left in expired lots.
+func (s *ProductService) ExpiredStock(ctx context.Context, productIDs []string) (map[string]int, error) {
+	return s.repo.ExpiredStock(ctx, productIDs)
+}
+
+// MoveStock applies a stock change and returns it together with the cost of the units
+// moved, which orders use as their cost of goods sold.
+func (s *ProductService) MoveStock(ctx context.Context, move repo.StockMove) (*repo.StockAdjustment, error) {
+	if move.ProductID == "" {
+		return nil, errors.New("productID required")
+	}
+	if move.Delta == 0 {
+		return &repo.StockAdjustment{ProductID: move.ProductID}, nil
+	}
+	if move.Reason == "" {
+		move.Reason = "manual"
+	}
+	adj, err := s.repo.MoveStock(ctx, move)
+	if err != nil {
+		return nil, err
 	}
-	if reason == "" {
-		reason = "manual"
+	s.publishStockChange(ctx, adj)
+	return adj, nil
+}
+
+// publishStockChange emits stock.adjusted and, when the change crosses the product
+// threshold downwards, stock.low.
+func (s *ProductService) publishStockChange(ctx context.Context, adj *repo.StockAdjustment) {
+	if adj == nil {
+		return
+	}
+	s.events.Publish(ctx, events.StockAdjusted, events.StockAdjustedData{
+		ProductID:     adj.ProductID,
+		Name:          adj.Name,
+		SKU:           adj.SKU,
+		Delta:         adj.Delta,
+		Reason:        adj.Reason,
+		PreviousStock: adj.PreviousStock,
+		Stock:         adj.Stock,
+	})
+	if adj.PreviousStock > adj.LowStockThreshold && adj.Stock <= adj.LowStockThreshold {
+		s.events.Publish(ctx, events.StockLow, events.StockLowData{
+			ProductID:         adj.ProductID,
+			Name:              adj.Name,
+			SKU:               adj.SKU,
+			Stock:             adj.Stock,
+			LowStockThreshold: adj.LowStockThreshold,
+		})
 	}
-	return s.repo.AdjustStock(ctx, productID, delta, reason)
 }
 
 func (s *ProductService) List(ctx context.Context) ([]domain.Product, error) {
@@ -139,10 +600,22 @@ func (s *ProductService) List(ctx context.Context) ([]domain.Product, error) {
 }
 
 func (s *ProductService) ListIncludingArchived(ctx context.Context) ([]domain.Product, error) {
-	items, err := s.repo.ListIncludingArchived(ctx)
+	return s.ListByArchive(ctx, ArchivedInclude)
+}
+
+// ListByArchive returns every product, with its variants, matching the archive filter.
+func (s *ProductService) ListByArchive(ctx context.Context, filter ArchiveFilter) ([]domain.Product, error) {
+	res, err := s.repo.ListPaged(ctx, filter.listOptions(repo.ProductListOptions{Page: 1, PageSize: 0}))
 	if err != nil {
 		return nil, err
 	}
+	items := res.Items
+	if err := s.hydrate(ctx, productRefs(items), filter != ArchivedExclude); err != nil {
+		return nil, err
+	}
+	if filter == ArchivedOnly {
+		keepArchivedVariants(items)
+	}
 	for i := range items {
 		s.decorate(&items[i])
 	}
@@ -150,16 +623,34 @@ func (s *ProductService) ListIncludingArchived(ctx context.Context) ([]domain.Pr
 }
 
 func (s *ProductService) ListPaged(ctx context.Context, opts ProductListOptions) (ProductListResult, error) {
-	repoResult, err := s.repo.ListPaged(ctx, repo.ProductListOptions{
-		Query:           opts.Query,
-		Page:            opts.Page,
-		PageSize:        opts.PageSize,
-		IncludeArchived: false,
+	if opts.Archived == "" {
+		opts.Archived = ArchivedExclude
+	}
+	repoOpts := opts.Archived.listOptions(repo.ProductListOptions{
+		Query:    opts.Query,
+		Page:     opts.Page,
+		PageSize: opts.PageSize,
 	})
+	if ref := strings.TrimSpace(opts.Category); strings.EqualFold(ref, "none") {
+		repoOpts.Uncategorised = true
+	} else if ref != "" {
+		category, items, err := resolveCategory(ctx, s.categories, ref)
+		if err != nil {
+			return ProductListResult{}, err
+		}
+		repoOpts.CategoryIDs = categorySubtree(items, category.ID)
+	}
+	repoResult, err := s.repo.ListPaged(ctx, repoOpts)
 	if err != nil {
 		return ProductListResult{}, err
 	}
 
+	if err := s.hydrate(ctx, append(productRefs(repoResult.Items), productRefs(repoResult.LowStockHighlights)...), opts.Archived != ArchivedExclude); err != nil {
+		return ProductListResult{}, err
+	}
+	if opts.Archived == ArchivedOnly {
+		keepArchivedVariants(repoResult.Items)
+	}
 	for i := range repoResult.Items {
 		s.decorate(&repoResult.Items[i])
 	}
@@ -175,6 +666,8 @@ func (s *ProductService) ListPaged(ctx context.Context, opts ProductListOptions)
 		OutOfStockCount:    repoResult.OutOfStockCount,
 		WarningStockCount:  repoResult.WarningStockCount,
 		LowStockHighlights: repoResult.LowStockHighlights,
+		ExpiringLotCount:   repoResult.ExpiringLotCount,
+		ExpiryWarnings:     repoResult.ExpiryWarnings,
 	}, nil
 }
 
@@ -186,6 +679,37 @@ func (s *ProductService) Get(ctx context.Context, id string) (*domain.Product, e
 	if err != nil {
 		return nil, err
 	}
+	if err := s.hydrate(ctx, []*domain.Product{product}, false); err != nil {
+		return nil, err
+	}
+	s.decorate(product)
+	return product, nil
+}
+
+// ErrProductNotFound is returned when a scanned code matches no product.
+var ErrProductNotFound = errors.New("produk tidak ditemukan")
+
+// Lookup resolves a scanned code to a product by exact SKU or barcode. Scanned UPC-A
+// codes also match the 13-digit form the barcode is stored in.
+func (s *ProductService) Lookup(ctx context.Context, code string) (*domain.Product, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil, errors.New("kode wajib diisi")
+	}
+	codes := []string{code}
+	if normalised, err := barcode.NormaliseGTIN(code); err == nil && normalised != code {
+		codes = append(codes, normalised)
+	}
+	product, err := s.repo.FindByCode(ctx, codes)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrProductNotFound, code)
+		}
+		return nil, err
+	}
+	if err := s.hydrate(ctx, []*domain.Product{product}, false); err != nil {
+		return nil, err
+	}
 	s.decorate(product)
 	return product, nil
 }
@@ -194,23 +718,94 @@ func (s *ProductService) Archive(ctx context.Context, id string) error {
 	if strings.TrimSpace(id) == "" {
 		return errors.New("product id required")
 	}
-	return s.repo.Archive(ctx, id)
+	existing, _ := s.repo.Get(ctx, id)
+	if err := s.repo.Archive(ctx, id); err != nil {
+		return err
+	}
+	s.publishProductChange(ctx, events.ProductArchived, existing)
+	return nil
+}
+
+// Unarchive makes an archived product, and the variants archived with it, active again
+// so it can be sold under the same id and order history. A variant can only come back
+// while its parent is active and its options still fit the parent's option axes.
+// Active products are left alone.
+func (s *ProductService) Unarchive(ctx context.Context, id string) (*domain.Product, error) {
+	if strings.TrimSpace(id) == "" {
+		return nil, errors.New("product id required")
+	}
+	existing, err := s.repo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrProductNotFound, id)
+		}
+		return nil, err
+	}
+	if existing.DeletedAt == nil {
+		return s.Get(ctx, id)
+	}
+	if existing.ParentID != "" {
+		parent, err := s.repo.Get(ctx, existing.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent.DeletedAt != nil {
+			return nil, fmt.Errorf("produk induk %s masih diarsipkan; aktifkan produk induk terlebih dahulu", parent.Name)
+		}
+		if !existing.FitsAxes(parent.OptionAxes) {
+			return nil, fmt.Errorf("opsi varian %s tidak lagi sesuai dengan opsi produk induk", existing.Name)
+		}
+	}
+	restored, err := s.repo.Unarchive(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if restored {
+		s.publishProductChange(ctx, events.ProductUnarchived, existing)
+	}
+	return s.Get(ctx, id)
 }
 
 func (s *ProductService) Delete(ctx context.Context, id string) error {
 	if strings.TrimSpace(id) == "" {
 		return errors.New("product id required")
 	}
+	// Variants are deleted with their parent, so their images go too.
 	var existing *domain.Product
+	files := make(map[string]bool)
 	if s.repo != nil {
 		existing, _ = s.repo.Get(ctx, id)
+		owners := make([]domain.Product, 0)
+		if existing != nil {
+			owners = append(owners, *existing)
+		}
+		if variants, err := s.repo.Variants(ctx, []string{id}, true); err == nil {
+			owners = append(owners, variants[id]...)
+		}
+		ids := make([]string, 0, len(owners))
+		for _, owner := range owners {
+			ids = append(ids, owner.ID)
+			files[owner.ImagePath] = true
+			files[owner.ThumbPath] = true
+		}
+		if galleries, err := s.repo.Images(ctx, ids); err == nil {
+			for _, gallery := range galleries {
+				for _, img := range gallery {
+					files[img.Path] = true
+					files[img.ThumbPath] = true
+				}
+			}
+		}
 	}
 	if err := s.repo.Delete(ctx, id); err != nil {
 		return err
 	}
 	if existing != nil && s.media != nil {
-		_ = s.media.Remove(existing.ImagePath, existing.ThumbPath)
+		for path := range files {
+			_ = s.media.Remove(path)
+		}
 	}
+	s.publishProductChange(ctx, events.ProductDeleted, existing)
 	return nil
 }
 
@@ -221,6 +816,118 @@ func (s *ProductService) ReplaceAll(ctx context.Context, items []domain.Product)
 	return s.repo.ReplaceAll(ctx, items)
 }
 
+// hydrate attaches bundle components and variants to the products that have them,
+// and the per-location stock of every stock-keeping product.
+func (s *ProductService) hydrate(ctx context.Context, products []*domain.Product, includeArchived bool) error {
+	if err := s.attachComponents(ctx, products); err != nil {
+		return err
+	}
+	if err := s.attachVariants(ctx, products, includeArchived); err != nil {
+		return err
+	}
+	if err := s.attachImages(ctx, products); err != nil {
+		return err
+	}
+	return s.attachLocations(ctx, products)
+}
+
+// attachLocations loads where the stock of each product, and of its variants, is kept.
+func (s *ProductService) attachLocations(ctx context.Context, products []*domain.Product) error {
+	targets := make([]*domain.Product, 0, len(products))
+	for _, p := range products {
+		if p == nil {
+			continue
+		}
+		targets = append(targets, p)
+		for i := range p.Variants {
+			targets = append(targets, &p.Variants[i])
+		}
+	}
+	ids := make([]string, 0, len(targets))
+	for _, p := range targets {
+		if !p.IsBundle && !p.HasVariants() {
+			ids = append(ids, p.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	locations, err := s.repo.ProductLocations(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, p := range targets {
+		p.Locations = locations[p.ID]
+	}
+	return nil
+}
+
+func (s *ProductService) attachVariants(ctx context.Context, products []*domain.Product, includeArchived bool) error {
+	ids := make([]string, 0)
+	for _, p := range products {
+		if p != nil && p.HasVariants() {
+			ids = append(ids, p.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	variants, err := s.repo.Variants(ctx, ids, includeArchived)
+	if err != nil {
+		return err
+	}
+	for _, p := range products {
+		if p == nil || !p.HasVariants() {
+			continue
+		}
+		p.Variants = variants[p.ID]
+		for i := range p.Variants {
+			s.decorate(&p.Variants[i])
+			if p.Variants[i].ImagePath == "" && s.media != nil {
+				p.Variants[i].ImageURL = s.media.PublicURL(p.ImagePath)
+				p.Variants[i].ThumbURL = s.media.PublicURL(p.ThumbPath)
+			}
+		}
+	}
+	return nil
+}
+
+// attachComponents loads bundle components and derives the bundle cost from them.
+func (s *ProductService) attachComponents(ctx context.Context, products []*domain.Product) error {
+	ids := make([]string, 0)
+	for _, p := range products {
+		if p != nil && p.IsBundle {
+			ids = append(ids, p.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	components, err := s.repo.BundleComponents(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, p := range products {
+		if p == nil || !p.IsBundle {
+			continue
+		}
+		p.Components = components[p.ID]
+		p.CostPrice = 0
+		for _, c := range p.Components {
+			p.CostPrice += c.CostPrice * float64(c.Quantity)
+		}
+	}
+	return nil
+}
+
+func productRefs(items []domain.Product) []*domain.Product {
+	refs := make([]*domain.Product, len(items))
+	for i := range items {
+		refs[i] = &items[i]
+	}
+	return refs
+}
+
 func (s *ProductService) decorate(product *domain.Product) {
 	if product == nil {
 		return
@@ -231,3 +938,87 @@ func (s *ProductService) decorate(product *domain.Product) {
 		product.ThumbURL = s.media.PublicURL(product.ThumbPath)
 	}
 }
+
+// PriceHistory returns the price and cost changes of a product, newest first, with the
+// margin each change resulted in.
+func (s *ProductService) PriceHistory(ctx context.Context, id string, limit int) ([]domain.PriceChange, error) {
+	if strings.TrimSpace(id) == "" {
+		return nil, errors.New("product id required")
+	}
+	if _, err := s.repo.Get(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrProductNotFound, id)
+		}
+		return nil, err
+	}
+	items, err := s.repo.PriceHistory(ctx, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].Margin, items[i].MarginPercent = marginOf(items[i].SalePrice, items[i].CostPrice)
+	}
+	return items, nil
+}
+
+// marginOf returns the unit margin and the margin as a percentage of the sale price.
+func marginOf(sale, cost float64) (float64, float64) {
+	margin := sale - cost
+	if sale == 0 {
+		return margin, 0
+	}
+	return margin, margin / sale * 100
+}
+
+// SetCostPrice updates the list cost of a product, e.g. after goods were received at a
+// new price. The change is kept in the price history.
+func (s *ProductService) SetCostPrice(ctx context.Context, id string, cost float64) error {
+	if cost < 0 {
+		return errors.New("harga modal tidak boleh negatif")
+	}
+	return s.repo.SetCostPrice(ctx, id, cost)
+}
+
+// StockMutationListOptions filters the stock ledger. Reasons are prefixes such as
+// "order:", "stock_opname:" or "manual"; "manual" includes adjustments booked under a
+// reason code.
+type StockMutationListOptions struct {
+	ProductID string
+	Reasons   []string
+	DateStart *time.Time
+	DateEnd   *time.Time
+	Page      int
+	PageSize  int
+}
+
+type StockMutationListResult struct {
+	Items    []domain.StockMutation `json:"items"`
+	Total    int                    `json:"total"`
+	Page     int                    `json:"page"`
+	PageSize int                    `json:"pageSize"`
+}
+
+// ListMutations pages through the stock ledger of one product, or of all products when
+// ProductID is empty, newest first with the running balance of each entry.
+func (s *ProductService) ListMutations(ctx context.Context, opts StockMutationListOptions) (StockMutationListResult, error) {
+	if opts.ProductID != "" {
+		if _, err := s.repo.Get(ctx, opts.ProductID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return StockMutationListResult{}, fmt.Errorf("%w: %s", ErrProductNotFound, opts.ProductID)
+			}
+			return StockMutationListResult{}, err
+		}
+	}
+	result, err := s.repo.ListMutations(ctx, repo.StockMutationListOptions{
+		ProductID: opts.ProductID,
+		Reasons:   opts.Reasons,
+		DateStart: opts.DateStart,
+		DateEnd:   opts.DateEnd,
+		Page:      opts.Page,
+		PageSize:  opts.PageSize,
+	})
+	if err != nil {
+		return StockMutationListResult{}, err
+	}
+	return StockMutationListResult{Items: result.Items, Total: result.Total, Page: result.Page, PageSize: result.PageSize}, nil
+}
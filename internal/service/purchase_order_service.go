@@ -0,0 +1,358 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"smartseller-lite-starter/internal/audit"
+	"smartseller-lite-starter/internal/domain"
+	"smartseller-lite-starter/internal/repo"
+)
+
+// ErrPurchaseOrderNotFound is returned when a purchase order id matches nothing.
+var ErrPurchaseOrderNotFound = errors.New("purchase order tidak ditemukan")
+
+// PurchaseOrderService handles purchase orders from draft to received, posting received
+// goods through the stock ledger as cost batches.
+type PurchaseOrderService struct {
+	repo      *repo.PurchaseOrderRepository
+	suppliers *SupplierService
+	products  *ProductService
+}
+
+func NewPurchaseOrderService(repo *repo.PurchaseOrderRepository, suppliers *SupplierService, products *ProductService) *PurchaseOrderService {
+	return &PurchaseOrderService{repo: repo, suppliers: suppliers, products: products}
+}
+
+type PurchaseOrderListOptions struct {
+	Status     string
+	SupplierID string
+}
+
+type PurchaseOrderLineInput struct {
+	ProductID string  `json:"productId"`
+	Quantity  int     `json:"quantity"`
+	UnitCost  float64 `json:"unitCost"`
+}
+
+type PurchaseOrderInput struct {
+	SupplierID string                   `json:"supplierId"`
+	Notes      string                   `json:"notes"`
+	ExpectedAt *time.Time               `json:"expectedAt"`
+	Lines      []PurchaseOrderLineInput `json:"lines"`
+}
+
+// ReceiveLineInput receives Quantity units of a line. UnitCost overrides the expected
+// cost when the invoice differs; LotNumber and ExpiresAt label perishable goods.
+type ReceiveLineInput struct {
+	LineID    string     `json:"lineId"`
+	Quantity  int        `json:"quantity"`
+	UnitCost  *float64   `json:"unitCost"`
+	LotNumber string     `json:"lotNumber"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+type ReceivePurchaseInput struct {
+	ReceivedAt *time.Time         `json:"receivedAt"`
+	Note       string             `json:"note"`
+	Lines      []ReceiveLineInput `json:"lines"`
+}
+
+type PurchasePaymentInput struct {
+	Amount float64    `json:"amount"`
+	Note   string     `json:"note"`
+	PaidAt *time.Time `json:"paidAt"`
+}
+
+// SupplierPayable sums what is owed to a supplier for goods already received. Overdue
+// is the part whose payment term has passed.
+type SupplierPayable struct {
+	SupplierID      string     `json:"supplierId"`
+	SupplierName    string     `json:"supplierName"`
+	PaymentTermDays int        `json:"paymentTermDays"`
+	OpenOrders      int        `json:"openOrders"`
+	ReceivedValue   float64    `json:"receivedValue"`
+	PaidAmount      float64    `json:"paidAmount"`
+	Outstanding     float64    `json:"outstanding"`
+	Overdue         float64    `json:"overdue"`
+	NextDueAt       *time.Time `json:"nextDueAt"`
+}
+
+func (s *PurchaseOrderService) List(ctx context.Context, opts PurchaseOrderListOptions) ([]domain.PurchaseOrder, error) {
+	status := domain.PurchaseOrderStatus(strings.TrimSpace(opts.Status))
+	if status != "" && !validPurchaseOrderStatus(status) {
+		return nil, fmt.Errorf("status purchase order %q tidak dikenal", status)
+	}
+	return s.repo.List(ctx, repo.PurchaseOrderListOptions{Status: status, SupplierID: strings.TrimSpace(opts.SupplierID)})
+}
+
+func validPurchaseOrderStatus(status domain.PurchaseOrderStatus) bool {
+	switch status {
+	case domain.PurchaseOrderDraft, domain.PurchaseOrderOrdered, domain.PurchaseOrderPartiallyReceived, domain.PurchaseOrderReceived:
+		return true
+	}
+	return false
+}
+
+func (s *PurchaseOrderService) Get(ctx context.Context, id string) (*domain.PurchaseOrder, error) {
+	if strings.TrimSpace(id) == "" {
+		return nil, errors.New("purchase order id required")
+	}
+	po, err := s.repo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrPurchaseOrderNotFound, id)
+		}
+		return nil, err
+	}
+	return po, nil
+}
+
+// Create saves a new purchase order as a draft.
+func (s *PurchaseOrderService) Create(ctx context.Context, input PurchaseOrderInput) (*domain.PurchaseOrder, error) {
+	po := &domain.PurchaseOrder{Status: domain.PurchaseOrderDraft}
+	if err := s.apply(ctx, po, input); err != nil {
+		return nil, err
+	}
+	saved, err := s.repo.Create(ctx, po)
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, saved.ID)
+}
+
+// Update edits a purchase order while it is still a draft.
+func (s *PurchaseOrderService) Update(ctx context.Context, id string, input PurchaseOrderInput) (*domain.PurchaseOrder, error) {
+	po, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if po.Status != domain.PurchaseOrderDraft {
+		return nil, errors.New("hanya purchase order berstatus draft yang dapat diubah")
+	}
+	if err := s.apply(ctx, po, input); err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.Update(ctx, po); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+func (s *PurchaseOrderService) apply(ctx context.Context, po *domain.PurchaseOrder, input PurchaseOrderInput) error {
+	supplier, err := s.suppliers.Get(ctx, strings.TrimSpace(input.SupplierID))
+	if err != nil {
+		return err
+	}
+	if len(input.Lines) == 0 {
+		return errors.New("purchase order memerlukan minimal satu baris produk")
+	}
+	lines := make([]domain.PurchaseOrderLine, 0, len(input.Lines))
+	seen := make(map[string]bool, len(input.Lines))
+	for _, in := range input.Lines {
+		product, err := s.products.Get(ctx, strings.TrimSpace(in.ProductID))
+		if err != nil {
+			return fmt.Errorf("produk %s: %w", in.ProductID, err)
+		}
+		if product.IsBundle || product.HasVariants() {
+			return fmt.Errorf("%s tidak menyimpan stok sendiri; pesan komponen atau variannya", product.Name)
+		}
+		if seen[product.ID] {
+			return fmt.Errorf("produk %s muncul lebih dari sekali", product.Name)
+		}
+		seen[product.ID] = true
+		if in.Quantity <= 0 {
+			return fmt.Errorf("jumlah %s harus lebih dari 0", product.Name)
+		}
+		if in.UnitCost < 0 {
+			return fmt.Errorf("harga modal %s tidak boleh negatif", product.Name)
+		}
+		lines = append(lines, domain.PurchaseOrderLine{ProductID: product.ID, Quantity: in.Quantity, UnitCost: in.UnitCost})
+	}
+	po.SupplierID = supplier.ID
+	po.Notes = strings.TrimSpace(input.Notes)
+	po.ExpectedAt = input.ExpectedAt
+	po.Lines = lines
+	return nil
+}
+
+// Place marks a draft as ordered with the supplier.
+func (s *PurchaseOrderService) Place(ctx context.Context, id string) (*domain.PurchaseOrder, error) {
+	po, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if po.Status != domain.PurchaseOrderDraft {
+		return nil, errors.New("purchase order sudah dipesan")
+	}
+	if err := s.repo.SetStatus(ctx, id, domain.PurchaseOrderOrdered); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// Receive books goods against an ordered purchase order. Every line is posted as a
+// stock mutation with reason po:<code>, opening a cost batch at the received cost, and
+// the product's list cost follows the latest purchase price. The whole receipt is one
+// transaction, so concurrent receipts cannot book more than was ordered.
+func (s *PurchaseOrderService) Receive(ctx context.Context, id string, input ReceivePurchaseInput) (*domain.PurchaseOrder, error) {
+	po, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if po.Status != domain.PurchaseOrderOrdered && po.Status != domain.PurchaseOrderPartiallyReceived {
+		return nil, fmt.Errorf("purchase order berstatus %s tidak dapat diterima", po.Status)
+	}
+	if len(input.Lines) == 0 {
+		return nil, errors.New("isi jumlah barang yang diterima")
+	}
+
+	lines := make(map[string]domain.PurchaseOrderLine, len(po.Lines))
+	for _, l := range po.Lines {
+		lines[l.ID] = l
+	}
+	seen := make(map[string]bool, len(input.Lines))
+	for _, in := range input.Lines {
+		line, ok := lines[in.LineID]
+		if !ok {
+			return nil, fmt.Errorf("baris %s tidak ada pada purchase order ini", in.LineID)
+		}
+		if seen[in.LineID] {
+			return nil, fmt.Errorf("baris %s diterima lebih dari sekali", line.ProductName)
+		}
+		seen[in.LineID] = true
+		if in.Quantity <= 0 {
+			return nil, fmt.Errorf("jumlah diterima untuk %s harus lebih dari 0", line.ProductName)
+		}
+		if open := line.Quantity - line.ReceivedQuantity; in.Quantity > open {
+			return nil, fmt.Errorf("%s hanya tersisa %d unit untuk diterima", line.ProductName, open)
+		}
+		if in.UnitCost != nil && *in.UnitCost < 0 {
+			return nil, fmt.Errorf("harga modal %s tidak boleh negatif", line.ProductName)
+		}
+		if len(strings.TrimSpace(in.LotNumber)) > 64 {
+			return nil, fmt.Errorf("nomor lot %s maksimal 64 karakter", line.ProductName)
+		}
+		if in.ExpiresAt != nil && !in.ExpiresAt.After(time.Now()) {
+			return nil, fmt.Errorf("tanggal kedaluwarsa %s sudah lewat", line.ProductName)
+		}
+	}
+
+	receivedAt := time.Now().UTC()
+	if input.ReceivedAt != nil {
+		receivedAt = input.ReceivedAt.UTC()
+	}
+	receipt := repo.PurchaseReceipt{
+		Reason:     "po:" + po.Code,
+		Note:       strings.TrimSpace(input.Note),
+		ReceivedAt: receivedAt,
+		Lines:      make([]repo.PurchaseReceiptLine, 0, len(input.Lines)),
+	}
+	for _, in := range input.Lines {
+		receipt.Lines = append(receipt.Lines, repo.PurchaseReceiptLine{
+			LineID:    in.LineID,
+			Quantity:  in.Quantity,
+			UnitCost:  in.UnitCost,
+			LotNumber: strings.TrimSpace(in.LotNumber),
+			ExpiresAt: in.ExpiresAt,
+		})
+	}
+	ctx = audit.WithSource(ctx, "purchase")
+	adjustments, err := s.repo.Receive(ctx, id, receipt)
+	if err != nil {
+		return nil, err
+	}
+	for _, adj := range adjustments {
+		s.products.publishStockChange(ctx, adj)
+	}
+	return s.Get(ctx, id)
+}
+
+// AddPayment records money paid to the supplier. Payments may be made in advance but
+// never exceed the order total; the repository checks this with the order locked.
+func (s *PurchaseOrderService) AddPayment(ctx context.Context, id string, input PurchasePaymentInput) (*domain.PurchaseOrder, error) {
+	po, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if input.Amount <= 0 {
+		return nil, errors.New("jumlah pembayaran harus lebih dari 0")
+	}
+	payment := &domain.PurchasePayment{PurchaseOrderID: po.ID, Amount: input.Amount, Note: strings.TrimSpace(input.Note)}
+	if input.PaidAt != nil {
+		payment.PaidAt = *input.PaidAt
+	}
+	if _, err := s.repo.AddPayment(ctx, payment); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// Delete removes a draft purchase order. Orders that were placed stay for the record.
+func (s *PurchaseOrderService) Delete(ctx context.Context, id string) error {
+	po, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if po.Status != domain.PurchaseOrderDraft {
+		return errors.New("hanya purchase order berstatus draft yang dapat dihapus")
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// Payables lists what is owed to each supplier for received goods, largest first. A
+// receipt falls due the supplier's payment term after the last delivery.
+func (s *PurchaseOrderService) Payables(ctx context.Context) ([]SupplierPayable, error) {
+	suppliers, err := s.suppliers.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := s.repo.List(ctx, repo.PurchaseOrderListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	bySupplier := make(map[string]*SupplierPayable, len(suppliers))
+	for _, sup := range suppliers {
+		bySupplier[sup.ID] = &SupplierPayable{SupplierID: sup.ID, SupplierName: sup.Name, PaymentTermDays: sup.PaymentTermDays}
+	}
+
+	now := time.Now().UTC()
+	for _, po := range orders {
+		p := bySupplier[po.SupplierID]
+		if p == nil || po.Status == domain.PurchaseOrderDraft {
+			continue
+		}
+		p.ReceivedValue += po.ReceivedValue
+		p.PaidAmount += po.PaidAmount
+		p.Outstanding += po.Outstanding
+		if po.Status != domain.PurchaseOrderReceived || po.Outstanding > 0.005 {
+			p.OpenOrders++
+		}
+		if po.Outstanding <= 0.005 || po.LastReceivedAt == nil {
+			continue
+		}
+		due := po.LastReceivedAt.AddDate(0, 0, p.PaymentTermDays)
+		if due.Before(now) {
+			p.Overdue += po.Outstanding
+		} else if p.NextDueAt == nil || due.Before(*p.NextDueAt) {
+			next := due
+			p.NextDueAt = &next
+		}
+	}
+
+	result := make([]SupplierPayable, 0, len(bySupplier))
+	for _, sup := range suppliers {
+		p := bySupplier[sup.ID]
+		if p.OpenOrders == 0 && p.Outstanding == 0 && p.ReceivedValue == 0 {
+			continue
+		}
+		result = append(result, *p)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Outstanding > result[j].Outstanding })
+	return result, nil
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"smartseller-lite-starter/internal/domain"
+	"smartseller-lite-starter/internal/sheet"
+)
+
+// Periods the adjustment report can group by.
+const (
+	AdjustmentPeriodDay   = "day"
+	AdjustmentPeriodWeek  = "week"
+	AdjustmentPeriodMonth = "month"
+)
+
+// AdjustmentReasonTotal sums the adjustments booked under one reason. Value is signed:
+// stock written off counts negative, stock found positive.
+type AdjustmentReasonTotal struct {
+	Code      string  `json:"code"`
+	Label     string  `json:"label"`
+	Direction string  `json:"direction"`
+	Count     int     `json:"count"`
+	UnitsIn   int     `json:"unitsIn"`
+	UnitsOut  int     `json:"unitsOut"`
+	Value     float64 `json:"value"`
+}
+
+// AdjustmentPeriodTotals holds the per-reason totals of one period. LossValue is the
+// value written off, as a positive amount.
+type AdjustmentPeriodTotals struct {
+	Period    string                  `json:"period"`
+	Reasons   []AdjustmentReasonTotal `json:"reasons"`
+	LossValue float64                 `json:"lossValue"`
+	NetValue  float64                 `json:"netValue"`
+}
+
+// AdjustmentReport summarises manual adjustments per reason per period, with totals
+// per reason over the whole range.
+type AdjustmentReport struct {
+	Start     *time.Time               `json:"start,omitempty"`
+	End       *time.Time               `json:"end,omitempty"`
+	Period    string                   `json:"period"`
+	Periods   []AdjustmentPeriodTotals `json:"periods"`
+	Reasons   []AdjustmentReasonTotal  `json:"reasons"`
+	LossValue float64                  `json:"lossValue"`
+	NetValue  float64                  `json:"netValue"`
+}
+
+func (t *AdjustmentReasonTotal) add(count, unitsIn, unitsOut int, value float64) {
+	t.Count += count
+	t.UnitsIn += unitsIn
+	t.UnitsOut += unitsOut
+	t.Value += value
+}
+
+// adjustmentPeriodKey names the period a UTC day falls in: the day itself, its ISO
+// week (2006-W01) or its month (2006-01).
+func adjustmentPeriodKey(day, period string) string {
+	ts, err := time.Parse("2006-01-02", day)
+	if err != nil {
+		return day
+	}
+	switch period {
+	case AdjustmentPeriodWeek:
+		year, week := ts.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case AdjustmentPeriodMonth:
+		return ts.Format("2006-01")
+	}
+	return day
+}
+
+// AdjustmentReport groups the adjustments in the range by period (day, week or month;
+// month when empty) and reason. Reasons follow their display order; a reason deleted
+// since shows under its code.
+func (s *ReportService) AdjustmentReport(ctx context.Context, start, end *time.Time, period string) (*AdjustmentReport, error) {
+	switch period {
+	case AdjustmentPeriodDay, AdjustmentPeriodWeek, AdjustmentPeriodMonth:
+	case "":
+		period = AdjustmentPeriodMonth
+	default:
+		return nil, fmt.Errorf("periode harus day, week atau month")
+	}
+	adjustments := s.store.AdjustmentRepository()
+	days, err := adjustments.DailyTotals(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	reasons, err := adjustments.ListReasons(ctx)
+	if err != nil {
+		return nil, err
+	}
+	order := make(map[string]int, len(reasons))
+	known := make(map[string]domain.AdjustmentReason, len(reasons))
+	for i, reason := range reasons {
+		order[reason.Code] = i
+		known[reason.Code] = reason
+	}
+	total := func(code string) AdjustmentReasonTotal {
+		if reason, ok := known[code]; ok {
+			return AdjustmentReasonTotal{Code: code, Label: reason.Label, Direction: reason.Direction}
+		}
+		return AdjustmentReasonTotal{Code: code, Label: code}
+	}
+	byReason := func(items []AdjustmentReasonTotal) {
+		sort.SliceStable(items, func(i, j int) bool {
+			oi, iok := order[items[i].Code]
+			oj, jok := order[items[j].Code]
+			if iok != jok {
+				return iok
+			}
+			if oi != oj {
+				return oi < oj
+			}
+			return items[i].Code < items[j].Code
+		})
+	}
+
+	report := &AdjustmentReport{Start: start, End: end, Period: period, Periods: make([]AdjustmentPeriodTotals, 0), Reasons: make([]AdjustmentReasonTotal, 0)}
+	overall := make(map[string]int)
+	for _, day := range days {
+		key := adjustmentPeriodKey(day.Day, period)
+		if n := len(report.Periods); n == 0 || report.Periods[n-1].Period != key {
+			report.Periods = append(report.Periods, AdjustmentPeriodTotals{Period: key, Reasons: make([]AdjustmentReasonTotal, 0)})
+		}
+		p := &report.Periods[len(report.Periods)-1]
+		idx := -1
+		for i := range p.Reasons {
+			if p.Reasons[i].Code == day.ReasonCode {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			p.Reasons = append(p.Reasons, total(day.ReasonCode))
+			idx = len(p.Reasons) - 1
+		}
+		p.Reasons[idx].add(day.Count, day.UnitsIn, day.UnitsOut, day.Value)
+		p.NetValue += day.Value
+
+		i, ok := overall[day.ReasonCode]
+		if !ok {
+			report.Reasons = append(report.Reasons, total(day.ReasonCode))
+			i = len(report.Reasons) - 1
+			overall[day.ReasonCode] = i
+		}
+		report.Reasons[i].add(day.Count, day.UnitsIn, day.UnitsOut, day.Value)
+		report.NetValue += day.Value
+	}
+	for i := range report.Periods {
+		p := &report.Periods[i]
+		for _, r := range p.Reasons {
+			if r.Value < 0 {
+				p.LossValue -= r.Value
+			}
+		}
+		report.LossValue += p.LossValue
+		byReason(p.Reasons)
+	}
+	byReason(report.Reasons)
+	return report, nil
+}
+
+// AdjustmentReportCSV renders the adjustment report as CSV, one row per period and
+// reason followed by the totals per reason.
+func (s *ReportService) AdjustmentReportCSV(ctx context.Context, start, end *time.Time, period string) ([]byte, error) {
+	report, err := s.AdjustmentReport(ctx, start, end, period)
+	if err != nil {
+		return nil, err
+	}
+	rows := [][]any{{"Periode", "Kode", "Alasan", "Jumlah", "Unit Masuk", "Unit Keluar", "Nilai"}}
+	for _, p := range report.Periods {
+		for _, r := range p.Reasons {
+			rows = append(rows, []any{p.Period, r.Code, r.Label, r.Count, r.UnitsIn, r.UnitsOut, r.Value})
+		}
+	}
+	rows = append(rows, []any{})
+	for _, r := range report.Reasons {
+		rows = append(rows, []any{"Total", r.Code, r.Label, r.Count, r.UnitsIn, r.UnitsOut, r.Value})
+	}
+	rows = append(rows,
+		[]any{},
+		[]any{"Total kerugian", "", "", "", "", "", -report.LossValue},
+		[]any{"Nilai bersih", "", "", "", "", "", report.NetValue},
+	)
+	return sheet.Write(sheet.FormatCSV, rows)
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CategoryReportFilters bounds the sales side of the category report. Stock figures
+// are always current.
+type CategoryReportFilters struct {
+	Start *time.Time
+	End   *time.Time
+}
+
+// CategoryRollup holds stock and sales totals for a category including all of its
+// subcategories. The row with an empty CategoryID collects uncategorised products.
+type CategoryRollup struct {
+	CategoryID  string  `json:"categoryId"`
+	ParentID    string  `json:"parentId"`
+	Name        string  `json:"name"`
+	Path        string  `json:"path"`
+	Depth       int     `json:"depth"`
+	SKUCount    int     `json:"skuCount"`
+	StockUnits  int     `json:"stockUnits"`
+	StockCost   float64 `json:"stockCost"`
+	StockRetail float64 `json:"stockRetail"`
+	UnitsSold   int     `json:"unitsSold"`
+	Revenue     float64 `json:"revenue"`
+	Profit      float64 `json:"profit"`
+}
+
+func (r *CategoryRollup) add(other CategoryRollup) {
+	r.SKUCount += other.SKUCount
+	r.StockUnits += other.StockUnits
+	r.StockCost += other.StockCost
+	r.StockRetail += other.StockRetail
+	r.UnitsSold += other.UnitsSold
+	r.Revenue += other.Revenue
+	r.Profit += other.Profit
+}
+
+// CategoryReport returns one rollup per category in tree order, followed by the
+// uncategorised bucket. Stock counts stock-keeping rows only (variants, not their
+// parents; no bundles), so units are not counted twice.
+func (s *ReportService) CategoryReport(ctx context.Context, filters CategoryReportFilters) ([]CategoryRollup, error) {
+	categories, err := s.store.CategoryRepository().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	own := make(map[string]*CategoryRollup)
+	bucket := func(id string) *CategoryRollup {
+		if own[id] == nil {
+			own[id] = &CategoryRollup{}
+		}
+		return own[id]
+	}
+
+	// Stock is valued from the cost batches, not the current list cost.
+	_, valuations, err := s.store.ProductRepository().InventoryValuation(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range valuations {
+		bucket(v.CategoryID).add(CategoryRollup{
+			SKUCount:    1,
+			StockUnits:  v.Stock,
+			StockCost:   v.Value,
+			StockRetail: v.SalePrice * float64(v.Stock),
+		})
+	}
+
+	salesStmt := `SELECT IFNULL(p.category_id,''), IFNULL(SUM(i.quantity),0), IFNULL(SUM(GREATEST(i.unit_price * i.quantity - i.discount_item, 0)),0), IFNULL(SUM(i.profit),0)
+        FROM order_items i
+        JOIN orders o ON o.id = i.order_id
+        JOIN products p ON p.id = i.product_id`
+	var conditions []string
+	args := make([]any, 0, 2)
+	if filters.Start != nil {
+		conditions = append(conditions, "o.created_at >= ?")
+		args = append(args, filters.Start.UTC().Format(time.RFC3339))
+	}
+	if filters.End != nil {
+		conditions = append(conditions, "o.created_at <= ?")
+		args = append(args, filters.End.UTC().Format(time.RFC3339))
+	}
+	for i, cond := range conditions {
+		if i == 0 {
+			salesStmt += "\n        WHERE " + cond
+		} else {
+			salesStmt += " AND " + cond
+		}
+	}
+	salesStmt += "\n        GROUP BY IFNULL(p.category_id,'');"
+	rows, err := s.store.DB().QueryContext(ctx, salesStmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query category sales: %w", err)
+	}
+	for rows.Next() {
+		var id string
+		var row CategoryRollup
+		if err := rows.Scan(&id, &row.UnitsSold, &row.Revenue, &row.Profit); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan category sales: %w", err)
+		}
+		bucket(id).add(row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate category sales: %w", err)
+	}
+
+	known := make(map[string]bool, len(categories))
+	result := make([]CategoryRollup, 0, len(categories)+1)
+	for _, c := range categories {
+		known[c.ID] = true
+		total := CategoryRollup{CategoryID: c.ID, ParentID: c.ParentID, Name: c.Name, Path: c.Path, Depth: c.Depth}
+		for _, id := range categorySubtree(categories, c.ID) {
+			if row := own[id]; row != nil {
+				total.add(*row)
+			}
+		}
+		result = append(result, total)
+	}
+
+	// Products pointing at a deleted category land in the uncategorised bucket.
+	uncategorised := CategoryRollup{Name: "Tanpa kategori", Path: "Tanpa kategori"}
+	for id, row := range own {
+		if !known[id] {
+			uncategorised.add(*row)
+		}
+	}
+	return append(result, uncategorised), nil
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MarginReportFilters bounds the margin report. Without a start the period begins with
+// each product's first recorded price; without an end it runs until now.
+type MarginReportFilters struct {
+	Start *time.Time
+	End   *time.Time
+}
+
+// ProductMargin compares a product's list margin at the start and end of a period, both
+// taken from the price history, with the margin actually realised on orders in it.
+type ProductMargin struct {
+	ProductID          string  `json:"productId"`
+	SKU                string  `json:"sku"`
+	Name               string  `json:"name"`
+	StartSalePrice     float64 `json:"startSalePrice"`
+	StartCostPrice     float64 `json:"startCostPrice"`
+	StartMarginPercent float64 `json:"startMarginPercent"`
+	EndSalePrice       float64 `json:"endSalePrice"`
+	EndCostPrice       float64 `json:"endCostPrice"`
+	EndMarginPercent   float64 `json:"endMarginPercent"`
+	PriceChanges       int     `json:"priceChanges"`
+	UnitsSold          int     `json:"unitsSold"`
+	Revenue            float64 `json:"revenue"`
+	Cost               float64 `json:"cost"`
+	Profit             float64 `json:"profit"`
+	RealisedMargin     float64 `json:"realisedMarginPercent"`
+}
+
+type priceSnapshot struct {
+	cost, sale float64
+	at         string
+}
+
+// MarginReport returns one row per product that had a price in effect during the
+// period. Realised figures use the cost captured on each order item, so they reflect
+// the cost at the time of sale rather than today's product row.
+func (s *ReportService) MarginReport(ctx context.Context, filters MarginReportFilters) ([]ProductMargin, error) {
+	var startAt, endAt string
+	if filters.Start != nil {
+		startAt = filters.Start.UTC().Format(time.RFC3339)
+	}
+	endAt = time.Now().UTC().Format(time.RFC3339)
+	if filters.End != nil {
+		endAt = filters.End.UTC().Format(time.RFC3339)
+	}
+
+	const productStmt = `SELECT id, IFNULL(sku,''), name FROM products WHERE deleted_at IS NULL AND IFNULL(option_axes,'') = '' ORDER BY name;`
+	rows, err := s.store.DB().QueryContext(ctx, productStmt)
+	if err != nil {
+		return nil, fmt.Errorf("query margin products: %w", err)
+	}
+	rowsByID := make(map[string]*ProductMargin)
+	order := make([]string, 0)
+	for rows.Next() {
+		var m ProductMargin
+		if err := rows.Scan(&m.ProductID, &m.SKU, &m.Name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan margin product: %w", err)
+		}
+		rowsByID[m.ProductID] = &m
+		order = append(order, m.ProductID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate margin products: %w", err)
+	}
+
+	const historyStmt = `SELECT product_id, cost_price, sale_price, changed_at FROM product_price_history WHERE changed_at <= ? ORDER BY product_id, changed_at, id;`
+	rows, err = s.store.DB().QueryContext(ctx, historyStmt, endAt)
+	if err != nil {
+		return nil, fmt.Errorf("query price history: %w", err)
+	}
+	history := make(map[string][]priceSnapshot)
+	for rows.Next() {
+		var id string
+		var snap priceSnapshot
+		if err := rows.Scan(&id, &snap.cost, &snap.sale, &snap.at); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan price history: %w", err)
+		}
+		history[id] = append(history[id], snap)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate price history: %w", err)
+	}
+
+	for id, snaps := range history {
+		m := rowsByID[id]
+		if m == nil {
+			continue
+		}
+		// The price at the start is the last change on or before it; a product created
+		// during the period starts at its first price, which counts as a change.
+		first := snaps[0]
+		for _, snap := range snaps {
+			if startAt != "" && snap.at <= startAt {
+				first = snap
+				continue
+			}
+			m.PriceChanges++
+		}
+		last := snaps[len(snaps)-1]
+		m.StartSalePrice, m.StartCostPrice = first.sale, first.cost
+		_, m.StartMarginPercent = marginOf(first.sale, first.cost)
+		m.EndSalePrice, m.EndCostPrice = last.sale, last.cost
+		_, m.EndMarginPercent = marginOf(last.sale, last.cost)
+	}
+
+	salesStmt := `SELECT i.product_id, IFNULL(SUM(i.quantity),0), IFNULL(SUM(GREATEST(i.unit_price * i.quantity - i.discount_item, 0)),0), IFNULL(SUM(i.cost_price * i.quantity),0), IFNULL(SUM(i.profit),0)
+        FROM order_items i
+        JOIN orders o ON o.id = i.order_id
+        WHERE o.created_at <= ?`
+	args := []any{endAt}
+	if startAt != "" {
+		salesStmt += " AND o.created_at >= ?"
+		args = append(args, startAt)
+	}
+	salesStmt += "\n        GROUP BY i.product_id;"
+	rows, err = s.store.DB().QueryContext(ctx, salesStmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query margin sales: %w", err)
+	}
+	for rows.Next() {
+		var id string
+		var units int
+		var revenue, cost, profit float64
+		if err := rows.Scan(&id, &units, &revenue, &cost, &profit); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan margin sales: %w", err)
+		}
+		if m := rowsByID[id]; m != nil {
+			m.UnitsSold, m.Revenue, m.Cost, m.Profit = units, revenue, cost, profit
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate margin sales: %w", err)
+	}
+
+	result := make([]ProductMargin, 0, len(order))
+	for _, id := range order {
+		m := rowsByID[id]
+		if len(history[id]) == 0 && m.UnitsSold == 0 {
+			continue
+		}
+		if m.Revenue != 0 {
+			m.RealisedMargin = m.Profit / m.Revenue * 100
+		}
+		result = append(result, *m)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Profit > result[j].Profit })
+	return result, nil
+}
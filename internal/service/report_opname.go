@@ -0,0 +1,356 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"smartseller-lite-starter/internal/sheet"
+)
+
+// OpnameVarianceLine values the difference of one product. Values follow the sign of
+// the difference: missing stock is negative.
+type OpnameVarianceLine struct {
+	ProductID     string  `json:"productId"`
+	SKU           string  `json:"sku"`
+	Name          string  `json:"name"`
+	PreviousStock int     `json:"previousStock"`
+	Counted       int     `json:"counted"`
+	Difference    int     `json:"difference"`
+	UnitCost      float64 `json:"unitCost"`
+	SalePrice     float64 `json:"salePrice"`
+	CostValue     float64 `json:"costValue"`
+	SaleValue     float64 `json:"saleValue"`
+}
+
+// OpnameVarianceReport is the valuation of one stock opname for finance. Shrinkage
+// figures are the positive value of the missing stock alone; Net figures offset it
+// with the stock found in excess.
+type OpnameVarianceReport struct {
+	OpnameID      string               `json:"opnameId"`
+	Brand         string               `json:"brand"`
+	LocationID    string               `json:"locationId"`
+	LocationName  string               `json:"locationName"`
+	Note          string               `json:"note"`
+	PerformedBy   string               `json:"performedBy"`
+	PerformedAt   time.Time            `json:"performedAt"`
+	SessionCode   string               `json:"sessionCode,omitempty"`
+	Counters      []string             `json:"counters"`
+	ReviewedBy    string               `json:"reviewedBy,omitempty"`
+	ApprovedBy    string               `json:"approvedBy,omitempty"`
+	Lines         []OpnameVarianceLine `json:"lines"`
+	LossUnits     int                  `json:"lossUnits"`
+	GainUnits     int                  `json:"gainUnits"`
+	ShrinkageCost float64              `json:"shrinkageCost"`
+	ShrinkageSale float64              `json:"shrinkageSale"`
+	NetCost       float64              `json:"netCost"`
+	NetSale       float64              `json:"netSale"`
+}
+
+// ShrinkagePoint is the shrinkage of one opname in the trend.
+type ShrinkagePoint struct {
+	OpnameID      string    `json:"opnameId"`
+	LocationID    string    `json:"locationId"`
+	PerformedBy   string    `json:"performedBy"`
+	PerformedAt   time.Time `json:"performedAt"`
+	Items         int       `json:"items"`
+	LossUnits     int       `json:"lossUnits"`
+	GainUnits     int       `json:"gainUnits"`
+	ShrinkageCost float64   `json:"shrinkageCost"`
+	ShrinkageSale float64   `json:"shrinkageSale"`
+	NetCost       float64   `json:"netCost"`
+	NetSale       float64   `json:"netSale"`
+}
+
+// ShrinkageMonth totals the opnames performed in one month, e.g. "2024-05".
+type ShrinkageMonth struct {
+	Month         string  `json:"month"`
+	Opnames       int     `json:"opnames"`
+	LossUnits     int     `json:"lossUnits"`
+	ShrinkageCost float64 `json:"shrinkageCost"`
+	ShrinkageSale float64 `json:"shrinkageSale"`
+	NetCost       float64 `json:"netCost"`
+	NetSale       float64 `json:"netSale"`
+}
+
+type ShrinkageTrend struct {
+	Start         *time.Time       `json:"start,omitempty"`
+	End           *time.Time       `json:"end,omitempty"`
+	Points        []ShrinkagePoint `json:"points"`
+	Months        []ShrinkageMonth `json:"months"`
+	ShrinkageCost float64          `json:"shrinkageCost"`
+	ShrinkageSale float64          `json:"shrinkageSale"`
+	NetCost       float64          `json:"netCost"`
+	NetSale       float64          `json:"netSale"`
+}
+
+// OpnameVariance values every difference of an opname at cost and at sale price.
+// Opnames posted through a session also name their counters, reviewer and approver.
+func (s *ReportService) OpnameVariance(ctx context.Context, opnameID string) (*OpnameVarianceReport, error) {
+	opnames := s.store.StockOpnameRepository()
+	opname, err := opnames.Get(ctx, opnameID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrStockOpnameNotFound, opnameID)
+		}
+		return nil, err
+	}
+	report := &OpnameVarianceReport{
+		OpnameID:    opname.ID,
+		LocationID:  opname.LocationID,
+		Note:        opname.Note,
+		PerformedBy: opname.PerformedBy,
+		PerformedAt: opname.PerformedAt,
+		Counters:    []string{},
+		Lines:       make([]OpnameVarianceLine, 0, len(opname.Items)),
+	}
+	if settings, err := s.store.SettingsRepository().Get(ctx); err == nil {
+		report.Brand = strings.TrimSpace(settings.BrandName)
+	}
+	if report.Brand == "" {
+		report.Brand = "SmartSeller Lite"
+	}
+	if opname.LocationID != "" {
+		if location, err := s.store.LocationRepository().Find(ctx, opname.LocationID); err == nil {
+			report.LocationName = location.Name
+		}
+	}
+	session, err := opnames.SessionByOpname(ctx, opname.ID)
+	switch {
+	case err == nil:
+		report.SessionCode = session.Code
+		report.Counters = session.Counters
+		report.ReviewedBy = session.ReviewedBy
+		report.ApprovedBy = session.ApprovedBy
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	}
+
+	for _, item := range opname.Items {
+		line := OpnameVarianceLine{
+			ProductID:     item.ProductID,
+			SKU:           item.ProductSKU,
+			Name:          item.ProductName,
+			PreviousStock: item.PreviousStock,
+			Counted:       item.Counted,
+			Difference:    item.Difference,
+			UnitCost:      item.UnitCost,
+			SalePrice:     item.SalePrice,
+			CostValue:     float64(item.Difference) * item.UnitCost,
+			SaleValue:     float64(item.Difference) * item.SalePrice,
+		}
+		if line.Difference < 0 {
+			report.LossUnits -= line.Difference
+			report.ShrinkageCost -= line.CostValue
+			report.ShrinkageSale -= line.SaleValue
+		} else {
+			report.GainUnits += line.Difference
+		}
+		report.NetCost += line.CostValue
+		report.NetSale += line.SaleValue
+		report.Lines = append(report.Lines, line)
+	}
+	return report, nil
+}
+
+// OpnameVarianceCSV renders the variance report as CSV, one row per product followed
+// by the totals.
+func (s *ReportService) OpnameVarianceCSV(ctx context.Context, opnameID string) ([]byte, error) {
+	report, err := s.OpnameVariance(ctx, opnameID)
+	if err != nil {
+		return nil, err
+	}
+	rows := [][]any{{"SKU", "Produk", "Stok Sistem", "Hitungan", "Selisih", "HPP Satuan", "Harga Jual", "Nilai HPP", "Nilai Jual"}}
+	for _, line := range report.Lines {
+		rows = append(rows, []any{line.SKU, line.Name, line.PreviousStock, line.Counted, line.Difference, line.UnitCost, line.SalePrice, line.CostValue, line.SaleValue})
+	}
+	rows = append(rows,
+		[]any{},
+		[]any{"", "Total susut", "", "", -report.LossUnits, "", "", -report.ShrinkageCost, -report.ShrinkageSale},
+		[]any{"", "Total lebih", "", "", report.GainUnits},
+		[]any{"", "Selisih bersih", "", "", report.GainUnits - report.LossUnits, "", "", report.NetCost, report.NetSale},
+		[]any{},
+		[]any{"Opname", report.OpnameID},
+		[]any{"Lokasi", report.LocationName},
+		[]any{"Tanggal", report.PerformedAt.Format(time.RFC3339)},
+		[]any{"Petugas", report.PerformedBy},
+	)
+	if report.SessionCode != "" {
+		rows = append(rows,
+			[]any{"Sesi", report.SessionCode},
+			[]any{"Penghitung", strings.Join(report.Counters, ", ")},
+			[]any{"Peninjau", report.ReviewedBy},
+			[]any{"Penyetuju", report.ApprovedBy},
+		)
+	}
+	return sheet.Write(sheet.FormatCSV, rows)
+}
+
+// OpnameVariancePDF renders the variance report on A4 with room for signatures.
+func (s *ReportService) OpnameVariancePDF(ctx context.Context, opnameID string) ([]byte, error) {
+	report, err := s.OpnameVariance(ctx, opnameID)
+	if err != nil {
+		return nil, err
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(12, 14, 12)
+	pdf.SetAutoPageBreak(true, 14)
+	pdf.AddPage()
+	pageW, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	contentW := pageW - left - right
+
+	pdf.SetFont("Arial", "B", 13)
+	pdf.CellFormat(contentW, 6, report.Brand, "", 1, "L", false, 0, "")
+	pdf.SetFont("Arial", "B", 11)
+	pdf.CellFormat(contentW, 6, "Laporan Selisih Stock Opname", "", 1, "L", false, 0, "")
+	pdf.Ln(1)
+
+	pdf.SetFont("Arial", "", 9)
+	meta := [][2]string{
+		{"Tanggal", report.PerformedAt.Local().Format("02 Jan 2006 15:04")},
+		{"Lokasi", report.LocationName},
+		{"Petugas", report.PerformedBy},
+	}
+	if report.SessionCode != "" {
+		meta = append(meta, [2]string{"Sesi", report.SessionCode}, [2]string{"Penghitung", strings.Join(report.Counters, ", ")})
+	}
+	if report.Note != "" {
+		meta = append(meta, [2]string{"Catatan", report.Note})
+	}
+	for _, m := range meta {
+		pdf.CellFormat(25, 4.5, m[0], "", 0, "L", false, 0, "")
+		pdf.CellFormat(contentW-25, 4.5, fitText(pdf, ": "+m[1], contentW-25), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(3)
+
+	headers := []string{"Produk", "Sistem", "Hitung", "Selisih", "HPP", "Nilai HPP", "Nilai Jual"}
+	widths := []float64{contentW - 6*22, 17, 17, 17, 25, 25, 31}
+	aligns := []string{"L", "R", "R", "R", "R", "R", "R"}
+	drawHeader := func() {
+		pdf.SetFont("Arial", "B", 8)
+		pdf.SetFillColor(235, 235, 235)
+		for i, h := range headers {
+			pdf.CellFormat(widths[i], 6, h, "1", 0, aligns[i], true, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont("Arial", "", 8)
+	}
+	drawHeader()
+	_, pageH := pdf.GetPageSize()
+	for _, line := range report.Lines {
+		if pdf.GetY()+5.5 > pageH-14 {
+			pdf.AddPage()
+			drawHeader()
+		}
+		name := line.Name
+		if line.SKU != "" {
+			name = line.SKU + " - " + name
+		}
+		cells := []string{
+			fitText(pdf, name, widths[0]-2),
+			fmt.Sprintf("%d", line.PreviousStock),
+			fmt.Sprintf("%d", line.Counted),
+			fmt.Sprintf("%+d", line.Difference),
+			formatRupiah(line.UnitCost),
+			formatRupiah(line.CostValue),
+			formatRupiah(line.SaleValue),
+		}
+		for i, c := range cells {
+			pdf.CellFormat(widths[i], 5.5, c, "1", 0, aligns[i], false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	pdf.Ln(3)
+	pdf.SetFont("Arial", "B", 9)
+	totals := [][2]string{
+		{fmt.Sprintf("Susut (%d unit) pada HPP", report.LossUnits), formatRupiah(report.ShrinkageCost)},
+		{fmt.Sprintf("Susut (%d unit) pada harga jual", report.LossUnits), formatRupiah(report.ShrinkageSale)},
+		{fmt.Sprintf("Selisih bersih (%+d unit) pada HPP", report.GainUnits-report.LossUnits), formatRupiah(report.NetCost)},
+		{"Selisih bersih pada harga jual", formatRupiah(report.NetSale)},
+	}
+	for _, t := range totals {
+		pdf.CellFormat(contentW-45, 5, t[0], "", 0, "R", false, 0, "")
+		pdf.CellFormat(45, 5, t[1], "", 1, "R", false, 0, "")
+	}
+
+	if pdf.GetY()+38 > pageH-14 {
+		pdf.AddPage()
+	}
+	pdf.Ln(10)
+	signers := [][2]string{
+		{"Dihitung oleh", strings.Join(report.Counters, ", ")},
+		{"Diperiksa oleh", report.ReviewedBy},
+		{"Disetujui oleh", report.ApprovedBy},
+	}
+	if report.SessionCode == "" {
+		signers[0][1] = report.PerformedBy
+	}
+	colW := contentW / float64(len(signers))
+	y := pdf.GetY()
+	pdf.SetFont("Arial", "", 9)
+	for i, signer := range signers {
+		x := left + float64(i)*colW
+		pdf.SetXY(x, y)
+		pdf.CellFormat(colW, 5, signer[0], "", 0, "C", false, 0, "")
+		pdf.Line(x+8, y+25, x+colW-8, y+25)
+		pdf.SetXY(x, y+26)
+		pdf.CellFormat(colW, 5, fitText(pdf, signer[1], colW-4), "", 0, "C", false, 0, "")
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ShrinkageTrend lists the shrinkage of every opname in the period with monthly totals.
+func (s *ReportService) ShrinkageTrend(ctx context.Context, start, end *time.Time) (*ShrinkageTrend, error) {
+	rows, err := s.store.StockOpnameRepository().ShrinkageTrend(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	trend := &ShrinkageTrend{Start: start, End: end, Points: make([]ShrinkagePoint, 0, len(rows)), Months: make([]ShrinkageMonth, 0)}
+	for _, r := range rows {
+		point := ShrinkagePoint{
+			OpnameID:      r.OpnameID,
+			LocationID:    r.LocationID,
+			PerformedBy:   r.PerformedBy,
+			PerformedAt:   r.PerformedAt,
+			Items:         r.Items,
+			LossUnits:     r.LossUnits,
+			GainUnits:     r.GainUnits,
+			ShrinkageCost: r.LossCost,
+			ShrinkageSale: r.LossSale,
+			NetCost:       r.NetCost,
+			NetSale:       r.NetSale,
+		}
+		trend.Points = append(trend.Points, point)
+		trend.ShrinkageCost += point.ShrinkageCost
+		trend.ShrinkageSale += point.ShrinkageSale
+		trend.NetCost += point.NetCost
+		trend.NetSale += point.NetSale
+
+		month := point.PerformedAt.Format("2006-01")
+		if n := len(trend.Months); n == 0 || trend.Months[n-1].Month != month {
+			trend.Months = append(trend.Months, ShrinkageMonth{Month: month})
+		}
+		m := &trend.Months[len(trend.Months)-1]
+		m.Opnames++
+		m.LossUnits += point.LossUnits
+		m.ShrinkageCost += point.ShrinkageCost
+		m.ShrinkageSale += point.ShrinkageSale
+		m.NetCost += point.NetCost
+		m.NetSale += point.NetSale
+	}
+	return trend, nil
+}
@@ -59,6 +59,7 @@ func (s *ReportService) ExportOrdersCSV(ctx context.Context, filters OrderExport
 		"Order Notes",
 		"Product SKU",
 		"Product Name",
+		"Product Category",
 		"Quantity",
 		"Unit Price",
 		"Item Discount",
@@ -106,6 +107,7 @@ func (s *ReportService) ExportOrdersCSV(ctx context.Context, filters OrderExport
 			itemProfit        sql.NullFloat64
 			productSKU        sql.NullString
 			productName       sql.NullString
+			productCategory   sql.NullString
 		)
 
 		if err := rows.Scan(
@@ -141,6 +143,7 @@ func (s *ReportService) ExportOrdersCSV(ctx context.Context, filters OrderExport
 			&itemProfit,
 			&productSKU,
 			&productName,
+			&productCategory,
 		); err != nil {
 			return nil, fmt.Errorf("scan export row: %w", err)
 		}
@@ -167,6 +170,7 @@ func (s *ReportService) ExportOrdersCSV(ctx context.Context, filters OrderExport
 			valueOrEmpty(orderNotes),
 			valueOrEmpty(productSKU),
 			valueOrEmpty(productName),
+			valueOrEmpty(productCategory),
 			formatInt(quantity),
 			formatFloat(unitPrice),
 			formatFloat(itemDiscount),
@@ -199,7 +203,7 @@ func buildOrderExportQuery(filters OrderExportFilters) (string, []any) {
   o.shipment_service,
   o.shipment_tracking,
   o.shipment_cost,
-  o.discount,
+  o.discount_order,
   o.total,
   o.profit,
   o.notes,
@@ -219,16 +223,18 @@ func buildOrderExportQuery(filters OrderExportFilters) (string, []any) {
   recipient.postal,
   items.quantity,
   items.unit_price,
-  items.discount,
+  items.discount_item,
   items.cost_price,
   items.profit,
   products.sku,
-  products.name
+  products.name,
+  COALESCE(categories.name, products.category)
 FROM orders o
 LEFT JOIN customers buyer ON buyer.id = o.buyer_id
 LEFT JOIN customers recipient ON recipient.id = o.recipient_id
 LEFT JOIN order_items items ON items.order_id = o.id
-LEFT JOIN products products ON products.id = items.product_id`
+LEFT JOIN products products ON products.id = items.product_id
+LEFT JOIN categories categories ON categories.id = products.category_id`
 
 	var conditions []string
 	var args []any
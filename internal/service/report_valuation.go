@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+)
+
+// ProductValuation is the stock on hand of one product valued under the costing method.
+type ProductValuation struct {
+	ProductID     string  `json:"productId"`
+	SKU           string  `json:"sku"`
+	Name          string  `json:"name"`
+	Stock         int     `json:"stock"`
+	UnitCost      float64 `json:"unitCost"`
+	Value         float64 `json:"value"`
+	ListCostValue float64 `json:"listCostValue"`
+}
+
+// InventoryValuation totals the stock value and shows how far it is from valuing the
+// same stock at today's list cost.
+type InventoryValuation struct {
+	Method        string             `json:"method"`
+	TotalUnits    int                `json:"totalUnits"`
+	TotalValue    float64            `json:"totalValue"`
+	ListCostValue float64            `json:"listCostValue"`
+	Items         []ProductValuation `json:"items"`
+}
+
+// InventoryValuation values stock from the remaining cost batches (FIFO) or the moving
+// average cost, depending on the configured costing method.
+func (s *ReportService) InventoryValuation(ctx context.Context) (InventoryValuation, error) {
+	method, valuations, err := s.store.ProductRepository().InventoryValuation(ctx)
+	if err != nil {
+		return InventoryValuation{}, err
+	}
+	result := InventoryValuation{Method: method, Items: make([]ProductValuation, 0, len(valuations))}
+	for _, v := range valuations {
+		if v.Stock == 0 {
+			continue
+		}
+		item := ProductValuation{
+			ProductID:     v.ProductID,
+			SKU:           v.SKU,
+			Name:          v.Name,
+			Stock:         v.Stock,
+			Value:         v.Value,
+			ListCostValue: v.ListCost,
+		}
+		item.UnitCost = v.Value / float64(v.Stock)
+		result.TotalUnits += v.Stock
+		result.TotalValue += v.Value
+		result.ListCostValue += v.ListCost
+		result.Items = append(result.Items, item)
+	}
+	return result, nil
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"smartseller-lite-starter/internal/repo"
+)
+
+const restockThresholdInterval = 24 * time.Hour
+
+// DefaultRestockWindows are the sales windows, in days, used when none are requested.
+var DefaultRestockWindows = []int{7, 30, 90}
+
+// RestockReportFilters overrides the planning settings for one report. Zero lead time
+// and cover days and nil SafetyDays fall back to the settings; empty Windows to
+// DefaultRestockWindows.
+type RestockReportFilters struct {
+	Windows      []int
+	LeadTimeDays int
+	SafetyDays   *int
+	CoverDays    int
+	OnlyReorder  bool
+}
+
+// SalesWindow is the sales rate of one product over one window. Days is shortened for
+// products younger than the window so new products are not diluted.
+type SalesWindow struct {
+	Window    int     `json:"window"`
+	Days      int     `json:"days"`
+	UnitsSold int     `json:"unitsSold"`
+	PerDay    float64 `json:"perDay"`
+}
+
+// RestockSuggestion is the reorder advice for one product. DailyVelocity averages the
+// windows; the reorder point covers lead time plus safety days, and the quantity tops
+// stock and open purchase orders up to the reorder point plus CoverDays of sales.
+type RestockSuggestion struct {
+	ProductID         string        `json:"productId"`
+	SKU               string        `json:"sku"`
+	Name              string        `json:"name"`
+	Category          string        `json:"category"`
+	Stock             int           `json:"stock"`
+	OnOrder           int           `json:"onOrder"`
+	LowStockThreshold int           `json:"lowStockThreshold"`
+	Sales             []SalesWindow `json:"sales"`
+	DailyVelocity     float64       `json:"dailyVelocity"`
+	DaysOfCover       *float64      `json:"daysOfCover"`
+	SafetyStock       int           `json:"safetyStock"`
+	ReorderPoint      int           `json:"reorderPoint"`
+	ReorderQuantity   int           `json:"reorderQuantity"`
+	NeedsReorder      bool          `json:"needsReorder"`
+}
+
+type RestockReport struct {
+	GeneratedAt  time.Time           `json:"generatedAt"`
+	Windows      []int               `json:"windows"`
+	LeadTimeDays int                 `json:"leadTimeDays"`
+	SafetyDays   int                 `json:"safetyDays"`
+	CoverDays    int                 `json:"coverDays"`
+	Items        []RestockSuggestion `json:"items"`
+}
+
+// ThresholdChange records a low stock threshold replaced by the suggested reorder point.
+type ThresholdChange struct {
+	ProductID string `json:"productId"`
+	Name      string `json:"name"`
+	Previous  int    `json:"previous"`
+	Threshold int    `json:"threshold"`
+}
+
+// RestockService turns recent sales into reorder suggestions and, when enabled in the
+// settings, keeps low stock thresholds in line with them.
+type RestockService struct {
+	repo     *repo.ProductRepository
+	settings *SettingsService
+}
+
+func NewRestockService(repo *repo.ProductRepository, settings *SettingsService) *RestockService {
+	return &RestockService{repo: repo, settings: settings}
+}
+
+// Start refreshes thresholds from the suggestions once a day while the automatic
+// threshold setting is on.
+func (s *RestockService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(restockThresholdInterval)
+		defer ticker.Stop()
+		for {
+			settings, err := s.settings.Get(ctx)
+			if err == nil && settings.RestockAutoThreshold != nil && *settings.RestockAutoThreshold {
+				changes, err := s.ApplyThresholds(ctx, nil)
+				switch {
+				case err != nil && !errors.Is(err, context.Canceled):
+					log.Printf("restock thresholds: %v", err)
+				case len(changes) > 0:
+					log.Printf("restock thresholds: updated %d products", len(changes))
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Report computes the suggestions for every active stock-keeping product, those that
+// need reordering first and then by how soon they run out.
+func (s *RestockService) Report(ctx context.Context, filters RestockReportFilters) (*RestockReport, error) {
+	settings, err := s.settings.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &RestockReport{
+		GeneratedAt:  time.Now().UTC(),
+		Windows:      filters.Windows,
+		LeadTimeDays: firstPositive(filters.LeadTimeDays, settings.RestockLeadTimeDays),
+		SafetyDays:   firstSet(filters.SafetyDays, settings.RestockSafetyDays),
+		CoverDays:    firstPositive(filters.CoverDays, settings.RestockCoverDays),
+		Items:        make([]RestockSuggestion, 0),
+	}
+	if len(report.Windows) == 0 {
+		report.Windows = DefaultRestockWindows
+	}
+	for _, window := range report.Windows {
+		if window <= 0 || window > 3650 {
+			return nil, fmt.Errorf("jendela penjualan %d hari tidak valid", window)
+		}
+	}
+
+	positions, err := s.repo.RestockPositions(ctx, report.Windows)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range positions {
+		item := suggestRestock(p, report)
+		if filters.OnlyReorder && !item.NeedsReorder {
+			continue
+		}
+		report.Items = append(report.Items, item)
+	}
+	sort.SliceStable(report.Items, func(i, j int) bool {
+		a, b := report.Items[i], report.Items[j]
+		if a.NeedsReorder != b.NeedsReorder {
+			return a.NeedsReorder
+		}
+		if (a.DaysOfCover == nil) != (b.DaysOfCover == nil) {
+			return a.DaysOfCover != nil
+		}
+		return a.DaysOfCover != nil && *a.DaysOfCover < *b.DaysOfCover
+	})
+	return report, nil
+}
+
+func suggestRestock(p repo.RestockPosition, report *RestockReport) RestockSuggestion {
+	item := RestockSuggestion{
+		ProductID:         p.ProductID,
+		SKU:               p.SKU,
+		Name:              p.Name,
+		Category:          p.Category,
+		Stock:             p.Stock,
+		OnOrder:           p.OnOrder,
+		LowStockThreshold: p.LowStockThreshold,
+		Sales:             make([]SalesWindow, 0, len(report.Windows)),
+	}
+	age := int(math.Ceil(report.GeneratedAt.Sub(p.CreatedAt).Hours() / 24))
+	for i, window := range report.Windows {
+		days := window
+		if !p.CreatedAt.IsZero() && age < days {
+			days = max(age, 1)
+		}
+		sales := SalesWindow{Window: window, Days: days, UnitsSold: p.Sold[i], PerDay: float64(p.Sold[i]) / float64(days)}
+		item.Sales = append(item.Sales, sales)
+		item.DailyVelocity += sales.PerDay
+	}
+	if len(item.Sales) > 0 {
+		item.DailyVelocity /= float64(len(item.Sales))
+	}
+	if item.DailyVelocity <= 0 {
+		return item
+	}
+
+	cover := float64(p.Stock) / item.DailyVelocity
+	item.DaysOfCover = &cover
+	item.SafetyStock = int(math.Ceil(item.DailyVelocity * float64(report.SafetyDays)))
+	item.ReorderPoint = int(math.Ceil(item.DailyVelocity * float64(report.LeadTimeDays+report.SafetyDays)))
+	available := p.Stock + p.OnOrder
+	item.NeedsReorder = available <= item.ReorderPoint
+	if item.NeedsReorder {
+		target := item.ReorderPoint + int(math.Ceil(item.DailyVelocity*float64(report.CoverDays)))
+		item.ReorderQuantity = max(target-available, 0)
+	}
+	return item
+}
+
+// ApplyThresholds sets the low stock threshold of the given products, or of every
+// product when ids is empty, to its suggested reorder point. Products without recent
+// sales keep their threshold.
+func (s *RestockService) ApplyThresholds(ctx context.Context, ids []string) ([]ThresholdChange, error) {
+	report, err := s.Report(ctx, RestockReportFilters{})
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	changes := make([]ThresholdChange, 0)
+	thresholds := make(map[string]int)
+	for _, item := range report.Items {
+		if len(wanted) > 0 && !wanted[item.ProductID] {
+			continue
+		}
+		if item.DailyVelocity <= 0 || item.ReorderPoint <= 0 || item.ReorderPoint == item.LowStockThreshold {
+			continue
+		}
+		thresholds[item.ProductID] = item.ReorderPoint
+		changes = append(changes, ThresholdChange{ProductID: item.ProductID, Name: item.Name, Previous: item.LowStockThreshold, Threshold: item.ReorderPoint})
+	}
+	if err := s.repo.UpdateLowStockThresholds(ctx, thresholds); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// firstSet returns the first non-nil value; unlike firstPositive, 0 counts.
+func firstSet(values ...*int) int {
+	for _, v := range values {
+		if v != nil {
+			return *v
+		}
+	}
+	return 0
+}
+
+func firstPositive(values ...int) int {
+	for _, v := range values {
+		if v > 0 {
+			return v
+		}
+	}
+	return 0
+}
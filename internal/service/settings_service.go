@@ -3,11 +3,14 @@ package service
 import (
 	"context"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
 
 	"smartseller-lite-starter/internal/domain"
+	"smartseller-lite-starter/internal/events"
 	"smartseller-lite-starter/internal/media"
 	"smartseller-lite-starter/internal/repo"
 )
@@ -16,10 +19,11 @@ type SettingsService struct {
 	repo         *repo.SettingsRepository
 	defaultBrand string
 	media        *media.Manager
+	events       *events.Bus
 }
 
-func NewSettingsService(repo *repo.SettingsRepository, defaultBrand string, mediaManager *media.Manager) *SettingsService {
-	return &SettingsService{repo: repo, defaultBrand: defaultBrand, media: mediaManager}
+func NewSettingsService(repo *repo.SettingsRepository, defaultBrand string, mediaManager *media.Manager, bus *events.Bus) *SettingsService {
+	return &SettingsService{repo: repo, defaultBrand: defaultBrand, media: mediaManager, events: bus}
 }
 
 func (s *SettingsService) Warm(ctx context.Context) {
@@ -43,6 +47,34 @@ func (s *SettingsService) Update(ctx context.Context, payload domain.AppSettings
 		payload.BrandName = s.defaultBrand
 	}
 	current, _ := s.repo.Get(ctx)
+	payload.CostingMethod = strings.ToLower(strings.TrimSpace(payload.CostingMethod))
+	switch payload.CostingMethod {
+	case domain.CostingFIFO, domain.CostingAverage:
+	case "":
+		payload.CostingMethod = domain.CostingFIFO
+		if current != nil {
+			payload.CostingMethod = current.CostingMethod
+		}
+	default:
+		return nil, fmt.Errorf("metode costing %q tidak dikenal; gunakan fifo atau average", payload.CostingMethod)
+	}
+	if payload.RestockLeadTimeDays < 0 || (payload.RestockSafetyDays != nil && *payload.RestockSafetyDays < 0) || payload.RestockCoverDays < 0 {
+		return nil, errors.New("lead time, stok pengaman, dan periode cakupan restock tidak boleh negatif")
+	}
+	if current != nil {
+		if payload.RestockLeadTimeDays == 0 {
+			payload.RestockLeadTimeDays = current.RestockLeadTimeDays
+		}
+		if payload.RestockSafetyDays == nil {
+			payload.RestockSafetyDays = current.RestockSafetyDays
+		}
+		if payload.RestockCoverDays == 0 {
+			payload.RestockCoverDays = current.RestockCoverDays
+		}
+		if payload.RestockAutoThreshold == nil {
+			payload.RestockAutoThreshold = current.RestockAutoThreshold
+		}
+	}
 	payload.LogoData = strings.TrimSpace(payload.LogoData)
 	if payload.LogoData != "" && s.media != nil {
 		asset, err := s.media.SaveLogo(ctx, payload.LogoData)
@@ -96,6 +128,7 @@ func (s *SettingsService) Update(ctx context.Context, payload domain.AppSettings
 		return nil, err
 	}
 	s.decorate(saved)
+	s.events.Publish(ctx, events.SettingsUpdated, saved)
 	return saved, nil
 }
 
@@ -104,6 +137,9 @@ func (s *SettingsService) ReplaceAll(ctx context.Context, payload domain.AppSett
 		payload.BrandName = s.defaultBrand
 	}
 	payload.LogoData = ""
+	if payload.CostingMethod != domain.CostingAverage {
+		payload.CostingMethod = domain.CostingFIFO
+	}
 	return s.repo.ReplaceAll(ctx, payload)
 }
 
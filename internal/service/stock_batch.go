@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"smartseller-lite-starter/internal/domain"
+	"smartseller-lite-starter/internal/repo"
+)
+
+// receiveStockReason is the mutation reason for stock received by hand.
+const receiveStockReason = "receive"
+
+// ReceiveStockInput records a purchase of Quantity units at UnitCost each. Perishable
+// goods carry the lot number and expiry date printed on them.
+type ReceiveStockInput struct {
+	Quantity   int        `json:"quantity"`
+	UnitCost   float64    `json:"unitCost"`
+	Reference  string     `json:"reference"`
+	Note       string     `json:"note"`
+	ReceivedAt *time.Time `json:"receivedAt"`
+	LotNumber  string     `json:"lotNumber"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+}
+
+// ReceiveStock adds stock as a new cost batch. The reference becomes the mutation
+// reason and defaults to "receive". Lots that have already expired are refused.
+func (s *ProductService) ReceiveStock(ctx context.Context, productID string, input ReceiveStockInput) (*domain.Product, error) {
+	if input.Quantity <= 0 {
+		return nil, errors.New("jumlah penerimaan harus lebih dari 0")
+	}
+	if input.UnitCost < 0 {
+		return nil, errors.New("harga modal tidak boleh negatif")
+	}
+	move := repo.StockMove{
+		ProductID: productID,
+		Delta:     input.Quantity,
+		Reason:    strings.TrimSpace(input.Reference),
+		UnitCost:  &input.UnitCost,
+		Note:      strings.TrimSpace(input.Note),
+		LotNumber: strings.TrimSpace(input.LotNumber),
+		ExpiresAt: input.ExpiresAt,
+	}
+	if len(move.LotNumber) > 64 {
+		return nil, errors.New("nomor lot maksimal 64 karakter")
+	}
+	if move.ExpiresAt != nil && !move.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("tanggal kedaluwarsa sudah lewat; barang kedaluwarsa tidak dapat diterima")
+	}
+	if move.Reason == "" {
+		move.Reason = receiveStockReason
+	}
+	if input.ReceivedAt != nil {
+		move.ReceivedAt = *input.ReceivedAt
+	}
+	if _, err := s.MoveStock(ctx, move); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, productID)
+}
+
+// ListBatches returns the cost batches of a product, newest first.
+func (s *ProductService) ListBatches(ctx context.Context, productID string, includeDepleted bool) ([]domain.StockBatch, error) {
+	if strings.TrimSpace(productID) == "" {
+		return nil, errors.New("product id required")
+	}
+	return s.repo.ListBatches(ctx, productID, includeDepleted)
+}
+
+// ExpiringLots lists lots with stock left that expire within the given number of days,
+// expired ones first.
+func (s *ProductService) ExpiringLots(ctx context.Context, withinDays int) ([]domain.ExpiringLot, error) {
+	if withinDays <= 0 {
+		withinDays = repo.ExpiryWarningDays
+	}
+	return s.repo.ExpiringLots(ctx, time.Now().UTC().AddDate(0, 0, withinDays), 0)
+}
@@ -2,16 +2,23 @@ package service
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 
 	"smartseller-lite-starter/internal/domain"
+	"smartseller-lite-starter/internal/events"
 	"smartseller-lite-starter/internal/repo"
 )
 
+// ErrStockOpnameNotFound is returned when an opname id matches nothing.
+var ErrStockOpnameNotFound = errors.New("stock opname tidak ditemukan")
+
 // PerformStockOpnameItem describes a counted product during stock take.
 type PerformStockOpnameItem struct {
 	ProductID string `json:"productId"`
@@ -19,27 +26,46 @@ type PerformStockOpnameItem struct {
 }
 
 // PerformStockOpnameInput is the payload accepted by the stock opname workflow.
+// LocationID names the location counted; empty means the default location.
 type PerformStockOpnameInput struct {
-	Note  string                   `json:"note"`
-	User  string                   `json:"user"`
-	Items []PerformStockOpnameItem `json:"items"`
+	LocationID string                   `json:"locationId"`
+	Note       string                   `json:"note"`
+	User       string                   `json:"user"`
+	Items      []PerformStockOpnameItem `json:"items"`
 }
 
 // StockOpnameService coordinates stock taking sessions and resulting adjustments.
 type StockOpnameService struct {
-	repo     *repo.StockOpnameRepository
-	products *ProductService
+	repo      *repo.StockOpnameRepository
+	products  *ProductService
+	locations *LocationService
+	events    *events.Bus
+
+	scanMu sync.Mutex
+	scans  map[string]*domain.OpnameScanSession
+
+	// sessionMu keeps approvals and cancellations of opname sessions from overlapping.
+	sessionMu sync.Mutex
 }
 
-func NewStockOpnameService(repo *repo.StockOpnameRepository, products *ProductService) *StockOpnameService {
-	return &StockOpnameService{repo: repo, products: products}
+func NewStockOpnameService(repo *repo.StockOpnameRepository, products *ProductService, locations *LocationService, bus *events.Bus) *StockOpnameService {
+	return &StockOpnameService{repo: repo, products: products, locations: locations, events: bus, scans: make(map[string]*domain.OpnameScanSession)}
 }
 
 func (s *StockOpnameService) Warm(ctx context.Context) {
-	_, _ = s.repo.List(ctx, 5)
+	_, _ = s.repo.ListPaged(ctx, repo.StockOpnameListOptions{PageSize: 5})
 }
 
+// Perform records a stock take and brings stock at the location to the counted
+// quantities. Reading the stock, saving the opname and posting its adjustments happen
+// in one transaction, so either all of it applies or none does.
 func (s *StockOpnameService) Perform(ctx context.Context, input PerformStockOpnameInput) (*domain.StockOpname, error) {
+	return s.perform(ctx, input, "")
+}
+
+// perform is Perform; with a session id the opname applies that session's counts and
+// marks the session applied in the same transaction.
+func (s *StockOpnameService) perform(ctx context.Context, input PerformStockOpnameInput, sessionID string) (*domain.StockOpname, error) {
 	if len(input.Items) == 0 {
 		return nil, fmt.Errorf("stock opname requires at least one item")
 	}
@@ -47,7 +73,11 @@ func (s *StockOpnameService) Perform(ctx context.Context, input PerformStockOpna
 	if actor == "" {
 		return nil, fmt.Errorf("nama petugas opname wajib diisi")
 	}
-	opname := &domain.StockOpname{ID: uuid.New().String(), Note: strings.TrimSpace(input.Note), PerformedBy: actor}
+	location, err := s.locations.Resolve(ctx, input.LocationID)
+	if err != nil {
+		return nil, err
+	}
+	opname := &domain.StockOpname{ID: uuid.New().String(), LocationID: location.ID, Note: strings.TrimSpace(input.Note), PerformedBy: actor}
 	opname.PerformedAt = time.Now().UTC()
 
 	for _, entry := range input.Items {
@@ -57,50 +87,100 @@ func (s *StockOpnameService) Perform(ctx context.Context, input PerformStockOpna
 		if entry.Counted < 0 {
 			return nil, fmt.Errorf("counted stock cannot be negative")
 		}
-		product, err := s.products.Get(ctx, entry.ProductID)
-		if err != nil {
-			return nil, err
-		}
-		diff := entry.Counted - product.Stock
-		opname.Items = append(opname.Items, domain.StockOpnameItem{
-			ProductID:     product.ID,
-			ProductName:   product.Name,
-			ProductSKU:    product.SKU,
-			Counted:       entry.Counted,
-			PreviousStock: product.Stock,
-			Difference:    diff,
-		})
+		opname.Items = append(opname.Items, domain.StockOpnameItem{ProductID: entry.ProductID, Counted: entry.Counted})
 	}
 
-	saved, err := s.repo.Create(ctx, opname)
+	var adjustments []*repo.StockAdjustment
+	if sessionID != "" {
+		adjustments, err = s.repo.ApplySession(ctx, opname, sessionID, actor)
+	} else {
+		adjustments, err = s.repo.Apply(ctx, opname)
+	}
 	if err != nil {
 		return nil, err
 	}
+	for _, adj := range adjustments {
+		s.products.publishStockChange(ctx, adj)
+	}
+	s.events.Publish(ctx, events.OpnamePerformed, opname)
+	return opname, nil
+}
 
-	for _, item := range saved.Items {
-		if item.Difference == 0 {
-			continue
-		}
-		reason := fmt.Sprintf("stock_opname:%s", saved.ID)
-		if err := s.products.AdjustStock(ctx, item.ProductID, item.Difference, reason); err != nil {
-			return nil, err
+// StockOpnameListOptions filters opname history. Dates are whole days; PerformedBy
+// matches part of the performer name.
+type StockOpnameListOptions struct {
+	DateStart   *time.Time
+	DateEnd     *time.Time
+	PerformedBy string
+	ProductID   string
+	LocationID  string
+	Page        int
+	PageSize    int
+}
+
+type StockOpnameListResult struct {
+	Items    []domain.StockOpnameSummary `json:"items"`
+	Total    int                         `json:"total"`
+	Page     int                         `json:"page"`
+	PageSize int                         `json:"pageSize"`
+}
+
+type ProductOpnameListResult struct {
+	Items    []domain.ProductOpnameEntry `json:"items"`
+	Total    int                         `json:"total"`
+	Page     int                         `json:"page"`
+	PageSize int                         `json:"pageSize"`
+}
+
+// List pages through opname history, newest first. Items are left out; fetch a single
+// opname for them.
+func (s *StockOpnameService) List(ctx context.Context, opts StockOpnameListOptions) (StockOpnameListResult, error) {
+	if opts.LocationID != "" {
+		location, err := s.locations.Resolve(ctx, opts.LocationID)
+		if err != nil {
+			return StockOpnameListResult{}, err
 		}
+		opts.LocationID = location.ID
+	}
+	result, err := s.repo.ListPaged(ctx, repo.StockOpnameListOptions{
+		DateStart:   opts.DateStart,
+		DateEnd:     opts.DateEnd,
+		PerformedBy: opts.PerformedBy,
+		ProductID:   opts.ProductID,
+		LocationID:  opts.LocationID,
+		Page:        opts.Page,
+		PageSize:    opts.PageSize,
+	})
+	if err != nil {
+		return StockOpnameListResult{}, err
 	}
+	return StockOpnameListResult{Items: result.Items, Total: result.Total, Page: result.Page, PageSize: result.PageSize}, nil
+}
 
-	// Refresh item details after adjustments to reflect latest stock in the response.
-	for idx, item := range saved.Items {
-		product, err := s.products.Get(ctx, item.ProductID)
-		if err == nil {
-			saved.Items[idx].ProductName = product.Name
-			saved.Items[idx].ProductSKU = product.SKU
+// ListForProduct pages through every opname that counted a product, newest first.
+func (s *StockOpnameService) ListForProduct(ctx context.Context, productID string, page, pageSize int) (ProductOpnameListResult, error) {
+	if _, err := s.products.Get(ctx, productID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ProductOpnameListResult{}, fmt.Errorf("%w: %s", ErrProductNotFound, productID)
 		}
+		return ProductOpnameListResult{}, err
 	}
-
-	return saved, nil
+	result, err := s.repo.ListForProduct(ctx, productID, page, pageSize)
+	if err != nil {
+		return ProductOpnameListResult{}, err
+	}
+	return ProductOpnameListResult{Items: result.Items, Total: result.Total, Page: result.Page, PageSize: result.PageSize}, nil
 }
 
-func (s *StockOpnameService) List(ctx context.Context, limit int) ([]domain.StockOpname, error) {
-	return s.repo.List(ctx, limit)
+func (s *StockOpnameService) Get(ctx context.Context, id string) (*domain.StockOpname, error) {
+	opname, err := s.repo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrStockOpnameNotFound, id)
+		}
+		return nil, err
+	}
+	return opname, nil
 }
 
 func (s *StockOpnameService) ListAll(ctx context.Context) ([]domain.StockOpname, error) {
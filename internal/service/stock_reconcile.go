@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"smartseller-lite-starter/internal/events"
+	"smartseller-lite-starter/internal/repo"
+)
+
+const defaultReconcileInterval = 24 * time.Hour
+
+// OrderStockDrift is one order whose stock movements do not match its lines. Missing
+// is how many units the order should still take; negative when too many were taken,
+// as happens when a deleted order never got its stock back.
+type OrderStockDrift struct {
+	OrderID    string `json:"orderId,omitempty"`
+	OrderCode  string `json:"orderCode"`
+	LocationID string `json:"locationId,omitempty"`
+	Deleted    bool   `json:"deleted"`
+	Missing    int    `json:"missing"`
+}
+
+// StockDiscrepancy reports a product whose stock disagrees with its ledger or orders.
+// LedgerDrift is on-hand stock minus the ledger balance; OrderDrift is the units orders
+// should have taken but did not, summed over Orders.
+type StockDiscrepancy struct {
+	ProductID     string            `json:"productId"`
+	Name          string            `json:"name"`
+	SKU           string            `json:"sku"`
+	Stock         int               `json:"stock"`
+	LedgerBalance int               `json:"ledgerBalance"`
+	LedgerDrift   int               `json:"ledgerDrift"`
+	OrderedUnits  int               `json:"orderedUnits"`
+	LedgerUnits   int               `json:"ledgerUnits"`
+	OrderDrift    int               `json:"orderDrift"`
+	Orders        []OrderStockDrift `json:"orders"`
+}
+
+type StockReconciliation struct {
+	CheckedAt       time.Time          `json:"checkedAt"`
+	ProductsChecked int                `json:"productsChecked"`
+	Discrepancies   []StockDiscrepancy `json:"discrepancies"`
+}
+
+// ReconcileConfirmation repeats the drift the operator reviewed. A product is only
+// corrected while its drift is still exactly this.
+type ReconcileConfirmation struct {
+	ProductID   string `json:"productId"`
+	LedgerDrift int    `json:"ledgerDrift"`
+	OrderDrift  int    `json:"orderDrift"`
+}
+
+type ApplyReconciliationInput struct {
+	Items []ReconcileConfirmation `json:"items"`
+}
+
+type ReconcileSkip struct {
+	ProductID string `json:"productId"`
+	Reason    string `json:"reason"`
+}
+
+type ReconciliationResult struct {
+	Corrected []string            `json:"corrected"`
+	Skipped   []ReconcileSkip     `json:"skipped"`
+	Report    StockReconciliation `json:"report"`
+}
+
+// StockReconcileService checks products.stock against the mutation ledger and the
+// orders that moved it, and posts corrective mutations once an operator confirms them.
+type StockReconcileService struct {
+	repo     *repo.ProductRepository
+	products *ProductService
+	events   *events.Bus
+	interval time.Duration
+
+	mu     sync.Mutex
+	latest *StockReconciliation
+}
+
+func NewStockReconcileService(repo *repo.ProductRepository, products *ProductService, bus *events.Bus, interval time.Duration) *StockReconcileService {
+	if interval <= 0 {
+		interval = defaultReconcileInterval
+	}
+	return &StockReconcileService{repo: repo, products: products, events: bus, interval: interval}
+}
+
+// Start runs the check in the background, publishing stock.drift whenever it finds
+// discrepancies. It never corrects anything by itself.
+func (s *StockReconcileService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			report, err := s.Check(ctx)
+			switch {
+			case err != nil && !errors.Is(err, context.Canceled):
+				log.Printf("stock reconciliation: %v", err)
+			case err == nil && len(report.Discrepancies) > 0:
+				ids := make([]string, 0, len(report.Discrepancies))
+				for _, d := range report.Discrepancies {
+					ids = append(ids, d.ProductID)
+				}
+				s.events.Publish(ctx, events.StockDrift, events.StockDriftData{CheckedAt: report.CheckedAt, Products: len(ids), ProductIDs: ids})
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Latest returns the most recent check, or nil before the first one has run.
+func (s *StockReconcileService) Latest() *StockReconciliation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latest
+}
+
+// Check compares every stock-keeping product with its ledger and with the orders that
+// should have moved it. Orders older than the ledger and without any movement, such as
+// orders restored from a backup, are left out.
+func (s *StockReconcileService) Check(ctx context.Context) (*StockReconciliation, error) {
+	positions, err := s.repo.LedgerPositions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := s.repo.OrderStockLines(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ledger, err := s.repo.OrderLedgerLines(ctx)
+	if err != nil {
+		return nil, err
+	}
+	epoch, err := s.repo.LedgerEpoch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct{ code, productID string }
+	taken := make(map[key]int)
+	ledgered := make(map[string]bool)
+	for _, l := range ledger {
+		taken[key{l.OrderCode, l.ProductID}] -= l.Delta
+		ledgered[l.OrderCode] = true
+	}
+
+	orderDrifts := make(map[string][]OrderStockDrift)
+	ordered := make(map[string]int)
+	accounted := make(map[string]int)
+	existing := make(map[key]bool)
+	for _, line := range lines {
+		k := key{line.OrderCode, line.ProductID}
+		existing[k] = true
+		if !ledgered[line.OrderCode] && (epoch == nil || line.CreatedAt.Before(*epoch)) {
+			continue
+		}
+		ordered[line.ProductID] += line.Quantity
+		accounted[line.ProductID] += taken[k]
+		if missing := line.Quantity - taken[k]; missing != 0 {
+			orderDrifts[line.ProductID] = append(orderDrifts[line.ProductID], OrderStockDrift{
+				OrderID:    line.OrderID,
+				OrderCode:  line.OrderCode,
+				LocationID: line.LocationID,
+				Missing:    missing,
+			})
+		}
+	}
+	for k, units := range taken {
+		if existing[k] || units == 0 {
+			continue
+		}
+		accounted[k.productID] += units
+		orderDrifts[k.productID] = append(orderDrifts[k.productID], OrderStockDrift{OrderCode: k.code, Deleted: true, Missing: -units})
+	}
+
+	report := &StockReconciliation{CheckedAt: time.Now().UTC(), ProductsChecked: len(positions), Discrepancies: make([]StockDiscrepancy, 0)}
+	for _, p := range positions {
+		d := StockDiscrepancy{
+			ProductID:     p.ProductID,
+			Name:          p.Name,
+			SKU:           p.SKU,
+			Stock:         p.Stock,
+			LedgerBalance: p.LedgerBalance,
+			LedgerDrift:   p.Stock - p.LedgerBalance,
+			OrderedUnits:  ordered[p.ProductID],
+			LedgerUnits:   accounted[p.ProductID],
+			Orders:        orderDrifts[p.ProductID],
+		}
+		for _, o := range d.Orders {
+			d.OrderDrift += o.Missing
+		}
+		if d.LedgerDrift == 0 && len(d.Orders) == 0 {
+			continue
+		}
+		if d.Orders == nil {
+			d.Orders = []OrderStockDrift{}
+		}
+		sort.Slice(d.Orders, func(i, j int) bool { return d.Orders[i].OrderCode < d.Orders[j].OrderCode })
+		report.Discrepancies = append(report.Discrepancies, d)
+	}
+
+	s.mu.Lock()
+	s.latest = report
+	s.mu.Unlock()
+	return report, nil
+}
+
+// Apply corrects the confirmed products. Orders that took too little or too much get
+// a real stock movement with reason reconcile:order:<code>; what remains between stock
+// and ledger is recorded as a reconcile:ledger entry that leaves the stock as it is.
+// Products whose drift changed since it was confirmed are skipped.
+func (s *StockReconcileService) Apply(ctx context.Context, input ApplyReconciliationInput) (*ReconciliationResult, error) {
+	if len(input.Items) == 0 {
+		return nil, errors.New("pilih minimal satu produk untuk diperbaiki")
+	}
+	report, err := s.Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]StockDiscrepancy, len(report.Discrepancies))
+	for _, d := range report.Discrepancies {
+		current[d.ProductID] = d
+	}
+
+	result := &ReconciliationResult{Corrected: []string{}, Skipped: []ReconcileSkip{}}
+	for _, confirmed := range input.Items {
+		d, ok := current[confirmed.ProductID]
+		if !ok {
+			result.Skipped = append(result.Skipped, ReconcileSkip{ProductID: confirmed.ProductID, Reason: "tidak ada selisih"})
+			continue
+		}
+		if d.LedgerDrift != confirmed.LedgerDrift || d.OrderDrift != confirmed.OrderDrift {
+			result.Skipped = append(result.Skipped, ReconcileSkip{ProductID: confirmed.ProductID, Reason: "selisih berubah sejak diperiksa; periksa ulang"})
+			continue
+		}
+		if err := s.correct(ctx, d); err != nil {
+			result.Skipped = append(result.Skipped, ReconcileSkip{ProductID: confirmed.ProductID, Reason: err.Error()})
+			continue
+		}
+		result.Corrected = append(result.Corrected, confirmed.ProductID)
+	}
+
+	report, err = s.Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result.Report = *report
+	return result, nil
+}
+
+func (s *StockReconcileService) correct(ctx context.Context, d StockDiscrepancy) error {
+	for _, o := range d.Orders {
+		move := repo.StockMove{
+			ProductID:  d.ProductID,
+			Delta:      -o.Missing,
+			Reason:     repo.ReconcileOrderReasonPrefix + o.OrderCode,
+			LocationID: o.LocationID,
+		}
+		if _, err := s.products.MoveStock(ctx, move); err != nil {
+			return fmt.Errorf("pesanan %s: %w", o.OrderCode, err)
+		}
+	}
+	if d.LedgerDrift != 0 {
+		return s.repo.PostLedgerCorrection(ctx, d.ProductID, d.LedgerDrift)
+	}
+	return nil
+}
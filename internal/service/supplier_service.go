@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"smartseller-lite-starter/internal/domain"
+	"smartseller-lite-starter/internal/repo"
+)
+
+// ErrSupplierNotFound is returned when a supplier id matches nothing.
+var ErrSupplierNotFound = errors.New("pemasok tidak ditemukan")
+
+// SupplierService manages the vendors purchase orders are placed with.
+type SupplierService struct {
+	repo *repo.SupplierRepository
+}
+
+func NewSupplierService(repo *repo.SupplierRepository) *SupplierService {
+	return &SupplierService{repo: repo}
+}
+
+func (s *SupplierService) List(ctx context.Context) ([]domain.Supplier, error) {
+	return s.repo.List(ctx)
+}
+
+func (s *SupplierService) Get(ctx context.Context, id string) (*domain.Supplier, error) {
+	supplier, err := s.repo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrSupplierNotFound, id)
+		}
+		return nil, err
+	}
+	return supplier, nil
+}
+
+// Save creates a supplier, or updates it when ID is set.
+func (s *SupplierService) Save(ctx context.Context, supplier domain.Supplier) (*domain.Supplier, error) {
+	supplier.Name = strings.TrimSpace(supplier.Name)
+	supplier.Contact = strings.TrimSpace(supplier.Contact)
+	supplier.Email = strings.TrimSpace(supplier.Email)
+	supplier.Address = strings.TrimSpace(supplier.Address)
+	supplier.Notes = strings.TrimSpace(supplier.Notes)
+	if supplier.Name == "" {
+		return nil, errors.New("nama pemasok wajib diisi")
+	}
+	if supplier.PaymentTermDays < 0 {
+		return nil, errors.New("tempo pembayaran tidak boleh negatif")
+	}
+	phone, err := normalisePhone(supplier.Phone)
+	if err != nil {
+		return nil, err
+	}
+	supplier.Phone = phone
+
+	if supplier.ID == "" {
+		return s.repo.Create(ctx, &supplier)
+	}
+	if _, err := s.Get(ctx, supplier.ID); err != nil {
+		return nil, err
+	}
+	return s.repo.Update(ctx, &supplier)
+}
+
+func (s *SupplierService) Delete(ctx context.Context, id string) error {
+	if strings.TrimSpace(id) == "" {
+		return errors.New("supplier id required")
+	}
+	return s.repo.Delete(ctx, id)
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"smartseller-lite-starter/internal/domain"
+	"smartseller-lite-starter/internal/repo"
+	"smartseller-lite-starter/internal/tracking"
+)
+
+const (
+	defaultTrackingInterval = 30 * time.Minute
+	trackingBatchSize       = 50
+)
+
+// TrackingService polls courier providers and records shipment checkpoints per order.
+type TrackingService struct {
+	repo      *repo.TrackingRepository
+	providers *tracking.Registry
+	interval  time.Duration
+}
+
+func NewTrackingService(repo *repo.TrackingRepository, providers *tracking.Registry, interval time.Duration) *TrackingService {
+	if interval <= 0 {
+		interval = defaultTrackingInterval
+	}
+	return &TrackingService{repo: repo, providers: providers, interval: interval}
+}
+
+// Start launches the background poller. It returns immediately and stops when ctx is cancelled.
+func (s *TrackingService) Start(ctx context.Context) {
+	if s.providers.Empty() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			if _, err := s.PollOnce(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("tracking poll: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// PollOnce checks one batch of undelivered shipments and returns how many were updated.
+func (s *TrackingService) PollOnce(ctx context.Context) (int, error) {
+	cutoff := time.Now().UTC().Add(-s.interval / 2)
+	candidates, err := s.repo.ListPending(ctx, cutoff, trackingBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	updated := 0
+	for _, candidate := range candidates {
+		if err := ctx.Err(); err != nil {
+			return updated, err
+		}
+		changed, err := s.poll(ctx, candidate)
+		if err != nil {
+			log.Printf("tracking %s (%s %s): %v", candidate.OrderCode, candidate.Courier, candidate.TrackingCode, err)
+			continue
+		}
+		if changed {
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+// Get returns the stored delivery state and checkpoints of an order.
+func (s *TrackingService) Get(ctx context.Context, orderID string) (*domain.ShipmentTracking, error) {
+	if strings.TrimSpace(orderID) == "" {
+		return nil, errors.New("order id required")
+	}
+	return s.repo.Get(ctx, orderID)
+}
+
+// Refresh polls the courier for a single order immediately.
+func (s *TrackingService) Refresh(ctx context.Context, orderID string) (*domain.ShipmentTracking, error) {
+	current, err := s.Get(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(current.TrackingCode) == "" {
+		return nil, errors.New("order belum memiliki nomor resi")
+	}
+	candidate := repo.TrackingCandidate{
+		OrderID:      current.OrderID,
+		OrderCode:    current.OrderCode,
+		Courier:      current.Courier,
+		TrackingCode: current.TrackingCode,
+		Status:       current.Status,
+	}
+	if _, err := s.poll(ctx, candidate); err != nil {
+		return nil, err
+	}
+	return s.repo.Get(ctx, orderID)
+}
+
+func (s *TrackingService) poll(ctx context.Context, candidate repo.TrackingCandidate) (bool, error) {
+	provider, ok := s.providers.Lookup(candidate.Courier)
+	if !ok {
+		return false, fmt.Errorf("no tracking provider for courier %q", candidate.Courier)
+	}
+
+	result, err := provider.Track(ctx, tracking.Request{CourierCode: candidate.Courier, TrackingCode: candidate.TrackingCode})
+	if err != nil {
+		_ = s.repo.MarkChecked(ctx, candidate.OrderID, time.Now())
+		if errors.Is(err, tracking.ErrNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s provider: %w", provider.Name(), err)
+	}
+
+	status := result.Status
+	if status == "" {
+		status = tracking.LatestStatus(result.Events)
+	}
+	inserted, err := s.repo.SaveResult(ctx, candidate.OrderID, status, result.Events, time.Now())
+	if err != nil {
+		return false, err
+	}
+	changed := status != domain.ShipmentStatusPending && status != candidate.Status
+	return inserted > 0 || changed, nil
+}
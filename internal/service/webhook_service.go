@@ -0,0 +1,355 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"smartseller-lite-starter/internal/domain"
+	"smartseller-lite-starter/internal/events"
+	"smartseller-lite-starter/internal/repo"
+)
+
+const (
+	webhookPollInterval = 15 * time.Second
+	webhookBatchSize    = 20
+	webhookMaxAttempts  = 8
+	webhookBaseBackoff  = 30 * time.Second
+	webhookMaxBackoff   = 6 * time.Hour
+)
+
+// WebhookService turns domain events into signed HTTP deliveries through a persisted outbox.
+//
+// Each request carries the event envelope as JSON plus these headers:
+//
+//	X-SmartSeller-Event:     event type, e.g. order.created
+//	X-SmartSeller-Delivery:  outbox entry id (stable across retries)
+//	X-SmartSeller-Timestamp: unix seconds of the attempt
+//	X-SmartSeller-Signature: sha256=<hex HMAC-SHA256 of "<timestamp>.<body>" keyed by the subscription secret>
+type WebhookService struct {
+	repo   *repo.WebhookRepository
+	client *http.Client
+	wake   chan struct{}
+
+	// subs caches the subscriptions for enqueue, which runs on every event; nil means
+	// they must be loaded again.
+	subsMu sync.Mutex
+	subs   []domain.WebhookSubscription
+}
+
+func NewWebhookService(repo *repo.WebhookRepository, bus *events.Bus) *WebhookService {
+	s := &WebhookService{
+		repo:   repo,
+		client: &http.Client{Timeout: 10 * time.Second},
+		wake:   make(chan struct{}, 1),
+	}
+	bus.Subscribe(s.enqueue)
+	return s
+}
+
+// EventTypes lists the events a subscription can select.
+func (s *WebhookService) EventTypes() []string {
+	types := events.Types()
+	names := make([]string, 0, len(types))
+	for _, t := range types {
+		names = append(names, string(t))
+	}
+	return names
+}
+
+func (s *WebhookService) List(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	return s.repo.ListSubscriptions(ctx)
+}
+
+func (s *WebhookService) Save(ctx context.Context, sub domain.WebhookSubscription) (*domain.WebhookSubscription, error) {
+	sub.Name = strings.TrimSpace(sub.Name)
+	sub.URL = strings.TrimSpace(sub.URL)
+	sub.Secret = strings.TrimSpace(sub.Secret)
+	if sub.URL == "" {
+		return nil, errors.New("webhook url is required")
+	}
+	parsed, err := url.Parse(sub.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, errors.New("webhook url must be an absolute http(s) URL")
+	}
+	if sub.Name == "" {
+		sub.Name = parsed.Host
+	}
+
+	known := make(map[string]struct{})
+	for _, t := range events.Types() {
+		known[string(t)] = struct{}{}
+	}
+	selected := make([]string, 0, len(sub.Events))
+	for _, name := range sub.Events {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := known[name]; !ok {
+			return nil, fmt.Errorf("unknown webhook event %q", name)
+		}
+		selected = append(selected, name)
+	}
+	sub.Events = selected
+
+	if sub.ID != "" {
+		existing, err := s.repo.GetSubscription(ctx, sub.ID)
+		if err != nil {
+			return nil, err
+		}
+		if sub.Secret == "" {
+			sub.Secret = existing.Secret
+		}
+	}
+	if sub.Secret == "" {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		sub.Secret = secret
+	}
+	sub.SecretSet = true
+	saved, err := s.repo.SaveSubscription(ctx, &sub)
+	s.forgetSubscriptions()
+	return saved, err
+}
+
+func (s *WebhookService) Delete(ctx context.Context, id string) error {
+	if strings.TrimSpace(id) == "" {
+		return errors.New("webhook id required")
+	}
+	err := s.repo.DeleteSubscription(ctx, id)
+	s.forgetSubscriptions()
+	return err
+}
+
+func (s *WebhookService) Deliveries(ctx context.Context, subscriptionID string, limit int) ([]domain.WebhookDelivery, error) {
+	if strings.TrimSpace(subscriptionID) == "" {
+		return nil, errors.New("webhook id required")
+	}
+	return s.repo.ListDeliveries(ctx, subscriptionID, limit)
+}
+
+// Redeliver puts an outbox entry back in the queue regardless of its previous outcome.
+func (s *WebhookService) Redeliver(ctx context.Context, deliveryID string) error {
+	if strings.TrimSpace(deliveryID) == "" {
+		return errors.New("delivery id required")
+	}
+	if err := s.repo.Requeue(ctx, deliveryID); err != nil {
+		return err
+	}
+	s.nudge()
+	return nil
+}
+
+// Start runs the outbox dispatcher until ctx is cancelled. Pending rows left over
+// from a previous run are picked up on the first pass.
+func (s *WebhookService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(webhookPollInterval)
+		defer ticker.Stop()
+		for {
+			s.dispatchDue(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+}
+
+// subscriptions returns the cached subscriptions, loading them on first use.
+func (s *WebhookService) subscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	if s.subs == nil {
+		subs, err := s.repo.ListSubscriptions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.subs = append(make([]domain.WebhookSubscription, 0, len(subs)), subs...)
+	}
+	return s.subs, nil
+}
+
+func (s *WebhookService) forgetSubscriptions() {
+	s.subsMu.Lock()
+	s.subs = nil
+	s.subsMu.Unlock()
+}
+
+// enqueue writes the outbox rows for an event. The event is published after its change
+// committed, so the rows are written even when the request that caused it has ended.
+func (s *WebhookService) enqueue(ctx context.Context, event events.Event) {
+	ctx = context.WithoutCancel(ctx)
+	subs, err := s.subscriptions(ctx)
+	if err != nil {
+		log.Printf("webhook enqueue %s: %v", event.Type, err)
+		return
+	}
+	var payload []byte
+	deliveries := make([]domain.WebhookDelivery, 0)
+	for _, sub := range subs {
+		if !sub.Active || !subscribesTo(sub, event.Type) {
+			continue
+		}
+		if payload == nil {
+			payload, err = json.Marshal(event)
+			if err != nil {
+				log.Printf("webhook encode %s: %v", event.Type, err)
+				return
+			}
+		}
+		deliveries = append(deliveries, domain.WebhookDelivery{
+			SubscriptionID: sub.ID,
+			EventID:        event.ID,
+			EventType:      string(event.Type),
+			Payload:        string(payload),
+		})
+	}
+	if len(deliveries) == 0 {
+		return
+	}
+	if err := s.repo.Enqueue(ctx, deliveries); err != nil {
+		log.Printf("webhook enqueue %s: %v", event.Type, err)
+		return
+	}
+	s.nudge()
+}
+
+func (s *WebhookService) nudge() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *WebhookService) dispatchDue(ctx context.Context) {
+	due, err := s.repo.Due(ctx, time.Now(), webhookBatchSize)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			log.Printf("webhook dispatch: %v", err)
+		}
+		return
+	}
+	for _, item := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		delivery := s.deliver(ctx, item)
+		if err := s.repo.RecordAttempt(ctx, delivery); err != nil {
+			log.Printf("webhook record attempt %s: %v", delivery.ID, err)
+		}
+	}
+}
+
+func (s *WebhookService) deliver(ctx context.Context, item repo.WebhookDispatch) domain.WebhookDelivery {
+	d := item.Delivery
+	d.Attempts++
+	now := time.Now().UTC()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	statusCode, err := s.post(ctx, item.Subscription, d, timestamp)
+	d.LastStatusCode = statusCode
+	if err == nil {
+		d.Status = domain.WebhookDeliveryDelivered
+		d.LastError = ""
+		d.NextAttemptAt = nil
+		d.DeliveredAt = &now
+		return d
+	}
+
+	d.LastError = err.Error()
+	if d.Attempts >= webhookMaxAttempts {
+		d.Status = domain.WebhookDeliveryFailed
+		d.NextAttemptAt = nil
+		return d
+	}
+	next := now.Add(webhookBackoff(d.Attempts))
+	d.Status = domain.WebhookDeliveryPending
+	d.NextAttemptAt = &next
+	return d
+}
+
+func (s *WebhookService) post(ctx context.Context, sub domain.WebhookSubscription, d domain.WebhookDelivery, timestamp string) (int, error) {
+	body := []byte(d.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "SmartSellerLite-Webhook/1")
+	req.Header.Set("X-SmartSeller-Event", d.EventType)
+	req.Header.Set("X-SmartSeller-Delivery", d.ID)
+	req.Header.Set("X-SmartSeller-Timestamp", timestamp)
+	req.Header.Set("X-SmartSeller-Signature", "sha256="+signWebhookPayload(sub.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func subscribesTo(sub domain.WebhookSubscription, eventType events.Type) bool {
+	if len(sub.Events) == 0 {
+		return true
+	}
+	for _, name := range sub.Events {
+		if name == string(eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookBackoff doubles the wait after every failed attempt, capped at webhookMaxBackoff.
+func webhookBackoff(attempts int) time.Duration {
+	wait := webhookBaseBackoff
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= webhookMaxBackoff {
+			return webhookMaxBackoff
+		}
+	}
+	return wait
+}
+
+func signWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate webhook secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
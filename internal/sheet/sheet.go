@@ -0,0 +1,159 @@
+// Package sheet reads and writes the single-sheet CSV and XLSX workbooks used for
+// catalogue import and export. Only the parts of SpreadsheetML needed for plain
+// tables are supported: no styles, formulas or multiple sheets.
+package sheet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Format names a supported file format.
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+// ParseFormat maps user input such as "xlsx" or "excel" to a Format.
+func ParseFormat(raw string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "", "csv":
+		return FormatCSV, nil
+	case "xlsx", "excel":
+		return FormatXLSX, nil
+	default:
+		return "", errors.New("format harus csv atau xlsx")
+	}
+}
+
+// DetectFormat picks the format from the file name, falling back to the content:
+// XLSX files are ZIP archives and start with "PK".
+func DetectFormat(name string, data []byte) Format {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".xlsx":
+		return FormatXLSX
+	case ".csv", ".txt":
+		return FormatCSV
+	}
+	if bytes.HasPrefix(data, []byte("PK")) {
+		return FormatXLSX
+	}
+	return FormatCSV
+}
+
+// Read parses the first sheet into rows of trimmed cells. Trailing empty rows are dropped.
+func Read(format Format, data []byte) ([][]string, error) {
+	var rows [][]string
+	var err error
+	switch format {
+	case FormatXLSX:
+		rows, err = readXLSX(data)
+	default:
+		rows, err = readCSV(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		for j := range rows[i] {
+			rows[i][j] = strings.TrimSpace(rows[i][j])
+		}
+	}
+	for len(rows) > 0 && isBlank(rows[len(rows)-1]) {
+		rows = rows[:len(rows)-1]
+	}
+	return rows, nil
+}
+
+// Write renders rows as the given format. Cells may be strings or numbers; XLSX keeps
+// numbers numeric so spreadsheets can sum them, while strings such as SKUs with
+// leading zeros stay text.
+func Write(format Format, rows [][]any) ([]byte, error) {
+	if format == FormatXLSX {
+		return writeXLSX(rows)
+	}
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	for _, row := range rows {
+		record := make([]string, len(row))
+		for i, cell := range row {
+			record[i] = formatCell(cell)
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, fmt.Errorf("write csv row: %w", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, fmt.Errorf("flush csv: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ContentType returns the MIME type of the format.
+func (f Format) ContentType() string {
+	if f == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// readCSV accepts comma or semicolon separated files; spreadsheet programs in
+// Indonesian locales save CSV with semicolons.
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	reader := csv.NewReader(bytes.NewReader(data))
+	firstLine := data
+	if idx := bytes.IndexByte(data, '\n'); idx >= 0 {
+		firstLine = data[:idx]
+	}
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("baca csv: %w", err)
+	}
+	return rows, nil
+}
+
+func formatCell(cell any) string {
+	switch v := cell.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return formatNumber(v)
+	case int:
+		return fmt.Sprintf("%d", v)
+	case int64:
+		return fmt.Sprintf("%d", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func formatNumber(v float64) string {
+	if v == float64(int64(v)) {
+		return fmt.Sprintf("%d", int64(v))
+	}
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.4f", v), "0"), ".")
+}
+
+func isBlank(row []string) bool {
+	for _, cell := range row {
+		if cell != "" {
+			return false
+		}
+	}
+	return true
+}
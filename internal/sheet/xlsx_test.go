@@ -0,0 +1,136 @@
+package sheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	testWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Produk" sheetId="1" r:id="rId3"/></sheets></workbook>`
+	testWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/produk.xml"/></Relationships>`
+	testSharedStrings = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="3" uniqueCount="3"><si><t>SKU</t></si><si><t>Name</t></si><si><r><t>Kaos </t></r><r><rPr><b/></rPr><t>Polos</t></r></si></sst>`
+)
+
+// testXLSX zips a workbook whose first sheet lives at xl/worksheets/produk.xml.
+func testXLSX(t *testing.T, sheetData string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	parts := map[string]string{
+		"xl/workbook.xml":            testWorkbook,
+		"xl/_rels/workbook.xml.rels": testWorkbookRels,
+		"xl/sharedStrings.xml":       testSharedStrings,
+		"xl/worksheets/produk.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + sheetData + `</sheetData></worksheet>`,
+	}
+	for name, body := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadXLSX(t *testing.T) {
+	tests := []struct {
+		name  string
+		sheet string
+		want  [][]string
+	}{
+		{
+			name:  "shared strings, rich text runs and numbers",
+			sheet: `<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row><row r="2"><c r="A2"><v>12500</v></c><c r="B2" t="s"><v>2</v></c></row>`,
+			want:  [][]string{{"SKU", "Name"}, {"12500", "Kaos Polos"}},
+		},
+		{
+			name:  "inline strings",
+			sheet: `<row r="1"><c r="A1" t="inlineStr"><is><t>KAOS-01</t></is></c><c r="B1" t="inlineStr"><is><r><t>Kaos </t></r><r><t>Hitam</t></r></is></c></row>`,
+			want:  [][]string{{"KAOS-01", "Kaos Hitam"}},
+		},
+		{
+			name:  "references leave gaps for skipped cells",
+			sheet: `<row r="1"><c r="B1" t="s"><v>0</v></c><c r="D1"><v>3</v></c></row><row r="2"><c r="AA2"><v>1</v></c></row>`,
+			want:  [][]string{{"", "SKU", "", "3"}, append(make([]string, 26), "1")},
+		},
+		{
+			name:  "cells without references follow one another",
+			sheet: `<row><c t="s"><v>0</v></c><c><v>7</v></c></row>`,
+			want:  [][]string{{"SKU", "7"}},
+		},
+		{
+			name:  "shared string index out of range reads as blank",
+			sheet: `<row r="1"><c r="A1" t="s"><v>9</v></c><c r="B1" t="s"><v>x</v></c></row>`,
+			want:  [][]string{{"", ""}},
+		},
+	}
+	for _, tt := range tests {
+		got, err := readXLSX(testXLSX(t, tt.sheet))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadXLSXRejectsBadReferences(t *testing.T) {
+	for _, ref := range []string{"XFE1", "ZZZZ1", "12", "a1"} {
+		sheet := `<row r="1"><c r="` + ref + `"><v>1</v></c></row>`
+		if _, err := readXLSX(testXLSX(t, sheet)); err == nil {
+			t.Errorf("readXLSX with cell %q succeeded, want error", ref)
+		}
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want int
+	}{
+		{"A1", 0},
+		{"Z9", 25},
+		{"AA10", 26},
+		{"AZ1", 51},
+		{"BA1", 52},
+		{"XFD1048576", maxXLSXColumns - 1},
+	}
+	for _, tt := range tests {
+		got, err := columnIndex(tt.ref)
+		if err != nil || got != tt.want {
+			t.Errorf("columnIndex(%q) = %d, %v, want %d", tt.ref, got, err, tt.want)
+		}
+		if name := columnName(tt.want); !strings.HasPrefix(tt.ref, name) {
+			t.Errorf("columnName(%d) = %q, want prefix of %q", tt.want, name, tt.ref)
+		}
+	}
+}
+
+func TestXLSXRoundTrip(t *testing.T) {
+	data, err := Write(FormatXLSX, [][]any{{"SKU", "Name", "Stock"}, {"KAOS-01", "Kaos & Topi <L>", 12}, {"", "Tanpa SKU", 0.5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(FormatXLSX, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"SKU", "Name", "Stock"}, {"KAOS-01", "Kaos & Topi <L>", "12"}, {"", "Tanpa SKU", "0.5"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,108 @@
+package tracking
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"smartseller-lite-starter/internal/domain"
+)
+
+// HTTPProvider queries a JSON tracking endpoint, such as a courier aggregator.
+//
+// The endpoint is a URL template where {courier} and {awb} are replaced with the
+// courier code and tracking number. The response is expected to look like:
+//
+//	{"status": "in_transit", "events": [{"status": "...", "description": "...", "location": "...", "time": "2024-01-02T15:04:05Z"}]}
+type HTTPProvider struct {
+	endpoint string
+	token    string
+	client   *http.Client
+}
+
+type httpTrackingResponse struct {
+	Status string              `json:"status"`
+	Events []httpTrackingEvent `json:"events"`
+}
+
+type httpTrackingEvent struct {
+	Status      string `json:"status"`
+	Description string `json:"description"`
+	Location    string `json:"location"`
+	Time        string `json:"time"`
+}
+
+// NewHTTPProvider builds an HTTP-JSON provider. The token, when set, is sent as a bearer token.
+func NewHTTPProvider(endpoint, token string) *HTTPProvider {
+	return &HTTPProvider{
+		endpoint: strings.TrimSpace(endpoint),
+		token:    strings.TrimSpace(token),
+		client:   &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (p *HTTPProvider) Name() string {
+	return "http"
+}
+
+func (p *HTTPProvider) Track(ctx context.Context, req Request) (*Result, error) {
+	if p.endpoint == "" {
+		return nil, fmt.Errorf("tracking endpoint is not configured")
+	}
+	target := strings.NewReplacer(
+		"{courier}", url.QueryEscape(normaliseCode(req.CourierCode)),
+		"{awb}", url.QueryEscape(strings.TrimSpace(req.TrackingCode)),
+	).Replace(p.endpoint)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build tracking request: %w", err)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if p.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("tracking request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("tracking request: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var payload httpTrackingResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("decode tracking response: %w", err)
+	}
+
+	result := &Result{Events: make([]domain.ShipmentEvent, 0, len(payload.Events))}
+	for _, raw := range payload.Events {
+		event := domain.ShipmentEvent{
+			Status:      NormaliseStatus(raw.Status),
+			Description: strings.TrimSpace(raw.Description),
+			Location:    strings.TrimSpace(raw.Location),
+		}
+		if ts, err := time.Parse(time.RFC3339, strings.TrimSpace(raw.Time)); err == nil {
+			event.OccurredAt = ts.UTC()
+		}
+		result.Events = append(result.Events, event)
+	}
+	if strings.TrimSpace(payload.Status) != "" {
+		result.Status = NormaliseStatus(payload.Status)
+	} else {
+		result.Status = LatestStatus(result.Events)
+	}
+	return result, nil
+}
@@ -0,0 +1,82 @@
+package tracking
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"smartseller-lite-starter/internal/domain"
+)
+
+// MockProvider simulates a courier offline. Every tracking code advances one
+// stage per step after it is first seen: picked up, in transit, delivered.
+// Codes ending in "RTS" (return to sender) end as returned instead.
+type MockProvider struct {
+	step time.Duration
+	now  func() time.Time
+
+	mu        sync.Mutex
+	firstSeen map[string]time.Time
+}
+
+type mockStage struct {
+	status      domain.ShipmentStatus
+	description string
+	location    string
+}
+
+func NewMockProvider(step time.Duration) *MockProvider {
+	if step <= 0 {
+		step = time.Minute
+	}
+	return &MockProvider{step: step, now: time.Now, firstSeen: make(map[string]time.Time)}
+}
+
+func (p *MockProvider) Name() string {
+	return "mock"
+}
+
+func (p *MockProvider) Track(_ context.Context, req Request) (*Result, error) {
+	awb := strings.ToUpper(strings.TrimSpace(req.TrackingCode))
+	if awb == "" {
+		return nil, ErrNotFound
+	}
+	key := normaliseCode(req.CourierCode) + "|" + awb
+
+	now := p.now().UTC()
+	p.mu.Lock()
+	start, ok := p.firstSeen[key]
+	if !ok {
+		start = now
+		p.firstSeen[key] = start
+	}
+	p.mu.Unlock()
+
+	stages := []mockStage{
+		{domain.ShipmentStatusPickedUp, "Paket diambil kurir", "Gudang pengirim"},
+		{domain.ShipmentStatusInTransit, "Paket dalam perjalanan", "Hub transit"},
+		{domain.ShipmentStatusDelivered, "Paket diterima penerima", "Alamat tujuan"},
+	}
+	if strings.HasSuffix(awb, "RTS") {
+		stages[len(stages)-1] = mockStage{domain.ShipmentStatusReturned, "Paket dikembalikan ke pengirim", "Gudang pengirim"}
+	}
+
+	reached := int(now.Sub(start)/p.step) + 1
+	if reached > len(stages) {
+		reached = len(stages)
+	}
+
+	result := &Result{Status: domain.ShipmentStatusPending}
+	for i := 0; i < reached; i++ {
+		stage := stages[i]
+		result.Events = append(result.Events, domain.ShipmentEvent{
+			Status:      stage.status,
+			Description: stage.description,
+			Location:    stage.location,
+			OccurredAt:  start.Add(time.Duration(i) * p.step).Truncate(time.Second),
+		})
+		result.Status = stage.status
+	}
+	return result, nil
+}
@@ -0,0 +1,177 @@
+package tracking
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"smartseller-lite-starter/internal/domain"
+)
+
+// ErrNotFound is returned by providers when the courier does not know the tracking code yet.
+var ErrNotFound = errors.New("tracking code not found")
+
+// Request identifies the shipment a provider should look up.
+type Request struct {
+	CourierCode  string
+	TrackingCode string
+}
+
+// Result is the courier view of a shipment: the current status and its checkpoints.
+type Result struct {
+	Status domain.ShipmentStatus
+	Events []domain.ShipmentEvent
+}
+
+// Provider fetches shipment status from a courier or aggregator.
+type Provider interface {
+	Name() string
+	Track(ctx context.Context, req Request) (*Result, error)
+}
+
+// Registry maps courier codes (see domain.Courier.Code) to tracking providers.
+type Registry struct {
+	mu        sync.RWMutex
+	providers map[string]Provider
+	fallback  Provider
+}
+
+func NewRegistry() *Registry {
+	return &Registry{providers: make(map[string]Provider)}
+}
+
+// Register binds a provider to a courier code. Codes are matched case-insensitively.
+func (r *Registry) Register(courierCode string, provider Provider) {
+	code := normaliseCode(courierCode)
+	if code == "" || provider == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[code] = provider
+}
+
+// SetFallback configures the provider used for couriers without an explicit binding.
+func (r *Registry) SetFallback(provider Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallback = provider
+}
+
+// Lookup returns the provider responsible for the courier code.
+func (r *Registry) Lookup(courierCode string) (Provider, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if provider, ok := r.providers[normaliseCode(courierCode)]; ok {
+		return provider, true
+	}
+	if r.fallback != nil {
+		return r.fallback, true
+	}
+	return nil, false
+}
+
+// Empty reports whether no provider has been configured at all.
+func (r *Registry) Empty() bool {
+	if r == nil {
+		return true
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.providers) == 0 && r.fallback == nil
+}
+
+// NormaliseStatus maps the many spellings used by couriers onto a domain status. It
+// matches whole words, and checks returns, failed attempts and negations before the
+// delivered words, so "undelivered", "delivery failed" or "belum diterima" never end
+// polling as delivered.
+func NormaliseStatus(raw string) domain.ShipmentStatus {
+	words := strings.FieldsFunc(strings.ToLower(raw), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	has := func(set map[string]bool) bool {
+		for _, w := range words {
+			if set[w] {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case len(words) == 0:
+		return domain.ShipmentStatusPending
+	case has(returnedWords):
+		return domain.ShipmentStatusReturned
+	case has(failedWords):
+		// A failed attempt stays on its way: the courier retries or starts a return.
+		return domain.ShipmentStatusInTransit
+	case hasPhrase(words, "out", "for"), hasPhrase(words, "on", "process"), hasPhrase(words, "with", "courier"), has(transitWords):
+		return domain.ShipmentStatusInTransit
+	case has(deliveredWords):
+		return domain.ShipmentStatusDelivered
+	case hasPhrase(words, "received", "at", "origin"), has(pickedUpWords):
+		return domain.ShipmentStatusPickedUp
+	default:
+		return domain.ShipmentStatusPending
+	}
+}
+
+var (
+	returnedWords = wordSet("retur", "return", "returned", "returning", "rts", "dikembalikan")
+	// failedWords mark attempts that did not deliver, including negations such as
+	// "not delivered" or "belum diterima".
+	failedWords = wordSet("undelivered", "undeliverable", "failed", "failure", "fail", "gagal", "unsuccessful",
+		"not", "belum", "tidak", "bukan")
+	// transitWords include hubs and warehouses, so "diterima di gudang" is a transit
+	// scan rather than a delivery.
+	transitWords   = wordSet("transit", "dikirim", "gudang", "warehouse", "hub", "sorting", "sortir", "facility", "agen")
+	deliveredWords = wordSet("delivered", "terkirim", "diterima", "pod")
+	pickedUpWords  = wordSet("pick", "pickup", "picked", "manifest", "manifested", "diambil")
+)
+
+func wordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
+
+// hasPhrase reports whether words contains phrase as consecutive words.
+func hasPhrase(words []string, phrase ...string) bool {
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		match := true
+		for j, p := range phrase {
+			if words[i+j] != p {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// LatestStatus derives the current status from the most recent checkpoint.
+func LatestStatus(events []domain.ShipmentEvent) domain.ShipmentStatus {
+	if len(events) == 0 {
+		return domain.ShipmentStatusPending
+	}
+	sorted := append([]domain.ShipmentEvent(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].OccurredAt.Before(sorted[j].OccurredAt)
+	})
+	return sorted[len(sorted)-1].Status
+}
+
+func normaliseCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,43 @@
+package tracking
+
+import (
+	"testing"
+
+	"smartseller-lite-starter/internal/domain"
+)
+
+func TestNormaliseStatus(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want domain.ShipmentStatus
+	}{
+		{"", domain.ShipmentStatusPending},
+		{"DELIVERED", domain.ShipmentStatusDelivered},
+		{"Delivered to recipient", domain.ShipmentStatusDelivered},
+		{"POD", domain.ShipmentStatusDelivered},
+		{"Terkirim", domain.ShipmentStatusDelivered},
+		{"Diterima oleh: Budi", domain.ShipmentStatusDelivered},
+		{"undelivered", domain.ShipmentStatusInTransit},
+		{"failed delivery", domain.ShipmentStatusInTransit},
+		{"Delivery failed", domain.ShipmentStatusInTransit},
+		{"delivery_failed", domain.ShipmentStatusInTransit},
+		{"not delivered", domain.ShipmentStatusInTransit},
+		{"belum diterima", domain.ShipmentStatusInTransit},
+		{"diterima di gudang", domain.ShipmentStatusInTransit},
+		{"out for delivery", domain.ShipmentStatusInTransit},
+		{"in-transit", domain.ShipmentStatusInTransit},
+		{"Paket dikirim", domain.ShipmentStatusInTransit},
+		{"returned to sender", domain.ShipmentStatusReturned},
+		{"Retur", domain.ShipmentStatusReturned},
+		{"delivery failed, returning to sender", domain.ShipmentStatusReturned},
+		{"picked up", domain.ShipmentStatusPickedUp},
+		{"Received at origin", domain.ShipmentStatusPickedUp},
+		{"manifested", domain.ShipmentStatusPickedUp},
+		{"label created", domain.ShipmentStatusPending},
+	}
+	for _, tt := range tests {
+		if got := NormaliseStatus(tt.raw); got != tt.want {
+			t.Errorf("NormaliseStatus(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -27,11 +27,15 @@ import (
 	"github.com/joho/godotenv"
 
 	"smartseller-lite-starter/internal/app"
+	"smartseller-lite-starter/internal/audit"
 	"smartseller-lite-starter/internal/db"
 	"smartseller-lite-starter/internal/domain"
+	"smartseller-lite-starter/internal/events"
 	"smartseller-lite-starter/internal/httpapi"
 	"smartseller-lite-starter/internal/media"
 	"smartseller-lite-starter/internal/service"
+	"smartseller-lite-starter/internal/sheet"
+	"smartseller-lite-starter/internal/tracking"
 	"smartseller-lite-starter/internal/util"
 )
 
@@ -40,6 +44,7 @@ var embeddedDist embed.FS
 
 const (
 	healthEndpoint      = "/api/health"
+	eventsEndpoint      = "/api/events"
 	healthCheckAttempts = 20
 	healthCheckInterval = 200 * time.Millisecond
 )
@@ -135,7 +140,20 @@ func main() {
 		log.Fatalf("prepare media manager: %v", err)
 	}
 
-	core := app.NewCore(store, app.CoreConfig{DefaultBrandName: brandName, MediaManager: mediaManager})
+	trackingProviders, trackingInterval := buildTrackingRegistry()
+
+	reconcileInterval, err := time.ParseDuration(strings.TrimSpace(getEnv("STOCK_RECONCILE_INTERVAL", "24h")))
+	if err != nil || reconcileInterval <= 0 {
+		reconcileInterval = 24 * time.Hour
+	}
+
+	core := app.NewCore(store, app.CoreConfig{
+		DefaultBrandName:  brandName,
+		MediaManager:      mediaManager,
+		TrackingProviders: trackingProviders,
+		TrackingInterval:  trackingInterval,
+		ReconcileInterval: reconcileInterval,
+	})
 	core.Warm(context.Background())
 	defer func() {
 		if err := core.Close(); err != nil {
@@ -143,12 +161,16 @@ func main() {
 		}
 	}()
 
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	core.Start(workerCtx)
+
 	router := chi.NewRouter()
 	router.Use(middleware.RequestID)
 	router.Use(middleware.RealIP)
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
-	router.Use(middleware.Timeout(30 * time.Second))
+	router.Use(withRequestTimeout(30 * time.Second))
 
 	api := app.NewAPI(core)
 	mountAPI(router, api)
@@ -191,6 +213,44 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// buildTrackingRegistry configures courier tracking providers from the environment.
+// TRACKING_PROVIDER selects "mock" or "http"; TRACKING_COURIERS optionally limits the
+// provider to a comma separated list of courier codes, otherwise it serves every courier.
+func buildTrackingRegistry() (*tracking.Registry, time.Duration) {
+	registry := tracking.NewRegistry()
+
+	interval, err := time.ParseDuration(strings.TrimSpace(getEnv("TRACKING_POLL_INTERVAL", "30m")))
+	if err != nil || interval <= 0 {
+		interval = 30 * time.Minute
+	}
+
+	var provider tracking.Provider
+	switch strings.ToLower(strings.TrimSpace(getEnv("TRACKING_PROVIDER", ""))) {
+	case "mock":
+		provider = tracking.NewMockProvider(interval)
+	case "http":
+		endpoint := strings.TrimSpace(getEnv("TRACKING_HTTP_URL", ""))
+		if endpoint == "" {
+			log.Printf("tracking: TRACKING_HTTP_URL is empty, courier tracking disabled")
+			return registry, interval
+		}
+		provider = tracking.NewHTTPProvider(endpoint, getEnv("TRACKING_HTTP_TOKEN", ""))
+	default:
+		return registry, interval
+	}
+
+	codes := strings.TrimSpace(getEnv("TRACKING_COURIERS", ""))
+	if codes == "" {
+		registry.SetFallback(provider)
+	} else {
+		for _, code := range strings.Split(codes, ",") {
+			registry.Register(code, provider)
+		}
+	}
+	log.Printf("tracking: %s provider enabled, polling every %s", provider.Name(), interval)
+	return registry, interval
+}
+
 func parsePositiveInt(raw string, fallback int) int {
 	if strings.TrimSpace(raw) == "" {
 		return fallback
@@ -400,23 +460,89 @@ func portAvailable(address string) bool {
 
 func mountAPI(r chi.Router, api *app.API) {
 	r.Route("/api", func(router chi.Router) {
+		router.Use(withActor)
+		router.Get("/events", handleEvents(api))
 		router.Get("/products", handleListProducts(api))
+		router.Get("/products/lookup", handleLookupProduct(api))
 		router.Post("/products", handleCreateProduct(api))
 		router.Put("/products/{id}", handleUpdateProduct(api))
 		router.Post("/products/{id}/adjust-stock", handleAdjustStock(api))
 		router.Post("/products/{id}/archive", handleArchiveProduct(api))
+		router.Post("/products/{id}/unarchive", handleUnarchiveProduct(api))
 		router.Delete("/products/{id}", handleDeleteProduct(api))
+		router.Get("/products/{id}/barcode.png", handleProductBarcode(api))
+		router.Post("/products/barcode-sheet", handleBarcodeSheet(api))
+		router.Get("/products/export", handleExportProducts(api))
+		router.Post("/products/import", handleImportProducts(api))
+		router.Get("/products/{id}/price-history", handleProductPriceHistory(api))
+		router.Get("/products/{id}/images", handleListProductImages(api))
+		router.Post("/products/{id}/images", handleAddProductImage(api))
+		router.Put("/products/{id}/images/order", handleReorderProductImages(api))
+		router.Put("/products/{id}/images/{imageId}", handleUpdateProductImage(api))
+		router.Delete("/products/{id}/images/{imageId}", handleDeleteProductImage(api))
+		router.Get("/products/{id}/batches", handleListStockBatches(api))
+		router.Get("/products/{id}/mutations", handleProductMutations(api))
+		router.Get("/products/{id}/opnames", handleProductOpnames(api))
+		router.Get("/stock-mutations", handleListStockMutations(api))
+		router.Get("/stock-reconciliation", handleCheckStockReconciliation(api))
+		router.Get("/stock-reconciliation/latest", handleLatestStockReconciliation(api))
+		router.Post("/stock-reconciliation/apply", handleApplyStockReconciliation(api))
+		router.Post("/products/{id}/batches", handleReceiveStock(api))
+
+		router.Get("/categories", handleListCategories(api))
+		router.Post("/categories", handleCreateCategory(api))
+		router.Put("/categories/{id}", handleUpdateCategory(api))
+		router.Delete("/categories/{id}", handleDeleteCategory(api))
+
+		router.Get("/suppliers", handleListSuppliers(api))
+		router.Post("/suppliers", handleCreateSupplier(api))
+		router.Put("/suppliers/{id}", handleUpdateSupplier(api))
+		router.Delete("/suppliers/{id}", handleDeleteSupplier(api))
+		router.Get("/payables", handleSupplierPayables(api))
+
+		router.Get("/purchase-orders", handleListPurchaseOrders(api))
+		router.Post("/purchase-orders", handleCreatePurchaseOrder(api))
+		router.Get("/purchase-orders/{id}", handleGetPurchaseOrder(api))
+		router.Put("/purchase-orders/{id}", handleUpdatePurchaseOrder(api))
+		router.Delete("/purchase-orders/{id}", handleDeletePurchaseOrder(api))
+		router.Post("/purchase-orders/{id}/order", handlePlacePurchaseOrder(api))
+		router.Post("/purchase-orders/{id}/receive", handleReceivePurchaseOrder(api))
+		router.Post("/purchase-orders/{id}/payments", handleAddPurchasePayment(api))
+
+		router.Get("/adjustment-reasons", handleListAdjustmentReasons(api))
+		router.Post("/adjustment-reasons", handleCreateAdjustmentReason(api))
+		router.Put("/adjustment-reasons/{code}", handleUpdateAdjustmentReason(api))
+		router.Delete("/adjustment-reasons/{code}", handleDeleteAdjustmentReason(api))
+		router.Get("/locations", handleListLocations(api))
+		router.Post("/locations", handleCreateLocation(api))
+		router.Put("/locations/{id}", handleUpdateLocation(api))
+		router.Delete("/locations/{id}", handleDeleteLocation(api))
+		router.Get("/stock-transfers", handleListStockTransfers(api))
+		router.Post("/stock-transfers", handleTransferStock(api))
 
 		router.Get("/customers", handleListCustomers(api))
 		router.Post("/customers", handleCreateCustomer(api))
 		router.Put("/customers/{id}", handleUpdateCustomer(api))
 		router.Delete("/customers/{id}", handleDeleteCustomer(api))
+		router.Get("/customers/duplicates", handleCustomerDuplicates(api))
+		router.Post("/customers/merge", handleMergeCustomers(api))
 
 		router.Get("/orders", handleListOrders(api))
 		router.Post("/orders", handleCreateOrder(api))
+		router.Get("/orders/{id}", handleGetOrder(api))
 		router.Delete("/orders/{id}", handleDeleteOrder(api))
 		router.Post("/orders/{id}/label", handleGenerateLabel(api))
 		router.Get("/orders/export.csv", handleExportOrdersCSV(api))
+		router.Get("/reports/categories", handleCategoryReport(api))
+		router.Get("/reports/margins", handleMarginReport(api))
+		router.Get("/reports/inventory-valuation", handleInventoryValuation(api))
+		router.Get("/reports/restock", handleRestockReport(api))
+		router.Get("/reports/expiry", handleExpiryReport(api))
+		router.Get("/reports/shrinkage", handleShrinkageTrend(api))
+		router.Get("/reports/adjustments", handleAdjustmentReport(api))
+		router.Post("/reports/restock/thresholds", handleApplyRestockThresholds(api))
+		router.Get("/orders/{id}/tracking", handleGetOrderTracking(api))
+		router.Post("/orders/{id}/tracking/refresh", handleRefreshOrderTracking(api))
 
 		router.Get("/settings", handleGetSettings(api))
 		router.Put("/settings", handleUpdateSettings(api))
@@ -427,7 +553,33 @@ func mountAPI(r chi.Router, api *app.API) {
 		router.Delete("/couriers/{id}", handleDeleteCourier(api))
 
 		router.Get("/stock-opnames", handleListStockOpnames(api))
+		router.Get("/stock-opnames/{id}", handleGetStockOpname(api))
+		router.Get("/stock-opnames/{id}/report", handleOpnameVarianceReport(api))
 		router.Post("/stock-opnames", handlePerformStockOpname(api))
+		router.Post("/stock-opnames/scan-sessions", handleStartScanSession(api))
+		router.Get("/stock-opnames/scan-sessions/{id}", handleGetScanSession(api))
+		router.Delete("/stock-opnames/scan-sessions/{id}", handleDiscardScanSession(api))
+		router.Post("/stock-opnames/scan-sessions/{id}/scans", handleRecordScan(api))
+		router.Put("/stock-opnames/scan-sessions/{id}/items/{productId}", handleSetScanCount(api))
+		router.Delete("/stock-opnames/scan-sessions/{id}/items/{productId}", handleRemoveScanItem(api))
+		router.Post("/stock-opnames/scan-sessions/{id}/submit", handleSubmitScanSession(api))
+		router.Get("/stock-opnames/sessions", handleListOpnameSessions(api))
+		router.Post("/stock-opnames/sessions", handleOpenOpnameSession(api))
+		router.Get("/stock-opnames/sessions/{id}", handleGetOpnameSession(api))
+		router.Post("/stock-opnames/sessions/{id}/counts", handleRecordOpnameCounts(api))
+		router.Delete("/stock-opnames/sessions/{id}/counts/{productId}", handleRemoveOpnameCount(api))
+		router.Post("/stock-opnames/sessions/{id}/review", handleOpnameSessionAction(api, api.SubmitOpnameSessionForReview))
+		router.Post("/stock-opnames/sessions/{id}/reopen", handleReopenOpnameSession(api))
+		router.Post("/stock-opnames/sessions/{id}/approve", handleOpnameSessionAction(api, api.ApproveOpnameSession))
+		router.Post("/stock-opnames/sessions/{id}/cancel", handleOpnameSessionAction(api, api.CancelOpnameSession))
+
+		router.Get("/webhooks", handleListWebhooks(api))
+		router.Get("/webhooks/events", handleListWebhookEventTypes(api))
+		router.Post("/webhooks", handleCreateWebhook(api))
+		router.Put("/webhooks/{id}", handleUpdateWebhook(api))
+		router.Delete("/webhooks/{id}", handleDeleteWebhook(api))
+		router.Get("/webhooks/{id}/deliveries", handleListWebhookDeliveries(api))
+		router.Post("/webhooks/deliveries/{id}/retry", handleRedeliverWebhook(api))
 
 		router.Post("/backups", handleCreateBackup(api))
 		router.Post("/backups/restore", handleRestoreBackup(api))
@@ -464,14 +616,25 @@ func handleListProducts(api *app.API) http.HandlerFunc {
 			pageSize = 20
 		}
 		search := strings.TrimSpace(query.Get("q"))
+		archived, err := service.ParseArchiveFilter(query.Get("archived"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
 
 		result, err := api.ListProducts(r.Context(), service.ProductListOptions{
 			Query:    search,
+			Category: strings.TrimSpace(query.Get("category")),
+			Archived: archived,
 			Page:     page,
 			PageSize: pageSize,
 		})
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, err)
+			status := http.StatusInternalServerError
+			if errors.Is(err, service.ErrCategoryNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
 			return
 		}
 		writeJSON(w, http.StatusOK, result)
@@ -514,25 +677,73 @@ func handleUpdateProduct(api *app.API) http.HandlerFunc {
 }
 
 func handleAdjustStock(api *app.API) http.HandlerFunc {
-	type request struct {
-		Delta  int    `json:"delta"`
-		Reason string `json:"reason"`
-	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "id")
-		var payload request
+		var payload service.AdjustStockInput
 		if err := decodeJSON(r.Body, &payload); err != nil {
 			writeError(w, http.StatusBadRequest, err)
 			return
 		}
-		if err := api.AdjustStock(r.Context(), id, payload.Delta, payload.Reason); err != nil {
-			writeError(w, http.StatusBadRequest, err)
+		if err := api.AdjustStock(r.Context(), id, payload); err != nil {
+			writeError(w, locationStatus(err), err)
 			return
 		}
 		writeJSON(w, http.StatusNoContent, nil)
 	}
 }
 
+func handleReceiveStock(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload service.ReceiveStockInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		product, err := api.ReceiveStock(r.Context(), chi.URLParam(r, "id"), payload)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, product)
+	}
+}
+
+func handleListStockBatches(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		includeDepleted := r.URL.Query().Get("all") == "1"
+		batches, err := api.ListStockBatches(r.Context(), chi.URLParam(r, "id"), includeDepleted)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, batches)
+	}
+}
+
+func handleInventoryValuation(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		valuation, err := api.InventoryValuation(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, valuation)
+	}
+}
+
+// handleExpiryReport lists lots with stock left that expire within ?days (default 30),
+// expired lots included.
+func handleExpiryReport(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lots, err := api.ExpiringLots(r.Context(), parsePositiveInt(r.URL.Query().Get("days"), 0))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, lots)
+	}
+}
+
 func handleArchiveProduct(api *app.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "id")
@@ -544,6 +755,22 @@ func handleArchiveProduct(api *app.API) http.HandlerFunc {
 	}
 }
 
+func handleUnarchiveProduct(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		product, err := api.UnarchiveProduct(r.Context(), id)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, service.ErrProductNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, product)
+	}
+}
+
 func handleDeleteProduct(api *app.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := chi.URLParam(r, "id")
@@ -624,6 +851,37 @@ func handleDeleteCustomer(api *app.API) http.HandlerFunc {
 	}
 }
 
+func handleCustomerDuplicates(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		groups, err := api.CustomerDuplicates(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, groups)
+	}
+}
+
+func handleMergeCustomers(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload service.MergeCustomersInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		result, err := api.MergeCustomers(r.Context(), payload)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, service.ErrCustomerNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
 func handleListOrders(api *app.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		query := r.URL.Query()
@@ -671,6 +929,21 @@ func handleListOrders(api *app.API) http.HandlerFunc {
 	}
 }
 
+func handleGetOrder(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		order, err := api.GetOrder(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, service.ErrOrderNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, order)
+	}
+}
+
 func handleCreateOrder(api *app.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var payload service.CreateOrderInput
@@ -698,138 +971,947 @@ func handleDeleteOrder(api *app.API) http.HandlerFunc {
 	}
 }
 
-func handleGenerateLabel(api *app.API) http.HandlerFunc {
-	type response struct {
-		Base64 string `json:"base64"`
-	}
+func handleListCategories(api *app.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		id := chi.URLParam(r, "id")
-		label, err := api.GenerateLabel(r.Context(), id)
+		categories, err := api.ListCategories(r.Context())
 		if err != nil {
-			writeError(w, http.StatusBadRequest, err)
+			writeError(w, http.StatusInternalServerError, err)
 			return
 		}
-		writeJSON(w, http.StatusOK, response{Base64: label})
+		writeJSON(w, http.StatusOK, categories)
 	}
 }
 
-func handleGetSettings(api *app.API) http.HandlerFunc {
+func handleCreateCategory(api *app.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		settings, err := api.GetSettings(r.Context())
+		var payload domain.Category
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		payload.ID = ""
+		created, err := api.SaveCategory(r.Context(), payload)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, err)
+			writeError(w, http.StatusBadRequest, err)
 			return
 		}
-		writeJSON(w, http.StatusOK, settings)
+		writeJSON(w, http.StatusCreated, created)
 	}
 }
 
-func handleUpdateSettings(api *app.API) http.HandlerFunc {
+func handleUpdateCategory(api *app.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var payload domain.AppSettings
+		var payload domain.Category
 		if err := decodeJSON(r.Body, &payload); err != nil {
 			writeError(w, http.StatusBadRequest, err)
 			return
 		}
-		updated, err := api.UpdateSettings(r.Context(), payload)
+		payload.ID = chi.URLParam(r, "id")
+		updated, err := api.SaveCategory(r.Context(), payload)
 		if err != nil {
-			writeError(w, http.StatusBadRequest, err)
+			status := http.StatusBadRequest
+			if errors.Is(err, service.ErrCategoryNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
 			return
 		}
 		writeJSON(w, http.StatusOK, updated)
 	}
 }
 
-func handleListCouriers(api *app.API) http.HandlerFunc {
+func handleDeleteCategory(api *app.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		query := r.URL.Query()
-		page := parsePositiveInt(query.Get("page"), 1)
-		pageSize := parsePositiveInt(query.Get("pageSize"), 20)
-		if pageSize <= 0 {
-			pageSize = 20
+		if err := api.DeleteCategory(r.Context(), chi.URLParam(r, "id")); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
 		}
-		search := strings.TrimSpace(query.Get("q"))
+		writeJSON(w, http.StatusNoContent, nil)
+	}
+}
 
-		result, err := api.ListCouriers(r.Context(), service.CourierListOptions{
-			Query:    search,
-			Page:     page,
-			PageSize: pageSize,
-		})
+func handleCategoryReport(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		var filters service.CategoryReportFilters
+		if raw := strings.TrimSpace(query.Get("start")); raw != "" {
+			if ts, err := time.Parse("2006-01-02", raw); err == nil {
+				start := ts
+				filters.Start = &start
+			}
+		}
+		if raw := strings.TrimSpace(query.Get("end")); raw != "" {
+			if ts, err := time.Parse("2006-01-02", raw); err == nil {
+				end := ts.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+				filters.End = &end
+			}
+		}
+		rollups, err := api.CategoryReport(r.Context(), filters)
 		if err != nil {
 			writeError(w, http.StatusInternalServerError, err)
 			return
 		}
-		writeJSON(w, http.StatusOK, result)
+		writeJSON(w, http.StatusOK, rollups)
 	}
 }
 
-func handleCreateCourier(api *app.API) http.HandlerFunc {
+func handleMarginReport(api *app.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var payload domain.Courier
-		if err := decodeJSON(r.Body, &payload); err != nil {
-			writeError(w, http.StatusBadRequest, err)
+		query := r.URL.Query()
+		var filters service.MarginReportFilters
+		if raw := strings.TrimSpace(query.Get("start")); raw != "" {
+			if ts, err := time.Parse("2006-01-02", raw); err == nil {
+				start := ts
+				filters.Start = &start
+			}
+		}
+		if raw := strings.TrimSpace(query.Get("end")); raw != "" {
+			if ts, err := time.Parse("2006-01-02", raw); err == nil {
+				end := ts.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+				filters.End = &end
+			}
+		}
+		margins, err := api.MarginReport(r.Context(), filters)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
 			return
 		}
-		payload.ID = ""
-		created, err := api.SaveCourier(r.Context(), payload)
+		writeJSON(w, http.StatusOK, margins)
+	}
+}
+
+// handleShrinkageTrend lists opname shrinkage between start and end (YYYY-MM-DD, both optional).
+func handleShrinkageTrend(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		var start, end *time.Time
+		if raw := strings.TrimSpace(query.Get("start")); raw != "" {
+			ts, err := time.Parse("2006-01-02", raw)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("tanggal mulai tidak valid"))
+				return
+			}
+			start = &ts
+		}
+		if raw := strings.TrimSpace(query.Get("end")); raw != "" {
+			ts, err := time.Parse("2006-01-02", raw)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("tanggal akhir tidak valid"))
+				return
+			}
+			ts = ts.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+			end = &ts
+		}
+		trend, err := api.ShrinkageTrend(r.Context(), start, end)
 		if err != nil {
-			writeError(w, http.StatusBadRequest, err)
+			writeError(w, http.StatusInternalServerError, err)
 			return
 		}
-		writeJSON(w, http.StatusCreated, created)
+		writeJSON(w, http.StatusOK, trend)
 	}
 }
 
-func handleUpdateCourier(api *app.API) http.HandlerFunc {
+// handleAdjustmentReport accepts start, end, period (day, week or month) and
+// format=csv for a download instead of JSON.
+func handleAdjustmentReport(api *app.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		id := chi.URLParam(r, "id")
-		var payload domain.Courier
-		if err := decodeJSON(r.Body, &payload); err != nil {
-			writeError(w, http.StatusBadRequest, err)
+		query := r.URL.Query()
+		var start, end *time.Time
+		if raw := strings.TrimSpace(query.Get("start")); raw != "" {
+			ts, err := time.Parse("2006-01-02", raw)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("tanggal mulai tidak valid"))
+				return
+			}
+			start = &ts
+		}
+		if raw := strings.TrimSpace(query.Get("end")); raw != "" {
+			ts, err := time.Parse("2006-01-02", raw)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("tanggal akhir tidak valid"))
+				return
+			}
+			ts = ts.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+			end = &ts
+		}
+		period := strings.ToLower(strings.TrimSpace(query.Get("period")))
+		if strings.EqualFold(query.Get("format"), "csv") {
+			data, err := api.AdjustmentReportCSV(r.Context(), start, end, period)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition", "attachment; filename=\"penyesuaian-stok.csv\"")
+			if _, err := w.Write(data); err != nil {
+				log.Printf("write adjustment report: %v", err)
+			}
 			return
 		}
-		payload.ID = id
-		updated, err := api.SaveCourier(r.Context(), payload)
+		report, err := api.AdjustmentReport(r.Context(), start, end, period)
 		if err != nil {
 			writeError(w, http.StatusBadRequest, err)
 			return
 		}
-		writeJSON(w, http.StatusOK, updated)
+		writeJSON(w, http.StatusOK, report)
 	}
 }
 
-func handleDeleteCourier(api *app.API) http.HandlerFunc {
+// handleRestockReport accepts windows (days, repeated or comma separated), leadTimeDays,
+// safetyDays, coverDays and reorderOnly=true to list only products that need ordering.
+func handleRestockReport(api *app.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		id := chi.URLParam(r, "id")
-		if err := api.DeleteCourier(r.Context(), id); err != nil {
+		query := r.URL.Query()
+		filters := service.RestockReportFilters{
+			LeadTimeDays: parsePositiveInt(query.Get("leadTimeDays"), 0),
+			CoverDays:    parsePositiveInt(query.Get("coverDays"), 0),
+			OnlyReorder:  query.Get("reorderOnly") == "true",
+		}
+		if raw := strings.TrimSpace(query.Get("safetyDays")); raw != "" {
+			days, err := strconv.Atoi(raw)
+			if err != nil || days < 0 {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("stok pengaman %q tidak valid", raw))
+				return
+			}
+			filters.SafetyDays = &days
+		}
+		for _, raw := range query["windows"] {
+			for _, part := range strings.Split(raw, ",") {
+				if part = strings.TrimSpace(part); part == "" {
+					continue
+				}
+				days, err := strconv.Atoi(part)
+				if err != nil {
+					writeError(w, http.StatusBadRequest, fmt.Errorf("jendela penjualan %q tidak valid", part))
+					return
+				}
+				filters.Windows = append(filters.Windows, days)
+			}
+		}
+		report, err := api.RestockReport(r.Context(), filters)
+		if err != nil {
 			writeError(w, http.StatusBadRequest, err)
 			return
 		}
-		writeJSON(w, http.StatusNoContent, nil)
+		writeJSON(w, http.StatusOK, report)
 	}
 }
 
-func handleListStockOpnames(api *app.API) http.HandlerFunc {
+// handleApplyRestockThresholds replaces low stock thresholds with the suggested reorder
+// points, for the listed products or for all of them when none are listed.
+func handleApplyRestockThresholds(api *app.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		limit := 10
-		if raw := r.URL.Query().Get("limit"); raw != "" {
-			if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
-				limit = parsed
+		var payload struct {
+			ProductIDs []string `json:"productIds"`
+		}
+		if r.ContentLength != 0 {
+			if err := decodeJSON(r.Body, &payload); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
 			}
 		}
-		opnames, err := api.ListStockOpnames(r.Context(), limit)
+		changes, err := api.ApplyRestockThresholds(r.Context(), payload.ProductIDs)
 		if err != nil {
 			writeError(w, http.StatusInternalServerError, err)
 			return
 		}
-		writeJSON(w, http.StatusOK, opnames)
+		writeJSON(w, http.StatusOK, changes)
 	}
 }
 
-func handlePerformStockOpname(api *app.API) http.HandlerFunc {
+// parseMutationListOptions reads the ledger filters: dateStart/dateEnd (YYYY-MM-DD),
+// reason prefixes (repeated or comma separated), page and pageSize.
+func parseMutationListOptions(r *http.Request) (service.StockMutationListOptions, error) {
+	query := r.URL.Query()
+	opts := service.StockMutationListOptions{
+		Page:     parsePositiveInt(query.Get("page"), 1),
+		PageSize: parsePositiveInt(query.Get("pageSize"), 50),
+	}
+	for _, raw := range query["reason"] {
+		for _, reason := range strings.Split(raw, ",") {
+			if reason = strings.TrimSpace(reason); reason != "" {
+				opts.Reasons = append(opts.Reasons, reason)
+			}
+		}
+	}
+	if raw := strings.TrimSpace(query.Get("dateStart")); raw != "" {
+		parsed, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return opts, fmt.Errorf("tanggal mulai tidak valid")
+		}
+		opts.DateStart = &parsed
+	}
+	if raw := strings.TrimSpace(query.Get("dateEnd")); raw != "" {
+		parsed, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return opts, fmt.Errorf("tanggal akhir tidak valid")
+		}
+		opts.DateEnd = &parsed
+	}
+	return opts, nil
+}
+
+func handleProductMutations(api *app.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var payload service.PerformStockOpnameInput
-		if err := decodeJSON(r.Body, &payload); err != nil {
+		opts, err := parseMutationListOptions(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		opts.ProductID = chi.URLParam(r, "id")
+		result, err := api.ListStockMutations(r.Context(), opts)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, service.ErrProductNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func handleListStockMutations(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opts, err := parseMutationListOptions(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		opts.ProductID = strings.TrimSpace(r.URL.Query().Get("productId"))
+		result, err := api.ListStockMutations(r.Context(), opts)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, service.ErrProductNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func handleCheckStockReconciliation(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report, err := api.CheckStockReconciliation(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	}
+}
+
+// handleLatestStockReconciliation returns the last check, typically the background
+// job's, without running a new one. It answers null before any check has run.
+func handleLatestStockReconciliation(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, api.LatestStockReconciliation())
+	}
+}
+
+func handleApplyStockReconciliation(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload service.ApplyReconciliationInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		result, err := api.ApplyStockReconciliation(r.Context(), payload)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func handleProductPriceHistory(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := parsePositiveInt(r.URL.Query().Get("limit"), 0)
+		history, err := api.ProductPriceHistory(r.Context(), chi.URLParam(r, "id"), limit)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, service.ErrProductNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, history)
+	}
+}
+
+// productImageStatus reports unknown products and images as 404.
+func productImageStatus(err error) int {
+	if errors.Is(err, service.ErrProductNotFound) || errors.Is(err, service.ErrProductImageNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+func handleListProductImages(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		images, err := api.ListProductImages(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, productImageStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, images)
+	}
+}
+
+func handleAddProductImage(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload service.AddProductImageInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		images, err := api.AddProductImage(r.Context(), chi.URLParam(r, "id"), payload)
+		if err != nil {
+			writeError(w, productImageStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, images)
+	}
+}
+
+func handleUpdateProductImage(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload service.UpdateProductImageInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		images, err := api.UpdateProductImage(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "imageId"), payload)
+		if err != nil {
+			writeError(w, productImageStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, images)
+	}
+}
+
+func handleReorderProductImages(api *app.API) http.HandlerFunc {
+	type request struct {
+		ImageIDs []string `json:"imageIds"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload request
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		images, err := api.ReorderProductImages(r.Context(), chi.URLParam(r, "id"), payload.ImageIDs)
+		if err != nil {
+			writeError(w, productImageStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, images)
+	}
+}
+
+func handleDeleteProductImage(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		images, err := api.DeleteProductImage(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "imageId"))
+		if err != nil {
+			writeError(w, productImageStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, images)
+	}
+}
+
+// withRequestTimeout bounds every request except the event stream, which stays open
+// for as long as the client listens.
+func withRequestTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	limit := middleware.Timeout(timeout)
+	return func(next http.Handler) http.Handler {
+		limited := limit(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == eventsEndpoint {
+				next.ServeHTTP(w, r)
+				return
+			}
+			limited.ServeHTTP(w, r)
+		})
+	}
+}
+
+// handleEvents streams domain events as Server-Sent Events. types optionally limits
+// the stream to a comma separated list of event types; an entry ending in a dot, such
+// as "stock.", matches every type with that prefix. Clients that reconnect with
+// Last-Event-ID receive the recent events they missed.
+func handleEvents(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filters := make([]string, 0)
+		for _, raw := range r.URL.Query()["types"] {
+			for _, part := range strings.Split(raw, ",") {
+				if part = strings.TrimSpace(part); part != "" {
+					filters = append(filters, part)
+				}
+			}
+		}
+		wanted := func(eventType events.Type) bool {
+			if len(filters) == 0 {
+				return true
+			}
+			for _, f := range filters {
+				if string(eventType) == f || (strings.HasSuffix(f, ".") && strings.HasPrefix(string(eventType), f)) {
+					return true
+				}
+			}
+			return false
+		}
+
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			log.Printf("event stream: %v", err)
+		}
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = r.URL.Query().Get("lastEventId")
+		}
+		missed, stream, cancel := api.ListenEvents(lastEventID)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		send := func(event events.Event) error {
+			if !wanted(event.Type) {
+				return nil
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("event stream encode %s: %v", event.Type, err)
+				return nil
+			}
+			if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		if _, err := fmt.Fprint(w, "retry: 3000\n\n"); err != nil {
+			return
+		}
+		for _, event := range missed {
+			if err := send(event); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(25 * time.Second)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event, ok := <-stream:
+				if !ok {
+					// Too far behind; the browser reconnects and replays from its last id.
+					return
+				}
+				if err := send(event); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+				if err := rc.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// withActor attributes changes made by the request to the operator named in the
+// X-SmartSeller-User header.
+func withActor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if actor := r.Header.Get(audit.ActorHeader); actor != "" {
+			r = r.WithContext(audit.WithActor(r.Context(), actor))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func handleLookupProduct(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		product, err := api.LookupProduct(r.Context(), r.URL.Query().Get("code"))
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, service.ErrProductNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, product)
+	}
+}
+
+func handleExportProducts(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		format, err := sheet.ParseFormat(query.Get("format"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		withImages := query.Get("images") == "1" || strings.EqualFold(query.Get("images"), "true")
+		// Exports have always carried archived products, so that stays the default.
+		archived := service.ArchivedInclude
+		if raw := query.Get("archived"); raw != "" {
+			if archived, err = service.ParseArchiveFilter(raw); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		data, err := api.ExportProducts(r.Context(), format, withImages, archived)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		ext, contentType := string(format), format.ContentType()
+		if withImages {
+			ext, contentType = "zip", "application/zip"
+		}
+		filename := fmt.Sprintf("products-%s.%s", time.Now().UTC().Format("20060102-150405"), ext)
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(data); err != nil {
+			log.Printf("write product export response: %v", err)
+		}
+	}
+}
+
+func handleImportProducts(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload service.ProductImportInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		result, err := api.ImportProducts(r.Context(), payload)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func handleProductBarcode(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		data, err := api.ProductBarcodeImage(r.Context(), id, r.URL.Query().Get("type"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(data)
+	}
+}
+
+func handleBarcodeSheet(api *app.API) http.HandlerFunc {
+	type response struct {
+		Base64 string `json:"base64"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload service.BarcodeSheetInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		sheet, err := api.GenerateBarcodeSheet(r.Context(), payload)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, response{Base64: sheet})
+	}
+}
+
+func handleGenerateLabel(api *app.API) http.HandlerFunc {
+	type response struct {
+		Base64 string `json:"base64"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		label, err := api.GenerateLabel(r.Context(), id)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, response{Base64: label})
+	}
+}
+
+func handleGetOrderTracking(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		result, err := api.GetOrderTracking(r.Context(), id)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func handleRefreshOrderTracking(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		result, err := api.RefreshOrderTracking(r.Context(), id)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func handleGetSettings(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		settings, err := api.GetSettings(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, settings)
+	}
+}
+
+func handleUpdateSettings(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload domain.AppSettings
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		updated, err := api.UpdateSettings(r.Context(), payload)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	}
+}
+
+func handleListCouriers(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		page := parsePositiveInt(query.Get("page"), 1)
+		pageSize := parsePositiveInt(query.Get("pageSize"), 20)
+		if pageSize <= 0 {
+			pageSize = 20
+		}
+		search := strings.TrimSpace(query.Get("q"))
+
+		result, err := api.ListCouriers(r.Context(), service.CourierListOptions{
+			Query:    search,
+			Page:     page,
+			PageSize: pageSize,
+		})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func handleCreateCourier(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload domain.Courier
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		payload.ID = ""
+		created, err := api.SaveCourier(r.Context(), payload)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+	}
+}
+
+func handleUpdateCourier(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		var payload domain.Courier
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		payload.ID = id
+		updated, err := api.SaveCourier(r.Context(), payload)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	}
+}
+
+func handleDeleteCourier(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if err := api.DeleteCourier(r.Context(), id); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusNoContent, nil)
+	}
+}
+
+// handleListStockOpnames pages through opname history. It accepts page, pageSize (or
+// the older limit), dateStart and dateEnd (YYYY-MM-DD), performedBy, productId and
+// locationId.
+func handleListStockOpnames(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		opts := service.StockOpnameListOptions{
+			Page:        parsePositiveInt(query.Get("page"), 1),
+			PageSize:    parsePositiveInt(query.Get("pageSize"), parsePositiveInt(query.Get("limit"), 20)),
+			PerformedBy: strings.TrimSpace(query.Get("performedBy")),
+			ProductID:   strings.TrimSpace(query.Get("productId")),
+			LocationID:  strings.TrimSpace(query.Get("locationId")),
+		}
+		if raw := strings.TrimSpace(query.Get("dateStart")); raw != "" {
+			parsed, err := time.Parse("2006-01-02", raw)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("tanggal mulai tidak valid"))
+				return
+			}
+			opts.DateStart = &parsed
+		}
+		if raw := strings.TrimSpace(query.Get("dateEnd")); raw != "" {
+			parsed, err := time.Parse("2006-01-02", raw)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("tanggal akhir tidak valid"))
+				return
+			}
+			opts.DateEnd = &parsed
+		}
+		result, err := api.ListStockOpnames(r.Context(), opts)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, service.ErrLocationNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func handleProductOpnames(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		result, err := api.ListProductOpnames(r.Context(), chi.URLParam(r, "id"), parsePositiveInt(query.Get("page"), 1), parsePositiveInt(query.Get("pageSize"), 20))
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, service.ErrProductNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func handleGetStockOpname(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opname, err := api.GetStockOpname(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, service.ErrStockOpnameNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, opname)
+	}
+}
+
+// handleOpnameVarianceReport serves the valued variance of an opname as PDF (default),
+// csv or json, chosen with ?format=.
+func handleOpnameVarianceReport(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		status := func(err error) int {
+			if errors.Is(err, service.ErrStockOpnameNotFound) {
+				return http.StatusNotFound
+			}
+			return http.StatusInternalServerError
+		}
+		format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+		if format == "json" {
+			report, err := api.OpnameVarianceReport(r.Context(), id)
+			if err != nil {
+				writeError(w, status(err), err)
+				return
+			}
+			writeJSON(w, http.StatusOK, report)
+			return
+		}
+
+		var data []byte
+		var err error
+		contentType := "application/pdf"
+		switch format {
+		case "", "pdf":
+			format = "pdf"
+			data, err = api.OpnameVariancePDF(r.Context(), id)
+		case "csv":
+			contentType = "text/csv; charset=utf-8"
+			data, err = api.OpnameVarianceCSV(r.Context(), id)
+		default:
+			writeError(w, http.StatusBadRequest, errors.New("format harus pdf, csv atau json"))
+			return
+		}
+		if err != nil {
+			writeError(w, status(err), err)
+			return
+		}
+		filename := fmt.Sprintf("opname-%s.%s", id, format)
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(data); err != nil {
+			log.Printf("write opname report response: %v", err)
+		}
+	}
+}
+
+func handlePerformStockOpname(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload service.PerformStockOpnameInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
 			writeError(w, http.StatusBadRequest, err)
 			return
 		}
@@ -842,6 +1924,654 @@ func handlePerformStockOpname(api *app.API) http.HandlerFunc {
 	}
 }
 
+func handleListSuppliers(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		suppliers, err := api.ListSuppliers(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, suppliers)
+	}
+}
+
+func handleCreateSupplier(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload domain.Supplier
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		payload.ID = ""
+		created, err := api.SaveSupplier(r.Context(), payload)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+	}
+}
+
+func handleUpdateSupplier(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload domain.Supplier
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		payload.ID = chi.URLParam(r, "id")
+		updated, err := api.SaveSupplier(r.Context(), payload)
+		if err != nil {
+			writeError(w, purchaseStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	}
+}
+
+func handleDeleteSupplier(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := api.DeleteSupplier(r.Context(), chi.URLParam(r, "id")); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusNoContent, nil)
+	}
+}
+
+func handleSupplierPayables(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		payables, err := api.SupplierPayables(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, payables)
+	}
+}
+
+// purchaseStatus maps unknown suppliers and purchase orders to 404.
+func purchaseStatus(err error) int {
+	if errors.Is(err, service.ErrPurchaseOrderNotFound) || errors.Is(err, service.ErrSupplierNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+func handleListPurchaseOrders(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		orders, err := api.ListPurchaseOrders(r.Context(), service.PurchaseOrderListOptions{
+			Status:     query.Get("status"),
+			SupplierID: query.Get("supplierId"),
+		})
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, orders)
+	}
+}
+
+func handleGetPurchaseOrder(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		po, err := api.GetPurchaseOrder(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, purchaseStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, po)
+	}
+}
+
+func handleCreatePurchaseOrder(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload service.PurchaseOrderInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		po, err := api.CreatePurchaseOrder(r.Context(), payload)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, po)
+	}
+}
+
+func handleUpdatePurchaseOrder(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload service.PurchaseOrderInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		po, err := api.UpdatePurchaseOrder(r.Context(), chi.URLParam(r, "id"), payload)
+		if err != nil {
+			writeError(w, purchaseStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, po)
+	}
+}
+
+func handleDeletePurchaseOrder(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := api.DeletePurchaseOrder(r.Context(), chi.URLParam(r, "id")); err != nil {
+			writeError(w, purchaseStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusNoContent, nil)
+	}
+}
+
+func handlePlacePurchaseOrder(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		po, err := api.PlacePurchaseOrder(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, purchaseStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, po)
+	}
+}
+
+func handleReceivePurchaseOrder(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload service.ReceivePurchaseInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		po, err := api.ReceivePurchaseOrder(r.Context(), chi.URLParam(r, "id"), payload)
+		if err != nil {
+			writeError(w, purchaseStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, po)
+	}
+}
+
+func handleAddPurchasePayment(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload service.PurchasePaymentInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		po, err := api.AddPurchasePayment(r.Context(), chi.URLParam(r, "id"), payload)
+		if err != nil {
+			writeError(w, purchaseStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, po)
+	}
+}
+
+// adjustmentReasonStatus reports unknown reason codes as 404.
+func adjustmentReasonStatus(err error) int {
+	if errors.Is(err, service.ErrAdjustmentReasonNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// handleListAdjustmentReasons lists every reason, or only active ones with active=true.
+func handleListAdjustmentReasons(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reasons, err := api.ListAdjustmentReasons(r.Context(), r.URL.Query().Get("active") == "true")
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, reasons)
+	}
+}
+
+func handleCreateAdjustmentReason(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload service.AdjustmentReasonInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		created, err := api.CreateAdjustmentReason(r.Context(), payload)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+	}
+}
+
+func handleUpdateAdjustmentReason(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload service.AdjustmentReasonInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		payload.Code = chi.URLParam(r, "code")
+		updated, err := api.UpdateAdjustmentReason(r.Context(), payload)
+		if err != nil {
+			writeError(w, adjustmentReasonStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	}
+}
+
+func handleDeleteAdjustmentReason(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := api.DeleteAdjustmentReason(r.Context(), chi.URLParam(r, "code")); err != nil {
+			writeError(w, adjustmentReasonStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusNoContent, nil)
+	}
+}
+
+func handleListLocations(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		locations, err := api.ListLocations(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, locations)
+	}
+}
+
+func handleCreateLocation(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload domain.Location
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		payload.ID = ""
+		created, err := api.SaveLocation(r.Context(), payload)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+	}
+}
+
+func handleUpdateLocation(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload domain.Location
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		payload.ID = chi.URLParam(r, "id")
+		updated, err := api.SaveLocation(r.Context(), payload)
+		if err != nil {
+			writeError(w, locationStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	}
+}
+
+func handleDeleteLocation(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := api.DeleteLocation(r.Context(), chi.URLParam(r, "id")); err != nil {
+			writeError(w, locationStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusNoContent, nil)
+	}
+}
+
+func handleListStockTransfers(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := parsePositiveInt(r.URL.Query().Get("limit"), 50)
+		transfers, err := api.ListStockTransfers(r.Context(), limit)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, transfers)
+	}
+}
+
+func handleTransferStock(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload service.TransferStockInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		transfer, err := api.TransferStock(r.Context(), payload)
+		if err != nil {
+			writeError(w, locationStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, transfer)
+	}
+}
+
+// locationStatus maps unknown locations to 404.
+func locationStatus(err error) int {
+	if errors.Is(err, service.ErrLocationNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// scanSessionStatus reports expired or unknown scan sessions as 404 so scanner
+// clients know to start a new session.
+func scanSessionStatus(err error) int {
+	if errors.Is(err, service.ErrScanSessionNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+func handleStartScanSession(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload service.StartScanSessionInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		session, err := api.StartOpnameScanSession(r.Context(), payload)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, session)
+	}
+}
+
+func handleGetScanSession(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := api.GetOpnameScanSession(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, scanSessionStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, session)
+	}
+}
+
+func handleDiscardScanSession(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := api.DiscardOpnameScanSession(r.Context(), chi.URLParam(r, "id")); err != nil {
+			writeError(w, scanSessionStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusNoContent, nil)
+	}
+}
+
+func handleRecordScan(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload service.ScanInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		session, err := api.RecordOpnameScan(r.Context(), chi.URLParam(r, "id"), payload)
+		if err != nil {
+			writeError(w, scanSessionStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, session)
+	}
+}
+
+func handleSetScanCount(api *app.API) http.HandlerFunc {
+	type request struct {
+		Counted int `json:"counted"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload request
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		session, err := api.SetOpnameScanCount(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "productId"), payload.Counted)
+		if err != nil {
+			writeError(w, scanSessionStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, session)
+	}
+}
+
+func handleRemoveScanItem(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := api.RemoveOpnameScanItem(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "productId"))
+		if err != nil {
+			writeError(w, scanSessionStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, session)
+	}
+}
+
+func handleSubmitScanSession(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opname, err := api.SubmitOpnameScanSession(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, scanSessionStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, opname)
+	}
+}
+
+// opnameSessionStatus maps unknown sessions to 404.
+func opnameSessionStatus(err error) int {
+	if errors.Is(err, service.ErrOpnameSessionNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+func handleListOpnameSessions(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var statuses []domain.OpnameSessionStatus
+		if raw := strings.TrimSpace(r.URL.Query().Get("status")); raw != "" {
+			for _, part := range strings.Split(raw, ",") {
+				if part = strings.TrimSpace(part); part != "" {
+					statuses = append(statuses, domain.OpnameSessionStatus(part))
+				}
+			}
+		}
+		sessions, err := api.ListOpnameSessions(r.Context(), statuses, parsePositiveInt(r.URL.Query().Get("limit"), 20))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, sessions)
+	}
+}
+
+func handleOpenOpnameSession(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload service.OpenOpnameSessionInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		session, err := api.OpenOpnameSession(r.Context(), payload)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, session)
+	}
+}
+
+func handleGetOpnameSession(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := api.GetOpnameSession(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, opnameSessionStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, session)
+	}
+}
+
+func handleRecordOpnameCounts(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload service.RecordOpnameCountsInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		session, err := api.RecordOpnameCounts(r.Context(), chi.URLParam(r, "id"), payload)
+		if err != nil {
+			writeError(w, opnameSessionStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, session)
+	}
+}
+
+func handleRemoveOpnameCount(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := api.RemoveOpnameCount(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "productId"), r.URL.Query().Get("counter"))
+		if err != nil {
+			writeError(w, opnameSessionStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, session)
+	}
+}
+
+func handleReopenOpnameSession(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := api.ReopenOpnameSession(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, opnameSessionStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, session)
+	}
+}
+
+// handleOpnameSessionAction serves the review, approve and cancel steps, which all take
+// the acting user.
+func handleOpnameSessionAction(api *app.API, action func(context.Context, string, service.OpnameSessionActionInput) (*domain.OpnameSession, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload service.OpnameSessionActionInput
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		session, err := action(r.Context(), chi.URLParam(r, "id"), payload)
+		if err != nil {
+			writeError(w, opnameSessionStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, session)
+	}
+}
+
+func handleListWebhooks(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		items, err := api.ListWebhooks(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, items)
+	}
+}
+
+func handleListWebhookEventTypes(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, api.ListWebhookEventTypes())
+	}
+}
+
+// webhookPayload accepts the signing secret, which WebhookSubscription never
+// serialises. It is also the create response, the only time the secret is returned.
+type webhookPayload struct {
+	domain.WebhookSubscription
+	Secret string `json:"secret,omitempty"`
+}
+
+func handleCreateWebhook(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload webhookPayload
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		payload.WebhookSubscription.ID = ""
+		payload.WebhookSubscription.Secret = payload.Secret
+		created, err := api.SaveWebhook(r.Context(), payload.WebhookSubscription)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, webhookPayload{WebhookSubscription: *created, Secret: created.Secret})
+	}
+}
+
+func handleUpdateWebhook(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		var payload webhookPayload
+		if err := decodeJSON(r.Body, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		payload.WebhookSubscription.ID = id
+		payload.WebhookSubscription.Secret = payload.Secret
+		updated, err := api.SaveWebhook(r.Context(), payload.WebhookSubscription)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+	}
+}
+
+func handleDeleteWebhook(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if err := api.DeleteWebhook(r.Context(), id); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusNoContent, nil)
+	}
+}
+
+func handleListWebhookDeliveries(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		limit := parsePositiveInt(r.URL.Query().Get("limit"), 50)
+		items, err := api.ListWebhookDeliveries(r.Context(), id, limit)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, items)
+	}
+}
+
+func handleRedeliverWebhook(api *app.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if err := api.RedeliverWebhook(r.Context(), id); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusNoContent, nil)
+	}
+}
+
 func handleCreateBackup(api *app.API) http.HandlerFunc {
 	type request struct {
 		IncludeSchema *bool `json:"includeSchema"`